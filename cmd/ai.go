@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"cli-top/features"
-	"cli-top/helpers"
-	types "cli-top/types"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -12,6 +10,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/features"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/spf13/cobra"
 )
 
@@ -31,9 +32,9 @@ var aiExportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "Export a consolidated JSON snapshot for AI tooling",
 	Run: func(cmd *cobra.Command, args []string) {
-		data, err := collectAIData()
+		data, err := collectAIData(cmd.Context())
 		if err != nil {
-			fmt.Printf("Failed to build AI dataset: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "Failed to build AI dataset: %v\n", err)
 			return
 		}
 
@@ -44,12 +45,12 @@ var aiExportCmd = &cobra.Command{
 			payload, err = json.MarshalIndent(data, "", "  ")
 		}
 		if err != nil {
-			fmt.Printf("Failed to encode AI dataset: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "Failed to encode AI dataset: %v\n", err)
 			return
 		}
 
 		if aiOutputPath == "-" {
-			fmt.Println(string(payload))
+			fmt.Fprintln(helpers.Messages, string(payload))
 			return
 		}
 
@@ -57,23 +58,23 @@ var aiExportCmd = &cobra.Command{
 		if targetPath == "" {
 			dir, err := helpers.GetOrCreateDownloadDir(filepath.Join("Other Downloads", "AI"))
 			if err != nil {
-				fmt.Printf("Failed to prepare AI export directory: %v\n", err)
+				fmt.Fprintf(helpers.Messages, "Failed to prepare AI export directory: %v\n", err)
 				return
 			}
 			targetPath = filepath.Join(dir, fmt.Sprintf("ai-data-%s.json", time.Now().Format("20060102-150405")))
 		} else {
 			if err := os.MkdirAll(filepath.Dir(targetPath), os.ModePerm); err != nil {
-				fmt.Printf("Failed to create output directory: %v\n", err)
+				fmt.Fprintf(helpers.Messages, "Failed to create output directory: %v\n", err)
 				return
 			}
 		}
 
 		if err := os.WriteFile(targetPath, payload, 0o600); err != nil {
-			fmt.Printf("Failed to write AI dataset: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "Failed to write AI dataset: %v\n", err)
 			return
 		}
 
-		fmt.Printf("AI dataset exported to %s\n", targetPath)
+		fmt.Fprintf(helpers.Messages, "AI dataset exported to %s\n", targetPath)
 	},
 }
 
@@ -89,12 +90,12 @@ var aiGradePredictCmd = &cobra.Command{
 		course, _ := cmd.Flags().GetString("course")
 		fat, _ := cmd.Flags().GetFloat64("fat")
 		if course == "" {
-			fmt.Println("--course is required")
+			fmt.Fprintln(helpers.Messages, "--course is required")
 			return
 		}
 		subArgs := []string{"grade", "predict", "--course", course, "--fat", fmt.Sprintf("%.2f", fat)}
-		if err := executePythonWithDataset(subArgs...); err != nil {
-			fmt.Printf("Prediction failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), subArgs...); err != nil {
+			fmt.Fprintf(helpers.Messages, "Prediction failed: %v\n", err)
 		}
 	},
 }
@@ -106,12 +107,12 @@ var aiGradeTargetCmd = &cobra.Command{
 		course, _ := cmd.Flags().GetString("course")
 		grade, _ := cmd.Flags().GetString("grade")
 		if course == "" || grade == "" {
-			fmt.Println("--course and --grade are required")
+			fmt.Fprintln(helpers.Messages, "--course and --grade are required")
 			return
 		}
 		subArgs := []string{"grade", "target", "--course", course, "--grade", grade}
-		if err := executePythonWithDataset(subArgs...); err != nil {
-			fmt.Printf("Target calculation failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), subArgs...); err != nil {
+			fmt.Fprintf(helpers.Messages, "Target calculation failed: %v\n", err)
 		}
 	},
 }
@@ -122,12 +123,12 @@ var aiGradeCompareCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		course, _ := cmd.Flags().GetString("course")
 		if course == "" {
-			fmt.Println("--course is required")
+			fmt.Fprintln(helpers.Messages, "--course is required")
 			return
 		}
 		subArgs := []string{"grade", "compare", "--course", course}
-		if err := executePythonWithDataset(subArgs...); err != nil {
-			fmt.Printf("Scenario comparison failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), subArgs...); err != nil {
+			fmt.Fprintf(helpers.Messages, "Scenario comparison failed: %v\n", err)
 		}
 	},
 }
@@ -136,8 +137,8 @@ var aiGradeCGPACmd = &cobra.Command{
 	Use:   "cgpa",
 	Short: "Analyse CGPA impact",
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := executePythonWithDataset("grade", "cgpa"); err != nil {
-			fmt.Printf("CGPA analysis failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), "grade", "cgpa"); err != nil {
+			fmt.Fprintf(helpers.Messages, "CGPA analysis failed: %v\n", err)
 		}
 	},
 }
@@ -152,8 +153,8 @@ var aiPlanCmd = &cobra.Command{
 		if courses != "" {
 			subArgs = append(subArgs, "--courses", courses)
 		}
-		if err := executePythonWithDataset(subArgs...); err != nil {
-			fmt.Printf("Study planner failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), subArgs...); err != nil {
+			fmt.Fprintf(helpers.Messages, "Study planner failed: %v\n", err)
 		}
 	},
 }
@@ -171,8 +172,8 @@ var aiAttendanceCmd = &cobra.Command{
 		if course != "" {
 			subArgs = append(subArgs, "--course", course)
 		}
-		if err := executePythonWithDataset(subArgs...); err != nil {
-			fmt.Printf("Attendance advisor failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), subArgs...); err != nil {
+			fmt.Fprintf(helpers.Messages, "Attendance advisor failed: %v\n", err)
 		}
 	},
 }
@@ -190,8 +191,8 @@ var aiTrendCmd = &cobra.Command{
 		if fullReport {
 			subArgs = append(subArgs, "--full-report")
 		}
-		if err := executePythonWithDataset(subArgs...); err != nil {
-			fmt.Printf("Trend analysis failed: %v\n", err)
+		if err := executePythonWithDataset(cmd.Context(), subArgs...); err != nil {
+			fmt.Fprintf(helpers.Messages, "Trend analysis failed: %v\n", err)
 		}
 	},
 }
@@ -208,41 +209,41 @@ var aiRunAllCmd = &cobra.Command{
 
 All features work completely offline with fresh VTOP data.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("🔄 Fetching fresh data from VTOP...")
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages, "🔄 Fetching fresh data from VTOP...")
+		fmt.Fprintln(helpers.Messages)
 
 		// Collect fresh AI data from VTOP
-		data, err := collectAIData()
+		data, err := collectAIData(cmd.Context())
 		if err != nil {
-			fmt.Printf("❌ Failed to fetch VTOP data: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "❌ Failed to fetch VTOP data: %v\n", err)
 			return
 		}
 
-		fmt.Printf("✅ Data fetched successfully\n")
-		fmt.Printf("   Student: %s\n", data.RegNo)
-		fmt.Printf("   Semester: %s\n", data.Semester)
-		fmt.Printf("   CGPA: %.2f\n", data.CGPA)
-		fmt.Printf("   Courses: %d\n", len(data.Marks))
-		fmt.Println()
+		fmt.Fprintf(helpers.Messages, "✅ Data fetched successfully\n")
+		fmt.Fprintf(helpers.Messages, "   Student: %s\n", data.RegNo)
+		fmt.Fprintf(helpers.Messages, "   Semester: %s\n", data.Semester)
+		fmt.Fprintf(helpers.Messages, "   CGPA: %.2f\n", data.CGPA)
+		fmt.Fprintf(helpers.Messages, "   Courses: %d\n", len(data.Marks))
+		fmt.Fprintln(helpers.Messages)
 
 		// Save to temporary file
 		var payload []byte
 		payload, err = json.MarshalIndent(data, "", "  ")
 		if err != nil {
-			fmt.Printf("❌ Failed to encode data: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "❌ Failed to encode data: %v\n", err)
 			return
 		}
 
 		tmpFile, err := os.CreateTemp("", "cli-top-vtop-*.json")
 		if err != nil {
-			fmt.Printf("❌ Failed to create temp file: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "❌ Failed to create temp file: %v\n", err)
 			return
 		}
 		defer os.Remove(tmpFile.Name())
 
 		if _, err := tmpFile.Write(payload); err != nil {
 			tmpFile.Close()
-			fmt.Printf("❌ Failed to write data: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "❌ Failed to write data: %v\n", err)
 			return
 		}
 		tmpFile.Close()
@@ -252,19 +253,19 @@ All features work completely offline with fresh VTOP data.`,
 		scriptPath := filepath.Join(aiDir, "run_all_features.py")
 
 		if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-			fmt.Printf("❌ AI features script not found at: %s\n", scriptPath)
-			fmt.Println("   Please ensure run_all_features.py exists in the ai directory")
+			fmt.Fprintf(helpers.Messages, "❌ AI features script not found at: %s\n", scriptPath)
+			fmt.Fprintln(helpers.Messages, "   Please ensure run_all_features.py exists in the ai directory")
 			return
 		}
 
 		pythonCmd := exec.Command(aiPythonBin, scriptPath, tmpFile.Name())
-		pythonCmd.Stdout = os.Stdout
+		pythonCmd.Stdout = helpers.Messages
 		pythonCmd.Stderr = os.Stderr
 		pythonCmd.Dir = aiDir
 
-		fmt.Println("🚀 Running AI features analysis...\n")
+		fmt.Fprint(helpers.Messages, "🚀 Running AI features analysis...\n\n")
 		if err := pythonCmd.Run(); err != nil {
-			fmt.Printf("\n❌ Analysis failed: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "\n❌ Analysis failed: %v\n", err)
 			return
 		}
 	},
@@ -285,7 +286,7 @@ Requires: Gemini API key configured in ai/.env`,
 		scriptPath := filepath.Join(aiDir, "chatbot.py")
 
 		if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-			fmt.Printf("❌ Chatbot script not found at: %s\n", scriptPath)
+			fmt.Fprintf(helpers.Messages, "❌ Chatbot script not found at: %s\n", scriptPath)
 			return
 		}
 
@@ -298,13 +299,13 @@ Requires: Gemini API key configured in ai/.env`,
 		}
 
 		pythonCmd := exec.Command(aiPythonBin, cmdArgs...)
-		pythonCmd.Stdout = os.Stdout
+		pythonCmd.Stdout = helpers.Messages
 		pythonCmd.Stderr = os.Stderr
 		pythonCmd.Stdin = os.Stdin
 		pythonCmd.Dir = aiDir
 
 		if err := pythonCmd.Run(); err != nil {
-			fmt.Printf("❌ Chatbot failed: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "❌ Chatbot failed: %v\n", err)
 		}
 	},
 }
@@ -314,7 +315,7 @@ var aiCareerCmd = &cobra.Command{
 	Short: "Get AI-powered career guidance",
 	Long:  `Analyze your academic performance and get personalized career recommendations, skill development plans, and industry insights.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		executeGeminiFeature("career_advisor.py")
+		executeGeminiFeature(cmd.Context(), "career_advisor.py")
 	},
 }
 
@@ -325,7 +326,7 @@ var aiStudyPlanCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		days, _ := cmd.Flags().GetInt("days")
 		hours, _ := cmd.Flags().GetInt("hours")
-		executeGeminiFeatureWithArgs("study_optimizer.py", strconv.Itoa(days), strconv.Itoa(hours))
+		executeGeminiFeatureWithArgs(cmd.Context(), "study_optimizer.py", strconv.Itoa(days), strconv.Itoa(hours))
 	},
 }
 
@@ -334,7 +335,7 @@ var aiInsightsCmd = &cobra.Command{
 	Short: "Deep performance analysis",
 	Long:  `Get comprehensive analysis of your academic performance with strengths, weaknesses, and actionable recommendations.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		executeGeminiFeature("performance_insights.py")
+		executeGeminiFeature(cmd.Context(), "performance_insights.py")
 	},
 }
 
@@ -343,7 +344,7 @@ var aiStudyGuideCmd = &cobra.Command{
 	Short: "Interactive study guide generator",
 	Long:  `Generate comprehensive study guides for your courses with chapter breakdowns, resources, and exam strategies.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		executeGeminiFeature("study_guide.py")
+		executeGeminiFeature(cmd.Context(), "study_guide.py")
 	},
 }
 
@@ -367,37 +368,37 @@ Install: pip install SpeechRecognition pyttsx3 pyaudio`,
 		scriptPath := filepath.Join(aiDir, "gemini_features", "voice_assistant.py")
 
 		if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-			fmt.Printf("❌ Voice assistant not found at: %s\n", scriptPath)
+			fmt.Fprintf(helpers.Messages, "❌ Voice assistant not found at: %s\n", scriptPath)
 			return
 		}
 
 		pythonCmd := exec.Command(aiPythonBin, scriptPath)
-		pythonCmd.Stdout = os.Stdout
+		pythonCmd.Stdout = helpers.Messages
 		pythonCmd.Stderr = os.Stderr
 		pythonCmd.Stdin = os.Stdin
 		pythonCmd.Dir = aiDir
 
-		fmt.Println("🎙️  Starting voice assistant...")
+		fmt.Fprintln(helpers.Messages, "🎙️  Starting voice assistant...")
 		if err := pythonCmd.Run(); err != nil {
-			fmt.Printf("❌ Voice assistant failed: %v\n", err)
+			fmt.Fprintf(helpers.Messages, "❌ Voice assistant failed: %v\n", err)
 		}
 	},
 }
 
-func collectAIData() (types.VTOPAIData, error) {
-	cookies, regNo := readCookiesFromFile()
-	data, buildErr := features.BuildAIData(regNo, cookies)
+func collectAIData(ctx context.Context) (types.VTOPAIData, error) {
+	cookies, regNo := readCookiesFromFile(ctx)
+	data, buildErr := features.BuildAIData(ctx, regNo, cookies)
 	if buildErr != nil {
 		if data.RegNo == "" {
 			return data, buildErr
 		}
-		fmt.Printf("AI dataset built with warnings: %v\n", buildErr)
+		fmt.Fprintf(helpers.Messages, "AI dataset built with warnings: %v\n", buildErr)
 	}
 	return data, nil
 }
 
-func prepareDatasetFile(compact bool) (string, func(), error) {
-	data, err := collectAIData()
+func prepareDatasetFile(ctx context.Context, compact bool) (string, func(), error) {
+	data, err := collectAIData(ctx)
 	if err != nil {
 		return "", nil, err
 	}
@@ -454,30 +455,30 @@ func resolveAIFeaturesDir() string {
 	return wd
 }
 
-func executeGeminiFeature(scriptName string) {
-	data, err := collectAIData()
+func executeGeminiFeature(ctx context.Context, scriptName string) {
+	data, err := collectAIData(ctx)
 	if err != nil {
-		fmt.Printf("❌ Failed to fetch VTOP data: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to fetch VTOP data: %v\n", err)
 		return
 	}
 
 	var payload []byte
 	payload, err = json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		fmt.Printf("❌ Failed to encode data: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to encode data: %v\n", err)
 		return
 	}
 
 	tmpFile, err := os.CreateTemp("", "cli-top-vtop-*.json")
 	if err != nil {
-		fmt.Printf("❌ Failed to create temp file: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to create temp file: %v\n", err)
 		return
 	}
 	defer os.Remove(tmpFile.Name())
 
 	if _, err := tmpFile.Write(payload); err != nil {
 		tmpFile.Close()
-		fmt.Printf("❌ Failed to write data: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to write data: %v\n", err)
 		return
 	}
 	tmpFile.Close()
@@ -486,44 +487,44 @@ func executeGeminiFeature(scriptName string) {
 	scriptPath := filepath.Join(aiDir, "gemini_features", scriptName)
 
 	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		fmt.Printf("❌ Feature script not found: %s\n", scriptName)
+		fmt.Fprintf(helpers.Messages, "❌ Feature script not found: %s\n", scriptName)
 		return
 	}
 
-	pythonCmd := exec.Command(aiPythonBin, scriptPath, tmpFile.Name())
-	pythonCmd.Stdout = os.Stdout
+	pythonCmd := exec.CommandContext(ctx, aiPythonBin, scriptPath, tmpFile.Name())
+	pythonCmd.Stdout = helpers.Messages
 	pythonCmd.Stderr = os.Stderr
 	pythonCmd.Dir = aiDir
 
 	if err := pythonCmd.Run(); err != nil {
-		fmt.Printf("❌ Feature failed: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Feature failed: %v\n", err)
 	}
 }
 
-func executeGeminiFeatureWithArgs(scriptName string, extraArgs ...string) {
-	data, err := collectAIData()
+func executeGeminiFeatureWithArgs(ctx context.Context, scriptName string, extraArgs ...string) {
+	data, err := collectAIData(ctx)
 	if err != nil {
-		fmt.Printf("❌ Failed to fetch VTOP data: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to fetch VTOP data: %v\n", err)
 		return
 	}
 
 	var payload []byte
 	payload, err = json.MarshalIndent(data, "", "  ")
 	if err != nil {
-		fmt.Printf("❌ Failed to encode data: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to encode data: %v\n", err)
 		return
 	}
 
 	tmpFile, err := os.CreateTemp("", "cli-top-vtop-*.json")
 	if err != nil {
-		fmt.Printf("❌ Failed to create temp file: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to create temp file: %v\n", err)
 		return
 	}
 	defer os.Remove(tmpFile.Name())
 
 	if _, err := tmpFile.Write(payload); err != nil {
 		tmpFile.Close()
-		fmt.Printf("❌ Failed to write data: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Failed to write data: %v\n", err)
 		return
 	}
 	tmpFile.Close()
@@ -532,27 +533,27 @@ func executeGeminiFeatureWithArgs(scriptName string, extraArgs ...string) {
 	scriptPath := filepath.Join(aiDir, "gemini_features", scriptName)
 
 	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
-		fmt.Printf("❌ Feature script not found: %s\n", scriptName)
+		fmt.Fprintf(helpers.Messages, "❌ Feature script not found: %s\n", scriptName)
 		return
 	}
 
 	cmdArgs := append([]string{scriptPath, tmpFile.Name()}, extraArgs...)
-	pythonCmd := exec.Command(aiPythonBin, cmdArgs...)
-	pythonCmd.Stdout = os.Stdout
+	pythonCmd := exec.CommandContext(ctx, aiPythonBin, cmdArgs...)
+	pythonCmd.Stdout = helpers.Messages
 	pythonCmd.Stderr = os.Stderr
 	pythonCmd.Dir = aiDir
 
 	if err := pythonCmd.Run(); err != nil {
-		fmt.Printf("❌ Feature failed: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "❌ Feature failed: %v\n", err)
 	}
 }
 
-func executePythonWithDataset(subArgs ...string) error {
+func executePythonWithDataset(ctx context.Context, subArgs ...string) error {
 	if err := ensurePythonBinary(); err != nil {
 		return err
 	}
 
-	datasetPath, cleanup, err := prepareDatasetFile(true)
+	datasetPath, cleanup, err := prepareDatasetFile(ctx, true)
 	if err != nil {
 		return err
 	}
@@ -561,8 +562,8 @@ func executePythonWithDataset(subArgs ...string) error {
 	}
 
 	args := append([]string{"-m", "ai_features.main", "--dataset", datasetPath}, subArgs...)
-	cmd := exec.Command(aiPythonBin, args...)
-	cmd.Stdout = os.Stdout
+	cmd := exec.CommandContext(ctx, aiPythonBin, args...)
+	cmd.Stdout = helpers.Messages
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	if dir := resolveAIFeaturesDir(); dir != "" {
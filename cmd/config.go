@@ -0,0 +1,465 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/store"
+	"github.com/lpernett/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultProfile = "default"
+const activeProfileFile = "active-profile"
+const legacyConfigFile = "cli-top-config.env"
+const legacyVaultFile = "cli-top-vault.json"
+
+var profileFlag string
+var configFlag string
+var caFileFlag string
+var insecureFlag bool
+var proxyFlag string
+var dnsFlag string
+var retriesFlag int
+var retryDelayFlag time.Duration
+var recordFlag string
+var rateLimitFlag float64
+var rateBurstFlag int
+var outputFlag string
+var replayFlag string
+var offlineFlag bool
+var maxAgeFlag time.Duration
+
+var sessionMu sync.Mutex
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// sessionKeys are kept in the state directory rather than the config file.
+// JSESSIONID and SERVERID only appear in files written by older versions and
+// are moved into the cookie jar on first use.
+var sessionKeys = []string{"CSRF", "JSESSIONID", "SERVERID", "REGNO"}
+
+// activeProfile resolves the profile for this invocation from the --profile
+// flag, then CLI_TOP_PROFILE, then the profile chosen with "profiles use".
+func activeProfile() string {
+	if profileFlag != "" {
+		return profileFlag
+	}
+	if profile := os.Getenv("CLI_TOP_PROFILE"); profile != "" {
+		return profile
+	}
+	if data, err := os.ReadFile(filepath.Join(helpers.ConfigDir(), activeProfileFile)); err == nil {
+		if profile := strings.TrimSpace(string(data)); profile != "" {
+			return profile
+		}
+	}
+	return defaultProfile
+}
+
+// profileSubDir places named profiles under profiles/<name> of a base
+// directory, while the default profile uses the base directory itself.
+func profileSubDir(base string, profile string) string {
+	if profile == defaultProfile {
+		return base
+	}
+	return filepath.Join(base, "profiles", profile)
+}
+
+func profileConfigDir(profile string) string {
+	return profileSubDir(helpers.ConfigDir(), profile)
+}
+
+func profileStateDir(profile string) string {
+	return profileSubDir(helpers.StateDir(), profile)
+}
+
+func profileDataDir(profile string) string {
+	return profileSubDir(helpers.DataDir(), profile)
+}
+
+// configPath returns the config file for this invocation: --config, then
+// CLI_TOP_CONFIG, then the active profile's file in the config directory.
+func configPath() string {
+	if configFlag != "" {
+		return configFlag
+	}
+	if path := os.Getenv("CLI_TOP_CONFIG"); path != "" {
+		return path
+	}
+	return filepath.Join(profileConfigDir(activeProfile()), "config.env")
+}
+
+func sessionPath() string {
+	return filepath.Join(profileStateDir(activeProfile()), "session.env")
+}
+
+func cookiesPath() string {
+	return filepath.Join(profileStateDir(activeProfile()), "cookies.json")
+}
+
+func vaultPath() string {
+	return filepath.Join(profileDataDir(activeProfile()), "vault.json")
+}
+
+// credentialAccount namespaces the credential store entry by profile so two
+// profiles never share a stored password.
+func credentialAccount(username string) string {
+	profile := activeProfile()
+	if profile == defaultProfile {
+		return username
+	}
+	return profile + "/" + username
+}
+
+func listProfiles() []string {
+	profiles := []string{defaultProfile}
+	entries, err := os.ReadDir(filepath.Join(helpers.ConfigDir(), "profiles"))
+	if err != nil {
+		return profiles
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != defaultProfile && profileNamePattern.MatchString(entry.Name()) {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	sort.Strings(profiles[1:])
+	return profiles
+}
+
+func profileExists(profile string) bool {
+	if profile == defaultProfile {
+		return true
+	}
+	info, err := os.Stat(profileConfigDir(profile))
+	return err == nil && info.IsDir()
+}
+
+// readSession returns the cookies and registration number saved by the last login.
+func readSession() map[string]string {
+	session, err := godotenv.Read(sessionPath())
+	if err != nil {
+		return map[string]string{}
+	}
+	return session
+}
+
+// updateSession merges values into the session file of the active profile.
+// An empty value removes the key.
+func updateSession(values map[string]string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	session := readSession()
+	for key, value := range values {
+		if value == "" {
+			delete(session, key)
+			continue
+		}
+		session[key] = value
+	}
+	if err := os.MkdirAll(filepath.Dir(sessionPath()), 0o700); err != nil {
+		return err
+	}
+	if err := godotenv.Write(session, sessionPath()); err != nil {
+		return err
+	}
+	return os.Chmod(sessionPath(), 0o600)
+}
+
+// migrateLegacyConfig moves a cli-top-config.env (and vault) left in the
+// working directory by older versions into the XDG directories, splitting
+// the session cookies out into the state directory.
+func migrateLegacyConfig() {
+	if configFlag != "" || os.Getenv("CLI_TOP_CONFIG") != "" || activeProfile() != defaultProfile {
+		return
+	}
+	if _, err := os.Stat(legacyConfigFile); err != nil {
+		return
+	}
+	if _, err := os.Stat(configPath()); err == nil {
+		if debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Ignoring", legacyConfigFile, "in the current directory, already migrated to", configPath())
+		}
+		return
+	}
+
+	legacy, err := godotenv.Read(legacyConfigFile)
+	if err != nil {
+		if debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error reading legacy config:", err)
+		}
+		return
+	}
+
+	config := map[string]string{}
+	session := map[string]string{}
+	for key, value := range legacy {
+		isSession := false
+		for _, sessionKey := range sessionKeys {
+			if key == sessionKey {
+				isSession = true
+				break
+			}
+		}
+		if isSession {
+			session[key] = value
+		} else {
+			config[key] = value
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath()), 0o700); err != nil {
+		fmt.Fprintln(helpers.Messages, "Error creating config directory:", err)
+		return
+	}
+	if err := godotenv.Write(config, configPath()); err != nil {
+		fmt.Fprintln(helpers.Messages, "Error migrating config:", err)
+		return
+	}
+	os.Chmod(configPath(), 0o600)
+	if err := updateSession(session); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error migrating session:", err)
+	}
+
+	if _, err := os.Stat(legacyVaultFile); err == nil {
+		if err := moveFile(legacyVaultFile, vaultPath()); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error migrating vault:", err)
+			return
+		}
+	}
+
+	if err := os.Remove(legacyConfigFile); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error removing legacy config:", err)
+	}
+	fmt.Fprintln(helpers.Messages, "Moved", legacyConfigFile, "to", configPath())
+}
+
+func moveFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	// Rename fails across filesystems, fall back to copy and delete.
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+func init() {
+	cobra.OnInitialize(initConfig)
+}
+
+// initConfig loads the active profile's config once flags have been parsed.
+func initConfig() {
+	if debugFlag {
+		debug.Debug = true
+	}
+	configureOutput()
+
+	if profile := activeProfile(); !profileNamePattern.MatchString(profile) {
+		fmt.Fprintf(helpers.Messages, "Invalid profile name %q, using the default profile.\n", profile)
+		profileFlag = defaultProfile
+	} else if !profileExists(profile) {
+		fmt.Fprintf(helpers.Messages, "Profile %q does not exist. Create it with \"cli-top profiles add %s\".\n", profile, profile)
+		os.Exit(1)
+	}
+
+	migrateLegacyConfig()
+
+	if err := os.MkdirAll(filepath.Dir(configPath()), 0o700); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error creating config directory:", err)
+	}
+
+	viper.SetConfigType("env")
+	viper.SetConfigFile(configPath())
+	viper.ReadInConfig()
+
+	err := godotenv.Load(configPath())
+	if err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error loading .env file:", err)
+	}
+	godotenv.Load(sessionPath())
+	if err := helpers.CookieJar().Load(cookiesPath()); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error loading cookies:", err)
+	}
+	configureTLS()
+	configureNetwork()
+	configureFixtures()
+	configureRateLimit()
+	configureStore()
+	if retriesFlag >= 0 {
+		helpers.Retry.Attempts = retriesFlag + 1
+	}
+	if retryDelayFlag > 0 {
+		helpers.Retry.BaseDelay = retryDelayFlag
+	}
+
+	userUUID := getOrCreateUUID()
+	if debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Profile:", activeProfile())
+		fmt.Fprintln(helpers.Messages, "Config:", configPath())
+		fmt.Fprintln(helpers.Messages, "Session:", sessionPath())
+		fmt.Fprintln(helpers.Messages, "Cookies:", cookiesPath())
+		fmt.Fprintln(helpers.Messages, "User UUID:", userUUID)
+	}
+
+	if !updateFlag && replayFlag == "" && os.Args[len(os.Args)-1] != "-u" && os.Args[len(os.Args)-1] != "--update" {
+		shouldNotify, latestVersion := helpers.ShouldShowUpdateNotification()
+		if shouldNotify {
+			helpers.ShowUpdateNotification(latestVersion)
+		}
+	}
+}
+
+// configureTLS sets how VTOP's certificate is checked: --ca-file (or CA_FILE)
+// adds a trusted bundle, TLS_PINS restricts the accepted keys, and
+// --insecure turns verification off after a warning.
+func configureTLS() {
+	opts := helpers.TLSOptions{Insecure: insecureFlag}
+	caFile := caFileFlag
+	if caFile == "" {
+		caFile = configValue("CA_FILE")
+	}
+	if caFile != "" {
+		opts.CAFiles = []string{caFile}
+	}
+	for _, pin := range strings.Split(configValue("TLS_PINS"), ",") {
+		if pin = strings.TrimSpace(pin); pin != "" {
+			opts.Pins = append(opts.Pins, pin)
+		}
+	}
+
+	if opts.Insecure {
+		fmt.Fprintln(os.Stderr, "Warning: --insecure disables TLS certificate verification. Your VTOP password and session can be read by anyone able to intercept this connection.")
+	}
+	if err := helpers.ConfigureTLS(opts); err != nil {
+		fmt.Fprintln(helpers.Messages, "Error configuring TLS:", err)
+		os.Exit(1)
+	}
+}
+
+// configureNetwork routes requests through --proxy (or PROXY) and resolves
+// names with --dns (or DNS_SERVER). Both can differ per profile.
+func configureNetwork() {
+	opts := helpers.NetworkOptions{Proxy: proxyFlag, DNSServer: dnsFlag}
+	if opts.Proxy == "" {
+		opts.Proxy = configValue("PROXY")
+	}
+	if opts.DNSServer == "" {
+		opts.DNSServer = configValue("DNS_SERVER")
+	}
+	if err := helpers.ConfigureNetwork(opts); err != nil {
+		fmt.Fprintln(helpers.Messages, "Error configuring network:", err)
+		os.Exit(1)
+	}
+	if debug.Debug {
+		if proxy := helpers.ProxyURL(helpers.VtopURL.String()); proxy != nil {
+			fmt.Fprintln(helpers.Messages, "Proxy:", proxy.Redacted())
+		}
+		if opts.DNSServer != "" {
+			fmt.Fprintln(helpers.Messages, "DNS server:", opts.DNSServer)
+		}
+	}
+}
+
+// configureOutput applies --output before anything is printed, so that
+// under a machine-readable format every message already goes to stderr.
+func configureOutput() {
+	format, err := helpers.ParseOutputFormat(outputFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	helpers.SetOutput(format)
+}
+
+// configureRateLimit sets the request rate from --rate-limit and
+// --rate-burst, or RATE_LIMIT and RATE_BURST. The bucket is shared by every
+// profile, since they all talk to the same VTOP.
+func configureRateLimit() {
+	helpers.Limiter.Path = filepath.Join(helpers.StateDir(), "ratelimit.json")
+	if rootCmd.PersistentFlags().Changed("rate-limit") {
+		helpers.Limiter.Rate = rateLimitFlag
+	} else if rate, err := strconv.ParseFloat(configValue("RATE_LIMIT"), 64); err == nil {
+		helpers.Limiter.Rate = rate
+	}
+	if rootCmd.PersistentFlags().Changed("rate-burst") {
+		helpers.Limiter.Burst = rateBurstFlag
+	} else if burst, err := strconv.Atoi(configValue("RATE_BURST")); err == nil {
+		helpers.Limiter.Burst = burst
+	}
+	if helpers.Limiter.Burst < 1 {
+		helpers.Limiter.Burst = 1
+	}
+	debug.Log(fmt.Sprintf("Rate limit: %g requests/s, burst %d", helpers.Limiter.Rate, helpers.Limiter.Burst))
+}
+
+// configureStore saves every dataset fetched to the profile's data
+// directory, and serves from it under --offline or when it is younger than
+// --max-age (or MAX_AGE). Replays are not saved.
+func configureStore() {
+	store.Default.Path = filepath.Join(profileDataDir(activeProfile()), "vtop.db")
+	if helpers.Replaying() {
+		store.Default.Path = ""
+	}
+	store.Default.Offline = offlineFlag
+	if rootCmd.PersistentFlags().Changed("max-age") {
+		store.Default.MaxAge = maxAgeFlag
+	} else if maxAge, err := time.ParseDuration(configValue("MAX_AGE")); err == nil {
+		store.Default.MaxAge = maxAge
+	}
+	if offlineFlag && recordFlag != "" {
+		fmt.Fprintln(helpers.Messages, "Error: --offline and --record cannot be used together")
+		os.Exit(1)
+	}
+}
+
+// configureFixtures saves VTOP traffic to --record or serves it from
+// --replay, so a failing command can be reproduced without an account.
+func configureFixtures() {
+	var err error
+	switch {
+	case recordFlag != "" && replayFlag != "":
+		err = fmt.Errorf("--record and --replay cannot be used together")
+	case recordFlag != "":
+		err = helpers.RecordFixtures(recordFlag)
+		if err == nil {
+			fmt.Fprintln(os.Stderr, "Recording VTOP traffic to", recordFlag+". Passwords and cookies are redacted, but pages still contain your personal details; review them before sharing.")
+		}
+	case replayFlag != "":
+		err = helpers.ReplayFixtures(replayFlag)
+	}
+	if err != nil {
+		fmt.Fprintln(helpers.Messages, "Error:", err)
+		os.Exit(1)
+	}
+}
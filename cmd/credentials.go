@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/secrets"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+var migrateTargetFlag string
+
+// configValue reads a key from the loaded config, dropping the quotes the
+// env writer wraps around every value.
+func configValue(key string) string {
+	value := strings.Trim(viper.GetString(key), "\"")
+	if value == "" {
+		value = os.Getenv(key)
+	}
+	return value
+}
+
+func storeOptions() secrets.Options {
+	return secrets.Options{
+		ConfigPath: configPath(),
+		VaultPath:  vaultPath(),
+		Passphrase: readPassphrase,
+	}
+}
+
+// configuredBackend returns the backend recorded in the config. Configs
+// written before SECRET_STORE existed keep using the env file.
+func configuredBackend() string {
+	backend := configValue("SECRET_STORE")
+	if backend == "" && configValue("PASSWORD") != "" {
+		backend = secrets.BackendEnv
+	}
+	return backend
+}
+
+func credentialStore() (secrets.Store, error) {
+	return secrets.Open(configuredBackend(), storeOptions())
+}
+
+func readPassphrase(confirm bool) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("vault passphrase required, set CLI_TOP_VAULT_PASSPHRASE when running without a terminal")
+	}
+
+	passphrase, err := promptSecret("Enter vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if confirm {
+		again, err := promptSecret("Confirm vault passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Manage where your VTOP password is stored",
+}
+
+var credentialsStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the active credential store",
+	Run: func(cmd *cobra.Command, args []string) {
+		store, err := credentialStore()
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error opening credential store:", err)
+			return
+		}
+		fmt.Fprintln(helpers.Messages, "Credential store:", store.Name())
+		if !secrets.KeyringAvailable() {
+			fmt.Fprintln(helpers.Messages, "System keyring: unavailable (install libsecret's secret-tool and run inside a desktop session)")
+		}
+	},
+}
+
+var credentialsMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move stored credentials into another backend (keyring, vault or env)",
+	Run: func(cmd *cobra.Command, args []string) {
+		username := configValue("VTOP_USERNAME")
+		if username == "" {
+			fmt.Fprintln(helpers.Messages, "No saved login found. Please login using the \"login\" command")
+			return
+		}
+
+		from, err := credentialStore()
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error opening current credential store:", err)
+			return
+		}
+		to, err := secrets.Open(migrateTargetFlag, storeOptions())
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error opening target credential store:", err)
+			return
+		}
+
+		if err := secrets.Migrate(from, to, credentialAccount(username)); err != nil {
+			fmt.Fprintln(helpers.Messages, "Migration failed:", err)
+			return
+		}
+
+		viper.Set("SECRET_STORE", "\""+to.Name()+"\"")
+		if err := viper.WriteConfigAs(configPath()); err != nil && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error writing to .env file:", err)
+		}
+
+		fmt.Fprintf(helpers.Messages, "Credentials moved from %s to %s.\n", from.Name(), to.Name())
+	},
+}
+
+func init() {
+	credentialsMigrateCmd.Flags().StringVar(&migrateTargetFlag, "to", secrets.BackendKeyring, "Target backend: "+strings.Join(secrets.Backends(), ", "))
+	credentialsCmd.AddCommand(credentialsStatusCmd, credentialsMigrateCmd)
+	rootCmd.AddCommand(credentialsCmd)
+}
@@ -1,60 +1,170 @@
 package cmd
 
 import (
-	"cli-top/debug"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 // var username = "k"
 // var password = "k"
 // var regno = "k"
 
+var storeFlag string
+
 var credCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login to VTOP",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("NOTE: Your password will be visible.")
-		username := promptInput("Enter your username: ")
-		password := promptInput("Enter your password: ")
-		key := GenerateAESKey()
-
-		encryptedPassword, err := encryptPassword(password, key)
-		if err != nil && debug.Debug {
-			fmt.Println("Error encrypting password:", err)
+		backend := storeFlag
+		if backend == "" {
+			backend = configValue("SECRET_STORE")
+		}
+		store, err := secrets.Open(backend, storeOptions())
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error opening credential store:", err)
+			return
+		}
+
+		// The password takes all of stdin, leaving nothing to answer the vault
+		// passphrase prompt.
+		if passwordStdin, _ := cmd.Flags().GetBool("password-stdin"); passwordStdin &&
+			store.Name() == secrets.BackendVault && os.Getenv("CLI_TOP_VAULT_PASSPHRASE") == "" {
+			fmt.Fprintln(helpers.Messages, "--password-stdin with the vault store needs CLI_TOP_VAULT_PASSPHRASE set, since stdin holds the password")
+			return
+		}
+
+		username, password, err := resolveLoginInput(cmd)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, err)
 			return
 		}
+		username = strings.ToUpper(username)
 
-		fmt.Printf("Logging in with username: %s\n", strings.ToUpper(username))
-		viper.Set("VTOP_USERNAME", "\""+strings.ToUpper(username)+"\"")
-		viper.Set("PASSWORD", "\""+encryptedPassword+"\"")
-		viper.Set("KEY", "\""+key+"\"")
+		if backend == "" && store.Name() == secrets.BackendVault {
+			fmt.Fprintln(helpers.Messages, "No system keyring found; the password will be kept in the encrypted vault (use --store env for the old config file).")
+		}
+		previous, previousErr := credentialStore()
+
+		if err := store.Set(credentialAccount(username), password); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error storing password:", err)
+			return
+		}
+
+		// Only once the new store holds the password, drop whatever the
+		// previous backend held so a plaintext KEY is not left behind.
+		if previousErr == nil && previous.Name() != store.Name() {
+			if err := previous.Delete(credentialAccount(configValue("VTOP_USERNAME"))); err != nil && debug.Debug {
+				fmt.Fprintln(helpers.Messages, "Error clearing previous credential store:", err)
+			}
+		}
+
+		fmt.Fprintf(helpers.Messages, "Logging in with username: %s\n", username)
+		viper.Set("VTOP_USERNAME", "\""+username+"\"")
+		viper.Set("SECRET_STORE", "\""+store.Name()+"\"")
+		if regNo, _ := cmd.Flags().GetString("regno"); regNo != "" {
+			if err := updateSession(map[string]string{"REGNO": strings.ToUpper(regNo)}); err != nil && debug.Debug {
+				fmt.Fprintln(helpers.Messages, "Error writing session file:", err)
+			}
+		}
 
-		if err := viper.WriteConfigAs("cli-top-config.env"); err != nil && debug.Debug {
-			fmt.Println("Error writing to .env file:", err)
+		if err := viper.WriteConfigAs(configPath()); err != nil && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error writing to .env file:", err)
 			return
 		}
 
-		fmt.Println("Username and encrypted password stored in .env file successfully.")
+		fmt.Fprintf(helpers.Messages, "Username saved and password stored in the %s credential store.\n", store.Name())
 	},
 }
 
 func promptInput(prompt string) string {
-	fmt.Print(prompt)
+	fmt.Fprint(helpers.Messages, prompt)
 	var input string
 	fmt.Scanln(&input)
 	return input
 }
 
+// promptSecret reads a value from the terminal without echoing it.
+func promptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New("no terminal available to prompt for input")
+	}
+
+	fmt.Fprint(helpers.Messages, prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(helpers.Messages)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// resolveLoginInput gathers the username and password from, in order of
+// precedence, command flags, stdin, the environment and an interactive prompt.
+func resolveLoginInput(cmd *cobra.Command) (string, string, error) {
+	username, _ := cmd.Flags().GetString("username")
+	password, _ := cmd.Flags().GetString("password")
+	passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+
+	if password != "" && passwordStdin {
+		return "", "", errors.New("--password and --password-stdin cannot be used together")
+	}
+
+	if passwordStdin {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", "", fmt.Errorf("error reading password from stdin: %w", err)
+		}
+		password = strings.TrimRight(string(input), "\r\n")
+	}
+
+	if username == "" {
+		username = os.Getenv("CLI_TOP_USERNAME")
+	}
+	if password == "" {
+		password = os.Getenv("CLI_TOP_PASSWORD")
+	}
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	if username == "" {
+		if !interactive {
+			return "", "", errors.New("username required: pass --username or set CLI_TOP_USERNAME")
+		}
+		username = promptInput("Enter your username: ")
+	}
+	if password == "" {
+		if !interactive {
+			return "", "", errors.New("password required: pass --password-stdin or set CLI_TOP_PASSWORD")
+		}
+		var err error
+		password, err = promptSecret("Enter your password: ")
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if strings.TrimSpace(username) == "" || password == "" {
+		return "", "", errors.New("username and password cannot be empty")
+	}
+
+	return strings.TrimSpace(username), password, nil
+}
+
 func init() {
 	credCmd.Flags().String("username", "", "Enter VTOP username")
-	credCmd.Flags().String("password", "", "Enter VTOP password")
+	credCmd.Flags().String("password", "", "Enter VTOP password (visible in process lists, prefer --password-stdin)")
+	credCmd.Flags().Bool("password-stdin", false, "Read the VTOP password from stdin")
 	credCmd.Flags().String("regno", "", "Enter VIT registration number")
-	viper.SetConfigType("env")
-	viper.SetConfigFile("cli-top-config.env")
-	viper.ReadInConfig()
+	credCmd.Flags().StringVar(&storeFlag, "store", "", "Credential store: "+strings.Join(secrets.Backends(), ", ")+" (default: keyring when available, else vault)")
 	rootCmd.AddCommand(credCmd)
 }
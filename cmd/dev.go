@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/login"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	collectDirFlag  string
+	collectOffFlag  bool
+	retrainOutFlag  string
+	retrainEpochs   int
+	retrainRate     float64
+	retrainSeed     int64
+	retrainHoldout  float64
+	retrainFromZero bool
+)
+
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Tools for cli-top developers",
+}
+
+var devCaptchaCmd = &cobra.Command{
+	Use:   "captcha",
+	Short: "Collect samples, evaluate and retrain the built-in captcha model",
+}
+
+func defaultCaptchaSampleDir() string {
+	return filepath.Join(helpers.DataDir(), "captchas")
+}
+
+// captchaCollectDir is where accepted captchas are saved, or "" when
+// collection is off.
+func captchaCollectDir() string {
+	return configValue("CAPTCHA_COLLECT_DIR")
+}
+
+// collectCaptchaSamples registers the login hook that saves every captcha
+// VTOP accepts while collection is on.
+func collectCaptchaSamples() {
+	dir := captchaCollectDir()
+	if dir == "" {
+		login.OnCaptchaAccepted = nil
+		return
+	}
+	login.OnCaptchaAccepted = func(captchaImage string, answer string) {
+		data, err := helpers.DecodeCaptchaImage(captchaImage)
+		if err != nil {
+			debug.Log(fmt.Sprintf("Not saving captcha sample: %v", err))
+			return
+		}
+		path, err := helpers.SaveCaptchaSample(dir, data, answer)
+		if err != nil {
+			debug.Log(fmt.Sprintf("Not saving captcha sample: %v", err))
+			return
+		}
+		debug.Log("Saved captcha sample " + path)
+	}
+}
+
+var devCaptchaCollectCmd = &cobra.Command{
+	Use:   "collect",
+	Short: "Save each captcha accepted at login, named after its answer",
+	Run: func(cmd *cobra.Command, args []string) {
+		if collectOffFlag {
+			viper.Set("CAPTCHA_COLLECT_DIR", "")
+		} else {
+			dir, err := filepath.Abs(collectDirFlag)
+			if err != nil {
+				fmt.Fprintln(helpers.Messages, "Error resolving sample directory:", err)
+				return
+			}
+			viper.Set("CAPTCHA_COLLECT_DIR", "\""+dir+"\"")
+		}
+		if err := viper.WriteConfigAs(configPath()); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error writing to .env file:", err)
+			return
+		}
+
+		if collectOffFlag {
+			fmt.Fprintln(helpers.Messages, "Captcha collection disabled.")
+			return
+		}
+		fmt.Fprintln(helpers.Messages, "Captchas accepted at login will be saved to", captchaCollectDir())
+		if samples, _, err := helpers.LoadCaptchaSamples(captchaCollectDir()); err == nil {
+			fmt.Fprintf(helpers.Messages, "%d samples collected so far.\n", len(samples))
+		}
+	},
+}
+
+func loadSamplesOrExit(dir string) []helpers.CaptchaSample {
+	samples, skipped, err := helpers.LoadCaptchaSamples(dir)
+	if err != nil {
+		fmt.Fprintln(helpers.Messages, "Error reading samples:", err)
+		os.Exit(1)
+	}
+	for _, path := range skipped {
+		debug.Log("Skipping " + path + ": name is not a captcha answer or image is not 200x40")
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(helpers.Messages, "Skipped %d files that are not labelled 200x40 JPEG captchas.\n", len(skipped))
+	}
+	if len(samples) == 0 {
+		fmt.Fprintln(helpers.Messages, "No labelled captchas found in", dir)
+		os.Exit(1)
+	}
+	return samples
+}
+
+func printCaptchaEvaluation(eval helpers.CaptchaEvaluation) {
+	fmt.Fprintf(helpers.Messages, "Captchas solved: %d/%d (%.1f%%)\n", eval.Solved, eval.Samples, 100*float64(eval.Solved)/float64(eval.Samples))
+	fmt.Fprintf(helpers.Messages, "Characters correct: %.1f%%\n", 100*eval.Accuracy())
+
+	var chars []byte
+	for c := range eval.Chars {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	table := [][]string{{"Char", "Seen", "Correct", "Accuracy"}}
+	for _, c := range chars {
+		acc := eval.Chars[c]
+		table = append(table, []string{string(c), fmt.Sprint(acc.Total), fmt.Sprint(acc.Correct), fmt.Sprintf("%.1f%%", 100*float64(acc.Correct)/float64(acc.Total))})
+	}
+	helpers.PrintTable(table, 0)
+
+	confusions := eval.TopConfusions(10)
+	if len(confusions) == 0 {
+		return
+	}
+	fmt.Fprintln(helpers.Messages, "\nMost common mistakes:")
+	for _, c := range confusions {
+		fmt.Fprintf(helpers.Messages, "  %c read as %c: %d\n", c.Expected, c.Predicted, c.Count)
+	}
+}
+
+var devCaptchaEvaluateCmd = &cobra.Command{
+	Use:   "evaluate [dir]",
+	Short: "Measure the built-in model on a directory of labelled captchas",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := defaultCaptchaSampleDir()
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		samples := loadSamplesOrExit(dir)
+		printCaptchaEvaluation(helpers.EvaluateCaptchaModel(helpers.BuiltinCaptchaModel(), samples))
+	},
+}
+
+var devCaptchaRetrainCmd = &cobra.Command{
+	Use:   "retrain [dir]",
+	Short: "Retrain the built-in model on labelled captchas and write a new weights file",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := defaultCaptchaSampleDir()
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		samples := loadSamplesOrExit(dir)
+		if retrainHoldout < 0 || retrainHoldout >= 1 {
+			fmt.Fprintln(helpers.Messages, "--holdout must be between 0 and 1")
+			return
+		}
+
+		rand.New(rand.NewSource(retrainSeed)).Shuffle(len(samples), func(i, j int) { samples[i], samples[j] = samples[j], samples[i] })
+		split := len(samples) - int(float64(len(samples))*retrainHoldout)
+		train, holdout := samples[:split], samples[split:]
+		fmt.Fprintf(helpers.Messages, "Training on %d captchas, holding out %d.\n", len(train), len(holdout))
+
+		opts := helpers.CaptchaTrainOptions{
+			Epochs:       retrainEpochs,
+			LearningRate: retrainRate,
+			Seed:         retrainSeed,
+			Progress: func(epoch int, loss float64) {
+				fmt.Fprintf(helpers.Messages, "\rEpoch %d/%d, loss %.4f", epoch, retrainEpochs, loss)
+			},
+		}
+		if !retrainFromZero {
+			builtin := helpers.BuiltinCaptchaModel()
+			opts.Start = &builtin
+		}
+		model := helpers.TrainCaptchaModel(train, opts)
+		fmt.Fprintln(helpers.Messages)
+
+		if len(holdout) > 0 {
+			fmt.Fprintln(helpers.Messages, "\nBuilt-in model on the held-out captchas:")
+			printCaptchaEvaluation(helpers.EvaluateCaptchaModel(helpers.BuiltinCaptchaModel(), holdout))
+			fmt.Fprintln(helpers.Messages, "\nRetrained model on the held-out captchas:")
+			printCaptchaEvaluation(helpers.EvaluateCaptchaModel(model, holdout))
+		}
+
+		out, err := os.Create(retrainOutFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error creating weights file:", err)
+			return
+		}
+		defer out.Close()
+		if err := helpers.WriteCaptchaWeights(out, model, "helpers"); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error writing weights file:", err)
+			return
+		}
+		fmt.Fprintf(helpers.Messages, "\nWrote %s. Copy it over helpers/weights.go and rebuild to use it.\n", retrainOutFlag)
+	},
+}
+
+func init() {
+	devCaptchaCollectCmd.Flags().StringVar(&collectDirFlag, "dir", defaultCaptchaSampleDir(), "Directory to save samples to")
+	devCaptchaCollectCmd.Flags().BoolVar(&collectOffFlag, "off", false, "Stop collecting samples")
+
+	devCaptchaRetrainCmd.Flags().StringVarP(&retrainOutFlag, "out", "o", "weights.go", "Go file to write the new weights to")
+	devCaptchaRetrainCmd.Flags().IntVar(&retrainEpochs, "epochs", 30, "Passes over the training captchas")
+	devCaptchaRetrainCmd.Flags().Float64Var(&retrainRate, "rate", 0.05, "Learning rate")
+	devCaptchaRetrainCmd.Flags().Int64Var(&retrainSeed, "seed", 1, "Seed for shuffling, for reproducible runs")
+	devCaptchaRetrainCmd.Flags().Float64Var(&retrainHoldout, "holdout", 0.2, "Fraction of captchas kept aside to compare the old and new model")
+	devCaptchaRetrainCmd.Flags().BoolVar(&retrainFromZero, "from-scratch", false, "Start from zero weights instead of the built-in model")
+
+	devCaptchaCmd.AddCommand(devCaptchaCollectCmd, devCaptchaEvaluateCmd, devCaptchaRetrainCmd)
+	devCmd.AddCommand(devCaptchaCmd)
+	rootCmd.AddCommand(devCmd)
+}
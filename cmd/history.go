@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/features"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
+	"github.com/spf13/cobra"
+)
+
+var sinceFlag string
+
+var historyCmd = &cobra.Command{
+	Use:       "history <dataset>",
+	Short:     "List the saved versions of marks, attendance, grades, das or cgpa",
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: features.VersionedDatasets(),
+	Run: func(cmd *cobra.Command, args []string) {
+		s, ok := savedSession()
+		if !ok {
+			return
+		}
+		history, err := features.FetchHistory(s, args[0])
+		if err != nil {
+			fetchFailed("reading history", err)
+			return
+		}
+		features.ShowHistory(args[0], history)
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:       "diff <dataset>",
+	Short:     "Show what changed in marks, attendance, grades, das or cgpa",
+	Long:      "Show the rows of a dataset added or changed in its latest version, or with --since, such as 7d or 12h, in every version saved in that time.",
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: features.VersionedDatasets(),
+	Run: func(cmd *cobra.Command, args []string) {
+		since, err := parseSince(sinceFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error:", err)
+			return
+		}
+		s, ok := savedSession()
+		if !ok {
+			return
+		}
+		changes, err := features.FetchChanges(s, args[0], since)
+		if err != nil {
+			fetchFailed("reading history", err)
+			return
+		}
+		features.ShowChanges(args[0], changes)
+	},
+}
+
+// savedSession returns the logged in student for reading saved data, which
+// needs only the registration number and never asks VTOP.
+func savedSession() (types.Session, bool) {
+	regNo := loadSessionState().RegNo
+	if regNo == "" {
+		fmt.Fprintln(helpers.Messages, "Please login first using the cli-top login command")
+		return types.Session{}, false
+	}
+	return types.Session{RegNo: regNo}, true
+}
+
+// parseSince reads a --since value: a Go duration, or a whole number of days
+// such as 7d.
+func parseSince(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid --since %q; use a number of days such as 7d", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid --since %q; use a duration such as 7d or 12h", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/secrets"
+	"github.com/lpernett/godotenv"
+	"github.com/spf13/cobra"
+)
+
+// profilesCmd manages login profiles; "cli-top profile" shows the student's
+// VTOP profile.
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Manage login profiles for several students on one machine",
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List login profiles",
+	Run: func(cmd *cobra.Command, args []string) {
+		active := activeProfile()
+		for _, profile := range listProfiles() {
+			marker := " "
+			if profile == active {
+				marker = "*"
+			}
+			fmt.Fprintf(helpers.Messages, "%s %s\n", marker, profile)
+		}
+	},
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a new login profile",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if !profileNamePattern.MatchString(name) {
+			fmt.Fprintln(helpers.Messages, "Profile names may only contain letters, digits, '-' and '_'.")
+			return
+		}
+		if profileExists(name) {
+			fmt.Fprintf(helpers.Messages, "Profile %q already exists.\n", name)
+			return
+		}
+		if err := os.MkdirAll(profileConfigDir(name), 0o700); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error creating profile:", err)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(profileConfigDir(name), "config.env"), nil, 0o600); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error creating profile config:", err)
+			return
+		}
+		fmt.Fprintf(helpers.Messages, "Profile %q created. Login with \"cli-top --profile %s login\".\n", name, name)
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a login profile and its stored credentials",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == defaultProfile {
+			fmt.Fprintln(helpers.Messages, "The default profile cannot be removed, use \"cli-top logout\" instead.")
+			return
+		}
+		if !profileNamePattern.MatchString(name) || !profileExists(name) {
+			fmt.Fprintf(helpers.Messages, "Profile %q does not exist.\n", name)
+			return
+		}
+
+		// The env and vault stores live inside the profile directories; only
+		// the keyring keeps an entry outside of them.
+		config, _ := godotenv.Read(filepath.Join(profileConfigDir(name), "config.env"))
+		if username := config["VTOP_USERNAME"]; username != "" && config["SECRET_STORE"] == secrets.BackendKeyring {
+			if err := secrets.NewKeyringStore().Delete(name + "/" + username); err != nil && debug.Debug {
+				fmt.Fprintln(helpers.Messages, "Error removing stored password:", err)
+			}
+		}
+
+		for _, dir := range []string{profileConfigDir(name), profileStateDir(name), profileDataDir(name)} {
+			if err := os.RemoveAll(dir); err != nil {
+				fmt.Fprintln(helpers.Messages, "Error removing profile:", err)
+				return
+			}
+		}
+		if activeProfile() == name {
+			os.Remove(filepath.Join(helpers.ConfigDir(), activeProfileFile))
+		}
+		fmt.Fprintf(helpers.Messages, "Profile %q removed.\n", name)
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the profile used when --profile is not given",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if !profileNamePattern.MatchString(name) || !profileExists(name) {
+			fmt.Fprintf(helpers.Messages, "Profile %q does not exist.\n", name)
+			return
+		}
+		if err := os.MkdirAll(helpers.ConfigDir(), 0o700); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error saving default profile:", err)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(helpers.ConfigDir(), activeProfileFile), []byte(name+"\n"), 0o600); err != nil {
+			fmt.Fprintln(helpers.Messages, "Error saving default profile:", err)
+			return
+		}
+		fmt.Fprintf(helpers.Messages, "Default profile set to %q.\n", name)
+	},
+}
+
+func init() {
+	profilesCmd.AddCommand(profileListCmd, profileAddCmd, profileRemoveCmd, profileUseCmd)
+	rootCmd.AddCommand(profilesCmd)
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/login"
+	types "github.com/ACM-VIT/CLI-TOP/types"
+)
+
+const (
+	sessionIssuedKey    = "ISSUED_AT"
+	sessionValidatedKey = "VALIDATED_AT"
+	sessionIdleKey      = "IDLE_TIMEOUT"
+
+	defaultIdleTimeout = 15 * time.Minute
+	minIdleTimeout     = 2 * time.Minute
+)
+
+// sessionRefresh tracks background refreshes so Execute can wait for them
+// before the process exits.
+var sessionRefresh sync.WaitGroup
+
+// sessionState is the saved VTOP session along with what we know about its
+// lifetime. The cookies themselves live in the shared jar; Cookies mirrors
+// them next to the CSRF token for the features. IdleTimeout is learned from
+// how long sessions actually survive.
+type sessionState struct {
+	Cookies     types.Cookies
+	RegNo       string
+	IssuedAt    time.Time
+	ValidatedAt time.Time
+	IdleTimeout time.Duration
+}
+
+func loadSessionState() sessionState {
+	session := readSession()
+	helpers.SeedVtopCookies(types.Cookies{
+		SERVERID:   session["SERVERID"],
+		JSESSIONID: session["JSESSIONID"],
+	})
+
+	cookies := helpers.VtopCookies()
+	cookies.CSRF = session["CSRF"]
+	state := sessionState{
+		Cookies:     cookies,
+		RegNo:       session["REGNO"],
+		IdleTimeout: defaultIdleTimeout,
+	}
+	if t, err := time.Parse(time.RFC3339, session[sessionIssuedKey]); err == nil {
+		state.IssuedAt = t
+	}
+	if t, err := time.Parse(time.RFC3339, session[sessionValidatedKey]); err == nil {
+		state.ValidatedAt = t
+	}
+	if d, err := time.ParseDuration(session[sessionIdleKey]); err == nil && d >= minIdleTimeout {
+		state.IdleTimeout = d
+	}
+	return state
+}
+
+func (s sessionState) save() error {
+	// The cookies are persisted by the jar; clear copies left by older versions.
+	values := map[string]string{
+		"CSRF":         s.Cookies.CSRF,
+		"JSESSIONID":   "",
+		"SERVERID":     "",
+		"REGNO":        s.RegNo,
+		sessionIdleKey: s.IdleTimeout.String(),
+	}
+	if !s.IssuedAt.IsZero() {
+		values[sessionIssuedKey] = s.IssuedAt.Format(time.RFC3339)
+	}
+	if !s.ValidatedAt.IsZero() {
+		values[sessionValidatedKey] = s.ValidatedAt.Format(time.RFC3339)
+	}
+	return updateSession(values)
+}
+
+func (s sessionState) usable() bool {
+	return s.RegNo != "" && s.Cookies.JSESSIONID != "" && s.Cookies.CSRF != "" && !s.ValidatedAt.IsZero()
+}
+
+func (s sessionState) age() time.Duration {
+	return time.Since(s.ValidatedAt)
+}
+
+// fresh reports whether the session was confirmed recently enough to use without checking.
+func (s sessionState) fresh() bool {
+	return s.usable() && s.age() < s.IdleTimeout/2
+}
+
+// nearExpiry reports whether the session is probably alive but should be refreshed soon.
+func (s sessionState) nearExpiry() bool {
+	return s.usable() && s.age() < s.IdleTimeout
+}
+
+// observe adjusts the idle timeout estimate after checking a session that
+// was last confirmed age ago.
+func (s *sessionState) observe(alive bool, age time.Duration) {
+	if alive && age > s.IdleTimeout {
+		s.IdleTimeout = age
+	}
+	if !alive && age < s.IdleTimeout {
+		s.IdleTimeout = age
+		if s.IdleTimeout < minIdleTimeout {
+			s.IdleTimeout = minIdleTimeout
+		}
+	}
+}
+
+// validate checks the session against VTOP and records the outcome.
+func (s *sessionState) validate(ctx context.Context) bool {
+	age := s.age()
+	var cookies types.Cookies
+	var regNo string
+	var err error
+	helpers.WithSession(func() {
+		cookies, regNo, err = login.HomePage(ctx, s.Cookies)
+	})
+	if errors.Is(err, login.ErrNetwork) {
+		// An unreachable VTOP says nothing about the session itself.
+		return false
+	}
+	alive := err == nil
+	if !s.ValidatedAt.IsZero() {
+		s.observe(alive, age)
+	}
+	if alive {
+		s.Cookies = cookies
+		s.RegNo = regNo
+		s.ValidatedAt = time.Now()
+	}
+	if err := s.save(); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error writing session file:", err)
+	}
+	return alive
+}
+
+// refreshInBackground keeps a session that is close to its idle timeout
+// alive. It only checks the session: if it has already expired, the
+// foreground command's next request logs in again through helpers.Relogin,
+// where prompts and the cookie jar are not shared with a second login.
+func refreshInBackground(ctx context.Context, state sessionState) {
+	sessionRefresh.Add(1)
+	go func() {
+		defer sessionRefresh.Done()
+		if state.validate(ctx) {
+			debug.Log("Background session refresh succeeded")
+			return
+		}
+		debug.Log("Background session refresh found an expired session; the next request will log in again")
+	}()
+}
@@ -2,20 +2,27 @@ package cmd
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/features"
-	"cli-top/helpers"
-	"cli-top/login"
-	types "cli-top/types"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/features"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/login"
+	"github.com/ACM-VIT/CLI-TOP/pkg/vtop"
+	"github.com/ACM-VIT/CLI-TOP/secrets"
+	"github.com/ACM-VIT/CLI-TOP/store"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/fatih/color"
 	"github.com/google/uuid"
 	"github.com/lpernett/godotenv"
@@ -44,22 +51,22 @@ func getOrCreateUUID() string {
 	if unregisteredUUID == "" {
 		unregisteredUUID = uuid.New().String()
 		viper.Set("UNREGISTERED_UUID", unregisteredUUID)
-		if err := viper.WriteConfigAs("cli-top-config.env"); err != nil && debug.Debug {
-			fmt.Println("Error saving unregistered UUID to config:", err)
+		if err := viper.WriteConfigAs(configPath()); err != nil && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error saving unregistered UUID to config:", err)
 		}
 	}
 
 	if err := helpers.RegisterUUID(unregisteredUUID); err != nil {
 		if debug.Debug {
-			fmt.Println("Error registering UUID with server:", err)
+			fmt.Fprintln(helpers.Messages, "Error registering UUID with server:", err)
 		}
 		return unregisteredUUID
 	}
 
 	viper.Set("UUID", unregisteredUUID)
 	viper.Set("UNREGISTERED_UUID", "")
-	if err := viper.WriteConfigAs("cli-top-config.env"); err != nil && debug.Debug {
-		fmt.Println("Error updating registered UUID in config:", err)
+	if err := viper.WriteConfigAs(configPath()); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error updating registered UUID in config:", err)
 	}
 
 	return unregisteredUUID
@@ -101,7 +108,7 @@ func trackCommand(command string) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("x-api-key", data.UUID)
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := helpers.NewHTTPClient(10 * time.Second)
 
 	// Send the POST request asynchronously
 	resp, err := client.Do(req)
@@ -130,8 +137,8 @@ func trackCommand(command string) {
 		}
 		viper.Set("UUID", newUUID)
 		viper.Set("UNREGISTERED_UUID", "")
-		if err := viper.WriteConfigAs("cli-top-config.env"); err != nil && debug.Debug {
-			fmt.Println("Error updating registered UUID in config:", err)
+		if err := viper.WriteConfigAs(configPath()); err != nil && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error updating registered UUID in config:", err)
 		}
 	} else if resp.StatusCode != http.StatusOK {
 		if debug.Debug {
@@ -144,7 +151,7 @@ func trackCommand(command string) {
 	}
 }
 
-func startfn() {
+func startfn(ctx context.Context) {
 	red := color.New(color.FgHiRed)
 	//blue := color.New(color.FgHiBlue)
 	pink := color.New(color.FgHiMagenta)
@@ -162,116 +169,177 @@ func startfn() {
 	}
 	red.Println("\nWelcome to CLI-TOP!\n ")
 	red.Println("Use \"cli-top help\" or \"cli-top --list\" to show available commands\nUse \"cli-top [command] --help\" for more information about a command.\n ")
-	fileName := "cli-top-config.env"
-
-	currentDir, err := os.Getwd()
+	filePath, err := filepath.Abs(configPath())
 	if err != nil && debug.Debug {
-		fmt.Println("Error getting current directory:", err)
+		fmt.Fprintln(helpers.Messages, "Error resolving config path:", err)
 		return
 	}
 
-	filePath := filepath.Join(currentDir, fileName)
-
 	if _, err := os.Stat(filePath); err == nil {
 		if debug.Debug {
-			fmt.Println("File exists:", filePath)
+			fmt.Fprintln(helpers.Messages, "File exists:", filePath)
 		}
-		err := godotenv.Load("cli-top-config.env")
+		err := godotenv.Load(configPath())
 		if err != nil && debug.Debug {
-			fmt.Println("Error loading .env file")
+			fmt.Fprintln(helpers.Messages, "Error loading .env file")
 		}
-		if debug.Debug {
-			fmt.Println(os.Getenv("PASSWORD"))
-		}
-
-		if os.Getenv("VTOP_USERNAME") != "" && os.Getenv("PASSWORD") != "" {
-			vtop_login()
+		if os.Getenv("VTOP_USERNAME") != "" {
+			helpers.Relogin(ctx)
 		}
 	} else if os.IsNotExist(err) {
-		fmt.Println("File does not exist:", filePath)
-		fmt.Println("Please login using the \"login\" command")
+		fmt.Fprintln(helpers.Messages, "File does not exist:", filePath)
+		fmt.Fprintln(helpers.Messages, "Please login using the \"login\" command")
 	} else {
-		fmt.Println("Error checking file existence:", err)
+		fmt.Fprintln(helpers.Messages, "Error checking file existence:", err)
 	}
 
 	userUUID := getOrCreateUUID()
 	if debug.Debug {
-		fmt.Println("User UUID:", userUUID)
+		fmt.Fprintln(helpers.Messages, "User UUID:", userUUID)
 	}
 }
 
-func vtop_login() (types.Cookies, string) {
-	err := godotenv.Load("cli-top-config.env")
+func vtop_login(ctx context.Context) (types.Cookies, string) {
+	err := godotenv.Load(configPath())
 	if err != nil && debug.Debug {
-		fmt.Println("Error loading .env file, please enter your credentials using the \"login\" command.")
+		fmt.Fprintln(helpers.Messages, "Error loading .env file, please enter your credentials using the \"login\" command.")
 	}
 
 	userInfo := types.LogIn{
-		Username: os.Getenv("VTOP_USERNAME"),
-		Password: os.Getenv("PASSWORD"),
+		Username: configValue("VTOP_USERNAME"),
 	}
 
-	key := os.Getenv("KEY")
+	store, err := credentialStore()
+	if err != nil {
+		fmt.Fprintln(helpers.Messages, "Error opening credential store:", err)
+		return types.Cookies{}, ""
+	}
 
-	password, err := decryptPassword(userInfo.Password, key)
-	if err != nil && debug.Debug {
-		fmt.Println("Error decrypting password:", err)
+	password, err := store.Get(credentialAccount(userInfo.Username))
+	if err != nil {
+		fmt.Fprintln(helpers.Messages, "Error reading stored password:", err)
+		return types.Cookies{}, ""
 	}
 
-	loginSecrets := login.Login(userInfo.Username, password)
-	cookies, tmp := login.HomePage(loginSecrets)
-	userInfo.RegNo = tmp
+	if attempts, err := strconv.Atoi(configValue("CAPTCHA_ATTEMPTS")); err == nil && attempts > 0 {
+		login.MaxCaptchaAttempts = attempts
+	}
+	solver, err := helpers.NewCaptchaSolver(configValue("CAPTCHA_SOLVER"), configValue("CAPTCHA_COMMAND"))
+	if err != nil {
+		fmt.Fprintln(helpers.Messages, err)
+		return types.Cookies{}, ""
+	}
+	helpers.ActiveCaptchaSolver = solver
+	if confidence, err := strconv.ParseFloat(configValue("CAPTCHA_MIN_CONFIDENCE"), 32); err == nil && confidence >= 0 && confidence <= 1 {
+		helpers.MinCaptchaConfidence = float32(confidence)
+	}
+	collectCaptchaSamples()
 
-	saveCookiesToFile(cookies, userInfo, key)
-	if err != nil && debug.Debug {
-		fmt.Println("Error saving cookies:", err)
+	client := newVtopClient()
+	if err := client.Login(ctx, userInfo.Username, password); err != nil {
+		fmt.Fprintln(helpers.Messages, loginErrorMessage(err))
+		return types.Cookies{}, ""
 	}
+	cookies := client.Session().Cookies
+	userInfo.RegNo = client.RegNo()
+
+	if env, ok := store.(*secrets.EnvStore); ok && userInfo.RegNo != "" {
+		// Re-encrypt passwords saved in the old unauthenticated format now that they are known to be correct.
+		if err := env.Upgrade(credentialAccount(userInfo.Username), password); err != nil && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error upgrading stored password format:", err)
+		}
+	}
+
+	saveCookiesToFile(cookies, userInfo)
 	if debug.Debug {
-		fmt.Println("(Main) VTOP Cookies", cookies)
+		fmt.Fprintln(helpers.Messages, "(Main) VTOP Cookies", cookies)
 	}
 
 	return cookies, userInfo.RegNo
 }
 
-func saveCookiesToFile(cookies types.Cookies, userInfo types.LogIn, Key string) {
-	viper.Set("CSRF", "\""+cookies.CSRF+"\"")
-	viper.Set("JSESSIONID", "\""+cookies.JSESSIONID+"\"")
-	viper.Set("SERVERID", "\""+cookies.SERVERID+"\"")
-	viper.Set("REGNO", "\""+userInfo.RegNo+"\"")
-	viper.Set("VTOP_USERNAME", "\""+userInfo.Username+"\"")
-	viper.Set("PASSWORD", "\""+userInfo.Password+"\"")
-	viper.Set("KEY", "\""+Key+"\"")
-	if err := viper.WriteConfigAs("cli-top-config.env"); err != nil && debug.Debug {
-		fmt.Println("Error writing to .env file:", err)
+// loginErrorMessage turns a typed login error into the message shown to the user.
+func loginErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, login.ErrInvalidCaptcha):
+		return "\nInvalid Captcha. The captcha solver can sometimes confuse between B and 8, please retry..."
+	case errors.Is(err, login.ErrInvalidCredentials):
+		return "\nInvalid LoginId/Password. Please check your credentials and login again using the \"login\" command."
+	case errors.Is(err, login.ErrAccountLocked):
+		return "\nNumber Of Maximum Fail Attempts Reached. Use Forgot Password on VTOP to reset your password."
+	case helpers.IsCertificateError(err):
+		return fmt.Sprintf("\nVTOP's certificate could not be verified: %v\nIf your network intercepts HTTPS, trust its CA with --ca-file or CA_FILE in your config.", err)
+	case errors.Is(err, login.ErrNetwork):
+		return "\nUnable to reach VTOP. Please check your internet connection and try again."
+	case errors.Is(err, login.ErrSessionTimedOut):
+		return "\nVTOP did not start a session after login, please retry..."
+	case errors.Is(err, login.ErrCaptchaSolver):
+		return fmt.Sprintf("\nCould not solve the captcha: %v\nCheck CAPTCHA_SOLVER and CAPTCHA_COMMAND in your config.", err)
+	default:
+		return fmt.Sprintf("\nLogin failed: %v", err)
+	}
+}
+
+func saveCookiesToFile(cookies types.Cookies, userInfo types.LogIn) {
+	state := loadSessionState()
+	state.Cookies = cookies
+	state.RegNo = userInfo.RegNo
+	state.IssuedAt = time.Now()
+	state.ValidatedAt = time.Time{}
+	if userInfo.RegNo != "" {
+		state.ValidatedAt = state.IssuedAt
+	}
+	if err := state.save(); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error writing session file:", err)
 	}
 }
 
-func readCookiesFromFile() (types.Cookies, string) {
+func readCookiesFromFile(ctx context.Context) (types.Cookies, string) {
 	if debugFlag {
 		debug.Debug = true
-		fmt.Println("Debug mode on")
+		fmt.Fprintln(helpers.Messages, "Debug mode on")
 	}
-	err := godotenv.Load("cli-top-config.env")
-	if err != nil && debug.Debug {
-		fmt.Println("Error loading .env file, please enter your credentials using the \"login\" command.")
+
+	state := loadSessionState()
+	if helpers.Replaying() {
+		return replayCookies(state.RegNo)
 	}
-	cookies := types.Cookies{
-		SERVERID:   os.Getenv("SERVERID"),
-		CSRF:       os.Getenv("CSRF"),
-		JSESSIONID: os.Getenv("JSESSIONID"),
+	if store.Default.Offline {
+		// Saved data is looked up by registration number; the session is
+		// not checked, since that needs VTOP.
+		return state.Cookies, state.RegNo
 	}
-	cookies, regNo := login.HomePage(cookies)
-	if regNo == "" {
-		cookies, regNo = vtop_login()
+	switch {
+	case state.fresh():
+		debug.Log(fmt.Sprintf("Session confirmed %s ago, skipping validation", state.age().Round(time.Second)))
+		return state.Cookies, state.RegNo
+	case state.nearExpiry():
+		debug.Log(fmt.Sprintf("Session confirmed %s ago, refreshing in the background", state.age().Round(time.Second)))
+		refreshInBackground(ctx, state)
+		return state.Cookies, state.RegNo
 	}
 
-	return cookies, regNo
+	if state.validate(ctx) {
+		return state.Cookies, state.RegNo
+	}
+	return helpers.Relogin(ctx)
+}
+
+// replayCookies stands in for a session under --replay, where the recorded
+// responses decide what every request returns.
+func replayCookies(regNo string) (types.Cookies, string) {
+	if regNo == "" {
+		regNo = "REPLAY"
+	}
+	return types.Cookies{CSRF: "replay", JSESSIONID: "replay", SERVERID: "replay"}, regNo
 }
 
 var rootCmd = &cobra.Command{
 	Use:   "cli-top",
 	Short: "A simple CLI tool for vtop",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		applyKillSwitch(cmd)
+
 		if cmd.Name() != "login" && cmd.Name() != "logout" && cmd.Name() != "cli-top" {
 			go trackCommand(cmd.Name())
 		}
@@ -310,12 +378,8 @@ var rootCmd = &cobra.Command{
 				req.Header.Set("Content-Type", "application/json")
 				req.Header.Set("x-api-key", userUUID)
 
-				client := &http.Client{
-					Timeout: 5 * time.Second,
-					Transport: &http.Transport{
-						DisableKeepAlives: true,
-					},
-				}
+				client := helpers.NewHTTPClient(5 * time.Second)
+				client.Transport.(*http.Transport).DisableKeepAlives = true
 
 				go func() {
 					resp, err := client.Do(req)
@@ -335,11 +399,11 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		if debugFlag {
 			debug.Debug = true
-			fmt.Println("Debug mode on")
+			fmt.Fprintln(helpers.Messages, "Debug mode on")
 		}
 
 		if versionFlag {
-			fmt.Println("Version:", debug.Version)
+			fmt.Fprintln(helpers.Messages, "Version:", debug.Version)
 			return
 		}
 
@@ -348,13 +412,12 @@ var rootCmd = &cobra.Command{
 			return
 		}
 
-		startfn()
+		startfn(cmd.Context())
 	},
 }
 
 func init() {
 	helpers.VtopLoginGlobal = vtop_login
-	helpers.DecryptPasswordProxy = decryptPassword
 
 	rootCmd.SetUsageTemplate(`Usage:
   {{.CommandPath}} [global flags] <subcommand> [subcommand flags] [arguments]
@@ -371,36 +434,46 @@ Use "{{.CommandPath}} <subcommand> --help" for more information about a subcomma
 `)
 }
 
-func Execute() {
-	killSwitch := helpers.CheckKillSwitch()
-	if killSwitch == 2 {
-		fmt.Println("This version of cli-top has been decommissioned.")
+// interruptContext returns a context cancelled by Ctrl-C, so requests in
+// flight are aborted and the command unwinds; Execute exits once it has.
+// Commands blocked on a prompt never look at the context, so a second Ctrl-C
+// exits straight away.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		cancel()
+		fmt.Fprintln(os.Stderr, "\nCancelled. Press Ctrl-C again to quit now.")
+		<-interrupts
+		os.Exit(130)
+	}()
+	return ctx
+}
+
+// applyKillSwitch stops cli-top when the server has decommissioned this
+// version or sent users to VTOP instead. It runs once flags and config are
+// applied, so the check uses the configured network. Replays, --offline and
+// the commands that only read saved data need no network and skip it.
+func applyKillSwitch(cmd *cobra.Command) {
+	if replayFlag != "" || store.Default.Offline || cmd == historyCmd || cmd == diffCmd {
 		return
-	} else if killSwitch == 3 {
+	}
+	switch helpers.CheckKillSwitch() {
+	case 2:
+		fmt.Fprintln(helpers.Messages, "This version of cli-top has been decommissioned.")
+		os.Exit(0)
+	case 3:
 		err := helpers.OpenURLInBrowser("https://vtop.vit.ac.in")
 		if err != nil {
-			fmt.Println("An unexpected error has occurred", err)
-		}
-		return
-	}
-
-	err := godotenv.Load("cli-top-config.env")
-	if err != nil && debug.Debug {
-		fmt.Println("Error loading .env file:", err)
-	}
-
-	userUUID := getOrCreateUUID()
-	if debug.Debug {
-		fmt.Println("User UUID:", userUUID)
-	}
-
-	if !updateFlag && os.Args[len(os.Args)-1] != "-u" && os.Args[len(os.Args)-1] != "--update" {
-		shouldNotify, latestVersion := helpers.ShouldShowUpdateNotification()
-		if shouldNotify {
-			helpers.ShowUpdateNotification(latestVersion)
+			fmt.Fprintln(helpers.Messages, "An unexpected error has occurred", err)
 		}
+		os.Exit(0)
 	}
+}
 
+func Execute() {
 	// Define flags for subcommands
 	marksCmd.PersistentFlags().IntVarP(&semesterFlag, "semester", "s", 0, "Specify the semester")
 	gradesCmd.PersistentFlags().IntVarP(&semesterFlag, "semester", "s", 0, "Specify the semester")
@@ -417,19 +490,47 @@ func Execute() {
 	coursePageArchiveCmd.PersistentFlags().StringVarP(&facultyFlag, "faculty", "f", "", "Specify the faculty")
 	coursePageArchiveCmd.PersistentFlags().IntVarP(&fuzzyIndexFlag, "fuzzy-index", "i", 0, "Specify the fuzzy index")
 
+	diffCmd.Flags().StringVar(&sinceFlag, "since", "", "Show every change saved within this long, such as 7d or 12h")
 	syllabusCmd.PersistentFlags().StringVarP(&syllabusCourseFlag, "course", "c", "", "Specify course search query ")
 	//daDetailsCmd.PersistentFlags().StringVarP(&courseNameFlag, "course-name", "c", "", "Specify the course name")
 	// Define global flags
 	rootCmd.PersistentFlags().BoolVarP(&debugFlag, "debug", "d", false, "Print Debug Messages")
 	rootCmd.PersistentFlags().BoolVarP(&updateFlag, "update", "u", false, "Check for Updates")
 	rootCmd.PersistentFlags().BoolVarP(&versionFlag, "version", "v", false, "Print Version Number")
+	rootCmd.PersistentFlags().StringVar(&outputFlag, "output", "table", "Print results as table, json, yaml, csv or tsv")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Use a named login profile")
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "", "Path to the config file (default $XDG_CONFIG_HOME/cli-top/config.env)")
+	rootCmd.PersistentFlags().StringVar(&caFileFlag, "ca-file", "", "PEM bundle of extra CA certificates to trust for VTOP")
+	rootCmd.PersistentFlags().BoolVar(&insecureFlag, "insecure", false, "Skip TLS certificate verification (unsafe)")
+	rootCmd.PersistentFlags().StringVar(&proxyFlag, "proxy", "", "Send requests through an http://, https:// or socks5:// proxy")
+	rootCmd.PersistentFlags().StringVar(&dnsFlag, "dns", "", "Resolve hostnames with this DNS server (host[:port])")
+	rootCmd.PersistentFlags().IntVar(&retriesFlag, "retries", helpers.Retry.Attempts-1, "Times to retry a VTOP request that fails on a flaky connection")
+	rootCmd.PersistentFlags().DurationVar(&retryDelayFlag, "retry-delay", helpers.Retry.BaseDelay, "Wait before the first retry, doubling after each")
+	rootCmd.PersistentFlags().Float64Var(&rateLimitFlag, "rate-limit", helpers.Limiter.Rate, "Most VTOP requests per second, shared by all cli-top processes (0 for no limit)")
+	rootCmd.PersistentFlags().IntVar(&rateBurstFlag, "rate-burst", helpers.Limiter.Burst, "Requests allowed at once before --rate-limit applies")
+	rootCmd.PersistentFlags().StringVar(&recordFlag, "record", "", "Save VTOP requests and responses to this directory, with credentials redacted")
+	rootCmd.PersistentFlags().StringVar(&replayFlag, "replay", "", "Answer VTOP requests from a directory made by --record instead of the network")
+	rootCmd.PersistentFlags().BoolVar(&offlineFlag, "offline", false, "Show the data saved by earlier commands instead of asking VTOP")
+	rootCmd.PersistentFlags().DurationVar(&maxAgeFlag, "max-age", 0, "Reuse saved data fetched within this long, such as 30m, instead of asking VTOP")
 
 	// Add subcommands to root command
-	rootCmd.AddCommand(profileCmd, marksCmd, gradesCmd, attendanceCmd, timeTableCmd, receiptCmd, hostelCmd, cgpaCmd, examScheduleCmd, libraryDuesCmd, logoutCmd, calendarCmd, coursePageCmd, coursePageArchiveCmd, nightslipCmd, leavestatusCmd, classMessagesCmd, daDetailsCmd, facilityCmd, syllabusCmd, courseAllocationCmd, aiCmd)
+	rootCmd.AddCommand(profileCmd, marksCmd, gradesCmd, attendanceCmd, timeTableCmd, receiptCmd, hostelCmd, cgpaCmd, examScheduleCmd, libraryDuesCmd, logoutCmd, calendarCmd, coursePageCmd, coursePageArchiveCmd, nightslipCmd, leavestatusCmd, classMessagesCmd, daDetailsCmd, facilityCmd, syllabusCmd, courseAllocationCmd, historyCmd, diffCmd, aiCmd)
 
+	ctx := interruptContext()
 	rootCmd.SetArgs(os.Args[1:])
-	if err := rootCmd.Execute(); err != nil && debug.Debug {
-		fmt.Println(err)
+	err := rootCmd.ExecuteContext(ctx)
+	sessionRefresh.Wait()
+	if verr := helpers.LastVtopError(); verr != nil && ctx.Err() == nil {
+		fmt.Fprintln(helpers.Messages, "\n"+verr.Error())
+	}
+	if asOf := store.Default.OldestServed(); !asOf.IsZero() && ctx.Err() == nil {
+		fmt.Fprintf(helpers.Messages, "\nData as of %s (saved copy).\n", asOf.Local().Format("Jan 2 15:04"))
+	}
+	if ctx.Err() != nil {
+		os.Exit(130)
+	}
+	if err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, err)
 		os.Exit(1)
 	}
 }
@@ -438,8 +539,45 @@ var courseAllocationCmd = &cobra.Command{
 	Use:   "course-allocation",
 	Short: "View course allocation",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.ExecuteInteractiveCourseAllocationView(regNo, cookies, "")
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		categories, err := client.AllocationCategories(ctx)
+		if err != nil {
+			fetchFailed("fetching course allocation", err)
+			return
+		}
+		for {
+			category, ok := features.SelectAllocationCategory(categories)
+			if !ok {
+				return
+			}
+		courses:
+			for {
+				courses, err := client.AllocationCourses(ctx, category)
+				if err != nil {
+					fetchFailed("fetching courses for "+category.Name, err)
+					break
+				}
+				course, action := features.SelectAllocationCourse(category, courses)
+				switch action {
+				case features.AllocationExit:
+					return
+				case features.AllocationBack:
+					break courses
+				}
+				details, err := client.CourseAllocation(ctx, course)
+				if err != nil {
+					fetchFailed("fetching course details for "+course.Name, err)
+					continue
+				}
+				if features.ShowCourseAllocation(details) == features.AllocationExit {
+					return
+				}
+			}
+		}
 	},
 }
 
@@ -447,8 +585,17 @@ var profileCmd = &cobra.Command{
 	Use:   "profile",
 	Short: "Show VTOP Student Profile",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.Profile(cookies, regNo)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Profile(ctx)
+		if err != nil {
+			fetchFailed("fetching profile", err)
+			return
+		}
+		features.ShowProfile(data)
 	},
 }
 
@@ -456,8 +603,35 @@ var facilityCmd = &cobra.Command{
 	Use:   "facility",
 	Short: "View facilities",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.RegisterPhyFacility(regNo, cookies)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Facilities(ctx)
+		if err != nil {
+			fetchFailed("fetching facilities", err)
+			return
+		}
+		features.ShowFacilities(data)
+		facility, ok := features.SelectFacility(data)
+		if !ok {
+			return
+		}
+		confirmation, err := client.RegisterFacility(ctx, facility)
+		if err != nil {
+			fetchFailed("registering for "+facility.Name, err)
+			return
+		}
+		fmt.Fprintln(helpers.Messages, "Facility Registration Response:")
+		fmt.Fprintln(helpers.Messages, confirmation)
+		fmt.Fprintln(helpers.Messages, "Registration completed successfully.")
+		if data, err = client.Facilities(ctx); err != nil {
+			fetchFailed("fetching updated registrations", err)
+			return
+		}
+		fmt.Fprintln(helpers.Messages, "\nYour Current Registrations:")
+		features.ShowFacilities(data)
 	},
 }
 
@@ -465,8 +639,27 @@ var syllabusCmd = &cobra.Command{
 	Use:   "syllabus",
 	Short: "Download syllabus for a selected course",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.ExecuteSyllabusDownload(regNo, cookies, syllabusCourseFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		courses, err := client.SyllabusCourses(ctx)
+		if err != nil {
+			fetchFailed("fetching syllabus courses", err)
+			return
+		}
+		course, err := features.SelectSyllabusCourse(courses, syllabusCourseFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Selection canceled")
+			return
+		}
+		pdf, err := client.Syllabus(ctx, course)
+		if err != nil {
+			fetchFailed("downloading syllabus", err)
+			return
+		}
+		features.SaveSyllabus(course, pdf)
 	},
 }
 
@@ -474,8 +667,22 @@ var marksCmd = &cobra.Command{
 	Use:   "marks",
 	Short: "Show Marks Details of a particular semester",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetMarks(regNo, cookies, "", semesterFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		opts, err := selectSemester(ctx, client)
+		if err != nil {
+			fetchFailed("fetching semesters", err)
+			return
+		}
+		data, err := client.Marks(ctx, opts)
+		if err != nil {
+			fetchFailed("fetching marks", err)
+			return
+		}
+		features.ShowMarks(data)
 	},
 }
 
@@ -483,8 +690,22 @@ var gradesCmd = &cobra.Command{
 	Use:   "grades",
 	Short: "Show Grade Details of a particular semester",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetGrades(regNo, cookies, "", semesterFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		opts, err := selectSemester(ctx, client)
+		if err != nil {
+			fetchFailed("fetching semesters", err)
+			return
+		}
+		data, err := client.Grades(ctx, opts)
+		if err != nil {
+			fetchFailed("fetching grades", err)
+			return
+		}
+		features.ShowGrades(data)
 	},
 }
 
@@ -492,8 +713,17 @@ var attendanceCmd = &cobra.Command{
 	Use:   "attendance",
 	Short: "Show Attendance Details of a particular semester",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetAttendance(regNo, cookies, semesterFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Attendance(ctx, vtop.FetchOptions{})
+		if err != nil {
+			fetchFailed("fetching attendance", err)
+			return
+		}
+		features.ShowAttendance(data)
 	},
 }
 
@@ -501,8 +731,17 @@ var receiptCmd = &cobra.Command{
 	Use:   "receipts",
 	Short: "Show Receipt Details of a user",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetReceipt(regNo, cookies)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Receipts(ctx)
+		if err != nil {
+			fetchFailed("fetching receipts", err)
+			return
+		}
+		features.ShowReceipts(data)
 	},
 }
 
@@ -510,8 +749,22 @@ var timeTableCmd = &cobra.Command{
 	Use:   "timetable",
 	Short: "Show Time Table of a particular semester",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetTimeTable(regNo, cookies, semesterFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		opts, err := selectSemester(ctx, client)
+		if err != nil {
+			fetchFailed("fetching semesters", err)
+			return
+		}
+		data, err := client.Timetable(ctx, opts)
+		if err != nil {
+			fetchFailed("fetching timetable", err)
+			return
+		}
+		features.ShowTimetable(ctx, client.Session(), opts.Semester, data)
 	},
 }
 
@@ -519,8 +772,17 @@ var hostelCmd = &cobra.Command{
 	Use:   "hostel",
 	Short: "Show Hostel Details of a user",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.PrintHostelInfo(regNo, cookies, "https://vtop.vit.ac.in/vtop/studentsRecord/StudentProfileAllView")
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Hostel(ctx)
+		if err != nil {
+			fetchFailed("fetching hostel details", err)
+			return
+		}
+		features.ShowHostelInfo(data)
 	},
 }
 
@@ -528,8 +790,17 @@ var cgpaCmd = &cobra.Command{
 	Use:   "cgpa",
 	Short: "Show CGPA details",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.PrintCgpa(regNo, cookies, "https://vtop.vit.ac.in/vtop/examinations/examGradeView/StudentGradeHistory")
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.CGPA(ctx)
+		if err != nil {
+			fetchFailed("fetching CGPA", err)
+			return
+		}
+		features.ShowCGPA(data)
 	},
 }
 
@@ -537,8 +808,17 @@ var examScheduleCmd = &cobra.Command{
 	Use:   "exams",
 	Short: "Show Exam Schedule",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetExamSchedule(regNo, cookies, semesterFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Exams(ctx, vtop.FetchOptions{})
+		if err != nil {
+			fetchFailed("fetching exam schedule", err)
+			return
+		}
+		features.ShowExams(data)
 	},
 }
 
@@ -546,8 +826,27 @@ var coursePageCmd = &cobra.Command{
 	Use:   "course-page",
 	Short: "Download course materials for a selected semester, course, and faculty",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.ExecuteCoursePageDownload(regNo, cookies, semesterFlag, courseFlag, facultyFlag, fuzzyIndexFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		courses, err := client.Courses(ctx)
+		if err != nil {
+			fetchFailed("fetching courses", err)
+			return
+		}
+		course, err := features.SelectCoursePageCourse(courses, courseFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error selecting course:", err)
+			return
+		}
+		uploads, err := client.CourseMaterials(ctx, course)
+		if err != nil {
+			fetchFailed("fetching faculties", err)
+			return
+		}
+		features.DownloadCoursePageMaterials(ctx, client.Session(), course, uploads, facultyFlag)
 	},
 }
 
@@ -555,8 +854,42 @@ var coursePageArchiveCmd = &cobra.Command{
 	Use:   "course-page-archive",
 	Short: "Download course materials for a selected semester, course, and faculty (Archive)",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.ExecuteCoursePageOldDownload(regNo, cookies, semesterFlag, courseFlag, facultyFlag, fuzzyIndexFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		opts, err := selectSemester(ctx, client)
+		if err != nil {
+			fetchFailed("fetching semesters", err)
+			return
+		}
+		courses, err := client.ArchiveCourses(ctx, opts)
+		if err != nil {
+			fetchFailed("fetching courses", err)
+			return
+		}
+		course, err := features.SelectArchiveCourse(courses, courseFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error selecting course:", err)
+			return
+		}
+		faculties, err := client.ArchiveFaculties(ctx, opts, course)
+		if err != nil {
+			fetchFailed("fetching faculties", err)
+			return
+		}
+		faculty, err := features.SelectArchiveFaculty(faculties, facultyFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error selecting faculty:", err)
+			return
+		}
+		materials, err := client.ArchiveMaterials(ctx, faculty)
+		if err != nil {
+			fetchFailed("fetching course materials", err)
+			return
+		}
+		features.DownloadArchiveMaterials(ctx, client.Session(), course, faculty, materials)
 	},
 }
 
@@ -564,8 +897,17 @@ var libraryDuesCmd = &cobra.Command{
 	Use:   "library-dues",
 	Short: "Show Library Dues",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetLibraryDues(regNo, cookies)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.LibraryDues(ctx)
+		if err != nil {
+			fetchFailed("fetching library dues", err)
+			return
+		}
+		features.ShowLibraryDues(data)
 	},
 }
 
@@ -573,8 +915,32 @@ var calendarCmd = &cobra.Command{
 	Use:   "calendar",
 	Short: "Show Calendar",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.PrintCal(regNo, cookies, semesterFlag, classGrpFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		opts, err := selectSemester(ctx, client)
+		if err != nil {
+			fetchFailed("fetching semesters", err)
+			return
+		}
+		groups, err := client.ClassGroups(ctx, opts)
+		if err != nil {
+			fetchFailed("fetching class groups", err)
+			return
+		}
+		group, err := features.SelectClassGroup(groups, classGrpFlag)
+		if err != nil {
+			fmt.Fprintln(helpers.Messages, "Error selecting class group:", err)
+			return
+		}
+		data, err := client.Calendar(ctx, opts, group)
+		if err != nil {
+			fetchFailed("fetching calendar", err)
+			return
+		}
+		features.ShowCalendar(data)
 	},
 }
 
@@ -582,16 +948,31 @@ var logoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Logout from VTOP",
 	Run: func(cmd *cobra.Command, args []string) {
-		err := godotenv.Load("cli-top-config.env")
+		err := godotenv.Load(configPath())
 		if err != nil && debug.Debug {
-			fmt.Println("Error loading .env file:", err)
+			fmt.Fprintln(helpers.Messages, "Error loading .env file:", err)
 			return
 		}
 
+		if username := os.Getenv("VTOP_USERNAME"); username != "" {
+			if store, err := credentialStore(); err == nil {
+				if err := store.Delete(credentialAccount(username)); err != nil && debug.Debug {
+					fmt.Fprintln(helpers.Messages, "Error removing stored password:", err)
+				}
+			}
+		}
+
+		if err := os.Remove(sessionPath()); err != nil && !os.IsNotExist(err) && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error removing session file:", err)
+		}
+		if err := helpers.CookieJar().Clear(); err != nil && debug.Debug {
+			fmt.Fprintln(helpers.Messages, "Error removing cookie file:", err)
+		}
+
 		uuid := os.Getenv("UUID")
 
 		if uuid == "" {
-			fmt.Println("UUID not found; nothing to preserve.")
+			fmt.Fprintln(helpers.Messages, "UUID not found; nothing to preserve.")
 			return
 		}
 
@@ -599,10 +980,10 @@ var logoutCmd = &cobra.Command{
 			"UUID": uuid,
 		}
 
-		f, err := os.Create("cli-top-config.env")
+		f, err := os.Create(configPath())
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error creating .env file:", err)
+				fmt.Fprintln(helpers.Messages, "Error creating .env file:", err)
 			}
 			return
 		}
@@ -611,12 +992,12 @@ var logoutCmd = &cobra.Command{
 		for key, value := range env {
 			_, err = f.WriteString(fmt.Sprintf("%s=%s\n", key, value))
 			if err != nil && debug.Debug {
-				fmt.Println("Error writing to .env file:", err)
+				fmt.Fprintln(helpers.Messages, "Error writing to .env file:", err)
 				return
 			}
 		}
 
-		fmt.Println("Logged out successfully.")
+		fmt.Fprintln(helpers.Messages, "Logged out successfully.")
 	},
 }
 
@@ -624,8 +1005,17 @@ var nightslipCmd = &cobra.Command{
 	Use:   "nightslip",
 	Short: "Show Nightslip Request Status of a user",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetNightSlipStatus(regNo, cookies)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.NightSlips(ctx)
+		if err != nil {
+			fetchFailed("fetching night slips", err)
+			return
+		}
+		features.ShowNightSlips(data)
 	},
 }
 
@@ -633,8 +1023,17 @@ var leavestatusCmd = &cobra.Command{
 	Use:   "leave",
 	Short: "Show Leave Status",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetLeaveStatus(regNo, cookies)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Leaves(ctx)
+		if err != nil {
+			fetchFailed("fetching leave status", err)
+			return
+		}
+		features.ShowLeaves(data)
 	},
 }
 
@@ -642,8 +1041,17 @@ var classMessagesCmd = &cobra.Command{
 	Use:   "msg",
 	Short: "Show Class Messages",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.GetClassMessage(regNo, cookies)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.Messages(ctx, vtop.FetchOptions{})
+		if err != nil {
+			fetchFailed("fetching class messages", err)
+			return
+		}
+		features.ShowClassMessages(data)
 	},
 }
 
@@ -651,7 +1059,16 @@ var daDetailsCmd = &cobra.Command{
 	Use:   "da",
 	Short: "Show Digital Assignment Details",
 	Run: func(cmd *cobra.Command, args []string) {
-		cookies, regNo := readCookiesFromFile()
-		features.PrintAllDAs(regNo, cookies, courseNameFlag)
+		ctx := cmd.Context()
+		client, ok := vtopClient(ctx)
+		if !ok {
+			return
+		}
+		data, err := client.DAs(ctx, vtop.FetchOptions{})
+		if err != nil {
+			fetchFailed("fetching digital assignments", err)
+			return
+		}
+		features.ShowDAs(ctx, client.Session(), data)
 	},
 }
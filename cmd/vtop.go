@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/pkg/vtop"
+	"github.com/ACM-VIT/CLI-TOP/store"
+)
+
+// newVtopClient returns a client that keeps its session in the CLI's saved
+// cookie jar, logs in again when it expires and saves to store.Default.
+func newVtopClient() *vtop.Client {
+	return vtop.NewClient(vtop.Options{HTTP: helpers.Vtop, Store: store.Default})
+}
+
+// vtopClient returns a client for the saved session, logging in again if it
+// has expired. It reports false, having told the user, when there is no
+// session to use.
+func vtopClient(ctx context.Context) (*vtop.Client, bool) {
+	cookies, regNo := readCookiesFromFile(ctx)
+	if !store.Default.Offline || regNo == "" {
+		if !helpers.ValidateLogin(cookies) {
+			return nil, false
+		}
+	}
+	client := newVtopClient()
+	client.SetSession(vtop.Session{RegNo: regNo, Cookies: cookies})
+	return client, true
+}
+
+// selectSemester picks the semester given by --semester, or asks for one.
+func selectSemester(ctx context.Context, client *vtop.Client) (vtop.FetchOptions, error) {
+	semesters, err := client.Semesters(ctx)
+	if err != nil {
+		return vtop.FetchOptions{}, err
+	}
+	// --semester counts from the oldest semester, as VTOP lists them.
+	oldestFirst := slices.Clone(semesters)
+	slices.Reverse(oldestFirst)
+	semester, err := helpers.ChooseSemester(oldestFirst, semesterFlag)
+	return vtop.FetchOptions{Semester: semester}, err
+}
+
+// fetchFailed reports an error from the client. Errors from VTOP itself are
+// explained by Execute once the command returns, so they are only logged.
+func fetchFailed(action string, err error) {
+	var verr *vtop.VtopError
+	switch {
+	case errors.As(err, &verr), errors.Is(err, context.Canceled):
+		debug.Log(fmt.Sprintf("Error %s: %v", action, err))
+	case errors.Is(err, vtop.ErrNotLoggedIn):
+		fmt.Fprintln(helpers.Messages, "Please login first using the cli-top login command")
+	case errors.Is(err, store.ErrNotSaved):
+		fmt.Fprintln(helpers.Messages, "Error:", err)
+	default:
+		helpers.HandleError(action, err)
+	}
+}
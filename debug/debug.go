@@ -2,15 +2,21 @@ package debug
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"time"
 )
 
 var Debug bool = false
 var Version string = "2.9.8"
 
+// Output is where Log writes. It follows helpers.Messages, moving to stderr
+// when --output asks for data on stdout.
+var Output io.Writer = os.Stdout
+
 func Log(message string) {
 	if Debug {
 		timestamp := time.Now().Format("15:04:05")
-		fmt.Printf("[DEBUG %s] %s\n", timestamp, message)
+		fmt.Fprintf(Output, "[DEBUG %s] %s\n", timestamp, message)
 	}
 }
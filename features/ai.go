@@ -1,17 +1,16 @@
 package features
 
 import (
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"errors"
 	"fmt"
 	"time"
-)
 
-const cgpaHistoryURL = "https://vtop.vit.ac.in/vtop/examinations/examGradeView/StudentGradeHistory"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
 
 // BuildAIData aggregates VTOP datasets into a single payload for the AI subsystem.
-func BuildAIData(regNo string, cookies types.Cookies) (types.VTOPAIData, error) {
+func BuildAIData(ctx context.Context, regNo string, cookies types.Cookies) (types.VTOPAIData, error) {
 	var data types.VTOPAIData
 
 	// ensure slices stay non-nil so downstream JSON marshalling emits [] instead of null
@@ -23,62 +22,58 @@ func BuildAIData(regNo string, cookies types.Cookies) (types.VTOPAIData, error)
 	data.Leaves = make([]types.LeaveApplication, 0)
 	data.CGPATrend = make([]types.CGPASnapshot, 0)
 
-	if !helpers.ValidateLogin(cookies) {
-		return data, errors.New("invalid login session")
-	}
-
+	s := types.Session{RegNo: regNo, Cookies: cookies}
 	data.RegNo = regNo
 	data.GeneratedAt = time.Now()
 
-	semesters, err := helpers.GetSemDetails(cookies, regNo)
+	// Every fetcher below reuses this list rather than asking VTOP again.
+	semesters, err := FetchSemesters(ctx, s)
 	if err != nil {
 		return data, err
 	}
-	if len(semesters) > 0 {
-		// Use the LAST semester (most recent/current) instead of first (oldest)
-		data.Semester = semesters[len(semesters)-1].SemName
-	}
+	data.Semester = semesters[0].SemName
 
 	var resultErr error
+	opts := types.FetchOptions{}
 
-	if snapshot, err := FetchCgpaSnapshot(regNo, cookies, cgpaHistoryURL); err != nil {
+	if snapshot, err := FetchCGPA(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("cgpa snapshot: %w", err))
 	} else {
 		data.CGPA = snapshot.CGPA
 		data.CGPATrend = append(data.CGPATrend, snapshot)
 	}
 
-	if marks, err := FetchMarksSummary(regNo, cookies); err != nil {
+	if marks, err := FetchMarks(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("marks summary: %w", err))
 	} else if len(marks) > 0 {
 		data.Marks = marks
 	}
 
-	if attendance, err := FetchAttendanceSummary(regNo, cookies); err != nil {
+	if attendance, err := FetchAttendance(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("attendance summary: %w", err))
 	} else if len(attendance) > 0 {
 		data.Attendance = attendance
 	}
 
-	if exams, err := FetchExamScheduleData(regNo, cookies); err != nil {
+	if exams, err := FetchExams(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("exam schedule: %w", err))
 	} else if len(exams) > 0 {
 		data.Exams = exams
 	}
 
-	if timetable, err := FetchTimetableEntries(regNo, cookies); err != nil {
+	if timetable, err := FetchTimetable(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("timetable: %w", err))
-	} else if len(timetable) > 0 {
-		data.Timetable = timetable
+	} else if len(timetable.Classes) > 0 {
+		data.Timetable = timetable.Classes
 	}
 
-	if assignments, err := FetchPendingAssignments(regNo, cookies); err != nil {
+	if assignments, err := FetchPendingAssignments(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("assignments: %w", err))
 	} else if len(assignments) > 0 {
 		data.Assignments = assignments
 	}
 
-	if leaves, err := FetchLeaveStatusSummary(regNo, cookies); err != nil {
+	if leaves, err := FetchLeaves(ctx, s, opts); err != nil {
 		resultErr = errors.Join(resultErr, fmt.Errorf("leave status: %w", err))
 	} else if len(leaves) > 0 {
 		data.Leaves = leaves
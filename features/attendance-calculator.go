@@ -2,16 +2,15 @@
 package features
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	types "cli-top/types"
-	"errors"
+	"context"
 	"fmt"
 	"math"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -29,186 +28,63 @@ var (
 	reProfessor   = regexp.MustCompile(`^(.*?)\s*-\s*`)
 )
 
-// FetchAttendanceSummary gathers attendance statistics for the latest semester without printing output.
-func FetchAttendanceSummary(regNo string, cookies types.Cookies) ([]types.AttendanceRecord, error) {
-	if !helpers.ValidateLogin(cookies) {
-		return nil, errors.New("invalid login session")
-	}
+const attendanceURL = "https://vtop.vit.ac.in/vtop/processViewStudentAttendance"
+
+// FetchAttendance returns attendance for each course in a semester, by
+// default the latest one that has any.
+func FetchAttendance(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.AttendanceRecord, error) {
+	return cached(ctx, s, datasetKey("attendance", opts), func() ([]types.AttendanceRecord, error) {
+		return fetchAttendance(ctx, s, opts)
+	})
+}
 
-	semDetails, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
+func fetchAttendance(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.AttendanceRecord, error) {
+	if err := checkSession(s); err != nil {
 		return nil, err
 	}
-	if len(semDetails) == 0 {
-		return nil, errors.New("no semesters available")
-	}
-
-	url := "https://vtop.vit.ac.in/vtop/processViewStudentAttendance"
-
-	// Start from the LAST semester (most recent/current) instead of first (oldest)
-	for i := len(semDetails) - 1; i >= 0; i-- {
-		semID := semDetails[i].SemID
-		bodyText, err := helpers.FetchReq(regNo, cookies, url, semID, "UTC", "POST", "")
+	return latestWithData(ctx, s, opts, func(sem types.Semester) ([]types.AttendanceRecord, bool, error) {
+		bodyText, err := helpers.VtopFor(ctx).Post(ctx, attendanceURL, helpers.SemesterForm(s.RegNo, s.Cookies, sem.SemID))
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error fetching attendance for semester %s: %v\n", semDetails[i].SemName, err)
-			}
-			continue
+			return nil, false, err
 		}
-
 		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error parsing attendance html for semester %s: %v\n", semDetails[i].SemName, err)
-			}
-			continue
+			return nil, false, err
 		}
-
 		records := parseAttendanceRecords(doc)
-		if len(records) > 0 {
-			return records, nil
-		}
-	}
-
-	return []types.AttendanceRecord{}, nil
+		return records, len(records) > 0, nil
+	})
 }
 
-func GetAttendance(regNo string, cookies types.Cookies, sem_choice int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-	url := "https://vtop.vit.ac.in/vtop/processViewStudentAttendance"
-
-	semDetails, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		if debug.Debug {
-			fmt.Printf("Error fetching semesters: %v\n", err)
-		}
-		fmt.Println("Please login using the cli-top login command.")
+// ShowAttendance prints attendance as a table, or in the --output format.
+func ShowAttendance(records []types.AttendanceRecord) {
+	if structuredOutput(records) {
 		return
 	}
+	renderAttendance(records)
+}
 
-	if len(semDetails) == 0 {
-		fmt.Println("No semesters found.")
-		return
-	}
-
-	var semID string
-	var attendanceList [][]string
-	found := false
-
-	// Iterate from the latest semester to the earliest
-	for i := len(semDetails) - 1; i >= 0; i-- {
-		semID = semDetails[i].SemID
-		bodyText, err := helpers.FetchReq(regNo, cookies, url, semID, "UTC", "POST", "")
-		if err != nil {
-			if debug.Debug {
-				fmt.Printf("Error fetching attendance for Semester %s: %v\n", semDetails[i].SemName, err)
-			}
-			continue // Try the previous semester
-		}
-
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
-		if err != nil {
-			if debug.Debug {
-				fmt.Printf("Error parsing HTML document for Semester %s: %v\n", semDetails[i].SemName, err)
-			}
-			continue // Try the previous semester
-		}
-
-		attendanceList = findAndSaveAttendance(doc)
-
-		// Check if attendance data exists (more than header row)
-		if len(attendanceList) > 1 {
-			found = true
-			if debug.Debug {
-				fmt.Printf("Selected Semester: %s (%s)\n", semDetails[i].SemName, semID)
-			}
-			break
-		} else {
-			if debug.Debug {
-				fmt.Printf("No attendance data found for Semester: %s (%s). Trying previous semester.\n", semDetails[i].SemName, semID)
-			}
-		}
-	}
-
-	// If no attendance data found in any semester
-	if !found {
-		fmt.Println("No attendance data available in any semester.")
+func renderAttendance(records []types.AttendanceRecord) {
+	if len(records) == 0 {
+		fmt.Fprintln(helpers.Messages, "No attendance data available in any semester.")
 		return
 	}
 
-	fmt.Println()
-	helpers.PrintTable(attendanceList, 1)
-	fmt.Println()
-}
-
-func findAndSaveAttendance(doc *goquery.Document) [][]string {
-	var attendanceList [][]string
-	attendanceList = append(attendanceList, []string{"Subject", "Type", "Faculty Name", "Classes Attended", "Percentage", "75% Alert"})
-
-	table := doc.Find(AttendanceTableSelector)
-	if table.Length() > 0 {
-		table.Find(AttendanceRowsSelector).Each(func(i int, rowSelection *goquery.Selection) {
-			sub_name_and_type := rowSelection.Find(AttendanceCellSelector).Eq(2).Find("span").Text()
-			var sub_name string
-			var sub_type string
-			proff := rowSelection.Find(AttendanceCellSelector).Eq(4).Find("span").Text()
-			attended := rowSelection.Find(AttendanceCellSelector).Eq(5).Find("span").Text()
-			total := rowSelection.Find(AttendanceCellSelector).Eq(6).Find("span").Text()
-			percent := rowSelection.Find(AttendanceCellSelector).Eq(7).Find("span").Find("span").Text()
-
-			// Extract Subject Name
-			match := reSubjectName.FindStringSubmatch(sub_name_and_type)
-			if len(match) > 1 {
-				sub_name = strings.TrimSpace(match[1])
-			}
-
-			// Extract Subject Type
-			matchType := reSubjectType.FindString(sub_name_and_type)
-			sub_type = strings.TrimSpace(matchType)
-
-			// Normalize Faculty Name
-			matchProf := reProfessor.FindStringSubmatch(proff)
-
-			if len(matchProf) > 1 {
-				caser := cases.Title(language.English)
-				proff = caser.String(strings.ToLower(matchProf[1]))
-			}
-
-			// Classes Attended
-			classes_attended := attended + "/" + total
-
-			// Convert attended and total to integers
-			attendedInt, err1 := strconv.Atoi(attended)
-			totalInt, err2 := strconv.Atoi(total)
-			if err1 != nil || err2 != nil {
-				if debug.Debug {
-					fmt.Printf("Error converting attendance numbers for subject %s: attended='%s', total='%s'\n", sub_name, attended, total)
-				}
-				return
-			}
-
-			// Calculate 75% Alert
-			var missOrAttend string
-			if sub_type == "Lab Only" || sub_type == "Embedded Lab" {
-				attendedInt = attendedInt / 2
-				totalInt = totalInt / 2
-				missOrAttend = calculateAttendance(attendedInt, totalInt, 1)
-			} else {
-				missOrAttend = calculateAttendance(attendedInt, totalInt, 0)
-			}
-
-			attendanceList = append(attendanceList, []string{sub_name, sub_type, proff, classes_attended, percent, missOrAttend})
+	attendanceList := [][]string{{"Subject", "Type", "Faculty Name", "Classes Attended", "Percentage", "75% Alert"}}
+	for _, r := range records {
+		attendanceList = append(attendanceList, []string{
+			r.CourseName,
+			r.CourseType,
+			r.Faculty,
+			fmt.Sprintf("%d/%d", r.Attended, r.Total),
+			strconv.FormatFloat(r.Percentage, 'f', -1, 64),
+			attendanceAlert(r),
 		})
-	} else {
-		if debug.Debug {
-			fmt.Println("Table with ID 'AttendanceDetailDataTable' not found.")
-		}
-		fmt.Println("No attendance table found for the selected semester.")
 	}
 
-	return attendanceList
+	fmt.Fprintln(helpers.Messages)
+	helpers.PrintTable(attendanceList, 1)
+	fmt.Fprintln(helpers.Messages)
 }
 
 func parseAttendanceRecords(doc *goquery.Document) []types.AttendanceRecord {
@@ -292,28 +168,15 @@ func parseAttendanceRecords(doc *goquery.Document) []types.AttendanceRecord {
 	return records
 }
 
-func calculateAttendance(attended, total, classtype int) string {
-	// Calculate how many more classes need to be attended to meet 74.01% attendance
-	targetAttendance := 0.7401
-	neededAttendance := targetAttendance * float64(total)
-
-	// If the current attendance is already below the target
-	if float64(attended) < neededAttendance {
-		// Calculate the exact number of additional classes required to meet 74.01%
-		x := (neededAttendance - float64(attended)) / (1 - targetAttendance)
-		x = math.Ceil(x) // Round up to ensure they meet the target after attending whole classes
-		if classtype == 1 {
-			return fmt.Sprintf("\033[31mAttend %d more lab(s)\033[0m", int(x))
-		} else {
-			return fmt.Sprintf("\033[31mAttend %d more class(es)\033[0m", int(x))
-		}
-	} else {
-		// If already at or above the target, calculate how many can be missed
-		canMiss := int(math.Floor((float64(attended) - neededAttendance) / targetAttendance))
-		if classtype == 1 {
-			return fmt.Sprintf("\033[32mCan miss %d lab(s)\033[0m", canMiss)
-		} else {
-			return fmt.Sprintf("\033[32mCan miss %d class(es)\033[0m", canMiss)
-		}
+// attendanceAlert says how many classes can be missed, or must be attended,
+// to stay at 74.01%.
+func attendanceAlert(r types.AttendanceRecord) string {
+	unit := "class(es)"
+	if r.CourseType == "Lab Only" || r.CourseType == "Embedded Lab" {
+		unit = "lab(s)"
+	}
+	if r.Buffer < 0 {
+		return fmt.Sprintf("\033[31mAttend %d more %s\033[0m", -r.Buffer, unit)
 	}
+	return fmt.Sprintf("\033[32mCan miss %d %s\033[0m", r.Buffer, unit)
 }
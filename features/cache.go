@@ -0,0 +1,89 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/store"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+// datasetKey names where a dataset is saved: by semester when opts picks
+// one, and otherwise as the latest semester.
+func datasetKey(dataset string, opts types.FetchOptions) string {
+	if opts.Semester.SemID == "" {
+		return dataset
+	}
+	return dataset + "/" + opts.Semester.SemID
+}
+
+// cached returns the dataset saved under key in the store for ctx when
+// --offline or --max-age allow it, and otherwise calls fetch and saves the
+// result. When VTOP cannot be reached, a saved copy is returned instead of
+// the error. Datasets with a history also keep the result as a new version
+// when it has changed.
+func cached[T any](ctx context.Context, s types.Session, key string, fetch func() (T, error)) (T, error) {
+	db := store.For(ctx)
+	dataset, _, _ := strings.Cut(key, "/")
+
+	var result T
+	if !db.Enabled() {
+		return fetch()
+	}
+	if db.Offline || db.MaxAge > 0 {
+		entry, err := db.Get(s.RegNo, key)
+		switch {
+		case err == nil && (db.Offline || time.Since(entry.FetchedAt) <= db.MaxAge):
+			return fromStore[T](db, entry)
+		case db.Offline && errors.Is(err, store.ErrNotSaved):
+			return result, fmt.Errorf("%w of %s; run the command once without --offline", store.ErrNotSaved, dataset)
+		case db.Offline:
+			return result, err
+		}
+	}
+
+	result, err := fetch()
+	if err != nil {
+		if unreachable(err) {
+			if entry, serr := db.Get(s.RegNo, key); serr == nil {
+				debug.Log(fmt.Sprintf("Using the saved %s: %v", dataset, err))
+				return fromStore[T](db, entry)
+			}
+		}
+		return result, err
+	}
+	save := db.Put
+	if _, ok := datasetDiffs[dataset]; ok {
+		save = db.PutVersion
+	}
+	if err := save(s.RegNo, key, result); err != nil {
+		debug.Log(fmt.Sprintf("Could not save %s: %v", dataset, err))
+	}
+	return result, nil
+}
+
+func fromStore[T any](db *store.Store, entry store.Entry) (T, error) {
+	var result T
+	if err := json.Unmarshal(entry.Data, &result); err != nil {
+		return result, fmt.Errorf("reading saved data: %w", err)
+	}
+	db.Served(entry.FetchedAt)
+	return result, nil
+}
+
+// unreachable reports whether err means VTOP could not serve the data at
+// all, as opposed to the request or session being wrong.
+func unreachable(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.Is(err, helpers.ErrMaintenance) || errors.Is(err, helpers.ErrUnavailable) ||
+		errors.Is(err, helpers.ErrEmptyResponse) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
@@ -1,107 +1,177 @@
 package features
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
-func PrintCal(regNo string, cookies types.Cookies, sem_choice int, classGrpFlag int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
+// FetchClassGroups lists the class groups with an academic calendar in the
+// semester in opts.
+func FetchClassGroups(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.ClassGroup, error) {
+	return cached(ctx, s, datasetKey("class-groups", opts), func() ([]types.ClassGroup, error) {
+		if err := checkSession(s); err != nil {
+			return nil, err
+		}
+		return classGroups(ctx, s.RegNo, s.Cookies, opts.Semester)
+	})
+}
+
+func classGroups(ctx context.Context, regNo string, cookies types.Cookies, semester types.Semester) ([]types.ClassGroup, error) {
+	url := "https://vtop.vit.ac.in/vtop/getDateForSemesterPreview"
+	payloadMap := map[string]string{
+		"_csrf":         cookies.CSRF,
+		"paramReturnId": "getDateForSemesterPreview",
+		"semSubId":      semester.SemID,
+		"authorizedID":  regNo,
+		"x":             fmt.Sprintf("%d", time.Now().Unix()),
+	}
+	formData := helpers.FormatBodyData(payloadMap)
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, url, formData)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
+	if err != nil {
+		return nil, err
+	}
+	var groups []types.ClassGroup
+	for _, grp := range extractclassgrp(doc) {
+		groups = append(groups, types.ClassGroup{Name: grp[0], ID: grp[1]})
+	}
+	return groups, nil
+}
+
+// SelectClassGroup asks for one of groups from FetchClassGroups, or takes it
+// from --class-group.
+func SelectClassGroup(groups []types.ClassGroup, classGrpFlag int) (types.ClassGroup, error) {
+	if len(groups) == 0 {
+		return types.ClassGroup{}, fmt.Errorf("no class groups found")
+	}
+	grp_list := [][]string{{"CLASS GROUP"}}
+	for _, grp := range groups {
+		grp_list = append(grp_list, []string{grp.Name})
+	}
+	result := helpers.TableSelector("class group", grp_list, strconv.Itoa(classGrpFlag))
+	if result.ExitRequest || !result.Selected || result.Index < 1 || result.Index > len(groups) {
+		return types.ClassGroup{}, fmt.Errorf("selection canceled by user")
 	}
+	return groups[result.Index-1], nil
+}
+
+// FetchCalendar returns group's academic calendar for the semester in opts,
+// a month at a time.
+func FetchCalendar(ctx context.Context, s types.Session, opts types.FetchOptions, group types.ClassGroup) ([]types.CalendarMonth, error) {
+	return cached(ctx, s, datasetKey("calendar", opts)+"/"+group.ID, func() ([]types.CalendarMonth, error) {
+		return fetchCalendar(ctx, s, opts, group)
+	})
+}
 
-	semester, err := helpers.SelectSemester(regNo, cookies, sem_choice)
+func fetchCalendar(ctx context.Context, s types.Session, opts types.FetchOptions, group types.ClassGroup) ([]types.CalendarMonth, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	datelist, err := calendarDates(ctx, s.RegNo, s.Cookies, opts.Semester, group.ID)
 	if err != nil {
-		if err.Error() == "selection canceled by user" {
-			fmt.Println("Selection canceled")
-			return
+		return nil, err
+	}
+	var months []types.CalendarMonth
+	for _, date := range datelist {
+		doc, err := calendarMonth(ctx, s.RegNo, s.Cookies, opts.Semester, group.ID, date)
+		if err != nil {
+			return nil, err
 		}
-		if debug.Debug {
-			fmt.Println(err)
+		month := types.CalendarMonth{Month: date[3:6], Year: date[7:]}
+		for _, kind := range extractTypeOfDay(doc, make([]int, 31)) {
+			month.Days = append(month.Days, types.CalendarDay(kind))
 		}
-		fmt.Println("Error selecting semester:", err)
-		return
+		months = append(months, month)
 	}
+	return months, nil
+}
 
-	grp_list := getClassGroups(regNo, cookies, semester)
-	if len(grp_list) == 0 {
-		fmt.Println("No class groups found")
+// ShowCalendar prints months with each day coloured by what it is, a row of
+// months per year, or prints them in the --output format.
+func ShowCalendar(months []types.CalendarMonth) {
+	if structuredOutput(months) {
 		return
 	}
-
-	grp_list = append([][]string{{"CLASS GROUP"}}, grp_list...)
-	result := helpers.TableSelector("class group", grp_list, strconv.Itoa(classGrpFlag))
-	if result.ExitRequest || !result.Selected {
-		fmt.Println("Selection canceled")
+	if len(months) == 0 {
+		fmt.Fprintln(helpers.Messages, "No months found")
 		return
 	}
-	grp := result.Index
 
-	datelist := getDateList(regNo, cookies, semester, grp_list[grp][1])
-	if len(datelist) == 0 {
-		fmt.Println("No months found")
-		return
+	fmt.Fprintln(helpers.Messages, "\033[31mRed-Exam Day\033[0m\n\033[34mBlue-Holiday\033[0m\n\033[32mGreen-Instructional Day\033[0m\n\033[33mYellow-Today\033[0m")
+	for start := 0; start < len(months); {
+		end := start
+		var names []string
+		var color_list [][]int
+		for ; end < len(months) && months[end].Year == months[start].Year; end++ {
+			var days []int
+			for _, day := range months[end].Days {
+				days = append(days, int(day))
+			}
+			names = append(names, months[end].Month)
+			color_list = append(color_list, days)
+		}
+		addPadding(&color_list)
+		renderMonths(names, months[start].Year, color_list)
+		start = end
 	}
-	processDates(regNo, cookies, semester, grp_list[grp][1], datelist, 1)
 }
 
-func getClassGroups(regNo string, cookies types.Cookies, semester types.Semester) [][]string {
-	url := "https://vtop.vit.ac.in/vtop/getDateForSemesterPreview"
+func calendarDates(ctx context.Context, regNo string, cookies types.Cookies, semester types.Semester, grp string) ([]string, error) {
+	url := "https://vtop.vit.ac.in/vtop/getListForSemester"
 	payloadMap := map[string]string{
 		"_csrf":         cookies.CSRF,
-		"paramReturnId": "getDateForSemesterPreview",
+		"paramReturnId": "getListForSemester",
 		"semSubId":      semester.SemID,
+		"classGroupId":  grp,
 		"authorizedID":  regNo,
 		"x":             fmt.Sprintf("%d", time.Now().Unix()),
 	}
 	formData := helpers.FormatBodyData(payloadMap)
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, semester.SemID, formData, "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, url, formData)
+	if err != nil {
+		return nil, err
 	}
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return nil, err
 	}
-	return extractclassgrp(doc)
+	return readmonths(doc), nil
 }
 
-func getDateList(regNo string, cookies types.Cookies, semester types.Semester, grp string) []string {
-	url := "https://vtop.vit.ac.in/vtop/getListForSemester"
+func calendarMonth(ctx context.Context, regNo string, cookies types.Cookies, semester types.Semester, grp string, date string) (*goquery.Document, error) {
+	url := "https://vtop.vit.ac.in/vtop/processViewCalendar"
 	payloadMap := map[string]string{
-		"_csrf":         cookies.CSRF,
-		"paramReturnId": "getListForSemester",
-		"semSubId":      semester.SemID,
-		"classGroupId":  grp,
-		"authorizedID":  regNo,
-		"x":             fmt.Sprintf("%d", time.Now().Unix()),
+		"_csrf":        cookies.CSRF,
+		"calDate":      date,
+		"semSubId":     semester.SemID,
+		"classGroupId": grp,
+		"authorizedID": regNo,
+		"x":            fmt.Sprintf("%d", time.Now().Unix()),
 	}
 	formData := helpers.FormatBodyData(payloadMap)
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, semester.SemID, formData, "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println(err)
-	}
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, url, formData)
+	if err != nil {
+		return nil, err
 	}
-	return readmonths(doc)
+	return goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
 }
 
-func processDates(regNo string, cookies types.Cookies, semester types.Semester, grp string, datelist []string, flag int) ([][]int, int, int) {
-	var months []string
+// processDates fetches the semester's calendar from datelist and returns, for
+// each month, the day order each day follows, along with the first month and
+// its year.
+func processDates(ctx context.Context, regNo string, cookies types.Cookies, semester types.Semester, grp string, datelist []string) ([][]int, int, int) {
 	year := datelist[0][7:]
-	var color_list [][]int
-	if flag == 1 {
-		fmt.Println("\033[31mRed-Exam Day\033[0m\n\033[34mBlue-Holiday\033[0m\n\033[32mGreen-Instructional Day\033[0m\n\033[33mYellow-Today\033[0m")
-	}
 	isLeapYear := func(year int) bool {
 		if year%4 == 0 {
 			if year%100 == 0 {
@@ -114,7 +184,7 @@ func processDates(regNo string, cookies types.Cookies, semester types.Semester,
 	// Convert year string to integer
 	yearInt, err := strconv.Atoi(year)
 	if err != nil {
-		fmt.Println("Invalid year:", year)
+		fmt.Fprintln(helpers.Messages, "Invalid year:", year)
 		return nil, -1, -1
 	}
 
@@ -144,7 +214,7 @@ func processDates(regNo string, cookies types.Cookies, semester types.Semester,
 	startMonthStr := datelist[0][3:6]
 	startMonth, ok := monthMap[startMonthStr]
 	if !ok {
-		fmt.Println("Invalid month:", startMonthStr)
+		fmt.Fprintln(helpers.Messages, "Invalid month:", startMonthStr)
 		return nil, -1, -1
 	}
 	// Create the nested array with each sublist having the number of days of the month
@@ -156,41 +226,14 @@ func processDates(regNo string, cookies types.Cookies, semester types.Semester,
 	}
 
 	for i, date := range datelist {
-		if year != date[7:] {
-			addPadding(&color_list)
-			if flag == 1 {
-				renderMonths(months, year, color_list)
+		doc, err := calendarMonth(ctx, regNo, cookies, semester, grp, date)
+		if err != nil {
+			if debug.Debug {
+				fmt.Fprintln(helpers.Messages, err)
 			}
-			months = []string{}
-			color_list = [][]int{}
-			year = ""
-		}
-		url := "https://vtop.vit.ac.in/vtop/processViewCalendar"
-		payloadMap := map[string]string{
-			"_csrf":        cookies.CSRF,
-			"calDate":      date,
-			"semSubId":     semester.SemID,
-			"classGroupId": grp,
-			"authorizedID": regNo,
-			"x":            fmt.Sprintf("%d", time.Now().Unix()),
-		}
-		formData := helpers.FormatBodyData(payloadMap)
-		bodyText, err := helpers.FetchReq(regNo, cookies, url, semester.SemID, formData, "POST", "")
-		if err != nil && debug.Debug {
-			fmt.Println(err)
-		}
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
-		if err != nil && debug.Debug {
-			fmt.Println(err)
+			continue
 		}
-		typeOfDay := extractTypeOfDay(doc, return_list[i])
-		color_list = append(color_list, typeOfDay)
-		months = append(months, date[3:6])
-		year = date[7:]
-	}
-	addPadding(&color_list)
-	if flag == 1 {
-		renderMonths(months, year, color_list)
+		extractTypeOfDay(doc, return_list[i])
 	}
 	return return_list, startMonth, yearInt
 }
@@ -266,16 +309,16 @@ func renderMonths(months []string, year string, nestedColour [][]int) {
 	yearHeader := fmt.Sprintf(" %s%s", strings.Repeat(" ", spaceSize), year)
 	maxLength := 20
 	yearHeader = fmt.Sprintf("%-*s", (maxLength*len(months)+len(yearHeader))/2, yearHeader)
-	fmt.Println(yearHeader)
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages, yearHeader)
+	fmt.Fprintln(helpers.Messages)
 	for row := 0; row < len(calendars[0]); row++ {
 		for i := 0; i < len(months); i++ {
-			fmt.Print(calendars[i][row])
+			fmt.Fprint(helpers.Messages, calendars[i][row])
 			if i < len(months)-1 {
-				fmt.Print("    ")
+				fmt.Fprint(helpers.Messages, "    ")
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages)
 	}
 }
 
@@ -355,6 +398,9 @@ func extractclassgrp(doc *goquery.Document) [][]string {
 			grp_list = append(grp_list, []string{text, value})
 		})
 	})
-	grp_list = grp_list[1:]
-	return grp_list
+	if len(grp_list) == 0 {
+		return nil
+	}
+	// The first option is the "Select" placeholder.
+	return grp_list[1:]
 }
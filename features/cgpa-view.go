@@ -1,14 +1,14 @@
 package features
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -29,74 +29,53 @@ const (
 	NGradesIndex           = 10
 )
 
-func PrintCgpa(regNo string, cookies types.Cookies, url string) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
+const cgpaHistoryURL = "https://vtop.vit.ac.in/vtop/examinations/examGradeView/StudentGradeHistory"
 
-	// Fetch the CGPA data
-	body, err := helpers.FetchReq(regNo, cookies, url, "", "", "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println("Error fetching CGPA data:", err)
-		return
-	}
-
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
-	if err != nil && debug.Debug {
-		fmt.Println("Error parsing HTML:", err)
+// ShowCGPA prints the CGPA summary, or the snapshot in the --output format.
+func ShowCGPA(snapshot types.CGPASnapshot) {
+	if structuredOutput(snapshot) {
 		return
 	}
+	renderCGPA(snapshot)
+}
 
-	// Extract and print data from the specified HTML structure
-	table := doc.Find(CGPATableSelector + " " + CGPARowsSelector)
-	row := table.First()
-
-	// Extract data from each column
-	creditsRegistered := row.Find(CGPACellSelector).Eq(CreditsRegisteredIndex).Text()
-	creditsEarned := row.Find(CGPACellSelector).Eq(CreditsEarnedIndex).Text()
-	cgpa := row.Find(CGPACellSelector).Eq(CGPAIndex).Text()
-	sGrades := row.Find(CGPACellSelector).Eq(SGradesIndex).Text()
-	aGrades := row.Find(CGPACellSelector).Eq(AGradesIndex).Text()
-	bGrades := row.Find(CGPACellSelector).Eq(BGradesIndex).Text()
-	cGrades := row.Find(CGPACellSelector).Eq(CGradesIndex).Text()
-	dGrades := row.Find(CGPACellSelector).Eq(DGradesIndex).Text()
-	eGrades := row.Find(CGPACellSelector).Eq(EGradesIndex).Text()
-	fGrades := row.Find(CGPACellSelector).Eq(FGradesIndex).Text()
-	nGrades := row.Find(CGPACellSelector).Eq(NGradesIndex).Text()
-
-	// Create a nested list for grades
+func renderCGPA(snapshot types.CGPASnapshot) {
 	gradesTableData := [][]string{
 		{"Grade", "Count"},
-		{"S Grades", sGrades},
-		{"A Grades", aGrades},
-		{"B Grades", bGrades},
-		{"C Grades", cGrades},
-		{"D Grades", dGrades},
-		{"E Grades", eGrades},
-		{"F Grades", fGrades},
-		{"N Grades", nGrades},
+		{"S Grades", strconv.Itoa(snapshot.SGrades)},
+		{"A Grades", strconv.Itoa(snapshot.AGrades)},
+		{"B Grades", strconv.Itoa(snapshot.BGrades)},
+		{"C Grades", strconv.Itoa(snapshot.CGrades)},
+		{"D Grades", strconv.Itoa(snapshot.DGrades)},
+		{"E Grades", strconv.Itoa(snapshot.EGrades)},
+		{"F Grades", strconv.Itoa(snapshot.FGrades)},
+		{"N Grades", strconv.Itoa(snapshot.NGrades)},
 	}
 
-	// Print the grades table
-	fmt.Println()
-	fmt.Printf("\nCredits Registered: %s\n", creditsRegistered)
-	fmt.Printf("Credits Earned: %s\n", creditsEarned)
-	fmt.Printf("CGPA: \033[32m%s\033[0m\n", cgpa) // Highlight CGPA in green
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
+	fmt.Fprintf(helpers.Messages, "\nCredits Registered: %d\n", snapshot.CreditsRegistered)
+	fmt.Fprintf(helpers.Messages, "Credits Earned: %d\n", snapshot.CreditsEarned)
+	fmt.Fprintf(helpers.Messages, "CGPA: \033[32m%.2f\033[0m\n", snapshot.CGPA) // Highlight CGPA in green
+	fmt.Fprintln(helpers.Messages)
 	helpers.PrintTable(gradesTableData, 0)
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
+}
 
-	// Print the credits and CGPA information in line format
+// FetchCGPA returns the student's CGPA, credits and grade counts from the
+// grade history page.
+func FetchCGPA(ctx context.Context, s types.Session, opts types.FetchOptions) (types.CGPASnapshot, error) {
+	return cached(ctx, s, datasetKey("cgpa", opts), func() (types.CGPASnapshot, error) {
+		return fetchCGPA(ctx, s, opts)
+	})
 }
 
-// FetchCgpaSnapshot retrieves CGPA details without printing to stdout.
-func FetchCgpaSnapshot(regNo string, cookies types.Cookies, url string) (types.CGPASnapshot, error) {
+func fetchCGPA(ctx context.Context, s types.Session, opts types.FetchOptions) (types.CGPASnapshot, error) {
 	var snapshot types.CGPASnapshot
-	if !helpers.ValidateLogin(cookies) {
-		return snapshot, errors.New("invalid login session")
+	if err := checkSession(s); err != nil {
+		return snapshot, err
 	}
 
-	body, err := helpers.FetchReq(regNo, cookies, url, "", "", "POST", "")
+	body, err := helpers.VtopFor(ctx).Post(ctx, cgpaHistoryURL, helpers.MenuForm(s.RegNo, s.Cookies))
 	if err != nil {
 		return snapshot, err
 	}
@@ -2,13 +2,13 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -17,70 +17,82 @@ const (
 	MessageBodySelector    = "div.panel-body"
 )
 
-func GetClassMessage(regNo string, cookies types.Cookies) {
-	if !helpers.ValidateLogin(cookies) {
-		return
+const classMessageURL = "https://vtop.vit.ac.in/vtop/academics/common/StudentClassMessage"
+
+// FetchClassMessages returns the messages faculty have posted to the
+// student's classes.
+func FetchClassMessages(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.ClassMessage, error) {
+	return cached(ctx, s, datasetKey("messages", opts), func() ([]types.ClassMessage, error) {
+		return fetchClassMessages(ctx, s, opts)
+	})
+}
+
+func fetchClassMessages(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.ClassMessage, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
 	}
-	url := "https://vtop.vit.ac.in/vtop/academics/common/StudentClassMessage"
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, "", "UTC", "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println(err)
-		return
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, classMessageURL, helpers.SemesterForm(s.RegNo, s.Cookies, opts.Semester.SemID))
+	if err != nil {
+		return nil, err
 	}
+	return extractClassMessages(bodyText)
+}
 
-	messages, err := extractClassMessages(bodyText)
-	if err != nil && debug.Debug {
-		fmt.Println("Error extracting messages:", err)
+// ShowClassMessages prints messages as a table, or in the --output format.
+func ShowClassMessages(messages []types.ClassMessage) {
+	if structuredOutput(messages) {
 		return
 	}
+	renderClassMessages(messages)
+}
 
-	if len(messages) == 1 {
-		fmt.Println("No class messages found")
+func renderClassMessages(messages []types.ClassMessage) {
+	if len(messages) == 0 {
+		fmt.Fprintln(helpers.Messages, "No class messages found")
 		return
 	}
-	fmt.Println()
-	helpers.PrintTable(messages, 1)
-	fmt.Println()
+	table := [][]string{{"Course", "Message"}}
+	for _, m := range messages {
+		table = append(table, []string{wrapText(m.Course, 60), wrapText(m.Message, 60)})
+	}
+	fmt.Fprintln(helpers.Messages)
+	helpers.PrintTable(table, 1)
+	fmt.Fprintln(helpers.Messages)
 }
 
-func extractClassMessages(bodyText []byte) ([][]string, error) {
-	var messages [][]string
-	messages = append(messages, []string{"Course", "Message"})
+var classMessagePrefix = regexp.MustCompile(`^[A-Z0-9]+ - | - Online Course`)
+
+func extractClassMessages(bodyText []byte) ([]types.ClassMessage, error) {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
 	if err != nil {
 		return nil, fmt.Errorf("error parsing HTML: %v", err)
 	}
 
-	re := regexp.MustCompile(`^[A-Z0-9]+ - | - Online Course`)
-
+	var messages []types.ClassMessage
 	doc.Find(MessageHeadingSelector).Each(func(i int, h5 *goquery.Selection) {
-		var row []string
+		var parts []string
 		h5.Find("span").Each(func(i int, span *goquery.Selection) {
-			trimmedText := strings.TrimSpace(span.Text())
-			cleanedText := re.ReplaceAllString(trimmedText, "")
-			cleanedText = strings.ReplaceAll(strings.TrimSpace(cleanedText), "\n", " ")
-
-			var messageLines []string
-			for len(cleanedText) > 60 {
-				cutIndex := 60
-
-				if spaceIndex := strings.LastIndex(cleanedText[:cutIndex], " "); spaceIndex != -1 {
-					cutIndex = spaceIndex
-				}
-				messageLines = append(messageLines, cleanedText[:cutIndex])
-				cleanedText = strings.TrimSpace(cleanedText[cutIndex:])
-			}
-			messageLines = append(messageLines, cleanedText)
-
-			row = append(row, strings.Join(messageLines, "\n"))
+			cleanedText := classMessagePrefix.ReplaceAllString(strings.TrimSpace(span.Text()), "")
+			parts = append(parts, strings.ReplaceAll(strings.TrimSpace(cleanedText), "\n", " "))
 		})
-		if len(row) == 2 {
-			messages = append(messages, row)
+		if len(parts) == 2 {
+			messages = append(messages, types.ClassMessage{Course: parts[0], Message: parts[1]})
 		}
 	})
+	return messages, nil
+}
 
-	if len(messages) == 0 {
-		return nil, fmt.Errorf("no class messages found")
+// wrapText breaks text into lines of at most width bytes, at spaces where
+// possible.
+func wrapText(text string, width int) string {
+	var lines []string
+	for len(text) > width {
+		cutIndex := width
+		if spaceIndex := strings.LastIndex(text[:cutIndex], " "); spaceIndex != -1 {
+			cutIndex = spaceIndex
+		}
+		lines = append(lines, text[:cutIndex])
+		text = strings.TrimSpace(text[cutIndex:])
 	}
-	return messages, nil
+	return strings.Join(append(lines, text), "\n")
 }
@@ -4,9 +4,7 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -21,6 +19,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/schollz/progressbar/v3"
 )
@@ -33,28 +34,13 @@ const (
 	CourseCellSelector   = "td"
 )
 
-var newHttpClient *http.Client
-
-func init() {
-	newHttpClient = &http.Client{
-		Timeout: time.Duration(60) * time.Second,
-	}
-}
-
-func ExecuteCoursePageDownload(regNo string, cookies types.Cookies, semesterFlag int, courseFlag int, facultyFlag string, fuzzyFlag int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-
-	selectedCourse, err := fetchAndSelectCourse(regNo, cookies, courseFlag)
-	if err != nil {
-		fmt.Println("Error selecting course:", err)
-		return
-	}
-
-	materials, faculty, err := fetchFacultieswithMaterials(regNo, cookies, selectedCourse.ID, selectedCourse.Name, facultyFlag)
+// DownloadCoursePageMaterials asks whose uploads for course, from
+// FetchCourseMaterials, to look at and which of them to download, then
+// downloads them.
+func DownloadCoursePageMaterials(ctx context.Context, s types.Session, course types.Course, uploads []types.FacultyMaterials, facultyFlag string) {
+	materials, faculty, err := selectFacultyMaterials(uploads, facultyFlag)
 	if err != nil {
-		fmt.Println("Error fetching faculties:", err)
+		fmt.Fprintln(helpers.Messages, "Error selecting faculty:", err)
 		return
 	}
 
@@ -62,46 +48,51 @@ func ExecuteCoursePageDownload(regNo string, cookies types.Cookies, semesterFlag
 
 	selectedMaterials, err := selectCourseMaterials(materials)
 	if err != nil {
-		fmt.Println("Error selecting materials:", err)
+		fmt.Fprintln(helpers.Messages, "Error selecting materials:", err)
 		return
 	}
 
-	err = downloadMaterialsHope(regNo, cookies, selectedCourse, materials, selectedMaterials, faculty)
+	err = downloadMaterialsHope(ctx, s.RegNo, s.Cookies, course, materials, selectedMaterials, faculty)
 	if err != nil {
-		fmt.Printf("Error downloading materials: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "Error downloading materials: %v\n", err)
 		return
 	}
 
-	fmt.Println("\nDownload complete!")
+	fmt.Fprintln(helpers.Messages, "\nDownload complete!")
+}
+
+// FetchCoursePageCourses lists every course on the consolidated course page
+// along with the semester it was offered in.
+func FetchCoursePageCourses(ctx context.Context, s types.Session) ([]types.CoursePageCourse, error) {
+	return cached(ctx, s, "courses", func() ([]types.CoursePageCourse, error) {
+		return fetchCoursePageCourses(ctx, s)
+	})
 }
 
-func fetchAndSelectCourse(regNo string, cookies types.Cookies, courseFlag int) (types.Course, error) {
+func fetchCoursePageCourses(ctx context.Context, s types.Session) ([]types.CoursePageCourse, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
 	getCourseURL := "https://vtop.vit.ac.in/vtop/academics/common/CoursePageConsolidated"
 	payloadMap := map[string]string{
-		"_csrf":        cookies.CSRF,
-		"authorizedID": regNo,
+		"_csrf":        s.Cookies.CSRF,
+		"authorizedID": s.RegNo,
 		"x":            time.Now().UTC().Format(time.RFC1123),
 		"verifyMenu":   "true",
 	}
 
 	formData := helpers.FormatBodyDataClient(payloadMap)
-	body, _, err := helpers.FetchReqClient(newHttpClient, regNo, cookies, getCourseURL, "", formData, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Post(ctx, getCourseURL, string(formData))
 	if err != nil {
-		return types.Course{}, err
+		return nil, err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		return types.Course{}, err
+		return nil, err
 	}
 
-	type courseWithSem struct {
-		Semester string
-		Course   types.Course
-	}
-
-	var courses []courseWithSem
-	semSet := make(map[string]struct{})
+	var courses []types.CoursePageCourse
 	doc.Find("select#courseId option").Each(func(_ int, s *goquery.Selection) {
 		value, exists := s.Attr("value")
 		if exists && value != "" {
@@ -114,15 +105,28 @@ func fetchAndSelectCourse(regNo string, cookies types.Cookies, courseFlag int) (
 			if semester == "" {
 				semester = "Unknown Semester"
 			}
-			semSet[semester] = struct{}{}
-			courses = append(courses, courseWithSem{
+			courses = append(courses, types.CoursePageCourse{
 				Semester: semester,
 				Course:   types.Course{ID: value, Name: text},
 			})
 		}
 	})
+	return courses, nil
+}
+
+// SelectCoursePageCourse asks for a semester and then one of its courses
+// from FetchCoursePageCourses, or takes the course from --course.
+func SelectCoursePageCourse(courses []types.CoursePageCourse, courseFlag int) (types.Course, error) {
+	if len(courses) == 0 {
+		return types.Course{}, fmt.Errorf("no courses found")
+	}
+
+	semSet := make(map[string]struct{})
+	for _, c := range courses {
+		semSet[c.Semester] = struct{}{}
+	}
 	// // Merge semester names from semDetails into semSet, but remove duplicates by normalizing.
-	// semDetails, err := helpers.GetSemDetails(cookies, regNo)
+	// semDetails, err := helpers.GetSemDetails(ctx, cookies, regNo)
 	// if err == nil && len(semDetails) > 0 {
 	// 	for _, sem := range semDetails {
 	// 		// Normalize by removing trailing " - VLR" if present
@@ -210,7 +214,7 @@ func fetchAndSelectCourse(regNo string, cookies types.Cookies, courseFlag int) (
 
 	// if fallIdx != -1 {
 	// 	 if semResult.Index-1 < fallIdx {
-	// 		coursePageOldAfterSemSelection(regNo, cookies, selectedSemester, courseFlag, facultyFlag)
+	// 		coursePageOldAfterSemSelection(ctx, regNo, cookies, selectedSemester, courseFlag, facultyFlag)
 	// 	 }
 	// }
 
@@ -253,38 +257,45 @@ func fetchAndSelectCourse(regNo string, cookies types.Cookies, courseFlag int) (
 	return selectedCourse, nil
 }
 
-func fetchFacultieswithMaterials(regNo string, cookies types.Cookies, courseID string, courseName string, facultyFlag string) ([]types.CourseMaterial, types.Faculty, error) {
+// FetchCourseMaterials returns the material each faculty has uploaded for a
+// course from FetchCoursePageCourses, sorted by faculty name.
+func FetchCourseMaterials(ctx context.Context, s types.Session, course types.Course) ([]types.FacultyMaterials, error) {
+	return cached(ctx, s, "course-materials/"+course.ID, func() ([]types.FacultyMaterials, error) {
+		return fetchCourseMaterials(ctx, s, course)
+	})
+}
+
+func fetchCourseMaterials(ctx context.Context, s types.Session, course types.Course) ([]types.FacultyMaterials, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
 	getFacultyMaterialURL := "https://vtop.vit.ac.in/vtop/academics/CoursePageConsolidated/getCourseDetail"
 	// Extract course type from courseName (assumed format: "Semester - CourseCode - CourseTitle - ...")
-	parts := strings.Split(courseName, " - ")
+	parts := strings.Split(course.Name, " - ")
 	if len(parts) < 3 {
-		return nil, types.Faculty{}, fmt.Errorf("invalid course name format")
+		return nil, fmt.Errorf("invalid course name format")
 	}
 	courseType := strings.TrimSpace(parts[len(parts)-3])
 	payloadMap := map[string]string{
-		"_csrf":        cookies.CSRF,
-		"CourseId":     courseID,
+		"_csrf":        s.Cookies.CSRF,
+		"CourseId":     course.ID,
 		"CoursType":    courseType,
-		"authorizedID": regNo,
+		"authorizedID": s.RegNo,
 		"x":            time.Now().UTC().Format(time.RFC1123),
 	}
 	formData := helpers.FormatBodyDataClient(payloadMap)
-	body, _, err := helpers.FetchReqClient(newHttpClient, regNo, cookies, getFacultyMaterialURL, "", formData, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Post(ctx, getFacultyMaterialURL, string(formData))
 	if err != nil {
-		return nil, types.Faculty{}, err
+		return nil, err
 	}
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		return nil, types.Faculty{}, err
+		return nil, err
 	}
 
-	// Temporary struct to hold faculty name (raw) and the material
-	type facultyMaterial struct {
-		Faculty  string
-		Material types.CourseMaterial
-	}
-	var allMaterials []facultyMaterial
-	facultySet := make(map[string]struct{})
+	// Materials are listed per faculty name; raw faculty cells are in the
+	// format "ERPID - Faculty Name - SCOPE".
+	byFaculty := make(map[string]*types.FacultyMaterials)
 
 	rows := doc.Find("table#materialTable tbody tr")
 	rows.Each(func(i int, row *goquery.Selection) {
@@ -301,10 +312,11 @@ func fetchFacultieswithMaterials(regNo string, cookies types.Cookies, courseID s
 		}
 		rawFaculty := strings.TrimSpace(facultySpans.Eq(0).Text())
 		date := strings.TrimSpace(facultySpans.Eq(1).Text())
-		if rawFaculty == "" {
+		fParts := strings.Split(rawFaculty, " - ")
+		if len(fParts) < 3 {
 			return
 		}
-		facultySet[rawFaculty] = struct{}{}
+		name := strings.TrimSpace(fParts[1])
 
 		// Get the material info
 		indexStr := strings.TrimSpace(cells.Eq(0).Text())
@@ -354,71 +366,50 @@ func fetchFacultieswithMaterials(regNo string, cookies types.Cookies, courseID s
 			},
 			MNo: mNo,
 		}
-		allMaterials = append(allMaterials, facultyMaterial{
-			Faculty:  rawFaculty,
-			Material: material,
-		})
-	})
 
-	// Build unique faculty list from facultySet.
-	// We assume each raw faculty is in the format "ERPID - Faculty Name - SCOPE"
-	var facultyList []types.Faculty
-	for raw := range facultySet {
-		parts := strings.Split(raw, " - ")
-		if len(parts) < 3 {
-			continue
-		}
-		erpID := strings.TrimSpace(parts[0])
-		name := strings.TrimSpace(parts[1])
-		facultyItem := types.Faculty{
-			Name:         name,
-			ErpID:        erpID,
-			SemesterName: courseName, // using courseName as provided
-			CourseName:   courseName,
+		fm, ok := byFaculty[name]
+		if !ok {
+			fm = &types.FacultyMaterials{Faculty: types.Faculty{
+				Name:         name,
+				ErpID:        strings.TrimSpace(fParts[0]),
+				SemesterName: course.Name, // using courseName as provided
+				CourseName:   course.Name,
+			}}
+			byFaculty[name] = fm
 		}
-		facultyList = append(facultyList, facultyItem)
+		fm.Materials = append(fm.Materials, material)
+	})
+
+	uploads := make([]types.FacultyMaterials, 0, len(byFaculty))
+	for _, fm := range byFaculty {
+		uploads = append(uploads, *fm)
 	}
-	sort.Slice(facultyList, func(i, j int) bool {
-		return facultyList[i].Name < facultyList[j].Name
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Faculty.Name < uploads[j].Faculty.Name
 	})
+	return uploads, nil
+}
 
+func selectFacultyMaterials(uploads []types.FacultyMaterials, facultyFlag string) ([]types.CourseMaterial, types.Faculty, error) {
 	// If nothing uploaded for this course, error out explicitly
-	if len(facultyList) == 0 {
+	if len(uploads) == 0 {
 		return nil, types.Faculty{}, fmt.Errorf("no material uploaded")
 	}
 
 	// Prompt the user to select a faculty
 	nestedList := [][]string{{"FACULTY"}}
-	for _, f := range facultyList {
-		nestedList = append(nestedList, []string{f.Name})
+	for _, fm := range uploads {
+		nestedList = append(nestedList, []string{fm.Faculty.Name})
 	}
 	result := helpers.TableSelectorFuzzy("Faculty", nestedList, facultyFlag, helpers.NewFuzzySearch)
 	if result.ExitRequest {
 		return nil, types.Faculty{}, fmt.Errorf("selection canceled by user")
 	}
-	if !result.Selected || result.Index < 1 || result.Index > len(facultyList) {
+	if !result.Selected || result.Index < 1 || result.Index > len(uploads) {
 		return nil, types.Faculty{}, fmt.Errorf("invalid faculty selection")
 	}
-	selectedFaculty := facultyList[result.Index-1]
-
-	// Filter materials for the selected faculty.
-	var materials []types.CourseMaterial
-	for _, fm := range allMaterials {
-		// Parse the raw faculty string to extract the name.
-		fParts := strings.Split(fm.Faculty, " - ")
-		if len(fParts) < 2 {
-			continue
-		}
-		fName := strings.TrimSpace(fParts[1])
-		if fName == selectedFaculty.Name {
-			materials = append(materials, fm.Material)
-		}
-	}
-	if len(materials) == 0 {
-		// No rows matched selected faculty – treat as no uploads
-		return nil, types.Faculty{}, fmt.Errorf("no material uploaded")
-	}
-	return materials, selectedFaculty, nil
+	selected := uploads[result.Index-1]
+	return selected.Materials, selected.Faculty, nil
 }
 
 func displayCourseMaterials(materials []types.CourseMaterial) {
@@ -464,25 +455,25 @@ func displayCourseMaterials(materials []types.CourseMaterial) {
 			})
 		}
 	}
-	fmt.Println()
-	helpers.PrintTable(nestedList, 1)
+	fmt.Fprintln(helpers.Messages)
+	helpers.DrawTable(nestedList, 1)
 }
 
 func selectCourseMaterials(materials []types.CourseMaterial) ([]types.CourseMaterial, error) {
 	for {
-		fmt.Println()
-		fmt.Print("Enter the index numbers of the topics to download (e.g., 1,2-5,8,5,3), or 0 for bulk download: ")
+		fmt.Fprintln(helpers.Messages)
+		fmt.Fprint(helpers.Messages, "Enter the index numbers of the topics to download (e.g., 1,2-5,8,5,3), or 0 for bulk download: ")
 
 		reader := bufio.NewReader(os.Stdin)
 		input, err := reader.ReadString('\n')
 		if err != nil && debug.Debug {
-			fmt.Println("Error reading input:", err)
+			fmt.Fprintln(helpers.Messages, "Error reading input:", err)
 			return nil, err
 		}
 
 		input = strings.TrimSpace(input)
 		if input == "" {
-			fmt.Println("No input provided.")
+			fmt.Fprintln(helpers.Messages, "No input provided.")
 			continue
 		}
 
@@ -492,11 +483,11 @@ func selectCourseMaterials(materials []types.CourseMaterial) ([]types.CourseMate
 
 		selectedIndices, invalidInputs := parseIndices(input, len(materials))
 		if len(invalidInputs) > 0 {
-			fmt.Println("Invalid indices:", strings.Join(invalidInputs, ", "))
+			fmt.Fprintln(helpers.Messages, "Invalid indices:", strings.Join(invalidInputs, ", "))
 		}
 
 		if len(selectedIndices) == 0 {
-			fmt.Println("No valid indices selected.")
+			fmt.Fprintln(helpers.Messages, "No valid indices selected.")
 			continue
 		}
 
@@ -545,7 +536,7 @@ func parseIndices(input string, max int) ([]int, []string) {
 	return uniqueIndices, invalid
 }
 
-func downloadMaterialsHope(regNo string, cookies types.Cookies, selectedCourse types.Course, allMaterials []types.CourseMaterial, selectedMaterials []types.CourseMaterial, faculty types.Faculty) error {
+func downloadMaterialsHope(ctx context.Context, regNo string, cookies types.Cookies, selectedCourse types.Course, allMaterials []types.CourseMaterial, selectedMaterials []types.CourseMaterial, faculty types.Faculty) error {
 	// Concurrency primitives
 	var wg sync.WaitGroup
 	errChan := make(chan error, 1024) // large enough buffer for errors
@@ -569,11 +560,6 @@ func downloadMaterialsHope(regNo string, cookies types.Cookies, selectedCourse t
 		selectedMaterials = allMaterials
 	}
 
-	if helpers.IsRateLimitExceeded() {
-		fmt.Println("Rate limit exceeded. Please try again later.")
-		return fmt.Errorf("rate limit exceeded")
-	}
-
 	if _, statErr := os.Stat(fullDirPath); os.IsNotExist(statErr) {
 		return fmt.Errorf("download directory does not exist: %s", fullDirPath)
 	}
@@ -587,6 +573,7 @@ func downloadMaterialsHope(regNo string, cookies types.Cookies, selectedCourse t
 	bar := progressbar.NewOptions(
 		totalRefMaterials,
 		progressbar.OptionSetDescription("Downloading materials..."),
+		progressbar.OptionSetWriter(helpers.Messages),
 		progressbar.OptionSetElapsedTime(true),
 		progressbar.OptionSetWidth(15),
 		progressbar.OptionThrottle(100*time.Millisecond),
@@ -655,17 +642,12 @@ func downloadMaterialsHope(regNo string, cookies types.Cookies, selectedCourse t
 					}
 
 					client := &http.Client{
-						Timeout: timeout,
-						Transport: &http.Transport{
-							MaxIdleConns:        10,
-							MaxIdleConnsPerHost: 5,
-							IdleConnTimeout:     30 * time.Second,
-							DisableKeepAlives:   true,
-						},
+						Timeout:   timeout,
+						Transport: downloadTransport(),
 					}
 
 					var fetchErr error
-					body, headers, fetchErr = helpers.FetchReqClient(client, regNo, cookies, downloadURL, "", formData, "POST", "application/x-www-form-urlencoded")
+					body, headers, fetchErr = downloadFile(ctx, client, downloadURL, formData)
 					if fetchErr != nil {
 						lastErr = fetchErr
 					} else if len(body) == 0 {
@@ -681,26 +663,23 @@ func downloadMaterialsHope(regNo string, cookies types.Cookies, selectedCourse t
 
 					backoff := time.Duration(attempt*attempt) * 500 * time.Millisecond
 					jitter := time.Duration(rand.Intn(1000)) * time.Millisecond
-					time.Sleep(backoff + jitter)
+					if helpers.Sleep(ctx, backoff+jitter) != nil {
+						break
+					}
 				}
 
 				// Final "fresh client" attempt with longer timeout & cache buster
-				if !success {
+				if !success && ctx.Err() == nil {
 					timeout := 5 * time.Minute
 					if large {
 						timeout = 10 * time.Minute
 					}
 					freshClient := &http.Client{
-						Timeout: timeout,
-						Transport: &http.Transport{
-							DisableKeepAlives: true,
-						},
+						Timeout:   timeout,
+						Transport: downloadTransport(),
 					}
 					randomParam := fmt.Sprintf("?nocache=%d", time.Now().UnixNano())
-					b2, h2, fetchErr := helpers.FetchReqClient(
-						freshClient, regNo, cookies, downloadURL+randomParam, "",
-						formData, "POST", "application/x-www-form-urlencoded",
-					)
+					b2, h2, fetchErr := downloadFile(ctx, freshClient, downloadURL+randomParam, formData)
 					if fetchErr == nil && len(b2) > 0 && ((large && len(b2) > 4096) || isSuccessfulDownload(b2)) {
 						body, headers = b2, h2
 						success = true
@@ -777,7 +756,7 @@ func downloadMaterialsHope(regNo string, cookies types.Cookies, selectedCourse t
 	}()
 
 	for e := range errChan {
-		fmt.Println("Error:", e)
+		fmt.Fprintln(helpers.Messages, "Error:", e)
 	}
 
 	helpers.OpenFolder(fullDirPath)
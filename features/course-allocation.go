@@ -2,32 +2,33 @@ package features
 
 import (
 	"bufio"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"math/rand"
-	"net/http"
-	"net/http/cookiejar"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/net/publicsuffix"
 )
 
+// AllocationAction is what the user chose at a course allocation prompt.
+type AllocationAction int
+
 const (
-	actionSelected    = "SELECTED"
-	actionGoBack      = "BACK"
-	actionExitApp     = "EXIT"
-	actionError       = "ERROR_OCCURRED"
-	actionSetupFailed = "SETUP_FAILED"
+	AllocationSelected AllocationAction = iota
+	AllocationBack
+	AllocationExit
 )
 
 const (
 	DefaultCourseAllocationPageURL = "https://vtop.vit.ac.in/vtop/academics/common/StudentRegistrationScheduleAllocation"
+	allocationBaseURL              = "https://vtop.vit.ac.in/vtop/"
 	getCoursesListEndpoint         = "academics/common/getCoursesListForCurriculmCategory"
 	getCoursesDetailEndpoint       = "academics/common/getCoursesDetailForRegistration"
 	curriculumCategorySelector     = "select#curriculumCategory option"
@@ -42,195 +43,118 @@ const (
 	associatedFunctionHintDetails  = "getCoursesDetail"
 )
 
-type CourseAllocationDetail struct {
-	Code    string
-	Title   string
-	Type    string
-	Venue   string
-	Slot    string
-	Faculty string
-}
-
-var httpClient *http.Client
+// allocationTokens remembers, for each session, the CSRF token and ID the
+// course allocation page's scripts send with their requests.
+var (
+	allocationTokensMu sync.Mutex
+	allocationTokens   = map[string][2]string{}
+)
 
-func init() {
-	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+// FetchAllocationCategories lists the curriculum categories on the course
+// allocation page.
+func FetchAllocationCategories(ctx context.Context, s types.Session) ([]types.Category, error) {
+	doc, err := allocationPage(ctx, s)
 	if err != nil {
-		panic(fmt.Sprintf("features: failed to create cookie jar: %v", err))
-	}
-	httpClient = &http.Client{
-		Timeout: time.Duration(60) * time.Second,
-		Jar:     jar,
+		return nil, err
 	}
+	var categories []types.Category
+	doc.Find(curriculumCategorySelector).Each(func(_ int, s *goquery.Selection) {
+		val, exists := s.Attr("value")
+		if exists && val != "" {
+			categories = append(categories, types.Category{ID: val, Name: strings.TrimSpace(s.Text())})
+		}
+	})
+	return categories, nil
 }
 
-func ExecuteInteractiveCourseAllocationView(regNo string, cookies types.Cookies, courseAllocationPageURL string) {
-	if !helpers.ValidateLogin(cookies) {
-		fmt.Println("User not logged in or session expired.")
-		return
-	}
-
-	if courseAllocationPageURL == "" {
-		courseAllocationPageURL = DefaultCourseAllocationPageURL
+// allocationPage loads the course allocation page and remembers the tokens
+// its scripts use.
+func allocationPage(ctx context.Context, s types.Session) (*goquery.Document, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
 	}
-
 	initialPayloadMap := map[string]string{
 		"verifyMenu":   "true",
-		"authorizedID": regNo,
-		"_csrf":        cookies.CSRF,
+		"authorizedID": s.RegNo,
+		"_csrf":        s.Cookies.CSRF,
 		"nocache":      strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10),
 	}
 	initialFormData := helpers.FormatBodyDataClient(initialPayloadMap)
-	initialPageHTMLBytes, _, err := helpers.FetchReqClient(httpClient, regNo, cookies, courseAllocationPageURL, "", initialFormData, "POST", "application/x-www-form-urlencoded")
+	initialPageHTMLBytes, err := helpers.VtopFor(ctx).Post(ctx, DefaultCourseAllocationPageURL, string(initialFormData))
 	if err != nil {
-		return
+		return nil, err
 	}
 	initialPageHTML := string(initialPageHTMLBytes)
 
 	if len(initialPageHTML) < 500 || strings.Contains(initialPageHTML, "Session Timed Out") || strings.Contains(strings.ToLower(initialPageHTML), "login") {
-		return
+		return nil, helpers.ErrSessionExpired
 	}
 
 	pageCsrfToken, pageAuthID := extractScriptParams(initialPageHTML)
-	currentAjaxCsrfToken := cookies.CSRF
+	currentAjaxCsrfToken := s.Cookies.CSRF
 	if pageCsrfToken != "" {
 		currentAjaxCsrfToken = pageCsrfToken
 	}
-	currentAjaxAuthID := regNo
+	currentAjaxAuthID := s.RegNo
 	if pageAuthID != "" {
 		currentAjaxAuthID = pageAuthID
 	}
+	allocationTokensMu.Lock()
+	allocationTokens[s.Cookies.CSRF] = [2]string{currentAjaxCsrfToken, currentAjaxAuthID}
+	allocationTokensMu.Unlock()
 
 	initialDoc, err := goquery.NewDocumentFromReader(strings.NewReader(initialPageHTML))
 	if err != nil {
-		fmt.Printf("Error parsing initial page HTML: %v\n", err)
-		return
+		return nil, err
 	}
-
 	if initialDoc.Find(curriculumDropdownSelector).Length() == 0 {
-		fmt.Printf("ERROR: The curriculum category dropdown was NOT FOUND on the page fetched from '%s'.\n", courseAllocationPageURL)
-		return
-	}
-
-	ajaxBaseURL := ""
-	if strings.Contains(courseAllocationPageURL, "/vtop/") {
-		ajaxBaseURL = strings.Split(courseAllocationPageURL, "/vtop/")[0] + "/vtop/"
-	} else {
-		ajaxBaseURL = "https://vtop.vit.ac.in/vtop/"
-	}
-
-	for {
-		selectedCategory, categoryAction := selectCurriculumCategory(initialDoc, currentAjaxCsrfToken, currentAjaxAuthID, ajaxBaseURL, regNo, cookies)
-		switch categoryAction {
-		case actionExitApp:
-			return
-		case actionSetupFailed:
-			return
-		case actionError:
-			continue
-		case actionSelected:
-		CourseLoop:
-			for {
-				selectedCourse, courseAction := selectCourseFromCategory(selectedCategory, currentAjaxCsrfToken, currentAjaxAuthID, ajaxBaseURL, regNo, cookies)
-				switch courseAction {
-				case actionExitApp:
-					return
-				case actionGoBack:
-					break CourseLoop
-				case actionError:
-					continue
-				case actionSelected:
-					detailsAction := displayCourseAllocationDetails(selectedCourse, currentAjaxCsrfToken, currentAjaxAuthID, ajaxBaseURL, regNo, cookies)
-					if detailsAction == actionExitApp {
-						return
-					}
-				}
-			}
-		}
+		return nil, fmt.Errorf("the curriculum category dropdown was not found on the course allocation page")
 	}
+	return initialDoc, nil
 }
 
-func extractScriptParams(htmlContent string) (csrfToken string, authID string) {
-	scriptRegex := regexp.MustCompile(`(?s)<script.*?>(.*?)</script>`)
-	csrfRegex := regexp.MustCompile(csrfVarRegexPattern)
-	authIDRegex := regexp.MustCompile(authIDVarRegexPattern)
-	scripts := scriptRegex.FindAllStringSubmatch(htmlContent, -1)
-	foundCsrf, foundAuthID := false, false
-	for _, scriptMatch := range scripts {
-		if len(scriptMatch) < 2 {
-			continue
-		}
-		scriptContent := scriptMatch[1]
-		isRelevant := strings.Contains(scriptContent, associatedFunctionHintCourses) || strings.Contains(scriptContent, associatedFunctionHintDetails)
-		if !foundCsrf {
-			m := csrfRegex.FindStringSubmatch(scriptContent)
-			if len(m) > 1 && (isRelevant || csrfToken == "") {
-				csrfToken = m[1]
-				foundCsrf = true
-			}
-		}
-		if !foundAuthID {
-			m := authIDRegex.FindStringSubmatch(scriptContent)
-			if len(m) > 1 && (isRelevant || authID == "") {
-				authID = m[1]
-				foundAuthID = true
-			}
-		}
-		if foundCsrf && foundAuthID && isRelevant {
-			break
+// allocationAuth returns the CSRF token and ID to send with requests the
+// course allocation page's scripts make, loading the page if needed.
+func allocationAuth(ctx context.Context, s types.Session) (string, string, error) {
+	allocationTokensMu.Lock()
+	tokens, ok := allocationTokens[s.Cookies.CSRF]
+	allocationTokensMu.Unlock()
+	if !ok {
+		if _, err := allocationPage(ctx, s); err != nil {
+			return "", "", err
 		}
+		allocationTokensMu.Lock()
+		tokens = allocationTokens[s.Cookies.CSRF]
+		allocationTokensMu.Unlock()
 	}
-	return
+	return tokens[0], tokens[1], nil
 }
 
-func selectCurriculumCategory(initialDoc *goquery.Document, csrfToken, authID, baseURL, regNo string, cookies types.Cookies) (types.Category, string) {
-	var categories []types.Category
-	initialDoc.Find(curriculumCategorySelector).Each(func(_ int, s *goquery.Selection) {
-		val, exists := s.Attr("value")
-		if exists && val != "" {
-			categories = append(categories, types.Category{ID: val, Name: strings.TrimSpace(s.Text())})
-		}
-	})
-	if len(categories) == 0 {
-		return types.Category{}, actionSetupFailed
-	}
-	tableData := [][]string{{"CATEGORY NAME"}}
-	for _, cat := range categories {
-		tableData = append(tableData, []string{cat.Name})
-	}
-	selectionResult := helpers.TableSelector("Category", tableData, "")
-	if selectionResult.ExitRequest {
-		return types.Category{}, actionExitApp
-	}
-	if !selectionResult.Selected || selectionResult.Index < 1 || selectionResult.Index > len(categories) {
-		fmt.Println("Invalid selection.")
-		return types.Category{}, actionError
+// FetchAllocationCourses lists the courses offered in category, from
+// FetchAllocationCategories.
+func FetchAllocationCourses(ctx context.Context, s types.Session, category types.Category) ([]types.Course, error) {
+	csrfToken, authID, err := allocationAuth(ctx, s)
+	if err != nil {
+		return nil, err
 	}
-	return categories[selectionResult.Index-1], actionSelected
-}
-
-func selectCourseFromCategory(category types.Category, csrfToken, authID, baseURL, regNo string, cookies types.Cookies) (types.Course, string) {
 	courseListParams := map[string]string{
 		"_csrf": csrfToken, "cccategory": category.ID,
 		"authorizedID": authID, "x": time.Now().UTC().Format(time.RFC1123),
 	}
 	formDataCourses := helpers.FormatBodyDataClient(courseListParams)
-	courseListHTMLBytes, _, err := helpers.FetchReqClient(httpClient, regNo, cookies, baseURL+getCoursesListEndpoint, "", formDataCourses, "POST", "application/x-www-form-urlencoded")
+	courseListHTMLBytes, err := helpers.VtopFor(ctx).Post(ctx, allocationBaseURL+getCoursesListEndpoint, string(formDataCourses))
 	if err != nil {
-		fmt.Printf("Error fetching course list for category %s: %v\n", category.Name, err)
-		return types.Course{}, actionError
+		return nil, err
 	}
 	courseListHTML := string(courseListHTMLBytes)
 	if len(courseListHTML) < 10 && (strings.Contains(strings.ToLower(courseListHTML), "error")) ||
 		strings.Contains(courseListHTML, "Session Timed Out") || strings.Contains(strings.ToLower(courseListHTML), "login required") {
-		fmt.Printf("Error or session issue fetching courses for %s.\n", category.Name)
-		return types.Course{}, actionError
+		return nil, fmt.Errorf("error or session issue fetching courses for %s", category.Name)
 	}
 
 	courseListDoc, err := goquery.NewDocumentFromReader(strings.NewReader(courseListHTML))
 	if err != nil {
-		fmt.Printf("Error parsing course list for category %s: %v\n", category.Name, err)
-		return types.Course{}, actionError
+		return nil, err
 	}
 	var courses []types.Course
 	courseListDoc.Find(courseListSelector).Each(func(_ int, s *goquery.Selection) {
@@ -239,91 +163,157 @@ func selectCourseFromCategory(category types.Category, csrfToken, authID, baseUR
 			courses = append(courses, types.Course{ID: code, Name: strings.TrimSpace(s.Text())})
 		}
 	})
-	if len(courses) == 0 {
-		fmt.Printf("No courses found for category: %s.\n", category.Name)
-		return types.Course{}, actionGoBack
-	}
-	tableData := [][]string{{"COURSE (CODE - TITLE)"}}
-	for _, c := range courses {
-		tableData = append(tableData, []string{c.Name})
-	}
-	selectionResult := helpers.TableSelector("Course", tableData, "")
-	if selectionResult.ExitRequest {
-		return types.Course{}, actionExitApp
-	}
-	if !selectionResult.Selected || selectionResult.Index < 1 || selectionResult.Index > len(courses) {
-		return types.Course{}, actionGoBack
-	}
-	return courses[selectionResult.Index-1], actionSelected
+	return courses, nil
 }
 
-func displayCourseAllocationDetails(course types.Course, csrfToken, authID, baseURL, regNo string, cookies types.Cookies) string {
+// FetchCourseAllocation returns the classes of course, from
+// FetchAllocationCourses, with their slot, venue and faculty.
+func FetchCourseAllocation(ctx context.Context, s types.Session, course types.Course) ([]types.CourseAllocationDetail, error) {
+	csrfToken, authID, err := allocationAuth(ctx, s)
+	if err != nil {
+		return nil, err
+	}
 	time.Sleep(time.Duration(100+rand.Intn(150)) * time.Millisecond)
 	courseDetailParams := map[string]string{
 		"_csrf": csrfToken, "courseCode": course.ID,
 		"authorizedID": authID, "x": time.Now().UTC().Format(time.RFC1123),
 	}
 	formDataDetails := helpers.FormatBodyDataClient(courseDetailParams)
-	courseDetailHTMLBytes, _, err := helpers.FetchReqClient(httpClient, regNo, cookies, baseURL+getCoursesDetailEndpoint, "", formDataDetails, "POST", "application/x-www-form-urlencoded")
+	courseDetailHTMLBytes, err := helpers.VtopFor(ctx).Post(ctx, allocationBaseURL+getCoursesDetailEndpoint, string(formDataDetails))
 	if err != nil {
-		fmt.Printf("Error fetching course details for %s: %v\n", course.Name, err)
-		return actionError
+		return nil, err
 	}
 	courseDetailHTML := string(courseDetailHTMLBytes)
 	if len(courseDetailHTML) < 10 && (strings.Contains(strings.ToLower(courseDetailHTML), "error")) ||
 		strings.Contains(courseDetailHTML, "Session Timed Out") || strings.Contains(strings.ToLower(courseDetailHTML), "login required") {
-		fmt.Printf("Error or session issue fetching details for %s.\n", course.Name)
-		return actionError
+		return nil, fmt.Errorf("error or session issue fetching details for %s", course.Name)
 	}
 
 	courseDetailDoc, err := goquery.NewDocumentFromReader(strings.NewReader(courseDetailHTML))
 	if err != nil {
-		return actionError
-	}
-	var detailsList []CourseAllocationDetail
-	table := courseDetailDoc.Find(courseDetailTableSelector)
-	if table.Length() == 0 {
-		return actionGoBack
-	} else {
-		table.Find(courseDetailRowSelector).Each(func(_ int, row *goquery.Selection) {
-			cells := row.Find(courseDetailCellSelector)
-			if cells.Length() == 4 {
-				titleParts := strings.SplitN(course.Name, " - ", 2)
-				actualTitle := course.ID
-				if len(titleParts) == 2 {
-					actualTitle = titleParts[1]
-				} else {
-					actualTitle = course.Name
-				}
-				detailsList = append(detailsList, CourseAllocationDetail{
-					Code: course.ID, Title: actualTitle,
-					Slot: strings.TrimSpace(cells.Eq(0).Text()), Venue: strings.TrimSpace(cells.Eq(1).Text()),
-					Faculty: strings.TrimSpace(cells.Eq(2).Text()), Type: strings.TrimSpace(cells.Eq(3).Text()),
-				})
+		return nil, err
+	}
+	var detailsList []types.CourseAllocationDetail
+	courseDetailDoc.Find(courseDetailTableSelector).Find(courseDetailRowSelector).Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find(courseDetailCellSelector)
+		if cells.Length() == 4 {
+			titleParts := strings.SplitN(course.Name, " - ", 2)
+			actualTitle := course.ID
+			if len(titleParts) == 2 {
+				actualTitle = titleParts[1]
+			} else {
+				actualTitle = course.Name
 			}
-		})
-		if len(detailsList) > 0 {
-			tableData := [][]string{{"FACULTY", "VENUE", "SLOT", "TYPE"}}
-			for _, d := range detailsList {
-				tableData = append(tableData, []string{d.Faculty, d.Venue, d.Slot, d.Type})
-			}
-			helpers.PrintTable(tableData, 0)
-		} else {
-			//fmt.Println("Details table found, but no rows matched expected structure (4 cells).")
+			detailsList = append(detailsList, types.CourseAllocationDetail{
+				Code: course.ID, Title: actualTitle,
+				Slot: strings.TrimSpace(cells.Eq(0).Text()), Venue: strings.TrimSpace(cells.Eq(1).Text()),
+				Faculty: strings.TrimSpace(cells.Eq(2).Text()), Type: strings.TrimSpace(cells.Eq(3).Text()),
+			})
 		}
+	})
+	return detailsList, nil
+}
+
+// SelectAllocationCategory asks for one of categories from
+// FetchAllocationCategories. It reports false when the user quits.
+func SelectAllocationCategory(categories []types.Category) (types.Category, bool) {
+	if len(categories) == 0 {
+		fmt.Fprintln(helpers.Messages, "No curriculum categories found.")
+		return types.Category{}, false
+	}
+	tableData := [][]string{{"CATEGORY NAME"}}
+	for _, cat := range categories {
+		tableData = append(tableData, []string{cat.Name})
 	}
-	fmt.Println("\nPress 'b' to go back to course list, or 'q' to exit.")
+	for {
+		selectionResult := helpers.TableSelector("Category", tableData, "")
+		if selectionResult.ExitRequest {
+			return types.Category{}, false
+		}
+		if selectionResult.Selected && selectionResult.Index >= 1 && selectionResult.Index <= len(categories) {
+			return categories[selectionResult.Index-1], true
+		}
+		fmt.Fprintln(helpers.Messages, "Invalid selection.")
+	}
+}
+
+// SelectAllocationCourse asks for one of courses from
+// FetchAllocationCourses, or whether the user went back to the categories or
+// quit.
+func SelectAllocationCourse(category types.Category, courses []types.Course) (types.Course, AllocationAction) {
+	if len(courses) == 0 {
+		fmt.Fprintf(helpers.Messages, "No courses found for category: %s.\n", category.Name)
+		return types.Course{}, AllocationBack
+	}
+	tableData := [][]string{{"COURSE (CODE - TITLE)"}}
+	for _, c := range courses {
+		tableData = append(tableData, []string{c.Name})
+	}
+	selectionResult := helpers.TableSelector("Course", tableData, "")
+	if selectionResult.ExitRequest {
+		return types.Course{}, AllocationExit
+	}
+	if !selectionResult.Selected || selectionResult.Index < 1 || selectionResult.Index > len(courses) {
+		return types.Course{}, AllocationBack
+	}
+	return courses[selectionResult.Index-1], AllocationSelected
+}
+
+// ShowCourseAllocation lists the classes in details, from
+// FetchCourseAllocation, and waits for the user to go back or quit.
+func ShowCourseAllocation(details []types.CourseAllocationDetail) AllocationAction {
+	if len(details) > 0 {
+		tableData := [][]string{{"FACULTY", "VENUE", "SLOT", "TYPE"}}
+		for _, d := range details {
+			tableData = append(tableData, []string{d.Faculty, d.Venue, d.Slot, d.Type})
+		}
+		helpers.DrawTable(tableData, 0)
+	}
+	fmt.Fprintln(helpers.Messages, "\nPress 'b' to go back to course list, or 'q' to exit.")
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		fmt.Print("> ")
+		fmt.Fprint(helpers.Messages, "> ")
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(strings.ToLower(input))
 		if input == "b" {
-			return actionGoBack
+			return AllocationBack
 		}
 		if input == "q" {
-			return actionExitApp
+			return AllocationExit
+		}
+		fmt.Fprintln(helpers.Messages, "Invalid input. 'b' for back, 'q' for quit.")
+	}
+}
+
+func extractScriptParams(htmlContent string) (csrfToken string, authID string) {
+	scriptRegex := regexp.MustCompile(`(?s)<script.*?>(.*?)</script>`)
+	csrfRegex := regexp.MustCompile(csrfVarRegexPattern)
+	authIDRegex := regexp.MustCompile(authIDVarRegexPattern)
+	scripts := scriptRegex.FindAllStringSubmatch(htmlContent, -1)
+	foundCsrf, foundAuthID := false, false
+	for _, scriptMatch := range scripts {
+		if len(scriptMatch) < 2 {
+			continue
+		}
+		scriptContent := scriptMatch[1]
+		isRelevant := strings.Contains(scriptContent, associatedFunctionHintCourses) || strings.Contains(scriptContent, associatedFunctionHintDetails)
+		if !foundCsrf {
+			m := csrfRegex.FindStringSubmatch(scriptContent)
+			if len(m) > 1 && (isRelevant || csrfToken == "") {
+				csrfToken = m[1]
+				foundCsrf = true
+			}
+		}
+		if !foundAuthID {
+			m := authIDRegex.FindStringSubmatch(scriptContent)
+			if len(m) > 1 && (isRelevant || authID == "") {
+				authID = m[1]
+				foundAuthID = true
+			}
+		}
+		if foundCsrf && foundAuthID && isRelevant {
+			break
 		}
-		fmt.Println("Invalid input. 'b' for back, 'q' for quit.")
 	}
+	return
 }
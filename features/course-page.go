@@ -2,9 +2,7 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -18,6 +16,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/schollz/progressbar/v3"
 )
@@ -30,168 +31,30 @@ import (
 // 	CourseCellSelector   = "td"
 // )
 
-// var newHttpClient *http.Client
-
-func init() {
-	newHttpClient = &http.Client{
-		Timeout: time.Duration(60) * time.Second,
-	}
-}
-
-func ExecuteCoursePageOldDownload(regNo string, cookies types.Cookies, semesterFlag int, courseFlag int, facultyFlag string, fuzzyFlag int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-
-	semDetails, err := helpers.GetSemDetails(cookies, regNo)
-	var selectedSem types.Semester
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error fetching sem details:", err)
-		}
-		semDetails, err = helpers.GetSemDetailsBackup(cookies, regNo)
-		if err != nil {
-			if debug.Debug {
-				fmt.Println("Error fetching semester details in backup", err)
-			}
-			fmt.Println("Error fetching semester details in backup")
-			return
-		}
-	}
-	if len(semDetails) == 0 {
-		if debug.Debug {
-			fmt.Println("Error fetching semester details", err)
-		}
-		fmt.Println("No semesters found. Please check your registration number or try again later.")
-		return
-	}
-
-	var nested_sem_list [][]string
-	nested_sem_list = append(nested_sem_list, []string{"Semester ID", "Semester"})
-	for i := 0; i < len(semDetails)-1; i++ {
-		nested_sem_list = append(nested_sem_list, []string{semDetails[i].SemID, semDetails[i].SemName})
-	}
-
-	choice := helpers.TableSelector("semester", nested_sem_list, strconv.Itoa(semesterFlag))
-	if choice.ExitRequest {
-		fmt.Println("Selection canceled by user.")
-		return
-	}
-	if !choice.Selected || choice.Index < 1 || choice.Index > len(semDetails) {
-		fmt.Println("Invalid semester selection. Please try again.")
-		return
-	}
-	selectedSem = semDetails[choice.Index-1]
-
-	if err != nil {
-		if err.Error() == "selection canceled by user" {
-			fmt.Println("Selection canceled")
-			return
-		}
-		if debug.Debug {
-			fmt.Println(err)
-		}
-		fmt.Println("Error selecting semester:", err)
-		return
-	}
-
-	coursePageOldAfterSemSelection(regNo, cookies, selectedSem, courseFlag, facultyFlag)
-
-}
-
-func coursePageOldAfterSemSelection(regNo string, cookies types.Cookies, selectedSemester types.Semester, courseFlag int, facultyFlag string) {
-	selectedCourse, err := fetchAndSelectCourseOld(regNo, cookies, selectedSemester.SemID, courseFlag)
-	if err != nil {
-		fmt.Println("Error selecting course:", err)
-		return
-	}
-
-	slotIds, err := fetchSlotIds(regNo, cookies, selectedSemester.SemID, selectedCourse.ID)
-	if err != nil {
-		fmt.Println("Error fetching slots:", err)
-		return
-	}
-
-	if len(slotIds) == 0 {
-		fmt.Println("No slots available for the selected course.")
-		return
-	}
-
-	faculties, err := fetchFacultiesForAllSlotsConcurrently(regNo, cookies, selectedSemester.SemID, selectedCourse.ID, slotIds)
-	if err != nil {
-		fmt.Println("Error fetching faculties:", err)
-		return
-	}
-
-	if len(faculties) == 0 {
-		fmt.Println("No faculties found for the selected course across all slots.")
-		return
-	}
-
-	selectedFaculty, err := selectFaculty(faculties, facultyFlag)
-	if err != nil {
-		// Check if this is a selection canceled error or a real error
-		if err.Error() == "selection canceled by user" {
-			fmt.Println("Selection canceled")
-			return
-		}
-		fmt.Println("Error selecting faculty:", err)
-		return
-	}
-
-	htmlContent, err := fetchCourseMaterialsPage(regNo, cookies, selectedFaculty)
-	if err != nil {
-		fmt.Println("Error fetching course materials page:", err)
-		return
-	}
-
-	materials, err := parseCourseMaterialsPage(htmlContent)
-	if err != nil {
-		fmt.Println("Error parsing course materials:", err)
-		return
-	}
-
-	if len(materials) == 0 {
-		fmt.Println("No course materials with reference materials available for download.")
-		return
-	}
-
-	displayCourseMaterials(materials)
-
-	selectedMaterials, err := selectCourseMaterials(materials)
-	if err != nil {
-		fmt.Println("Error selecting materials:", err)
-		return
-	}
-
-	err = downloadMaterialsIndividually(regNo, cookies, selectedCourse, selectedFaculty, materials, selectedMaterials)
-	if err != nil {
-		fmt.Printf("Error downloading materials: %v\n", err)
-		return
+// FetchArchiveCourses lists the courses on the course page VTOP kept before
+// the consolidated one, for a semester in opts.
+func FetchArchiveCourses(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.Course, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
 	}
-
-	fmt.Println("\nDownload complete!")
-}
-
-func fetchAndSelectCourseOld(regNo string, cookies types.Cookies, semSubId string, courseFlag int) (types.Course, error) {
 	getCourseURL := "https://vtop.vit.ac.in/vtop/getCourseForCoursePage"
 	payloadMap := map[string]string{
-		"_csrf":         cookies.CSRF,
+		"_csrf":         s.Cookies.CSRF,
 		"paramReturnId": "getCourseForCoursePage",
-		"semSubId":      semSubId,
-		"authorizedID":  regNo,
+		"semSubId":      opts.Semester.SemID,
+		"authorizedID":  s.RegNo,
 		"x":             time.Now().UTC().Format(time.RFC1123),
 	}
 
 	formData := helpers.FormatBodyDataClient(payloadMap)
-	body, _, err := helpers.FetchReqClient(newHttpClient, regNo, cookies, getCourseURL, "", formData, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Post(ctx, getCourseURL, string(formData))
 	if err != nil {
-		return types.Course{}, err
+		return nil, err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		return types.Course{}, err
+		return nil, err
 	}
 
 	var courses []types.Course
@@ -202,7 +65,12 @@ func fetchAndSelectCourseOld(regNo string, cookies types.Cookies, semSubId strin
 			courses = append(courses, types.Course{ID: value, Name: text})
 		}
 	})
+	return courses, nil
+}
 
+// SelectArchiveCourse asks for one of courses from FetchArchiveCourses, or
+// takes it from --course.
+func SelectArchiveCourse(courses []types.Course, courseFlag int) (types.Course, error) {
 	if len(courses) == 0 {
 		return types.Course{}, fmt.Errorf("no courses found for the selected semester")
 	}
@@ -225,7 +93,58 @@ func fetchAndSelectCourseOld(regNo string, cookies types.Cookies, semSubId strin
 	return selectedCourse, nil
 }
 
-func fetchSlotIds(regNo string, cookies types.Cookies, semSubId string, classId string) ([]string, error) {
+// FetchArchiveFaculties lists everyone who taught course in the semester in
+// opts, across all of its slots, by name.
+func FetchArchiveFaculties(ctx context.Context, s types.Session, opts types.FetchOptions, course types.Course) ([]types.FacultyOld, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	slotIds, err := fetchSlotIds(ctx, s.RegNo, s.Cookies, opts.Semester.SemID, course.ID)
+	if err != nil {
+		return nil, err
+	}
+	return fetchFacultiesForAllSlotsConcurrently(ctx, s.RegNo, s.Cookies, opts.Semester.SemID, course.ID, slotIds)
+}
+
+// FetchArchiveMaterials returns the material faculty, from
+// FetchArchiveFaculties, uploaded for their class.
+func FetchArchiveMaterials(ctx context.Context, s types.Session, faculty types.FacultyOld) ([]types.CourseMaterial, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	htmlContent, err := fetchCourseMaterialsPage(ctx, s.RegNo, s.Cookies, faculty)
+	if err != nil {
+		return nil, err
+	}
+	return parseCourseMaterialsPage(htmlContent)
+}
+
+// DownloadArchiveMaterials asks which of materials, from
+// FetchArchiveMaterials, to download and downloads them.
+func DownloadArchiveMaterials(ctx context.Context, s types.Session, course types.Course, faculty types.FacultyOld, materials []types.CourseMaterial) {
+	if len(materials) == 0 {
+		fmt.Fprintln(helpers.Messages, "No course materials with reference materials available for download.")
+		return
+	}
+
+	displayCourseMaterials(materials)
+
+	selectedMaterials, err := selectCourseMaterials(materials)
+	if err != nil {
+		fmt.Fprintln(helpers.Messages, "Error selecting materials:", err)
+		return
+	}
+
+	err = downloadMaterialsIndividually(ctx, s.RegNo, s.Cookies, course, faculty, materials, selectedMaterials)
+	if err != nil {
+		fmt.Fprintf(helpers.Messages, "Error downloading materials: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(helpers.Messages, "\nDownload complete!")
+}
+
+func fetchSlotIds(ctx context.Context, regNo string, cookies types.Cookies, semSubId string, classId string) ([]string, error) {
 	getSlotURL := "https://vtop.vit.ac.in/vtop/getSlotIdForCoursePage"
 	payloadMap := map[string]string{
 		"_csrf":         cookies.CSRF,
@@ -238,7 +157,7 @@ func fetchSlotIds(regNo string, cookies types.Cookies, semSubId string, classId
 	}
 
 	formData := helpers.FormatBodyDataClient(payloadMap)
-	body, _, err := helpers.FetchReqClient(newHttpClient, regNo, cookies, getSlotURL, "", formData, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Post(ctx, getSlotURL, string(formData))
 	if err != nil {
 		return nil, err
 	}
@@ -263,7 +182,7 @@ func fetchSlotIds(regNo string, cookies types.Cookies, semSubId string, classId
 	return slots, nil
 }
 
-func fetchFacultiesForAllSlotsConcurrently(regNo string, cookies types.Cookies, semSubId string, classId string, slotIds []string) ([]types.FacultyOld, error) {
+func fetchFacultiesForAllSlotsConcurrently(ctx context.Context, regNo string, cookies types.Cookies, semSubId string, classId string, slotIds []string) ([]types.FacultyOld, error) {
 	var wg sync.WaitGroup
 	concurrency := getOptimizedConcurrency()
 	sem := make(chan struct{}, concurrency)
@@ -278,10 +197,10 @@ func fetchFacultiesForAllSlotsConcurrently(regNo string, cookies types.Cookies,
 		go func(i int, slotId string) {
 			defer wg.Done()
 			defer func() { <-sem }()
-			faculties, err := fetchFaculties(newHttpClient, regNo, cookies, semSubId, classId, slotId)
+			faculties, err := fetchFaculties(ctx, regNo, cookies, semSubId, classId, slotId)
 			if err != nil {
 				if debug.Debug {
-					fmt.Printf("Error fetching faculties for slot %s: %v\n", slotId, err)
+					fmt.Fprintf(helpers.Messages, "Error fetching faculties for slot %s: %v\n", slotId, err)
 				}
 				mu.Lock()
 				errorsOccurred = true
@@ -309,7 +228,7 @@ func fetchFacultiesForAllSlotsConcurrently(regNo string, cookies types.Cookies,
 	return uniqueFaculties, nil
 }
 
-func fetchFaculties(client *http.Client, regNo string, cookies types.Cookies, semSubId string, classId string, slotId string) ([]types.FacultyOld, error) {
+func fetchFaculties(ctx context.Context, regNo string, cookies types.Cookies, semSubId string, classId string, slotId string) ([]types.FacultyOld, error) {
 	getFacultyURL := "https://vtop.vit.ac.in/vtop/getFacultyForCoursePage"
 	payloadMap := map[string]string{
 		"_csrf":         cookies.CSRF,
@@ -323,7 +242,7 @@ func fetchFaculties(client *http.Client, regNo string, cookies types.Cookies, se
 	}
 
 	formData := helpers.FormatBodyDataClient(payloadMap)
-	body, _, err := helpers.FetchReqClient(client, regNo, cookies, getFacultyURL, "", formData, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Post(ctx, getFacultyURL, string(formData))
 	if err != nil {
 		return nil, err
 	}
@@ -385,7 +304,13 @@ func fetchFaculties(client *http.Client, regNo string, cookies types.Cookies, se
 	return faculties, nil
 }
 
-func selectFaculty(faculties []types.FacultyOld, facultyFlag string) (types.FacultyOld, error) {
+// SelectArchiveFaculty asks for one of faculties from FetchArchiveFaculties,
+// or takes it from --faculty.
+func SelectArchiveFaculty(faculties []types.FacultyOld, facultyFlag string) (types.FacultyOld, error) {
+	if len(faculties) == 0 {
+		return types.FacultyOld{}, fmt.Errorf("no faculties found for the selected course across all slots")
+	}
+
 	nestedList := [][]string{{"NAME", "SLOT"}}
 	for _, faculty := range faculties {
 		cleanName := removeNumberPrefix(faculty.Name)
@@ -420,7 +345,7 @@ func removeNumberPrefix(facultyName string) string {
 	return facultyName
 }
 
-func fetchCourseMaterialsPage(regNo string, cookies types.Cookies, selectedFaculty types.FacultyOld) (string, error) {
+func fetchCourseMaterialsPage(ctx context.Context, regNo string, cookies types.Cookies, selectedFaculty types.FacultyOld) (string, error) {
 	url := "https://vtop.vit.ac.in/vtop/processViewStudentCourseDetail"
 	payloadMap := map[string]string{
 		"_csrf":        cookies.CSRF,
@@ -431,7 +356,7 @@ func fetchCourseMaterialsPage(regNo string, cookies types.Cookies, selectedFacul
 		"x":            time.Now().UTC().Format(time.RFC1123),
 	}
 	formData := helpers.FormatBodyDataClient(payloadMap)
-	body, _, err := helpers.FetchReqClient(newHttpClient, regNo, cookies, url, "", formData, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Post(ctx, url, string(formData))
 	if err != nil {
 		return "", err
 	}
@@ -724,7 +649,7 @@ func parseCourseMaterialsPage(htmlContent string) ([]types.CourseMaterial, error
 	return materials, nil
 }
 
-func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selectedCourse types.Course, selectedFaculty types.FacultyOld, allMaterials []types.CourseMaterial, selectedMaterials []types.CourseMaterial) error {
+func downloadMaterialsIndividually(ctx context.Context, regNo string, cookies types.Cookies, selectedCourse types.Course, selectedFaculty types.FacultyOld, allMaterials []types.CourseMaterial, selectedMaterials []types.CourseMaterial) error {
 	// Create the Course Page directory
 	coursePageDir, err := helpers.GetOrCreateDownloadDir("Course Page")
 	if err != nil {
@@ -782,11 +707,6 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 		selectedMaterials = allMaterials
 	}
 
-	if helpers.IsRateLimitExceeded() {
-		fmt.Println("Rate limit exceeded. Please try again later.")
-		return fmt.Errorf("rate limit exceeded")
-	}
-
 	if _, err := os.Stat(fullDirPath); os.IsNotExist(err) {
 		return fmt.Errorf("download directory does not exist: %s", fullDirPath)
 	}
@@ -798,6 +718,7 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 
 	bar := progressbar.NewOptions(totalRefMaterials,
 		progressbar.OptionSetDescription("Downloading materials..."),
+		progressbar.OptionSetWriter(helpers.Messages),
 		progressbar.OptionSetElapsedTime(true),
 		progressbar.OptionSetWidth(15),
 		progressbar.OptionThrottle(100*time.Millisecond),
@@ -853,8 +774,8 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 
 	for _, material := range selectedMaterials {
 		if material.WebLink != "" {
-			fmt.Printf("Web Material available for '%s'\n", material.Topic)
-			fmt.Printf("Link: %s\n", material.WebLink)
+			fmt.Fprintf(helpers.Messages, "Web Material available for '%s'\n", material.Topic)
+			fmt.Fprintf(helpers.Messages, "Link: %s\n", material.WebLink)
 		}
 
 		topicName := helpers.SanitizeFilename(material.Topic)
@@ -917,20 +838,15 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 
 				for attempt := 1; attempt <= retries; attempt++ {
 					attemptClient := &http.Client{
-						Timeout: time.Minute * 2,
-						Transport: &http.Transport{
-							MaxIdleConns:        10,
-							MaxIdleConnsPerHost: 5,
-							IdleConnTimeout:     30 * time.Second,
-							DisableKeepAlives:   true,
-						},
+						Timeout:   time.Minute * 2,
+						Transport: downloadTransport(),
 					}
 
 					if isPotentialPptx {
 						attemptClient.Timeout = time.Minute * 5
 					}
 
-					body, headers, downloadErr = helpers.FetchReqClient(attemptClient, regNo, cookies, downloadURL, "", formData, "POST", "application/x-www-form-urlencoded")
+					body, headers, downloadErr = downloadFile(ctx, attemptClient, downloadURL, formData)
 					if downloadErr == nil && len(body) > 0 {
 						if (isPotentialPptx && len(body) > 4096) || isSuccessfulDownload(body) {
 							break
@@ -945,17 +861,19 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 					}
 
 					if debug.Debug {
-						fmt.Printf("Attempt %d: Error downloading material ID %s: %v\n", attempt, refMat.MaterialID, downloadErr)
+						fmt.Fprintf(helpers.Messages, "Attempt %d: Error downloading material ID %s: %v\n", attempt, refMat.MaterialID, downloadErr)
 					}
 
 					backoffTime := time.Duration(attempt*attempt) * 500 * time.Millisecond
 					jitter := time.Duration(rand.Intn(1000)) * time.Millisecond
-					time.Sleep(backoffTime + jitter)
+					if helpers.Sleep(ctx, backoffTime+jitter) != nil {
+						break
+					}
 				}
 
 				if downloadErr != nil || !isSuccessfulDownload(body) {
 					if debug.Debug {
-						fmt.Printf("Failed to download material ID %s after %d attempts: %v\n", refMat.MaterialID, retries, downloadErr)
+						fmt.Fprintf(helpers.Messages, "Failed to download material ID %s after %d attempts: %v\n", refMat.MaterialID, retries, downloadErr)
 					}
 
 					failedMu.Lock()
@@ -1031,7 +949,7 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 		err := helpers.SaveFile(result.Body, result.FilePath)
 		if err != nil {
 			if debug.Debug {
-				fmt.Printf("Error saving file: %v\n", err)
+				fmt.Fprintf(helpers.Messages, "Error saving file: %v\n", err)
 			}
 			failedMu.Lock()
 			failedDownloads = append(failedDownloads, FailedDownload{
@@ -1058,9 +976,10 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 	var permanentlyFailedDownloads []FailedDownload
 
 	if len(failedDownloads) > 0 {
-		fmt.Printf("\nRetrying %d failed downloads...\n", len(failedDownloads))
+		fmt.Fprintf(helpers.Messages, "\nRetrying %d failed downloads...\n", len(failedDownloads))
 		retryBar := progressbar.NewOptions(len(failedDownloads),
 			progressbar.OptionSetDescription("Retrying failed downloads..."),
+			progressbar.OptionSetWriter(helpers.Messages),
 			progressbar.OptionSetElapsedTime(true),
 			progressbar.OptionSetWidth(15),
 			progressbar.OptionThrottle(100*time.Millisecond),
@@ -1097,13 +1016,8 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 			var lastError string
 
 			retryClient := &http.Client{
-				Timeout: time.Minute * 5,
-				Transport: &http.Transport{
-					MaxIdleConns:        5,
-					MaxIdleConnsPerHost: 2,
-					IdleConnTimeout:     90 * time.Second,
-					DisableKeepAlives:   true,
-				},
+				Timeout:   time.Minute * 5,
+				Transport: downloadTransport(),
 			}
 
 			if isPotentialPptx {
@@ -1115,10 +1029,12 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 			for attempt := 1; attempt <= 5; attempt++ {
 				if attempt > 1 {
 					sleepTime := time.Duration(attempt*3) * time.Second
-					time.Sleep(sleepTime)
+					if helpers.Sleep(ctx, sleepTime) != nil {
+						break
+					}
 				}
 
-				body, headers, downloadErr = helpers.FetchReqClient(retryClient, regNo, cookies, downloadURL, "", formData, "POST", "application/x-www-form-urlencoded")
+				body, headers, downloadErr = downloadFile(ctx, retryClient, downloadURL, formData)
 
 				if downloadErr == nil && len(body) > 0 {
 					if (isPotentialPptx && len(body) > 4096) || isSuccessfulDownload(body) {
@@ -1151,7 +1067,9 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 				}
 
 				if attempt == 5 {
-					time.Sleep(5 * time.Second)
+					if helpers.Sleep(ctx, 5*time.Second) != nil {
+						break
+					}
 
 					var timeout time.Duration
 					if isPotentialPptx {
@@ -1161,14 +1079,12 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 					}
 
 					freshClient := &http.Client{
-						Timeout: timeout,
-						Transport: &http.Transport{
-							DisableKeepAlives: true,
-						},
+						Timeout:   timeout,
+						Transport: downloadTransport(),
 					}
 
 					randomParam := fmt.Sprintf("&nocache=%d", time.Now().UnixNano())
-					body, headers, downloadErr = helpers.FetchReqClient(freshClient, regNo, cookies, downloadURL+randomParam, "", formData, "POST", "application/x-www-form-urlencoded")
+					body, headers, downloadErr = downloadFile(ctx, freshClient, downloadURL+randomParam, formData)
 
 					if downloadErr == nil && len(body) > 0 {
 						if (isPotentialPptx && len(body) > 4096) || isSuccessfulDownload(body) {
@@ -1213,7 +1129,7 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 			retryBar.Add(1)
 
 			if i < len(failedDownloads)-1 {
-				time.Sleep(1 * time.Second)
+				helpers.Sleep(ctx, 1*time.Second)
 			}
 		}
 		retryBar.Finish()
@@ -1224,27 +1140,27 @@ func downloadMaterialsIndividually(regNo string, cookies types.Cookies, selected
 	totalFiles := totalRefMaterials
 	successfulFiles := totalFiles - len(permanentlyFailedDownloads)
 
-	fmt.Printf("\n\nDownload Summary:\n")
-	fmt.Printf("Total files: %d\n", totalFiles)
-	fmt.Printf("Successfully downloaded: %d\n", successfulFiles)
+	fmt.Fprintf(helpers.Messages, "\n\nDownload Summary:\n")
+	fmt.Fprintf(helpers.Messages, "Total files: %d\n", totalFiles)
+	fmt.Fprintf(helpers.Messages, "Successfully downloaded: %d\n", successfulFiles)
 
 	if len(permanentlyFailedDownloads) > 0 {
-		fmt.Printf("Failed to download: %d\n\n", len(permanentlyFailedDownloads))
-		fmt.Println("The following files could not be downloaded:")
+		fmt.Fprintf(helpers.Messages, "Failed to download: %d\n\n", len(permanentlyFailedDownloads))
+		fmt.Fprintln(helpers.Messages, "The following files could not be downloaded:")
 
 		for i, fd := range permanentlyFailedDownloads {
-			fmt.Printf("%d. Topic: %s\n", i+1, fd.Topic)
-			fmt.Printf("   File: %s\n", fd.RefMat.Name)
-			fmt.Printf("   Error: %s\n", fd.Error)
-			fmt.Println()
+			fmt.Fprintf(helpers.Messages, "%d. Topic: %s\n", i+1, fd.Topic)
+			fmt.Fprintf(helpers.Messages, "   File: %s\n", fd.RefMat.Name)
+			fmt.Fprintf(helpers.Messages, "   Error: %s\n", fd.Error)
+			fmt.Fprintln(helpers.Messages)
 		}
 
-		fmt.Println("\nYou can try downloading these files individually later.")
+		fmt.Fprintln(helpers.Messages, "\nYou can try downloading these files individually later.")
 	} else {
-		fmt.Println("All files were downloaded successfully!")
+		fmt.Fprintln(helpers.Messages, "All files were downloaded successfully!")
 	}
 
-	fmt.Printf("Files have been saved to: %s\n", fullDirPath)
+	fmt.Fprintf(helpers.Messages, "Files have been saved to: %s\n", fullDirPath)
 	helpers.OpenFolder(fullDirPath)
 	return nil
 }
@@ -1286,3 +1202,23 @@ func generateFilePath(dirPath string, indexNo int, moduleNo, topicNo, topicName
 // 	}
 // 	return numCPU
 // }
+
+// downloadTransport gives each download attempt its own connection, through
+// the configured proxy.
+func downloadTransport() *http.Transport {
+	transport := helpers.NewVtopTransport()
+	transport.DisableKeepAlives = true
+	return transport
+}
+
+// downloadFile posts formData to a download endpoint through client, which
+// callers tune per attempt, and returns the file with its headers. The
+// client's timeout, when set, replaces the endpoint deadline. Callers retry
+// downloads themselves, checking the content, so the client does not.
+func downloadFile(ctx context.Context, client *http.Client, url string, formData []byte) ([]byte, http.Header, error) {
+	resp, err := helpers.VtopFor(ctx).WithHTTP(client).Do(ctx, helpers.VtopRequest{URL: url, Body: string(formData), Timeout: client.Timeout, Attempts: 1})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, resp.Header, nil
+}
@@ -1,12 +1,8 @@
 package features
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -16,6 +12,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -25,118 +24,94 @@ const (
 	DACellSelector        = "td"
 )
 
-// FetchPendingAssignments returns a list of outstanding DA events for the latest semester.
-func FetchPendingAssignments(regNo string, cookies types.Cookies) ([]types.AssignmentSummary, error) {
-	if !helpers.ValidateLogin(cookies) {
-		return nil, errors.New("invalid login session")
-	}
+// FetchDAs returns every digital assignment for each course in a semester,
+// by default the latest one that has courses.
+func FetchDAs(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.SubjectDAs, error) {
+	return cached(ctx, s, datasetKey("das", opts), func() ([]types.SubjectDAs, error) {
+		return fetchDAs(ctx, s, opts)
+	})
+}
 
-	semesters, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		semesters, err = helpers.GetSemDetailsBackup(cookies, regNo)
-		if err != nil {
-			return nil, err
-		}
+func fetchDAs(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.SubjectDAs, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
 	}
-	if len(semesters) == 0 {
-		return nil, errors.New("no semesters available")
+	subjects, err := latestWithData(ctx, s, opts, func(sem types.Semester) ([]types.DAsubject, bool, error) {
+		subjects, err := getAllSubs(ctx, s, sem.SemID)
+		return subjects, len(subjects) > 0, err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var subjects []types.DAsubject
-	for i := 0; i < len(semesters); i++ {
-		subjects = getAllSubs(regNo, cookies, semesters[i].SemID)
-		if len(subjects) > 0 {
-			break
+	var subjDAs []types.SubjectDAs
+	for _, subject := range subjects {
+		doc, err := getOneSub(ctx, s, subject.ID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if debug.Debug {
+				fmt.Fprintf(helpers.Messages, "Error fetching details for subject code %s: %v\n", subject.ID, err)
+			}
+			continue
 		}
+		_, subjectDAs := pendingDAs(doc, subject)
+		subjDAs = append(subjDAs, subjectDAs)
 	}
+	return subjDAs, nil
+}
 
-	if len(subjects) == 0 {
-		return []types.AssignmentSummary{}, nil
+// FetchPendingAssignments returns the DAs that have not been uploaded yet.
+func FetchPendingAssignments(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.AssignmentSummary, error) {
+	subjDAs, err := FetchDAs(ctx, s, opts)
+	if err != nil {
+		return nil, err
 	}
+	return assignmentSummaries(subjDAs, true), nil
+}
 
+// assignmentSummaries flattens DAs into one list, optionally keeping only
+// those with nothing uploaded.
+func assignmentSummaries(subjDAs []types.SubjectDAs, pendingOnly bool) []types.AssignmentSummary {
 	var assignments []types.AssignmentSummary
-
-	for _, subject := range subjects {
-		doc := getOneSub(regNo, cookies, subject.ID)
-		if doc == nil {
-			continue
-		}
-
-		_, subjectAssignments := pendingDAs(doc, subject)
-		for _, da := range subjectAssignments.DAs {
-			pendingUpload := strings.EqualFold(da.Last_upload, "n/a") ||
-				strings.EqualFold(da.Last_upload, "file not uploaded") ||
-				strings.TrimSpace(da.Last_upload) == ""
-
-			if !pendingUpload {
+	for _, subject := range subjDAs {
+		for _, da := range subject.DAs {
+			if pendingOnly && !notUploaded(da) {
 				continue
 			}
-
-			assignment := types.AssignmentSummary{
-				CourseCode: subject.Code,
-				CourseName: subject.Name,
+			assignments = append(assignments, types.AssignmentSummary{
+				CourseCode: subject.Subject.Code,
+				CourseName: subject.Subject.Name,
 				Title:      da.Title,
+				DueDate:    da.DueDate,
 				Status:     da.Last_upload,
 				Link:       da.DownloadLink,
-			}
-
-			if !da.DueDate.IsZero() {
-				assignment.DueDate = da.DueDate
-			}
-
-			assignments = append(assignments, assignment)
+			})
 		}
 	}
-
-	return assignments, nil
+	return assignments
 }
 
-func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
+// notUploaded reports whether nothing has been uploaded for da.
+func notUploaded(da types.DAEvent) bool {
+	return strings.EqualFold(da.Last_upload, "n/a") ||
+		strings.EqualFold(da.Last_upload, "file not uploaded") ||
+		strings.TrimSpace(da.Last_upload) == ""
+}
 
-	allSems, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error retrieving semester details:", err)
-		}
-		allSems, err = helpers.GetSemDetailsBackup(cookies, regNo)
-		if err != nil {
-			fmt.Println("Error retrieving semester details:", err)
-			return
-		}
-	}
-	if len(allSems) == 0 {
-		fmt.Println("No semesters found.")
+// ShowDAs lists each course's assignments and lets the student open one to
+// download its question paper, or prints them in the --output format.
+func ShowDAs(ctx context.Context, s types.Session, subjDAs []types.SubjectDAs) {
+	if structuredOutput(assignmentSummaries(subjDAs, false)) {
 		return
 	}
-
-	var semID string
-	var listOfSubjects []types.DAsubject
-
-	for i := len(allSems) - 1; i >= 0; i-- {
-		semID = allSems[i].SemID
-		listOfSubjects = getAllSubs(regNo, cookies, semID)
-		if len(listOfSubjects) > 0 {
-			if debug.Debug {
-				fmt.Printf("Selected Semester: %s (%s)\n", allSems[i].SemName, semID)
-			}
-			break
-		} else {
-			if debug.Debug {
-				fmt.Printf("No subjects found for Semester: %s (%s). Trying previous semester.\n", allSems[i].SemName, semID)
-			}
-		}
-	}
-
-	if len(listOfSubjects) == 0 {
-		fmt.Println("No subjects available in any semester.")
+	if len(subjDAs) == 0 {
+		fmt.Fprintln(helpers.Messages, "No subjects available in any semester.")
 		return
 	}
 
 	var (
-		subjDAs        []types.SubjectDAs
 		allUpcomingDAs []types.DAEvent
 		subjectsTable  [][]string
 		subjectIDs     []string
@@ -144,16 +119,8 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 
 	today := time.Now().Truncate(24 * time.Hour)
 
-	for _, detail := range listOfSubjects {
-		doc := getOneSub(regNo, cookies, detail.ID)
-		if doc == nil {
-			if debug.Debug {
-				fmt.Printf("Document for subject ID %s is nil. Skipping.\n", detail.ID)
-			}
-			continue
-		}
-		_, singleSubAllDa := pendingDAs(doc, detail)
-		subjDAs = append(subjDAs, singleSubAllDa)
+	for _, singleSubAllDa := range subjDAs {
+		detail := singleSubAllDa.Subject
 		subjectIDs = append(subjectIDs, detail.ID)
 
 		var nextDueDate string = "N/A"
@@ -183,17 +150,17 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 					(qpNormalized == "no" && lastUploadNormalized == "n/a") {
 					allUpcomingDAs = append(allUpcomingDAs, da)
 					if debug.Debug {
-						fmt.Printf("Identified Upcoming DA: Title='%s', QP='%s', DueDate='%s', Last_upload='%s'\n",
+						fmt.Fprintf(helpers.Messages, "Identified Upcoming DA: Title='%s', QP='%s', DueDate='%s', Last_upload='%s'\n",
 							da.Title, da.QP, da.DueDate.Format(time.RFC3339), da.Last_upload)
 					}
 				} else {
 					if debug.Debug {
-						fmt.Printf("DA '%s' does not meet upcoming criteria.\n", da.Title)
+						fmt.Fprintf(helpers.Messages, "DA '%s' does not meet upcoming criteria.\n", da.Title)
 					}
 				}
 			} else {
 				if debug.Debug {
-					fmt.Printf("DA '%s' is not upcoming. DueDate: '%s'\n", da.Title, da.DueDate.Format(time.RFC3339))
+					fmt.Fprintf(helpers.Messages, "DA '%s' is not upcoming. DueDate: '%s'\n", da.Title, da.DueDate.Format(time.RFC3339))
 				}
 			}
 		}
@@ -237,30 +204,30 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 		// Create the Other Downloads/ICS File directory for DA deadlines
 		icsDir, err := helpers.GetOrCreateDownloadDir(filepath.Join("Other Downloads", "ICS File"))
 		if err != nil {
-			fmt.Println("Error creating ICS file directory:", err)
+			fmt.Fprintln(helpers.Messages, "Error creating ICS file directory:", err)
 		} else {
 			icsFileName := "All_DA_Deadlines.ics"
 			icsFilePath = filepath.Join(icsDir, icsFileName)
 
 			err := helpers.GenerateICSFileDateOnly(icsEvents, icsFilePath, "CLI-TOP DA")
 			if err != nil {
-				fmt.Println("Error generating ICS file:", err)
+				fmt.Fprintln(helpers.Messages, "Error generating ICS file:", err)
 			} else {
 				uploadedFileURL, err = helpers.UploadICSFile(icsFilePath, helpers.CalendarServerURL)
 				if err != nil {
-					fmt.Println("Error uploading ICS file:", err)
-					fmt.Println("Please import the 'All_DA_Deadlines.ics' file manually from your Downloads folder.")
+					fmt.Fprintln(helpers.Messages, "Error uploading ICS file:", err)
+					fmt.Fprintln(helpers.Messages, "Please import the 'All_DA_Deadlines.ics' file manually from your Downloads folder.")
 				} else {
 					icsGenerated = true
 					if debug.Debug {
-						fmt.Println("ICS file uploaded successfully. URL:", uploadedFileURL)
+						fmt.Fprintln(helpers.Messages, "ICS file uploaded successfully. URL:", uploadedFileURL)
 					}
 				}
 			}
 		}
 	} else {
 		if debug.Debug {
-			fmt.Println("No upcoming DAs found. Skipping ICS generation.")
+			fmt.Fprintln(helpers.Messages, "No upcoming DAs found. Skipping ICS generation.")
 		}
 	}
 
@@ -271,10 +238,10 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 		if icsGenerated {
 			helpers.GenerateCalendarImportLinks(uploadedFileURL, "DAs")
 		}
-		fmt.Println("\nPlease select a subject by entering the corresponding number:")
+		fmt.Fprintln(helpers.Messages, "\nPlease select a subject by entering the corresponding number:")
 		subjectChoice := helpers.TableSelector("subject", subjectsTable, "0")
 		if subjectChoice.ExitRequest || !subjectChoice.Selected {
-			fmt.Println("Selection canceled")
+			fmt.Fprintln(helpers.Messages, "Selection canceled")
 			return
 		}
 
@@ -344,13 +311,13 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 		if len(singleSubDownload) > 1 {
 			downloadChoice := helpers.TableSelector("DA", singleSubDownload, "0")
 			if downloadChoice.ExitRequest || !downloadChoice.Selected {
-				fmt.Println("Selection canceled")
+				fmt.Fprintln(helpers.Messages, "Selection canceled")
 				return
 			}
 
 			selectedDA := singleSubDownload[downloadChoice.Index]
 			if selectedDA[4] == "No" {
-				fmt.Println("No question papers available for this DA.")
+				fmt.Fprintln(helpers.Messages, "No question papers available for this DA.")
 				return
 			}
 
@@ -384,43 +351,42 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 			}
 
 			if selectedCode == "" || selectedClassID == "" {
-				fmt.Println("Download link not found for the selected DA.")
+				fmt.Fprintln(helpers.Messages, "Download link not found for the selected DA.")
 				return
 			}
 
 			baseURL := "https://vtop.vit.ac.in/vtop/examinations/doDownloadQuestion/"
-			cleanCSRF := strings.Trim(os.Getenv("CSRF"), "\"")
 			payloadMap := map[string]string{
-				"_csrf":         cleanCSRF,
-				"authorizedID":  regNo,
+				"_csrf":         s.Cookies.CSRF,
+				"authorizedID":  s.RegNo,
 				"code":          selectedCode,
 				"classIdNumber": selectedClassID,
 				"x":             fmt.Sprintf("%d", time.Now().Unix()),
 			}
 			formData := helpers.FormatBodyDataClient(payloadMap)
 
-			client := &http.Client{Timeout: 30 * time.Second}
-			body, headers, err := helpers.FetchReqClient(client, regNo, cookies, baseURL, "", formData, "POST", "application/x-www-form-urlencoded")
+			resp, err := helpers.VtopFor(ctx).Do(ctx, helpers.VtopRequest{URL: baseURL, Body: string(formData), Timeout: 30 * time.Second})
 			if err != nil {
 				if debug.Debug {
-					fmt.Println("Error fetching DA download:", err)
+					fmt.Fprintln(helpers.Messages, "Error fetching DA download:", err)
 				}
-				fmt.Println("Failed to download the selected DA.")
+				fmt.Fprintln(helpers.Messages, "Failed to download the selected DA.")
 				return
 			}
+			body, headers := resp.Body, resp.Header
 
 			defaultName := "downloadedFile.pdf"
 			ext := helpers.GetFileExtension(defaultName, body, headers)
 			if debug.Debug {
-				fmt.Printf("Determined file extension: %s\n", ext)
+				fmt.Fprintf(helpers.Messages, "Determined file extension: %s\n", ext)
 			}
 
 			var selectedSubjectName string
 			var selectedSubjectCode string
-			for _, detail := range listOfSubjects {
-				if detail.ID == selectedSubjectID {
-					selectedSubjectName = detail.Name
-					selectedSubjectCode = detail.Code
+			for _, subject := range subjDAs {
+				if subject.Subject.ID == selectedSubjectID {
+					selectedSubjectName = subject.Subject.Name
+					selectedSubjectCode = subject.Subject.Code
 					break
 				}
 			}
@@ -432,7 +398,7 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 			fileName := fmt.Sprintf("%s%s", selectedDA[0], ext)
 			daDir, err := helpers.GetOrCreateDownloadDir("DA")
 			if err != nil {
-				fmt.Println("Error creating DA download directory:", err)
+				fmt.Fprintln(helpers.Messages, "Error creating DA download directory:", err)
 				return
 			}
 
@@ -442,7 +408,7 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 
 			// Create the course directory
 			if err := os.MkdirAll(courseDir, os.ModePerm); err != nil {
-				fmt.Println("Error creating course directory:", err)
+				fmt.Fprintln(helpers.Messages, "Error creating course directory:", err)
 				return
 			}
 
@@ -450,14 +416,14 @@ func PrintAllDAs(regNo string, cookies types.Cookies, courseName string) {
 
 			err = helpers.SaveFile(body, filePath)
 			if err != nil {
-				fmt.Println("Error saving file:", err)
+				fmt.Fprintln(helpers.Messages, "Error saving file:", err)
 				return
 			}
-			fmt.Printf("File saved to: %s\n", filePath)
+			fmt.Fprintf(helpers.Messages, "File saved to: %s\n", filePath)
 
-			fmt.Println()
-			fmt.Printf("\033]8;;file://%s\a\033[34mClick Here\033[0m\033]8;;\a\n", filePath)
-			fmt.Println()
+			fmt.Fprintln(helpers.Messages)
+			fmt.Fprintf(helpers.Messages, "\033]8;;file://%s\a\033[34mClick Here\033[0m\033]8;;\a\n", filePath)
+			fmt.Fprintln(helpers.Messages)
 
 			openFile(filePath)
 		}
@@ -483,35 +449,27 @@ func openFile(filePath string) {
 			} else if _, err := exec.LookPath("gio"); err == nil {
 				cmd = exec.Command("gio", "open", filePath)
 			} else {
-				fmt.Println("No supported command found to open the file automatically. Please open it manually:", filePath)
+				fmt.Fprintln(helpers.Messages, "No supported command found to open the file automatically. Please open it manually:", filePath)
 				return
 			}
 		}
 	}
 	if err := cmd.Start(); err != nil {
-		fmt.Printf("Error opening file: %v\n", err)
+		fmt.Fprintf(helpers.Messages, "Error opening file: %v\n", err)
 	}
 }
 
-func getAllSubs(regNo string, cookies types.Cookies, semID string) []types.DAsubject {
+func getAllSubs(ctx context.Context, s types.Session, semID string) ([]types.DAsubject, error) {
 	url := "https://vtop.vit.ac.in/vtop/examinations/doDigitalAssignment"
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, semID, "UTC", "POST", "")
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, url, helpers.SemesterForm(s.RegNo, s.Cookies, semID))
 	if err != nil {
-		if debug.Debug {
-			fmt.Printf("Error fetching subjects: %v\n", err)
-		}
-		fmt.Println("Failed to fetch subjects.")
-		return nil
+		return nil, fmt.Errorf("fetching subjects: %w", err)
 	}
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
 	if err != nil {
-		if debug.Debug {
-			fmt.Printf("Error parsing subjects document: %v\n", err)
-		}
-		fmt.Println("Failed to parse subjects data.")
-		return nil
+		return nil, fmt.Errorf("parsing subjects: %w", err)
 	}
-	return allSubDetails(doc)
+	return allSubDetails(doc), nil
 }
 
 func allSubDetails(doc *goquery.Document) []types.DAsubject {
@@ -533,37 +491,25 @@ func allSubDetails(doc *goquery.Document) []types.DAsubject {
 		allsubs = append(allsubs, tempsub)
 	})
 	if debug.Debug {
-		fmt.Printf("Found %d unique subjects.\n", len(allsubs))
+		fmt.Fprintf(helpers.Messages, "Found %d unique subjects.\n", len(allsubs))
 	}
 	return allsubs
 }
 
-func getOneSub(regNo string, cookies types.Cookies, code string) *goquery.Document {
+func getOneSub(ctx context.Context, s types.Session, code string) (*goquery.Document, error) {
 	url := "https://vtop.vit.ac.in/vtop/examinations/processDigitalAssignment"
 	payloadMap := map[string]string{
-		"_csrf":        cookies.CSRF,
+		"_csrf":        s.Cookies.CSRF,
 		"classId":      code,
-		"authorizedID": regNo,
+		"authorizedID": s.RegNo,
 		"x":            fmt.Sprintf("%d", time.Now().Unix()),
 	}
 	formData := helpers.FormatBodyData(payloadMap)
-	subBody, err := helpers.FetchReq(regNo, cookies, url, "", formData, "POST", "")
-	if err != nil {
-		if debug.Debug {
-			fmt.Printf("Error fetching subject details for code %s: %v\n", code, err)
-		}
-		fmt.Printf("Failed to fetch details for subject code: %s\n", code)
-		return nil
-	}
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(subBody)))
+	subBody, err := helpers.VtopFor(ctx).Post(ctx, url, formData)
 	if err != nil {
-		if debug.Debug {
-			fmt.Printf("Error parsing subject details document for code %s: %v\n", code, err)
-		}
-		fmt.Printf("Failed to parse details for subject code: %s\n", code)
-		return nil
+		return nil, err
 	}
-	return doc
+	return goquery.NewDocumentFromReader(strings.NewReader(string(subBody)))
 }
 
 func pendingDAs(doc *goquery.Document, subject types.DAsubject) (types.LatestDA, types.SubjectDAs) {
@@ -602,7 +548,7 @@ func pendingDAs(doc *goquery.Document, subject types.DAsubject) (types.LatestDA,
 					date, err := time.Parse("02-Jan-2006", dueDateStr)
 					if err != nil {
 						if debug.Debug {
-							fmt.Printf("Error parsing date %s: %v\n", dueDateStr, err)
+							fmt.Fprintf(helpers.Messages, "Error parsing date %s: %v\n", dueDateStr, err)
 						}
 						dueDate = time.Time{}
 					} else {
@@ -667,7 +613,7 @@ func pendingDAs(doc *goquery.Document, subject types.DAsubject) (types.LatestDA,
 
 				events.DAs = append(events.DAs, tempDA)
 				if debug.Debug {
-					fmt.Printf("Parsed DA: %+v\n", tempDA)
+					fmt.Fprintf(helpers.Messages, "Parsed DA: %+v\n", tempDA)
 				}
 			})
 		}
@@ -2,10 +2,7 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
-	"errors"
+	"context"
 	"fmt"
 	"path/filepath"
 	"sort"
@@ -13,6 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -22,129 +22,61 @@ const (
 	ExamCellSelector  = "td"
 )
 
-// FetchExamScheduleData retrieves exam schedule details for the latest semester.
-func FetchExamScheduleData(regNo string, cookies types.Cookies) ([]types.ExamEvent, error) {
-	if !helpers.ValidateLogin(cookies) {
-		return nil, errors.New("invalid login session")
-	}
-
-	semesters, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		return nil, err
-	}
-	if len(semesters) == 0 {
-		return nil, errors.New("no semesters available")
-	}
-
-	url := "https://vtop.vit.ac.in/vtop/examinations/doSearchExamScheduleForStudent"
+const examScheduleURL = "https://vtop.vit.ac.in/vtop/examinations/doSearchExamScheduleForStudent"
 
-	for i := 0; i < len(semesters); i++ {
-		semID := semesters[i].SemID
-		bodyText, err := helpers.FetchReq(regNo, cookies, url, semID, "UTC", "POST", "")
-		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error fetching exam schedule for %s: %v\n", semesters[i].SemName, err)
-			}
-			continue
-		}
-
-		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error parsing exam html for %s: %v\n", semesters[i].SemName, err)
-			}
-			continue
-		}
-
-		exams, err := parseExamSchedule(doc)
-		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error extracting exams for %s: %v\n", semesters[i].SemName, err)
-			}
-			continue
-		}
-
-		if len(exams) > 0 {
-			return exams, nil
-		}
-	}
-
-	return []types.ExamEvent{}, nil
+// FetchExams returns every exam in a semester's schedule, by default the
+// latest semester that has one.
+func FetchExams(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.ExamEvent, error) {
+	return cached(ctx, s, datasetKey("exams", opts), func() ([]types.ExamEvent, error) {
+		return fetchExams(ctx, s, opts)
+	})
 }
 
-func GetExamSchedule(regNo string, cookies types.Cookies, sem_choice int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-
-	url := "https://vtop.vit.ac.in/vtop/examinations/doSearchExamScheduleForStudent"
-
-	allSems, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error retrieving semester details:", err)
-		}
-		fmt.Println("Failed to retrieve semester details.")
-		return
-	}
-
-	if len(allSems) == 0 {
-		fmt.Println("No semester details found.")
-		return
+func fetchExams(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.ExamEvent, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
 	}
-
-	var semID string
-	var allExams []types.ExamEvent
-
-	for i := len(allSems) - 1; i >= 0; i-- {
-		semID = allSems[i].SemID
-		bodyText, err := helpers.FetchReq(regNo, cookies, url, semID, "UTC", "POST", "")
+	return latestWithData(ctx, s, opts, func(sem types.Semester) ([]types.ExamEvent, bool, error) {
+		bodyText, err := helpers.VtopFor(ctx).Post(ctx, examScheduleURL, helpers.SemesterForm(s.RegNo, s.Cookies, sem.SemID))
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("Error fetching exam schedule for Semester %s: %v\n", allSems[i].SemName, err)
-			}
-			continue
+			return nil, false, err
 		}
-
 		if debug.Debug {
-			fmt.Printf("HTML Response for Semester %s:\n%s\n", allSems[i].SemName, string(bodyText))
+			fmt.Fprintf(helpers.Messages, "HTML Response for Semester %s:\n%s\n", sem.SemName, string(bodyText))
 		}
-
 		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("Error parsing HTML document for Semester %s: %v\n", allSems[i].SemName, err)
-			}
-			continue
+			return nil, false, err
 		}
-
-		allExams, err = parseExamSchedule(doc)
+		exams, err := parseExamSchedule(doc)
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("Error parsing exam schedule for Semester %s: %v\n", allSems[i].SemName, err)
-			}
-			continue
+			return nil, false, err
 		}
+		return exams, len(exams) > 0, nil
+	})
+}
 
-		if len(allExams) > 0 {
-			if debug.Debug {
-				fmt.Printf("Selected Semester: %s (%s)\n", allSems[i].SemName, semID)
-			}
-			break
-		} else {
-			if debug.Debug {
-				fmt.Printf("No exams found for Semester: %s (%s). Trying previous semester.\n", allSems[i].SemName, semID)
-			}
-		}
+// ShowExams prints the upcoming exams among allExams by category and
+// exports them to a calendar file, or prints them in the --output format.
+func ShowExams(allExams []types.ExamEvent) {
+	upcoming := filterAndSortUpcomingExams(allExams)
+	if structuredOutput(upcoming) {
+		return
 	}
 
 	if len(allExams) == 0 {
-		fmt.Println("No exams scheduled in this Semester.")
+		fmt.Fprintln(helpers.Messages, "No exams scheduled in this Semester.")
 		return
 	}
+	if renderExams(upcoming) {
+		// Generate ICS file with all upcoming exams
+		generateICSFile(upcoming)
+	}
+}
 
-	// Filter and sort upcoming exams
-	allExams = filterAndSortUpcomingExams(allExams)
+// renderExams prints upcoming exams grouped by category and reports whether
+// there were any.
+func renderExams(allExams []types.ExamEvent) bool {
 	// Group exams by category
 	var cat1Exams, cat2Exams, mtExams, fatExams []types.ExamEvent
 	for _, exam := range allExams {
@@ -162,38 +94,34 @@ func GetExamSchedule(regNo string, cookies types.Cookies, sem_choice int) {
 
 	totalExams := len(cat1Exams) + len(cat2Exams) + len(mtExams) + len(fatExams)
 	if totalExams == 0 {
-		fmt.Println("No exams scheduled yet. Try checking VTOP.")
-		return
+		fmt.Fprintln(helpers.Messages, "No exams scheduled yet. Try checking VTOP.")
+		return false
 	}
 
 	// Display the grouped exams
 	if len(cat1Exams) > 0 {
-		fmt.Println("\nCAT1 EXAMS")
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages, "\nCAT1 EXAMS")
+		fmt.Fprintln(helpers.Messages)
 		displayExamScheduleTable(cat1Exams)
 	}
 	if len(cat2Exams) > 0 {
-		fmt.Println("\nCAT2 EXAMS")
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages, "\nCAT2 EXAMS")
+		fmt.Fprintln(helpers.Messages)
 		displayExamScheduleTable(cat2Exams)
 	}
 
 	if len(mtExams) > 0 {
-		fmt.Println("\nMID-TERM EXAMS")
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages, "\nMID-TERM EXAMS")
+		fmt.Fprintln(helpers.Messages)
 		displayExamScheduleTable(mtExams)
 	}
 
 	if len(fatExams) > 0 {
-		fmt.Println("\nFAT EXAMS")
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages, "\nFAT EXAMS")
+		fmt.Fprintln(helpers.Messages)
 		displayExamScheduleTable(fatExams)
 	}
-
-	// Generate ICS file with all upcoming exams
-	if len(allExams) > 0 {
-		generateICSFile(allExams)
-	}
+	return true
 }
 
 func safeGetCellText(cells *goquery.Selection, index int) string {
@@ -215,7 +143,7 @@ func parseExamSchedule(doc *goquery.Document) ([]types.ExamEvent, error) {
 		if s.Find("td.panelHead-secondary").Length() > 0 {
 			headerText := strings.TrimSpace(s.Find("td.panelHead-secondary").Text())
 			if debug.Debug {
-				fmt.Printf("Found exam section header: %s\n", headerText)
+				fmt.Fprintf(helpers.Messages, "Found exam section header: %s\n", headerText)
 			}
 			currentExamType = headerText
 			return
@@ -224,7 +152,7 @@ func parseExamSchedule(doc *goquery.Document) ([]types.ExamEvent, error) {
 		cells := s.Find(ExamCellSelector)
 		if cells.Length() < 8 {
 			if debug.Debug {
-				fmt.Printf("Skipping row %d with insufficient cells (%d)\n", i+1, cells.Length())
+				fmt.Fprintf(helpers.Messages, "Skipping row %d with insufficient cells (%d)\n", i+1, cells.Length())
 			}
 			return
 		}
@@ -232,7 +160,7 @@ func parseExamSchedule(doc *goquery.Document) ([]types.ExamEvent, error) {
 		serialNo := safeGetCellText(cells, 0)
 		if _, err := strconv.Atoi(serialNo); err != nil {
 			if debug.Debug {
-				fmt.Printf("Skipping non-data row %d with serial '%s'\n", i+1, serialNo)
+				fmt.Fprintf(helpers.Messages, "Skipping non-data row %d with serial '%s'\n", i+1, serialNo)
 			}
 			return
 		}
@@ -254,7 +182,7 @@ func parseExamSchedule(doc *goquery.Document) ([]types.ExamEvent, error) {
 		examDate, err := time.ParseInLocation("02-Jan-2006", examDateStr, now.Location())
 		if err != nil {
 			if debug.Debug {
-				fmt.Printf("Error parsing exam date '%s': %v\n", examDateStr, err)
+				fmt.Fprintf(helpers.Messages, "Error parsing exam date '%s': %v\n", examDateStr, err)
 			}
 			return
 		}
@@ -297,7 +225,7 @@ func parseExamSchedule(doc *goquery.Document) ([]types.ExamEvent, error) {
 				fatCount++
 			}
 		}
-		fmt.Printf("Parsed exams: FAT=%d, CAT1=%d, CAT2=%d, MT=%d\n", fatCount, cat1Count, cat2Count, mtCount)
+		fmt.Fprintf(helpers.Messages, "Parsed exams: FAT=%d, CAT1=%d, CAT2=%d, MT=%d\n", fatCount, cat1Count, cat2Count, mtCount)
 	}
 
 	return allExams, nil
@@ -330,7 +258,7 @@ func generateICSFile(exams []types.ExamEvent) {
 	for _, exam := range exams {
 		timeRange := strings.Split(exam.ExamTime, " - ")
 		if len(timeRange) != 2 {
-			fmt.Println("Invalid time range format")
+			fmt.Fprintln(helpers.Messages, "Invalid time range format")
 			continue
 		}
 
@@ -341,7 +269,7 @@ func generateICSFile(exams []types.ExamEvent) {
 		endTime, err2 := time.Parse(inputTimeLayout, timeRange[1])
 
 		if err1 != nil || err2 != nil {
-			fmt.Println("Error parsing time range:", err1, err2)
+			fmt.Fprintln(helpers.Messages, "Error parsing time range:", err1, err2)
 			continue
 		}
 
@@ -384,7 +312,7 @@ func generateICSFile(exams []types.ExamEvent) {
 	// Create the Other Downloads/ICS File directory
 	icsDir, err := helpers.GetOrCreateDownloadDir(filepath.Join("Other Downloads", "ICS File"))
 	if err != nil {
-		fmt.Println("Error creating ICS file directory:", err)
+		fmt.Fprintln(helpers.Messages, "Error creating ICS file directory:", err)
 		return
 	}
 
@@ -393,15 +321,15 @@ func generateICSFile(exams []types.ExamEvent) {
 
 	err = helpers.VenueAdd(icsEvents, icsFilePath, "CLI-TOP Exams")
 	if err != nil {
-		fmt.Println("Error generating ICS file:", err)
+		fmt.Fprintln(helpers.Messages, "Error generating ICS file:", err)
 	} else {
 		uploadedFileURL, err := helpers.UploadICSFile(icsFilePath, helpers.CalendarServerURL)
 		if err != nil {
-			fmt.Println("Error uploading ICS file:", err)
-			fmt.Println("Please import the 'Exam_Schedule.ics' file manually from your Downloads folder.")
+			fmt.Fprintln(helpers.Messages, "Error uploading ICS file:", err)
+			fmt.Fprintln(helpers.Messages, "Please import the 'Exam_Schedule.ics' file manually from your Downloads folder.")
 		} else {
-			fmt.Println()
-			fmt.Println("ICS file generated and saved successfully.")
+			fmt.Fprintln(helpers.Messages)
+			fmt.Fprintln(helpers.Messages, "ICS file generated and saved successfully.")
 			helpers.GenerateCalendarImportLinks(uploadedFileURL, "Exams")
 		}
 	}
@@ -456,7 +384,7 @@ func displayExamScheduleTable(exams []types.ExamEvent) {
 		})
 	}
 	if len(tableData) == 1 {
-		fmt.Println("No upcoming exams scheduled!")
+		fmt.Fprintln(helpers.Messages, "No upcoming exams scheduled!")
 	} else {
 		helpers.PrintTable(tableData, 1)
 	}
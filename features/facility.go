@@ -3,9 +3,7 @@ package features
 import (
 	"bufio"
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"os"
 	"regexp"
@@ -13,6 +11,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -23,49 +24,35 @@ const (
 	FacilityPanelHeadingSelector = "div.panel-heading.panel-head-custom"
 )
 
-// RegisterPhyFacility fetches and displays physical education facilities available for registration.
-// When FacilityRegistrationEnabled is set to 1, it allows interactive registration.
-// When FacilityRegistrationEnabled is set to 0, it only displays the facilities without prompting for registration.
-func RegisterPhyFacility(regNo string, cookies types.Cookies) {
-	if !helpers.ValidateLogin(cookies) {
-		return
+// FetchFacilities returns the physical education facilities open for
+// registration, with those the student has registered for marked, and the
+// student's registrations.
+func FetchFacilities(ctx context.Context, s types.Session) (types.Facilities, error) {
+	if err := checkSession(s); err != nil {
+		return types.Facilities{}, err
 	}
+	url := "https://vtop.vit.ac.in/vtop/phyedu/facilityAvailable"
 
-	killSwitch := helpers.CheckKillSwitch()
-	if killSwitch == 4 {
-		// fmt.Println("This feature is currently disabled by the administrator (killswitch=4). View-only mode enabled.")
-		registrations, err := ListRegistrations(regNo, cookies)
-		if err != nil {
-			fmt.Println("Error fetching registrations:", err)
-			registrations = []types.Registration{}
-		}
-		facilities, err := fetchAvailableFacilities(regNo, cookies)
-		if err != nil {
-			fmt.Println("Error fetching facilities:", err)
-		}
-		displayFacilities(facilities, registrations)
-		return
-	}
+	nocache := fmt.Sprintf("%d", time.Now().UnixMilli())
 
-	registrations, err := ListRegistrations(regNo, cookies)
-	if err != nil {
-		fmt.Println("Error fetching registrations:", err)
-		registrations = []types.Registration{}
-	}
+	payload := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%s",
+		s.RegNo,
+		s.Cookies.CSRF,
+		nocache,
+	)
 
-	facilities, err := fetchAvailableFacilities(regNo, cookies)
+	body, err := helpers.VtopFor(ctx).Post(ctx, url, payload)
 	if err != nil {
-		fmt.Println("Error fetching facilities:", err)
+		return types.Facilities{}, err
 	}
 
-	if len(facilities) == 0 && len(registrations) == 0 {
-		fmt.Println("No facilities or registrations found.")
-		return
-	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("Error fetching registrations:", err)
-		registrations = []types.Registration{}
+		return types.Facilities{}, err
 	}
+
+	facilities := parseFacilities(doc)
+	registrations := parseRegistrations(doc)
 	for _, reg := range registrations {
 		found := false
 		for idx, fac := range facilities {
@@ -86,78 +73,41 @@ func RegisterPhyFacility(regNo string, cookies types.Cookies) {
 			})
 		}
 	}
+	return types.Facilities{Facilities: facilities, Registrations: registrations}, nil
+}
 
-	displayFacilities(facilities, registrations)
-
-	if killSwitch == 4 {
-		// fmt.Println("Registration feature is currently in view-only mode.")
-		return
-	}
-
-	selectedFacility, err := promptFacilitySelection(facilities, nil)
-	if err != nil {
-		fmt.Println("Registration aborted:", err)
-		return
-	}
-
-	err = performRegistration(regNo, cookies, selectedFacility)
-	if err != nil {
-		fmt.Println("Error during registration:", err)
+// ShowFacilities lists the facilities in f with their fees and seats or the
+// student's registration, or prints them in the --output format.
+func ShowFacilities(f types.Facilities) {
+	if structuredOutput(f) {
 		return
 	}
-
-	fmt.Println("Registration completed successfully.")
-
-	updatedRegistrations, err := ListRegistrations(regNo, cookies)
-	if err != nil {
-		fmt.Println("Error fetching updated registrations:", err)
+	if len(f.Facilities) == 0 && len(f.Registrations) == 0 {
+		fmt.Fprintln(helpers.Messages, "No facilities or registrations found.")
 		return
 	}
-
-	for _, reg := range updatedRegistrations {
-		for idx, fac := range facilities {
-			if strings.EqualFold(strings.TrimSpace(fac.Name), strings.TrimSpace(reg.FacilityName)) {
-				facilities[idx].Registered = true
-				break
-			}
-		}
-	}
-
-	fmt.Println("\nYour Current Registrations:")
-	displayFacilities(facilities, updatedRegistrations)
+	displayFacilities(f.Facilities, f.Registrations)
 }
 
-func fetchAvailableFacilities(regNo string, cookies types.Cookies) ([]types.Facility, error) {
-	url := "https://vtop.vit.ac.in/vtop/phyedu/facilityAvailable"
-
-	nocache := fmt.Sprintf("%d", time.Now().UnixMilli())
-
-	payload := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%s",
-		regNo,
-		cookies.CSRF,
-		nocache,
-	)
-
-	body, err := helpers.FetchReq(regNo, cookies, url, "", payload, "POST", "application/x-www-form-urlencoded")
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error fetching facilities:", err)
-		}
-		return nil, err
+// SelectFacility asks which of the facilities in f to register for. It
+// reports false when the user cancels, and without asking when registration
+// is view-only or --output asks for data.
+func SelectFacility(f types.Facilities) (types.Facility, bool) {
+	if helpers.Structured() || len(f.Facilities) == 0 || helpers.CheckKillSwitch() == 4 {
+		return types.Facility{}, false
 	}
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	facility, err := promptFacilitySelection(f.Facilities, nil)
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error parsing HTML response:", err)
-		}
-		return nil, err
+		fmt.Fprintln(helpers.Messages, "Registration aborted:", err)
+		return types.Facility{}, false
 	}
+	return facility, true
+}
 
+func parseFacilities(doc *goquery.Document) []types.Facility {
 	var facilities []types.Facility
 
 	buttonRegex := regexp.MustCompile(`registerNow\(["']1["'],\s*["'](\d+)["']\)`)
-
 	doc.Find(FacilityTableSelector).Find(FacilityRowsSelector).Each(func(i int, s *goquery.Selection) {
 		if i == 0 {
 			cells := s.Find(FacilityCellSelector)
@@ -172,7 +122,7 @@ func fetchAvailableFacilities(regNo string, cookies types.Cookies) ([]types.Faci
 		cells := s.Find(FacilityCellSelector)
 		if cells.Length() < 4 {
 			if debug.Debug {
-				fmt.Printf("Skipping row %d: insufficient cells\n", i)
+				fmt.Fprintf(helpers.Messages, "Skipping row %d: insufficient cells\n", i)
 			}
 			return
 		}
@@ -191,12 +141,12 @@ func fetchAvailableFacilities(regNo string, cookies types.Cookies) ([]types.Faci
 		if !exists {
 			onclick = ""
 			if debug.Debug {
-				fmt.Printf("No onclick attribute found for facility: %s\n", name)
+				fmt.Fprintf(helpers.Messages, "No onclick attribute found for facility: %s\n", name)
 			}
 		}
 
 		if debug.Debug {
-			fmt.Printf("Facility: %s, onclick attribute: %s\n", name, onclick)
+			fmt.Fprintf(helpers.Messages, "Facility: %s, onclick attribute: %s\n", name, onclick)
 		}
 
 		matches := buttonRegex.FindStringSubmatch(onclick)
@@ -204,12 +154,12 @@ func fetchAvailableFacilities(regNo string, cookies types.Cookies) ([]types.Faci
 		if len(matches) == 2 {
 			miscID = matches[1]
 			if debug.Debug {
-				fmt.Printf("Extracted miscID: %s for facility: %s\n", miscID, name)
+				fmt.Fprintf(helpers.Messages, "Extracted miscID: %s for facility: %s\n", miscID, name)
 			}
 		} else {
 			miscID = ""
 			if debug.Debug {
-				fmt.Printf("Unable to extract miscID for facility: %s\n", name)
+				fmt.Fprintf(helpers.Messages, "Unable to extract miscID for facility: %s\n", name)
 			}
 		}
 
@@ -226,43 +176,17 @@ func fetchAvailableFacilities(regNo string, cookies types.Cookies) ([]types.Faci
 	})
 
 	if debug.Debug {
-		fmt.Printf("Parsed %d facilities.\n", len(facilities))
+		fmt.Fprintf(helpers.Messages, "Parsed %d facilities.\n", len(facilities))
 		for _, f := range facilities {
-			fmt.Printf("Facility: %s, Fees: %s, Seats Available: %d, MiscID: %s, Registered: %v\n",
+			fmt.Fprintf(helpers.Messages, "Facility: %s, Fees: %s, Seats Available: %d, MiscID: %s, Registered: %v\n",
 				f.Name, f.Fees, f.SeatsAvailable, f.MiscID, f.Registered)
 		}
 	}
 
-	return facilities, nil
+	return facilities
 }
 
-func ListRegistrations(regNo string, cookies types.Cookies) ([]types.Registration, error) {
-	url := "https://vtop.vit.ac.in/vtop/phyedu/facilityAvailable"
-
-	nocache := fmt.Sprintf("%d", time.Now().UnixMilli())
-
-	payload := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%s",
-		regNo,
-		cookies.CSRF,
-		nocache,
-	)
-
-	body, err := helpers.FetchReq(regNo, cookies, url, "", payload, "POST", "application/x-www-form-urlencoded")
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error fetching registrations:", err)
-		}
-		return nil, err
-	}
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error parsing HTML response:", err)
-		}
-		return nil, err
-	}
-
+func parseRegistrations(doc *goquery.Document) []types.Registration {
 	var registrations []types.Registration
 
 	registrationsHeader := doc.Find(FacilityPanelHeadingSelector).FilterFunction(func(i int, s *goquery.Selection) bool {
@@ -271,17 +195,17 @@ func ListRegistrations(regNo string, cookies types.Cookies) ([]types.Registratio
 
 	if registrationsHeader.Length() == 0 {
 		if debug.Debug {
-			fmt.Println("Registration section not found in the response.")
+			fmt.Fprintln(helpers.Messages, "Registration section not found in the response.")
 		}
-		return registrations, nil
+		return registrations
 	}
 
 	registrationsTable := registrationsHeader.NextAllFiltered("div.box-body").Find("table.dataTable").First()
 	if registrationsTable.Length() == 0 {
 		if debug.Debug {
-			fmt.Println("Registration table not found in the response.")
+			fmt.Fprintln(helpers.Messages, "Registration table not found in the response.")
 		}
-		return registrations, nil
+		return registrations
 	}
 
 	registrationsTable.Find(FacilityRowsSelector).Each(func(i int, s *goquery.Selection) {
@@ -303,18 +227,18 @@ func ListRegistrations(regNo string, cookies types.Cookies) ([]types.Registratio
 		registrations = append(registrations, registration)
 	})
 	if debug.Debug {
-		fmt.Printf("Parsed %d registrations.\n", len(registrations))
+		fmt.Fprintf(helpers.Messages, "Parsed %d registrations.\n", len(registrations))
 		for _, reg := range registrations {
-			fmt.Printf("Registered Facility: %s, Status: %s, Paid: %v\n", reg.FacilityName, reg.StatusMessage, reg.IsPaid)
+			fmt.Fprintf(helpers.Messages, "Registered Facility: %s, Status: %s, Paid: %v\n", reg.FacilityName, reg.StatusMessage, reg.IsPaid)
 		}
 	}
 
-	return registrations, nil
+	return registrations
 }
 
 func displayFacilities(facilities []types.Facility, registrations []types.Registration) {
 	if len(facilities) == 0 && len(registrations) > 0 {
-		fmt.Println("\nYour Current Registrations:")
+		fmt.Fprintln(helpers.Messages, "\nYour Current Registrations:")
 		nestedList := [][]string{
 			{"No.", "Facility Name", "Status"},
 		}
@@ -334,9 +258,9 @@ func displayFacilities(facilities []types.Facility, registrations []types.Regist
 			})
 		}
 
-		fmt.Println()
-		helpers.PrintTable(nestedList, 2)
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages)
+		helpers.DrawTable(nestedList, 2)
+		fmt.Fprintln(helpers.Messages)
 		return
 	}
 
@@ -375,20 +299,20 @@ func displayFacilities(facilities []types.Facility, registrations []types.Regist
 		})
 	}
 
-	fmt.Println()
-	helpers.PrintTable(nestedList, 2)
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
+	helpers.DrawTable(nestedList, 2)
+	fmt.Fprintln(helpers.Messages)
 }
 
 func promptFacilitySelection(facilities []types.Facility, registrationsMap map[string]bool) (types.Facility, error) {
 	reader := bufio.NewReader(os.Stdin)
 
 	for {
-		fmt.Print("Enter the number of the facility you want to register for (or type 'exit' to cancel): ")
+		fmt.Fprint(helpers.Messages, "Enter the number of the facility you want to register for (or type 'exit' to cancel): ")
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error reading input:", err)
+				fmt.Fprintln(helpers.Messages, "Error reading input:", err)
 			}
 			return types.Facility{}, fmt.Errorf("failed to read input")
 		}
@@ -400,26 +324,26 @@ func promptFacilitySelection(facilities []types.Facility, registrationsMap map[s
 
 		selection, err := strconv.Atoi(input)
 		if err != nil || selection < 1 || selection > len(facilities) {
-			fmt.Println("Invalid selection. Please enter a valid facility number.")
+			fmt.Fprintln(helpers.Messages, "Invalid selection. Please enter a valid facility number.")
 			continue
 		}
 
 		selectedFacility := facilities[selection-1]
 		if selectedFacility.Registered {
-			fmt.Println("You are already registered for this facility.")
+			fmt.Fprintln(helpers.Messages, "You are already registered for this facility.")
 			continue
 		}
 		if selectedFacility.MiscID == "" || selectedFacility.SeatsAvailable <= 0 {
-			fmt.Println("Selected facility is full or cannot be registered. Please choose another facility.")
+			fmt.Fprintln(helpers.Messages, "Selected facility is full or cannot be registered. Please choose another facility.")
 			continue
 		}
 
-		fmt.Printf("You have selected '%s' with %d seats available.\n", selectedFacility.Name, selectedFacility.SeatsAvailable)
-		fmt.Print("Do you want to proceed with registration? (yes/no): ")
+		fmt.Fprintf(helpers.Messages, "You have selected '%s' with %d seats available.\n", selectedFacility.Name, selectedFacility.SeatsAvailable)
+		fmt.Fprint(helpers.Messages, "Do you want to proceed with registration? (yes/no): ")
 		confirmInput, err := reader.ReadString('\n')
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error reading confirmation:", err)
+				fmt.Fprintln(helpers.Messages, "Error reading confirmation:", err)
 			}
 			return types.Facility{}, fmt.Errorf("failed to read confirmation")
 		}
@@ -430,16 +354,20 @@ func promptFacilitySelection(facilities []types.Facility, registrationsMap map[s
 		} else if confirmInput == "no" || confirmInput == "n" {
 			return types.Facility{}, fmt.Errorf("user declined the registration")
 		} else {
-			fmt.Println("Invalid input. Please respond with 'yes' or 'no'.")
+			fmt.Fprintln(helpers.Messages, "Invalid input. Please respond with 'yes' or 'no'.")
 			continue
 		}
 	}
 }
 
-func performRegistration(regNo string, cookies types.Cookies, facility types.Facility) error {
+// RegisterFacility registers the student for facility, one from
+// FetchFacilities with seats left, and returns VTOP's confirmation.
+func RegisterFacility(ctx context.Context, s types.Session, facility types.Facility) (string, error) {
+	if err := checkSession(s); err != nil {
+		return "", err
+	}
 	if facility.ID == "" || facility.MiscID == "" {
-		fmt.Println("Cannot proceed with registration due to missing facility identifiers.")
-		return fmt.Errorf("missing facility identifiers")
+		return "", fmt.Errorf("missing facility identifiers")
 	}
 
 	url := "https://vtop.vit.ac.in/vtop/phyedu/PhyFacilityProcessRegistration"
@@ -447,27 +375,21 @@ func performRegistration(regNo string, cookies types.Cookies, facility types.Fac
 	xTime := time.Now().UTC().Format(time.RFC1123)
 
 	payload := fmt.Sprintf("_csrf=%s&authorizedID=%s&x=%s&facilityId=%s&miscId=%s",
-		cookies.CSRF,
-		regNo,
+		s.Cookies.CSRF,
+		s.RegNo,
 		xTime,
 		facility.ID,
 		facility.MiscID,
 	)
 
-	body, err := helpers.FetchReq(regNo, cookies, url, "", payload, "POST", "application/x-www-form-urlencoded")
+	body, err := helpers.VtopFor(ctx).Submit(ctx, url, payload)
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error initiating physical education facility registration:", err)
-		}
-		return err
+		return "", err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error parsing HTML response:", err)
-		}
-		return err
+		return "", err
 	}
 
 	registrationsHeader := doc.Find("div.panel-heading.panel-head-custom").FilterFunction(func(i int, s *goquery.Selection) bool {
@@ -475,14 +397,12 @@ func performRegistration(regNo string, cookies types.Cookies, facility types.Fac
 	})
 
 	if registrationsHeader.Length() == 0 {
-		fmt.Println("Registration confirmation section not found in the response.")
-		return fmt.Errorf("registration confirmation section not found")
+		return "", fmt.Errorf("registration confirmation section not found")
 	}
 
 	registrationsTable := registrationsHeader.NextAllFiltered("div.box-body").Find("table.dataTable").First()
 	if registrationsTable.Length() == 0 {
-		fmt.Println("Registration table not found in the response. Unable to verify registration.")
-		return fmt.Errorf("registration table not found")
+		return "", fmt.Errorf("registration table not found")
 	}
 
 	registrationSuccess := false
@@ -503,14 +423,10 @@ func performRegistration(regNo string, cookies types.Cookies, facility types.Fac
 		}
 	})
 
-	if registrationSuccess {
-		fmt.Println("Facility Registration Response:")
-		fmt.Println(confirmationMessage)
-		return nil
-	} else {
-		fmt.Println("Registration might have failed. Confirmation message not found.")
-		return fmt.Errorf("registration confirmation not found for facility: %s", facility.Name)
+	if !registrationSuccess {
+		return "", fmt.Errorf("registration confirmation not found for facility: %s", facility.Name)
 	}
+	return confirmationMessage, nil
 }
 
 func Colorize(text string, color string) string {
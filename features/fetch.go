@@ -0,0 +1,130 @@
+package features
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+// Each feature is split into a FetchX function, which returns typed data and
+// never prints, and a renderer that draws it as a table. The CLI commands, the
+// AI export and --output all go through the fetchers.
+
+// checkSession returns helpers.ErrNotLoggedIn for a session without cookies.
+func checkSession(s types.Session) error {
+	if !helpers.ValidateCookies(s.Cookies) {
+		return helpers.ErrNotLoggedIn
+	}
+	return nil
+}
+
+// semestersToTry lists the semesters a fetcher looks at in order: the one in
+// opts, or every semester from the latest back.
+func semestersToTry(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.Semester, error) {
+	if opts.Semester.SemID != "" {
+		return []types.Semester{opts.Semester}, nil
+	}
+	return FetchSemesters(ctx, s)
+}
+
+// latestWithData calls fetch for each semester from semestersToTry until one
+// has data, and returns that result. An error is returned only when no
+// semester could be fetched at all.
+func latestWithData[T any](ctx context.Context, s types.Session, opts types.FetchOptions, fetch func(types.Semester) (result T, found bool, err error)) (T, error) {
+	var result T
+	semesters, err := semestersToTry(ctx, s, opts)
+	if err != nil {
+		return result, err
+	}
+
+	var lastErr error
+	fetched := false
+	for _, sem := range semesters {
+		r, found, err := fetch(sem)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			if debug.Debug {
+				fmt.Fprintf(helpers.Messages, "Error fetching semester %s: %v\n", sem.SemName, err)
+			}
+			lastErr = err
+			continue
+		}
+		if !fetched {
+			result, fetched = r, true
+		}
+		if found {
+			return r, nil
+		}
+	}
+	if !fetched {
+		return result, lastErr
+	}
+	return result, nil
+}
+
+// structuredOutput prints v when --output asks for data rather than a table,
+// and reports whether it did.
+func structuredOutput(v any) bool {
+	if !helpers.Structured() {
+		return false
+	}
+	if err := helpers.Render(v); err != nil {
+		helpers.HandleError("writing output", err)
+	}
+	return true
+}
+
+// semesterLists remembers each student's semesters for the rest of the
+// process, since every dataset fetched without a semester starts from them.
+var (
+	semesterListsMu sync.Mutex
+	semesterLists   = map[string][]types.Semester{}
+)
+
+// FetchSemesters lists the student's semesters, latest first.
+func FetchSemesters(ctx context.Context, s types.Session) ([]types.Semester, error) {
+	semesterListsMu.Lock()
+	semesters, ok := semesterLists[s.RegNo]
+	semesterListsMu.Unlock()
+	if ok {
+		return semesters, nil
+	}
+
+	semesters, err := cached(ctx, s, "semesters", func() ([]types.Semester, error) {
+		return fetchSemesters(ctx, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	semesterListsMu.Lock()
+	semesterLists[s.RegNo] = semesters
+	semesterListsMu.Unlock()
+	return semesters, nil
+}
+
+func fetchSemesters(ctx context.Context, s types.Session) ([]types.Semester, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	semesters, err := helpers.GetSemDetails(ctx, s.Cookies, s.RegNo)
+	if err != nil {
+		if semesters, err = helpers.GetSemDetailsBackup(ctx, s.Cookies, s.RegNo); err != nil {
+			return nil, err
+		}
+	}
+	if len(semesters) == 0 {
+		return nil, errors.New("no semesters available")
+	}
+	// GetSemDetails lists the oldest semester first.
+	latestFirst := slices.Clone(semesters)
+	slices.Reverse(latestFirst)
+	return latestFirst, nil
+}
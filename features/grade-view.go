@@ -2,12 +2,14 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -18,117 +20,136 @@ const (
 	GradeCellSelector      = "td"
 	GradeHeaderSelector    = "th"
 	GradeSummarySelector   = "div.panel-body"
+	GradeGPASelector       = "span[style='font-size: 18px; font-weight: bold;']"
 )
 
-func GetGrades(regNo string, cookies types.Cookies, semId string, semChoice int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
+const gradeViewURL = "https://vtop.vit.ac.in/vtop/examinations/examGradeView/doStudentGradeView"
 
-	url := "https://vtop.vit.ac.in/vtop/examinations/examGradeView/doStudentGradeView"
+var gpaValue = regexp.MustCompile(`\d+(\.\d+)?`)
 
-	semester, err := helpers.SelectSemester(regNo, cookies, semChoice)
-	if err != nil {
-		helpers.HandleError("fetching semesters", err)
-		fmt.Println()
-		return
-	}
+// FetchGrades returns a semester's grades, by default those of the latest
+// semester that has any.
+func FetchGrades(ctx context.Context, s types.Session, opts types.FetchOptions) (types.SemesterGrades, error) {
+	return cached(ctx, s, datasetKey("grades", opts), func() (types.SemesterGrades, error) {
+		return fetchGrades(ctx, s, opts)
+	})
+}
 
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, semester.SemID, "UTC", "POST", "")
-	if err != nil {
-		helpers.HandleError("fetching grades", err)
-		if debug.Debug {
-			fmt.Println(err)
-		}
+func fetchGrades(ctx context.Context, s types.Session, opts types.FetchOptions) (types.SemesterGrades, error) {
+	if err := checkSession(s); err != nil {
+		return types.SemesterGrades{}, err
 	}
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-	if err != nil {
-		helpers.HandleError("parsing HTML", err)
-		if debug.Debug {
-			fmt.Println(err)
+	return latestWithData(ctx, s, opts, func(sem types.Semester) (types.SemesterGrades, bool, error) {
+		bodyText, err := helpers.VtopFor(ctx).Post(ctx, gradeViewURL, helpers.SemesterForm(s.RegNo, s.Cookies, sem.SemID))
+		if err != nil {
+			return types.SemesterGrades{}, false, err
 		}
-	}
-
-	findAndSaveGrade(doc)
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
+		if err != nil {
+			return types.SemesterGrades{}, false, err
+		}
+		grades := parseGrades(doc)
+		grades.Semester = sem.SemName
+		return grades, len(grades.Courses) > 0, nil
+	})
 }
 
-func findAndSaveGrade(doc *goquery.Document) {
-	var gradesData [][]string
-	table := doc.Find(GradeTableSelector)
-
-	if table.Length() == 0 {
-		fmt.Println("Data not found")
-		return
-	}
-
-	headers := []string{
-		"Course Code", "Course Title", "Course Type",
-		"Credits", "Total", "Grading", "Grade",
-	}
-	gradesData = append(gradesData, headers)
-
-	table.Find("tbody tr").Each(func(i int, rowSelection *goquery.Selection) {
+func parseGrades(doc *goquery.Document) types.SemesterGrades {
+	var grades types.SemesterGrades
+	doc.Find(GradeTableSelector).Find(GradeRowsSelector).Each(func(i int, rowSelection *goquery.Selection) {
 		row := helpers.ExtractRowData(rowSelection)
-
-		if len(row) < 12 {
-			for len(row) < 12 {
-				row = append(row, "")
-			}
+		for len(row) < 12 {
+			row = append(row, "")
 		}
-
 		if containsGPA(row) {
 			return
 		}
 
-		selectedRow := []string{
-			row[1],  // Course Code
-			row[2],  // Course Title
-			row[3],  // Course Type
-			row[7],  // Credits
-			row[9],  // Total
-			row[8],  // Grading
-			row[10], // Grade
+		course := types.CourseGrade{
+			CourseCode:  row[1],
+			CourseTitle: row[2],
+			CourseType:  row[3],
+			Credits:     helpers.ParseFloat(row[7]),
+			Total:       helpers.ParseFloat(row[9]),
+			Grading:     row[8],
+			Grade:       row[10],
 		}
-
-		grading := strings.ToUpper(strings.TrimSpace(selectedRow[5]))
-		switch grading {
+		switch strings.ToUpper(strings.TrimSpace(course.Grading)) {
 		case "AG":
-			selectedRow[5] = "Absolute"
+			course.Grading = "Absolute"
 		case "RG":
-			selectedRow[5] = "Relative"
+			course.Grading = "Relative"
 		}
+		if course.CourseCode == "" && course.CourseTitle == "" && course.Grade == "" {
+			return
+		}
+		grades.Courses = append(grades.Courses, course)
+	})
 
-		courseType := strings.ToUpper(strings.TrimSpace(selectedRow[2]))
-		grade := strings.ToUpper(strings.TrimSpace(selectedRow[6]))
+	doc.Find(GradeGPASelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if value := gpaValue.FindString(s.Text()); value != "" {
+			grades.GPA = helpers.ParseFloat(value)
+			return false
+		}
+		return true
+	})
+	return grades
+}
+
+// ShowGrades prints grades as a table, or in the --output format.
+func ShowGrades(grades types.SemesterGrades) {
+	if structuredOutput(grades) {
+		return
+	}
+	renderGrades(grades)
+}
+
+func renderGrades(grades types.SemesterGrades) {
+	if len(grades.Courses) == 0 {
+		fmt.Fprintln(helpers.Messages, "Data not found")
+		return
+	}
+
+	gradesData := [][]string{{
+		"Course Code", "Course Title", "Course Type",
+		"Credits", "Total", "Grading", "Grade",
+	}}
+	for _, course := range grades.Courses {
+		row := []string{
+			course.CourseCode,
+			course.CourseTitle,
+			course.CourseType,
+			strconv.FormatFloat(course.Credits, 'f', -1, 64),
+			strconv.FormatFloat(course.Total, 'f', -1, 64),
+			course.Grading,
+			course.Grade,
+		}
+
+		courseType := strings.ToUpper(strings.TrimSpace(course.CourseType))
+		grade := strings.ToUpper(strings.TrimSpace(course.Grade))
 
 		if courseType == "ONLINE COURSE" || courseType == "PROJECT" || courseType == "EXTRA CURRICULAR ACTIVITY" {
-			for idx := range selectedRow {
-				if selectedRow[0][0:4] != "CFOC" {
-					selectedRow[idx] = fmt.Sprintf("\x1b[32m%s\x1b[0m", selectedRow[idx]) // Green
+			if !strings.HasPrefix(course.CourseCode, "CFOC") {
+				for idx := range row {
+					row[idx] = fmt.Sprintf("\x1b[32m%s\x1b[0m", row[idx]) // Green
 				}
 			}
-		} else {
-			if grade == "F" || grade == "N" {
-				for idx := range selectedRow {
-					selectedRow[idx] = fmt.Sprintf("\x1b[31m%s\x1b[0m", selectedRow[idx]) // Red
-				}
+		} else if grade == "F" || grade == "N" {
+			for idx := range row {
+				row[idx] = fmt.Sprintf("\x1b[31m%s\x1b[0m", row[idx]) // Red
 			}
 		}
 
-		gradesData = append(gradesData, selectedRow)
-	})
-
-	gradesData = filterEmptyRows(gradesData, len(headers))
+		gradesData = append(gradesData, row)
+	}
 
 	helpers.PrintTable(gradesData, 1)
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
 
-	doc.Find("span[style='font-size: 18px; font-weight: bold;']").Each(func(i int, s *goquery.Selection) {
-		gpa := s.Text()
-		fmt.Println("\x1b[32;1m**Course not included in GPA/CGPA\x1b[0m")
-		fmt.Println(gpa)
-	})
+	if grades.GPA > 0 {
+		fmt.Fprintln(helpers.Messages, "\x1b[32;1m**Course not included in GPA/CGPA\x1b[0m")
+		fmt.Fprintf(helpers.Messages, "GPA : %.2f\n", grades.GPA)
+	}
 }
 
 func containsGPA(row []string) bool {
@@ -139,23 +160,3 @@ func containsGPA(row []string) bool {
 	}
 	return false
 }
-
-func filterEmptyRows(data [][]string, expectedLength int) [][]string {
-	filtered := [][]string{}
-	for _, row := range data {
-		if len(row) != expectedLength {
-			continue
-		}
-		empty := true
-		for _, cell := range row {
-			if strings.TrimSpace(cell) != "" {
-				empty = false
-				break
-			}
-		}
-		if !empty {
-			filtered = append(filtered, row)
-		}
-	}
-	return filtered
-}
@@ -0,0 +1,346 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/store"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+// datasetDiff lists, in words, the rows added or changed between two saved
+// versions of a dataset.
+type datasetDiff func(old, new json.RawMessage) ([]string, error)
+
+// datasetDiffs are the datasets that keep a history of every version
+// fetched, for cli-top history and cli-top diff.
+var datasetDiffs = map[string]datasetDiff{
+	"marks":      diffSaved(diffMarks),
+	"attendance": diffSaved(diffAttendance),
+	"grades":     diffSaved(diffGrades),
+	"das":        diffSaved(diffDAs),
+	"cgpa":       diffSaved(diffCGPA),
+}
+
+// VersionedDatasets names the datasets that keep a history.
+func VersionedDatasets() []string {
+	datasets := make([]string, 0, len(datasetDiffs))
+	for dataset := range datasetDiffs {
+		datasets = append(datasets, dataset)
+	}
+	sort.Strings(datasets)
+	return datasets
+}
+
+func diffSaved[T any](diff func(old, new T) []string) datasetDiff {
+	return func(old, new json.RawMessage) ([]string, error) {
+		var before, after T
+		if err := json.Unmarshal(old, &before); err != nil {
+			return nil, fmt.Errorf("reading saved data: %w", err)
+		}
+		if err := json.Unmarshal(new, &after); err != nil {
+			return nil, fmt.Errorf("reading saved data: %w", err)
+		}
+		return diff(before, after), nil
+	}
+}
+
+func historyOf(s types.Session, dataset string) ([]store.Version, datasetDiff, error) {
+	diff, ok := datasetDiffs[dataset]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no history; use one of %s", dataset, strings.Join(VersionedDatasets(), ", "))
+	}
+	versions, err := store.Default.History(s.RegNo, dataset)
+	return versions, diff, err
+}
+
+// FetchHistory returns every saved version of dataset, oldest first. A new
+// version is saved whenever a fetch returns something different.
+func FetchHistory(s types.Session, dataset string) ([]types.DatasetVersion, error) {
+	versions, diff, err := historyOf(s, dataset)
+	if err != nil {
+		return nil, err
+	}
+	names := savedSemesterNames(s)
+	previous := map[string]store.Version{}
+	var history []types.DatasetVersion
+	for _, v := range versions {
+		version := types.DatasetVersion{
+			Version:   v.Number,
+			Semester:  semesterOfKey(v.Key, names),
+			FetchedAt: v.FetchedAt,
+		}
+		if prev, ok := previous[v.Key]; ok {
+			changes, err := diff(prev.Data, v.Data)
+			if err != nil {
+				return nil, err
+			}
+			version.Changes = len(changes)
+		}
+		previous[v.Key] = v
+		history = append(history, version)
+	}
+	return history, nil
+}
+
+// FetchChanges returns the rows of dataset added or changed within since,
+// that is after the newest version older than since, or after the first
+// version when none is that old. With since zero it returns what changed in
+// the newest version of each semester.
+func FetchChanges(s types.Session, dataset string, since time.Duration) ([]types.DatasetChange, error) {
+	versions, diff, err := historyOf(s, dataset)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	byKey := map[string][]store.Version{}
+	for _, v := range versions {
+		byKey[v.Key] = append(byKey[v.Key], v)
+	}
+	names := savedSemesterNames(s)
+	cutoff := time.Now().Add(-since)
+	// The latest semester is saved both with and without --semester, so
+	// changes to the unkeyed copy are held back until the others are known.
+	var changes, latest []types.DatasetChange
+	for key, keyVersions := range byKey {
+		start := max(len(keyVersions)-2, 0)
+		if since > 0 {
+			start = 0
+			for i, v := range keyVersions {
+				if !v.FetchedAt.After(cutoff) {
+					start = i
+				}
+			}
+		}
+		for i := start + 1; i < len(keyVersions); i++ {
+			descriptions, err := diff(keyVersions[i-1].Data, keyVersions[i].Data)
+			if err != nil {
+				return nil, err
+			}
+			for _, description := range descriptions {
+				change := types.DatasetChange{
+					At:          keyVersions[i].FetchedAt,
+					Semester:    semesterOfKey(key, names),
+					Description: description,
+				}
+				if strings.Contains(key, "/") {
+					changes = append(changes, change)
+				} else {
+					latest = append(latest, change)
+				}
+			}
+		}
+	}
+
+	// Each change seen under a semester accounts for one identical change
+	// under the latest copy, so real repeats are kept.
+	named := map[string]int{}
+	for _, c := range changes {
+		named[c.Description]++
+	}
+	for _, c := range latest {
+		if named[c.Description] > 0 {
+			named[c.Description]--
+			continue
+		}
+		changes = append(changes, c)
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].At.Before(changes[j].At) })
+	return changes, nil
+}
+
+// savedSemesterNames maps semester IDs to names from the saved semester
+// list, without asking VTOP.
+func savedSemesterNames(s types.Session) map[string]string {
+	names := map[string]string{}
+	entry, err := store.Default.Get(s.RegNo, "semesters")
+	if err != nil {
+		return names
+	}
+	var semesters []types.Semester
+	if json.Unmarshal(entry.Data, &semesters) == nil {
+		for _, sem := range semesters {
+			names[sem.SemID] = sem.SemName
+		}
+	}
+	return names
+}
+
+// semesterOfKey names the semester a dataset key is saved for.
+func semesterOfKey(key string, names map[string]string) string {
+	_, semID, ok := strings.Cut(key, "/")
+	switch {
+	case !ok:
+		return "Latest"
+	case names[semID] != "":
+		return names[semID]
+	}
+	return semID
+}
+
+// ShowHistory lists the saved versions of dataset, or prints them in the
+// --output format.
+func ShowHistory(dataset string, history []types.DatasetVersion) {
+	if structuredOutput(history) {
+		return
+	}
+	if len(history) == 0 {
+		fmt.Fprintf(helpers.Messages, "No saved versions of %s yet; one is kept each time it is fetched and has changed.\n", dataset)
+		return
+	}
+
+	table := [][]string{{"Version", "Semester", "Fetched", "Changes"}}
+	for _, v := range history {
+		changes := strconv.Itoa(v.Changes)
+		if v.Version == 1 {
+			changes = "first saved"
+		}
+		table = append(table, []string{
+			strconv.FormatUint(v.Version, 10),
+			v.Semester,
+			v.FetchedAt.Local().Format("Jan 2 2006 15:04"),
+			changes,
+		})
+	}
+	fmt.Fprintln(helpers.Messages)
+	helpers.PrintTable(table, 0)
+	fmt.Fprintln(helpers.Messages)
+}
+
+// ShowChanges lists what changed in dataset, or prints the changes in the
+// --output format.
+func ShowChanges(dataset string, changes []types.DatasetChange) {
+	if structuredOutput(changes) {
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(helpers.Messages, "No changes to %s.\n", dataset)
+		return
+	}
+
+	table := [][]string{{"Seen", "Semester", "Change"}}
+	for _, c := range changes {
+		table = append(table, []string{c.At.Local().Format("Jan 2 15:04"), c.Semester, c.Description})
+	}
+	fmt.Fprintln(helpers.Messages)
+	helpers.PrintTable(table, 0)
+	fmt.Fprintln(helpers.Messages)
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func diffMarks(old, new []types.CourseMarksSummary) []string {
+	before := map[string]types.CourseMarksComponent{}
+	for _, course := range old {
+		for _, c := range course.Components {
+			before[course.CourseCode+"/"+course.CourseType+"/"+c.Title] = c
+		}
+	}
+	var changes []string
+	for _, course := range new {
+		for _, c := range course.Components {
+			score := formatNumber(c.ScoredMarks) + "/" + formatNumber(c.MaxMarks)
+			prev, ok := before[course.CourseCode+"/"+course.CourseType+"/"+c.Title]
+			switch {
+			case !ok:
+				changes = append(changes, fmt.Sprintf("%s marks posted for %s: %s", c.Title, course.CourseCode, score))
+			case prev.ScoredMarks != c.ScoredMarks || prev.MaxMarks != c.MaxMarks:
+				changes = append(changes, fmt.Sprintf("%s marks for %s changed from %s/%s to %s", c.Title, course.CourseCode,
+					formatNumber(prev.ScoredMarks), formatNumber(prev.MaxMarks), score))
+			}
+		}
+	}
+	return changes
+}
+
+func diffAttendance(old, new []types.AttendanceRecord) []string {
+	before := map[string]types.AttendanceRecord{}
+	for _, r := range old {
+		before[r.CourseCode+"/"+r.CourseType] = r
+	}
+	var changes []string
+	for _, r := range new {
+		course := strings.TrimSpace(r.CourseCode + " " + r.CourseType)
+		classes := fmt.Sprintf("%s%% (%d/%d)", formatNumber(r.Percentage), r.Attended, r.Total)
+		prev, ok := before[r.CourseCode+"/"+r.CourseType]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("Attendance for %s started at %s", course, classes))
+		case r.Percentage < prev.Percentage:
+			changes = append(changes, fmt.Sprintf("Attendance for %s dropped to %s", course, classes))
+		case r.Percentage > prev.Percentage:
+			changes = append(changes, fmt.Sprintf("Attendance for %s rose to %s", course, classes))
+		case r.Attended != prev.Attended || r.Total != prev.Total:
+			changes = append(changes, fmt.Sprintf("Attendance for %s is now %s", course, classes))
+		}
+	}
+	return changes
+}
+
+func diffGrades(old, new types.SemesterGrades) []string {
+	before := map[string]types.CourseGrade{}
+	for _, g := range old.Courses {
+		before[g.CourseCode+"/"+g.CourseType] = g
+	}
+	var changes []string
+	for _, g := range new.Courses {
+		prev, ok := before[g.CourseCode+"/"+g.CourseType]
+		switch {
+		case !ok || prev.Grade == "":
+			changes = append(changes, fmt.Sprintf("Grade posted for %s: %s", g.CourseCode, g.Grade))
+		case prev.Grade != g.Grade:
+			changes = append(changes, fmt.Sprintf("Grade for %s changed from %s to %s", g.CourseCode, prev.Grade, g.Grade))
+		}
+	}
+	switch {
+	case new.GPA == old.GPA:
+	case old.GPA == 0:
+		changes = append(changes, fmt.Sprintf("GPA posted: %.2f", new.GPA))
+	default:
+		changes = append(changes, fmt.Sprintf("GPA changed from %.2f to %.2f", old.GPA, new.GPA))
+	}
+	return changes
+}
+
+func diffDAs(old, new []types.SubjectDAs) []string {
+	before := map[string]types.DAEvent{}
+	for _, subject := range old {
+		for _, da := range subject.DAs {
+			before[subject.Subject.Code+"/"+da.Title] = da
+		}
+	}
+	var changes []string
+	for _, subject := range new {
+		for _, da := range subject.DAs {
+			prev, ok := before[subject.Subject.Code+"/"+da.Title]
+			switch {
+			case !ok && da.DueDate.IsZero():
+				changes = append(changes, fmt.Sprintf("New DA for %s: %s", subject.Subject.Code, da.Title))
+			case !ok:
+				changes = append(changes, fmt.Sprintf("New DA for %s: %s, due %s", subject.Subject.Code, da.Title, da.DueDate.Format("02-Jan-2006")))
+			case !prev.DueDate.Equal(da.DueDate):
+				changes = append(changes, fmt.Sprintf("Due date of %s for %s moved to %s", da.Title, subject.Subject.Code, da.DueDate.Format("02-Jan-2006")))
+			case notUploaded(prev) && !notUploaded(da):
+				changes = append(changes, fmt.Sprintf("%s uploaded for %s", da.Title, subject.Subject.Code))
+			}
+		}
+	}
+	return changes
+}
+
+func diffCGPA(old, new types.CGPASnapshot) []string {
+	var changes []string
+	if new.CGPA != old.CGPA {
+		changes = append(changes, fmt.Sprintf("CGPA changed from %.2f to %.2f", old.CGPA, new.CGPA))
+	}
+	if new.CreditsEarned != old.CreditsEarned {
+		changes = append(changes, fmt.Sprintf("Credits earned changed from %d to %d", old.CreditsEarned, new.CreditsEarned))
+	}
+	return changes
+}
@@ -2,10 +2,10 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 	"strings"
 )
@@ -16,38 +16,54 @@ const (
 	HostelCellSelector  = "td"
 )
 
-func PrintHostelInfo(regNo string, cookies types.Cookies, url string) {
-	if !helpers.ValidateLogin(cookies) {
-		return
+const studentProfileURL = "https://vtop.vit.ac.in/vtop/studentsRecord/StudentProfileAllView"
+
+// FetchHostelInfo returns the accommodation section of the student profile,
+// which is the last five rows of its table.
+func FetchHostelInfo(ctx context.Context, s types.Session, opts types.FetchOptions) (types.HostelInfo, error) {
+	return cached(ctx, s, datasetKey("hostel", opts), func() (types.HostelInfo, error) {
+		return fetchHostelInfo(ctx, s, opts)
+	})
+}
+
+func fetchHostelInfo(ctx context.Context, s types.Session, opts types.FetchOptions) (types.HostelInfo, error) {
+	var info types.HostelInfo
+	if err := checkSession(s); err != nil {
+		return info, err
 	}
-	body, err := helpers.FetchReq(regNo, cookies, url, "", "", "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println("Error fetching HTML:", err)
-		return
+	body, err := helpers.VtopFor(ctx).Post(ctx, studentProfileURL, helpers.MenuForm(s.RegNo, s.Cookies))
+	if err != nil {
+		return info, err
 	}
-
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
-	if err != nil && debug.Debug {
-		fmt.Println("Error parsing HTML:", err)
-		return
+	if err != nil {
+		return info, err
 	}
 
-	fmt.Println("Student Accommodation Info")
-
 	table := doc.Find(HostelTableSelector + " " + HostelRowsSelector)
-	lastFiveRows := table.Slice(-5, table.Length())
-
-	// Prepare nested list for PrintTable
-	nestedList := [][]string{{"Field", "Information"}}
-	lastFiveRows.Each(func(j int, rowSelection *goquery.Selection) {
-		header := rowSelection.Find(HostelCellSelector).Eq(0).Text()
-		value := rowSelection.Find(HostelCellSelector).Eq(1).Text()
-		nestedList = append(nestedList, []string{
-			strings.TrimSpace(header),
-			strings.TrimSpace(value),
+	table.Slice(max(table.Length()-5, 0), table.Length()).Each(func(j int, rowSelection *goquery.Selection) {
+		cells := rowSelection.Find(HostelCellSelector)
+		info.Details = append(info.Details, types.HostelDetail{
+			Field: strings.TrimSpace(cells.Eq(0).Text()),
+			Value: strings.TrimSpace(cells.Eq(1).Text()),
 		})
 	})
+	return info, nil
+}
 
-	// Use PrintTable to display the information
-	helpers.PrintTable(nestedList, 0)
+// ShowHostelInfo prints info as a table, or in the --output format.
+func ShowHostelInfo(info types.HostelInfo) {
+	if structuredOutput(info) {
+		return
+	}
+	renderHostelInfo(info)
+}
+
+func renderHostelInfo(info types.HostelInfo) {
+	fmt.Fprintln(helpers.Messages, "Student Accommodation Info")
+	table := [][]string{{"Field", "Information"}}
+	for _, detail := range info.Details {
+		table = append(table, []string{detail.Field, detail.Value})
+	}
+	helpers.PrintTable(table, 0)
 }
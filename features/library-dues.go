@@ -2,10 +2,10 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 	"strings"
 )
@@ -16,40 +16,56 @@ const (
 	LibraryDuesCellSelector  = "td"
 )
 
-func GetLibraryDues(regNo string, cookies types.Cookies) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-	url := "https://vtop.vit.ac.in/vtop/finance/libraryPayments"
+const libraryDuesURL = "https://vtop.vit.ac.in/vtop/finance/libraryPayments"
 
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, "", "", "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println("Error fetching data:", err)
-		return
-	}
+// FetchLibraryDues returns the student's outstanding library payments.
+func FetchLibraryDues(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.LibraryDue, error) {
+	return cached(ctx, s, datasetKey("library-dues", opts), func() ([]types.LibraryDue, error) {
+		return fetchLibraryDues(ctx, s, opts)
+	})
+}
 
+func fetchLibraryDues(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.LibraryDue, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, libraryDuesURL, helpers.MenuForm(s.RegNo, s.Cookies))
+	if err != nil {
+		return nil, err
+	}
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-	if err != nil && debug.Debug {
-		fmt.Println("Error parsing HTML:", err)
-		return
+	if err != nil {
+		return nil, err
 	}
 
-	// Create table
-	var AllDuesTable [][]string
-	AllDuesTable = append(AllDuesTable, []string{"TYPE", "AMOUNT"})
-
+	var dues []types.LibraryDue
 	doc.Find(LibraryDuesTableSelector + " " + LibraryDuesRowsSelector).Each(func(i int, rowSelection *goquery.Selection) {
-		row := []string{}
-		rowSelection.Find(LibraryDuesCellSelector).Each(func(j int, cellSelection *goquery.Selection) {
-			cellText := strings.TrimSpace(cellSelection.Text())
-			row = append(row, cellText)
+		cells := rowSelection.Find(LibraryDuesCellSelector)
+		if cells.Length() < 2 {
+			return
+		}
+		dues = append(dues, types.LibraryDue{
+			Type:   strings.TrimSpace(cells.Eq(0).Text()),
+			Amount: parseAmount(cells.Eq(1).Text()),
 		})
-		// Append the row to the table
-		AllDuesTable = append(AllDuesTable, row)
 	})
+	return dues, nil
+}
+
+// ShowLibraryDues prints dues as a table, or in the --output format.
+func ShowLibraryDues(dues []types.LibraryDue) {
+	if structuredOutput(dues) {
+		return
+	}
+	renderLibraryDues(dues)
+}
 
-	// Render the table
-	fmt.Println()
-	helpers.PrintTable(AllDuesTable, 1)
-	fmt.Println()
+func renderLibraryDues(dues []types.LibraryDue) {
+	table := [][]string{{"TYPE", "AMOUNT"}}
+	for _, due := range dues {
+		table = append(table, []string{due.Type, formatAmount(due.Amount)})
+	}
+	fmt.Fprintln(helpers.Messages)
+	helpers.PrintTable(table, 1)
+	fmt.Fprintln(helpers.Messages)
 }
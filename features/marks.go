@@ -2,14 +2,13 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
-	"errors"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -18,7 +17,6 @@ const (
 	MarksCustomTableSelector    = "customTable-level1"
 	MarksRowsSelector           = "tbody tr"
 	MarksCellSelector           = "td"
-	MarksGPASpanSelector        = "span[style='font-size: 18px; font-weight: bold;']"
 	MarksTitleCellIndex         = 1
 	MarksMaxMarkCellIndex       = 2
 	MarksWeightageCellIndex     = 3
@@ -32,41 +30,43 @@ const (
 	CourseSlotCellIndex         = 7
 )
 
-// FetchMarksSummary retrieves marks data for the latest available semester without printing to stdout.
-func FetchMarksSummary(regNo string, cookies types.Cookies) ([]types.CourseMarksSummary, error) {
-	if !helpers.ValidateLogin(cookies) {
-		return nil, errors.New("invalid login session")
-	}
-
-	semesters, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		return nil, err
-	}
-	if len(semesters) == 0 {
-		return nil, errors.New("no semesters available")
-	}
-
-	// Use the LAST semester (most recent/current) instead of first (oldest)
-	selectedSem := semesters[len(semesters)-1]
-	url := "https://vtop.vit.ac.in/vtop/examinations/doStudentMarkView"
+const marksURL = "https://vtop.vit.ac.in/vtop/examinations/doStudentMarkView"
 
-	payload := fmt.Sprintf(
-		"------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"authorizedID\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"semesterSubId\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"_csrf\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J--\r\n",
-		regNo,
-		selectedSem.SemID,
-		cookies.CSRF,
-	)
+// FetchMarks returns every course's assessment marks for a semester, by
+// default the latest one that has any.
+func FetchMarks(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.CourseMarksSummary, error) {
+	return cached(ctx, s, datasetKey("marks", opts), func() ([]types.CourseMarksSummary, error) {
+		return fetchMarks(ctx, s, opts)
+	})
+}
 
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, selectedSem.SemID, payload, "POST", "marks")
-	if err != nil {
-		return nil, err
-	}
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-	if err != nil {
+func fetchMarks(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.CourseMarksSummary, error) {
+	if err := checkSession(s); err != nil {
 		return nil, err
 	}
+	return latestWithData(ctx, s, opts, func(sem types.Semester) ([]types.CourseMarksSummary, bool, error) {
+		payload := fmt.Sprintf(
+			"------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"authorizedID\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"semesterSubId\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"_csrf\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J--\r\n",
+			s.RegNo,
+			sem.SemID,
+			s.Cookies.CSRF,
+		)
+		bodyText, err := postMarksForm(ctx, marksURL, payload)
+		if err != nil {
+			return nil, false, err
+		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
+		if err != nil {
+			return nil, false, err
+		}
+		marks := parseMarksSummaries(doc)
+		return marks, len(marks) > 0, nil
+	})
+}
 
+// parseMarksSummaries reads every course's assessment components from the
+// marks page.
+func parseMarksSummaries(doc *goquery.Document) []types.CourseMarksSummary {
 	courseDetails := subjectDetails(doc)
 	elements := findElementsByClass(doc, MarksCustomTableSelector)
 	var summaries []types.CourseMarksSummary
@@ -129,92 +129,49 @@ func FetchMarksSummary(regNo string, cookies types.Cookies) ([]types.CourseMarks
 		summaries = append(summaries, summary)
 	}
 
-	return summaries, nil
+	return summaries
 }
 
-func GetMarks(regNo string, cookies types.Cookies, semID string, semChoice int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-
-	url := "https://vtop.vit.ac.in/vtop/examinations/doStudentMarkView"
-	semester, err := helpers.SelectSemester(regNo, cookies, semChoice)
-	if err != nil {
-		helpers.HandleError("fetching semesters", err)
-		fmt.Println()
+// ShowMarks prints marks as a table, or in the --output format.
+func ShowMarks(marks []types.CourseMarksSummary) {
+	if structuredOutput(marks) {
 		return
 	}
+	renderMarks(marks)
+}
 
-	payload := fmt.Sprintf(
-		"------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"authorizedID\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"semesterSubId\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J\r\nContent-Disposition: form-data; name=\"_csrf\"\r\n\r\n%s\r\n------WebKitFormBoundary9yjNZXu7BBjgQK7J--\r\n",
-		regNo,
-		semester.SemID,
-		cookies.CSRF,
-	)
-
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, semester.SemID, payload, "POST", "marks")
-	if err != nil && debug.Debug {
-		fmt.Println(err)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-	if err != nil && debug.Debug {
-		fmt.Println(err)
-	}
-
-	courseDetails := subjectDetails(doc)
-
-	elements := findElementsByClass(doc, MarksCustomTableSelector)
-
-	if len(elements) == 0 {
-		fmt.Println()
-		in := "No Data Found"
-		out := fmt.Sprintf("\033[1;31m%s\033[0m", in)
-		fmt.Println(out)
+func renderMarks(marks []types.CourseMarksSummary) {
+	if len(marks) == 0 {
+		fmt.Fprintln(helpers.Messages)
+		fmt.Fprintf(helpers.Messages, "\033[1;31m%s\033[0m\n", "No Data Found")
 		return
 	}
 
-	for idx, course := range courseDetails {
-		if idx >= len(elements) {
-			if debug.Debug {
-				fmt.Printf("No corresponding table found for course: %s\n", course.CourseTitle)
-			}
+	formatNumber := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, course := range marks {
+		if len(course.Components) == 0 {
+			fmt.Fprintf(helpers.Messages, "No Data Found for %s\n\n", course.CourseTitle)
 			continue
 		}
 
-		selectedElement := elements[idx]
-		selectedCourseDetail := courseDetails[idx]
-
-		OneSubTable, weightageMark, maxMarkSum := ExtractMarks(selectedElement)
-		if err != nil && debug.Debug {
-			fmt.Println(OneSubTable)
-			fmt.Println(err)
-		}
-		if len(OneSubTable) == 0 {
-			fmt.Printf("No Data Found for %s\n\n", selectedCourseDetail.CourseTitle)
-			continue
+		fmt.Fprintf(helpers.Messages, "\033[1;34m%s\033[0m\n", course.CourseTitle)
+		fmt.Fprintln(helpers.Messages)
+
+		tableData := [][]string{{"Title", "Max Marks", "Weightage %", "Status", "Scored Mark", "Weightage Mark"}}
+		for _, c := range course.Components {
+			tableData = append(tableData, []string{
+				c.Title,
+				formatNumber(c.MaxMarks),
+				formatNumber(c.Weightage),
+				c.Status,
+				formatNumber(c.ScoredMarks),
+				formatNumber(c.WeightageMark),
+			})
 		}
-
-		courseDetail := fmt.Sprintf("\033[1;34m%s\033[0m", selectedCourseDetail.CourseTitle)
-		fmt.Println(courseDetail)
-		fmt.Println()
-
-		headers := []string{"Title", "Max Marks", "Weightage %", "Status", "Scored Mark", "Weightage Mark"}
-
-		tableData := append([][]string{headers}, OneSubTable...)
-
 		helpers.PrintTable(tableData, 0)
 
-		weightageMarkStr := fmt.Sprintf("\033[32m%.2f\033[0m", weightageMark)
-		maxMarkSumStr := fmt.Sprintf("\033[32m%d\033[0m", maxMarkSum)
-		fmt.Printf("\n%s/%s\n\n", weightageMarkStr, maxMarkSumStr)
+		fmt.Fprintf(helpers.Messages, "\n\033[32m%.2f\033[0m/\033[32m%s\033[0m\n\n", course.TotalScored, formatNumber(course.TotalWeight))
 	}
-
-	doc.Find(MarksGPASpanSelector).Each(func(i int, s *goquery.Selection) {
-		gpa := s.Text()
-		fmt.Println("\x1b[32;1mCourse not included in GPA/CGPA\x1b[0m")
-		fmt.Println(gpa)
-	})
 }
 
 func subjectDetails(doc *goquery.Document) []types.CourseDetail {
@@ -288,3 +245,16 @@ func ExtractMarks(element *goquery.Selection) ([][]string, float64, int) {
 
 	return SingleSubTable, weightageMarkSum, maxSubjectMarksSum
 }
+
+// postMarksForm sends the multipart form the marks page expects.
+func postMarksForm(ctx context.Context, url string, payload string) ([]byte, error) {
+	resp, err := helpers.VtopFor(ctx).Do(ctx, helpers.VtopRequest{
+		URL:         url,
+		Body:        payload,
+		ContentType: "multipart/form-data; boundary=----WebKitFormBoundary9yjNZXu7BBjgQK7J",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -1,13 +1,14 @@
 package features
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -17,93 +18,92 @@ const (
 	NightSlipCellSelector  = "td"
 )
 
-func GetNightSlipStatus(regNo string, cookies types.Cookies) {
-	if !helpers.ValidateLogin(cookies) {
-		return
+// FetchNightSlips returns the student's late-hour permission requests.
+func FetchNightSlips(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.NightSlip, error) {
+	return cached(ctx, s, datasetKey("nightslips", opts), func() ([]types.NightSlip, error) {
+		return fetchNightSlips(ctx, s, opts)
+	})
+}
+
+func fetchNightSlips(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.NightSlip, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
 	}
 	url1 := "https://vtop.vit.ac.in/vtop/hostels/late/hour/student/request/1"
 	payload1 := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%d",
-		regNo,
-		cookies.CSRF,
+		s.RegNo,
+		s.Cookies.CSRF,
 		time.Now().UnixNano(),
 	)
-	_, err := helpers.FetchReq(regNo, cookies, url1, "", payload1, "POST", "")
-	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error fetching night slip status menu:", err)
-		}
-		return
+	if _, err := helpers.VtopFor(ctx).Post(ctx, url1, payload1); err != nil {
+		return nil, fmt.Errorf("fetching night slip status menu: %w", err)
 	}
 
 	url2 := "https://vtop.vit.ac.in/vtop/hostels/late/hour/student/request/9"
 	payload2 := fmt.Sprintf("_csrf=%s&authorizedID=%s&status=&form=undefined&control=status&x=%s",
-		cookies.CSRF,
-		regNo,
+		s.Cookies.CSRF,
+		s.RegNo,
 		time.Now().UTC().Format(time.RFC1123),
 	)
-	bodyText, err := helpers.FetchReq(regNo, cookies, url2, "", payload2, "POST", "")
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, url2, payload2)
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error fetching night slip status data:", err)
-		}
-		return
+		return nil, fmt.Errorf("fetching night slip status data: %w", err)
 	}
 
 	if debug.Debug {
-		fmt.Println("---- Response Body Start ----")
-		fmt.Println(string(bodyText))
-		fmt.Println("---- Response Body End ----")
+		fmt.Fprintln(helpers.Messages, "---- Response Body Start ----")
+		fmt.Fprintln(helpers.Messages, string(bodyText))
+		fmt.Fprintln(helpers.Messages, "---- Response Body End ----")
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(bodyText)))
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error parsing HTML:", err)
-		}
-		return
+		return nil, err
 	}
 
-	var nightSlipRequests []types.NightSlipRequest
-
+	var nightSlips []types.NightSlip
 	doc.Find(NightSlipTableSelector).Find(NightSlipRowsSelector).Each(func(i int, rowSelection *goquery.Selection) {
-		venue := strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(2).Text())
-		eventType := strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(3).Text())
-		details := strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(4).Text())
-		appliedTo := strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(5).Text())
-		fromDate := helpers.FormatDate(strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(6).Text()))
-		toDate := helpers.FormatDate(strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(7).Text()))
-		fromToTime := strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(8).Text())
-		status := strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(9).Text())
-
-		status = strings.Replace(status, "REQUEST RAISED-", "", -1)
-
-		coloredStatus := helpers.ColorStatus(status)
-
-		if venue != "" {
-			nightSlipRequests = append(nightSlipRequests, types.NightSlipRequest{
-				Venue:      venue,
-				EventType:  eventType,
-				Details:    details,
-				AppliedTo:  appliedTo,
-				FromDate:   fromDate,
-				ToDate:     toDate,
-				FromToTime: fromToTime,
-				Status:     coloredStatus,
-			})
+		cell := func(j int) string {
+			return strings.TrimSpace(rowSelection.Find(NightSlipCellSelector).Eq(j).Text())
+		}
+		venue := cell(2)
+		if venue == "" {
+			return
 		}
+		nightSlips = append(nightSlips, types.NightSlip{
+			Venue:      venue,
+			EventType:  cell(3),
+			Details:    cell(4),
+			AppliedTo:  cell(5),
+			FromDate:   helpers.FormatDate(cell(6)),
+			ToDate:     helpers.FormatDate(cell(7)),
+			FromToTime: cell(8),
+			Status:     strings.Replace(cell(9), "REQUEST RAISED-", "", -1),
+		})
 	})
+	return nightSlips, nil
+}
+
+// ShowNightSlips prints night slips as a table, or in the --output format.
+func ShowNightSlips(nightSlips []types.NightSlip) {
+	if structuredOutput(nightSlips) {
+		return
+	}
+	renderNightSlips(nightSlips)
+}
 
-	fmt.Println()
-	if len(nightSlipRequests) == 0 {
-		fmt.Println("No nightslip requests found.")
-		fmt.Println("")
+func renderNightSlips(nightSlips []types.NightSlip) {
+	fmt.Fprintln(helpers.Messages)
+	if len(nightSlips) == 0 {
+		fmt.Fprintln(helpers.Messages, "No nightslip requests found.")
+		fmt.Fprintln(helpers.Messages, "")
 		return
 	}
 
 	var allRequests [][]string
 	allRequests = append(allRequests, []string{"VENUE", "EVENT TYPE", "DETAILS", "APPLIED TO", "FROM DATE", "TO DATE", "FROM/TO TIME", "STATUS"})
 
-	for _, slip := range nightSlipRequests {
+	for _, slip := range nightSlips {
 		allRequests = append(allRequests, []string{
 			slip.Venue,
 			slip.EventType,
@@ -112,10 +112,10 @@ func GetNightSlipStatus(regNo string, cookies types.Cookies) {
 			slip.FromDate,
 			slip.ToDate,
 			slip.FromToTime,
-			slip.Status,
+			helpers.ColorStatus(slip.Status),
 		})
 	}
 
 	helpers.PrintTable(allRequests, 0)
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
 }
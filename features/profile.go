@@ -1,13 +1,13 @@
 package features
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -18,26 +18,27 @@ const (
 	ProfileSchoolNameSelector     = "label[for='schoolno']"
 )
 
-func fetchStudentDetails(cookies types.Cookies, regNo string) (types.StudentDetails, error) {
-	if !helpers.ValidateLogin(cookies) {
-		return types.StudentDetails{}, fmt.Errorf("please login using the cli-top login command")
+// FetchProfile returns the student's register number, programme, email and
+// school.
+func FetchProfile(ctx context.Context, s types.Session, opts types.FetchOptions) (types.StudentDetails, error) {
+	return cached(ctx, s, datasetKey("profile", opts), func() (types.StudentDetails, error) {
+		return fetchProfile(ctx, s, opts)
+	})
+}
+
+func fetchProfile(ctx context.Context, s types.Session, opts types.FetchOptions) (types.StudentDetails, error) {
+	if err := checkSession(s); err != nil {
+		return types.StudentDetails{}, err
 	}
-	url := "https://vtop.vit.ac.in/vtop/studentsRecord/StudentProfileAllView"
-	payload := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%d", regNo, cookies.CSRF, time.Now().UnixNano())
+	payload := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%d", s.RegNo, s.Cookies.CSRF, time.Now().UnixNano())
 
-	body, err := helpers.FetchReq(regNo, cookies, url, "", payload, "POST", "")
+	body, err := helpers.VtopFor(ctx).Post(ctx, studentProfileURL, payload)
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error fetching student details:", err)
-		}
 		return types.StudentDetails{}, err
 	}
 
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
 	if err != nil {
-		if debug.Debug {
-			fmt.Println("Error parsing response body:", err)
-		}
 		return types.StudentDetails{}, err
 	}
 
@@ -58,18 +59,15 @@ func fetchStudentDetails(cookies types.Cookies, regNo string) (types.StudentDeta
 	}, nil
 }
 
-func Profile(cookies types.Cookies, regNo string) {
-	studentDetails, err := fetchStudentDetails(cookies, regNo)
-	if err != nil {
-		if debug.Debug {
-			fmt.Printf("Error fetching profile: %v\n", err)
-		} else {
-			fmt.Println(err)
-			fmt.Println()
-			return
-		}
+// ShowProfile prints the student's details, or writes them in the --output format.
+func ShowProfile(studentDetails types.StudentDetails) {
+	if structuredOutput(studentDetails) {
+		return
 	}
+	renderProfile(studentDetails)
+}
 
+func renderProfile(studentDetails types.StudentDetails) {
 	tableData := [][]string{
 		{"Field", "Information"},
 		{"Register Number", studentDetails.RegisterNumber},
@@ -77,7 +75,7 @@ func Profile(cookies types.Cookies, regNo string) {
 		{"VIT Email", studentDetails.VITEmail},
 		{"School Name", studentDetails.SchoolName},
 	}
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
 	helpers.PrintTable(tableData, 0)
-	fmt.Println()
+	fmt.Fprintln(helpers.Messages)
 }
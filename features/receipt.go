@@ -2,12 +2,12 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	"cli-top/types"
-	"fmt"
+	"context"
+	"strconv"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -18,41 +18,69 @@ const (
 	ReceiptHeaderSelector = "th"
 )
 
-func GetReceipt(regNo string, cookies types.Cookies) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-	url := "https://vtop.vit.ac.in/vtop/finance/getStudentReceipts"
+const receiptsURL = "https://vtop.vit.ac.in/vtop/finance/getStudentReceipts"
 
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, "", "", "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println("Error fetching data:", err)
-		return
-	}
+// FetchReceipts returns the student's fee receipts.
+func FetchReceipts(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.Receipt, error) {
+	return cached(ctx, s, datasetKey("receipts", opts), func() ([]types.Receipt, error) {
+		return fetchReceipts(ctx, s, opts)
+	})
+}
 
+func fetchReceipts(ctx context.Context, s types.Session, opts types.FetchOptions) ([]types.Receipt, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	bodyText, err := helpers.VtopFor(ctx).Post(ctx, receiptsURL, helpers.MenuForm(s.RegNo, s.Cookies))
+	if err != nil {
+		return nil, err
+	}
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-	if err != nil && debug.Debug {
-		fmt.Println("Error parsing HTML:", err)
-		return
+	if err != nil {
+		return nil, err
 	}
-	var receipts [][]string
-	receipts = append(receipts, []string{"INVOICE NUMBER", "RECEIPT NUMBER", "DATE", "AMOUNT"})
 
+	var receipts []types.Receipt
 	doc.Find(ReceiptTableSelector + " " + ReceiptRowsSelector).Each(func(i int, rowSelection *goquery.Selection) {
 		if rowSelection.Find(ReceiptHeaderSelector).Length() > 0 {
 			return
 		}
-		var row []string
-		rowSelection.Find(ReceiptCellSelector).Each(func(j int, cellSelection *goquery.Selection) {
-			if j < 4 { // Exclude the "VIEW" column
-				cellText := strings.TrimSpace(cellSelection.Text())
-				row = append(row, cellText)
-			}
-		})
-		if len(row) == 4 {
-			receipts = append(receipts, row)
+		cells := rowSelection.Find(ReceiptCellSelector)
+		if cells.Length() < 4 { // The fifth column is the "VIEW" button
+			return
 		}
+		cell := func(j int) string { return strings.TrimSpace(cells.Eq(j).Text()) }
+		receipts = append(receipts, types.Receipt{
+			InvoiceNumber: cell(0),
+			ReceiptNumber: cell(1),
+			Date:          cell(2),
+			Amount:        parseAmount(cell(3)),
+		})
 	})
+	return receipts, nil
+}
+
+// ShowReceipts prints receipts as a table, or in the --output format.
+func ShowReceipts(receipts []types.Receipt) {
+	if structuredOutput(receipts) {
+		return
+	}
+	renderReceipts(receipts)
+}
+
+func renderReceipts(receipts []types.Receipt) {
+	table := [][]string{{"INVOICE NUMBER", "RECEIPT NUMBER", "DATE", "AMOUNT"}}
+	for _, r := range receipts {
+		table = append(table, []string{r.InvoiceNumber, r.ReceiptNumber, r.Date, formatAmount(r.Amount)})
+	}
+	helpers.PrintTable(table, 1)
+}
+
+// parseAmount reads a rupee amount such as "1,20,000.00"; anything else is 0.
+func parseAmount(text string) float64 {
+	return helpers.ParseFloat(strings.ReplaceAll(text, ",", ""))
+}
 
-	helpers.PrintTable(receipts, 1)
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
 }
@@ -3,6 +3,7 @@ package features
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,9 +13,9 @@ import (
 	"strings"
 	"time"
 
-	"cli-top/helpers"
-	"cli-top/types"
-
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -29,77 +30,116 @@ const (
 	SyllabusCourseTitleIndex     = 2
 )
 
-type Category struct {
-	ID   string
-	Name string
-}
-
-type Course struct {
-	Code  string
-	Title string
-}
-
 func sanitizeFilename(filename string) string {
 	re := regexp.MustCompile(`[^a-zA-Z0-9_\-\.]`)
 	return re.ReplaceAllString(filename, "_")
 }
 
-func DownloadSyllabus(courseCode, courseName string, cookies types.Cookies, authorizedID, outputDir string) (string, error) {
+// FetchSyllabus downloads the syllabus PDF of course, from
+// FetchSyllabusCourses.
+func FetchSyllabus(ctx context.Context, s types.Session, course types.SyllabusCourse) ([]byte, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
 	downloadURL := "https://vtop.vit.ac.in/vtop/courseSyllabusDownload1"
-	payload := fmt.Sprintf("_csrf=%s&_csrf=%s&authorizedID=%s&courseCode=%s", cookies.CSRF, cookies.CSRF, authorizedID, courseCode)
+	payload := fmt.Sprintf("_csrf=%s&_csrf=%s&authorizedID=%s&courseCode=%s", s.Cookies.CSRF, s.Cookies.CSRF, s.RegNo, course.Code)
 
-	bodyBytes, err := helpers.FetchReq("", cookies, downloadURL, "", payload, "POST", "")
+	bodyBytes, err := helpers.VtopFor(ctx).Post(ctx, downloadURL, payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 	ct := http.DetectContentType(bodyBytes)
-	var pdfBytes []byte
 	if strings.HasPrefix(ct, "application/pdf") {
-		pdfBytes = bodyBytes
-	} else if strings.HasPrefix(ct, "application/zip") || strings.HasPrefix(ct, "application/x-zip-compressed") {
-		zipReader, err := zip.NewReader(bytes.NewReader(bodyBytes), int64(len(bodyBytes)))
-		if err != nil {
-			return "", fmt.Errorf("failed to read zip file: %w", err)
-		}
-		found := false
-		for _, file := range zipReader.File {
-			if strings.HasSuffix(strings.ToLower(file.Name), ".pdf") {
-				rc, err := file.Open()
-				if err != nil {
-					return "", fmt.Errorf("failed to open pdf file in zip: %w", err)
-				}
-				pdfBytes, err = io.ReadAll(rc)
-				rc.Close()
-				if err != nil {
-					return "", fmt.Errorf("failed to read pdf file from zip: %w", err)
-				}
-				found = true
-				break
+		return bodyBytes, nil
+	}
+	if !strings.HasPrefix(ct, "application/zip") && !strings.HasPrefix(ct, "application/x-zip-compressed") {
+		return nil, fmt.Errorf("unexpected content type: %s", ct)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(bodyBytes), int64(len(bodyBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip file: %w", err)
+	}
+	for _, file := range zipReader.File {
+		if strings.HasSuffix(strings.ToLower(file.Name), ".pdf") {
+			rc, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to open pdf file in zip: %w", err)
+			}
+			pdfBytes, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read pdf file from zip: %w", err)
 			}
+			return pdfBytes, nil
 		}
-		if !found {
-			return "", fmt.Errorf("no pdf file found in zip")
+	}
+	return nil, fmt.Errorf("no pdf file found in zip")
+}
+
+// SaveSyllabus saves pdf, from FetchSyllabus, to the Syllabus download
+// folder and opens the folder.
+func SaveSyllabus(course types.SyllabusCourse, pdf []byte) {
+	outputDir, err := helpers.GetOrCreateDownloadDir("Syllabus")
+	if err != nil {
+		helpers.HandleError("creating syllabus download directory", err)
+		return
+	}
+	filename := fmt.Sprintf("%s_%s.pdf", course.Title, course.Code)
+	outputPath := filepath.Join(outputDir, sanitizeFilename(filename))
+	if err := os.WriteFile(outputPath, pdf, 0644); err != nil {
+		helpers.HandleError("downloading syllabus", fmt.Errorf("failed to save file: %w", err))
+		return
+	}
+	fmt.Fprintf(helpers.Messages, "Successfully downloaded syllabus for course %s. File saved at: %s\n", course.Code, outputPath)
+	helpers.OpenFolder(outputDir)
+}
+
+// FetchSyllabusCourses lists the courses in every category of the student's
+// curriculum.
+func FetchSyllabusCourses(ctx context.Context, s types.Session) ([]types.SyllabusCourse, error) {
+	if err := checkSession(s); err != nil {
+		return nil, err
+	}
+	categories, err := getCurriculumCategories(ctx, s.RegNo, s.Cookies)
+	if err != nil {
+		return nil, err
+	}
+	var allCourses []types.SyllabusCourse
+	for _, category := range categories {
+		courses, err := getCoursesForCategory(ctx, s.RegNo, s.Cookies, category)
+		if err != nil {
+			debug.Log(fmt.Sprintf("Error fetching courses for category %s: %v", category.Name, err))
+			continue
 		}
-	} else {
-		return "", fmt.Errorf("unexpected content type: %s", ct)
+		allCourses = append(allCourses, courses...)
 	}
-	filename := fmt.Sprintf("%s_%s.pdf", courseName, courseCode)
-	sanitizedFilename := sanitizeFilename(filename)
-	outputPath := filepath.Join(outputDir, sanitizedFilename)
-	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-		return "", fmt.Errorf("failed to create output directory: %w", err)
+	if len(allCourses) == 0 {
+		return nil, fmt.Errorf("no courses found in any category")
 	}
-	if err := os.WriteFile(outputPath, pdfBytes, 0644); err != nil {
-		return "", fmt.Errorf("failed to save file: %w", err)
+	return allCourses, nil
+}
+
+// SelectSyllabusCourse asks for one of courses from FetchSyllabusCourses,
+// searching them for --course when it is given.
+func SelectSyllabusCourse(courses []types.SyllabusCourse, courseSearch string) (types.SyllabusCourse, error) {
+	var courseTable [][]string
+	courseTable = append(courseTable, []string{"Course Title", "Course Code", "Category"})
+	for _, course := range courses {
+		courseTable = append(courseTable, []string{course.Title, course.Code, course.Category})
+	}
+
+	// Use fuzzy search for course selection with the provided courseSearch query flag
+	selectedCourseIndex := helpers.TableSelectorFuzzy("Course", courseTable, courseSearch, helpers.FuzzySearchWithAcronym)
+	if selectedCourseIndex.ExitRequest || !selectedCourseIndex.Selected || selectedCourseIndex.Index < 1 || selectedCourseIndex.Index > len(courses) {
+		return types.SyllabusCourse{}, fmt.Errorf("selection canceled by user")
 	}
-	fmt.Printf("Successfully downloaded syllabus for course %s. File saved at: %s\n", courseCode, outputPath)
-	return outputPath, nil
+	return courses[selectedCourseIndex.Index-1], nil // Adjust for header row
 }
 
-func getCurriculumCategories(regNo string, cookies types.Cookies) ([]Category, error) {
+func getCurriculumCategories(ctx context.Context, regNo string, cookies types.Cookies) ([]types.Category, error) {
 	payload := fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%d", regNo, cookies.CSRF, time.Now().UnixNano())
 	endpoint := "https://vtop.vit.ac.in/vtop/academics/common/Curriculum"
-	body, err := helpers.FetchReq(regNo, cookies, endpoint, "", payload, "POST", "")
+	body, err := helpers.VtopFor(ctx).Post(ctx, endpoint, payload)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching curriculum page: %w", err)
 	}
@@ -107,7 +147,7 @@ func getCurriculumCategories(regNo string, cookies types.Cookies) ([]Category, e
 	if err != nil {
 		return nil, fmt.Errorf("error parsing curriculum page HTML: %w", err)
 	}
-	var categories []Category
+	var categories []types.Category
 	doc.Find("div.card.categoty-card").Each(func(i int, s *goquery.Selection) {
 		onclick, exists := s.Find(SyllabusCategorySelector).Attr("onclick")
 		if !exists {
@@ -121,7 +161,7 @@ func getCurriculumCategories(regNo string, cookies types.Cookies) ([]Category, e
 		catID := matches[1]
 		catName := strings.TrimSpace(s.Find(SyllabusCategoryNameSelector).Text())
 		if catName != "" && catID != "" {
-			categories = append(categories, Category{ID: catID, Name: catName})
+			categories = append(categories, types.Category{ID: catID, Name: catName})
 		}
 	})
 	if len(categories) == 0 {
@@ -131,10 +171,10 @@ func getCurriculumCategories(regNo string, cookies types.Cookies) ([]Category, e
 	return categories, nil
 }
 
-func getCoursesForCategory(regNo string, cookies types.Cookies, categoryID string) ([]Course, error) {
-	payload := fmt.Sprintf("_csrf=%s&categoryId=%s&authorizedID=%s&x=%s", cookies.CSRF, categoryID, regNo, time.Now().UTC().Format(time.RFC1123))
+func getCoursesForCategory(ctx context.Context, regNo string, cookies types.Cookies, category types.Category) ([]types.SyllabusCourse, error) {
+	payload := fmt.Sprintf("_csrf=%s&categoryId=%s&authorizedID=%s&x=%s", cookies.CSRF, category.ID, regNo, time.Now().UTC().Format(time.RFC1123))
 	endpoint := "https://vtop.vit.ac.in/vtop/academics/common/curriculumCategoryView"
-	body, err := helpers.FetchReq(regNo, cookies, endpoint, "", payload, "POST", "")
+	body, err := helpers.VtopFor(ctx).Post(ctx, endpoint, payload)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching category view: %w", err)
 	}
@@ -149,7 +189,7 @@ func getCoursesForCategory(regNo string, cookies types.Cookies, categoryID strin
 	if table.Length() == 0 {
 		return nil, fmt.Errorf("no course table found in category view")
 	}
-	var courses []Course
+	var courses []types.SyllabusCourse
 	table.Find(SyllabusRowsSelector).Each(func(i int, s *goquery.Selection) {
 		tds := s.Find(SyllabusCellSelector)
 		if tds.Length() < 3 {
@@ -166,7 +206,7 @@ func getCoursesForCategory(regNo string, cookies types.Cookies, categoryID strin
 		}
 		title := strings.TrimSpace(tds.Eq(SyllabusCourseTitleIndex).Text())
 		if code != "" && title != "" {
-			courses = append(courses, Course{Code: code, Title: title})
+			courses = append(courses, types.SyllabusCourse{Code: code, Title: title, Category: category.Name})
 		}
 	})
 	if len(courses) == 0 {
@@ -174,87 +214,3 @@ func getCoursesForCategory(regNo string, cookies types.Cookies, categoryID strin
 	}
 	return courses, nil
 }
-
-func ExecuteSyllabusDownload(regNo string, cookies types.Cookies, courseSearch string) {
-	if !helpers.ValidateLogin(cookies) {
-		return
-	}
-
-	categories, err := getCurriculumCategories(regNo, cookies)
-	if err != nil {
-		helpers.HandleError("fetching syllabus categories", err)
-		return
-	}
-
-	// Get all courses from all categories
-	var allCourses []struct {
-		Course   Course
-		Category Category
-	}
-
-	for _, category := range categories {
-		courses, err := getCoursesForCategory(regNo, cookies, category.ID)
-		if err != nil {
-			fmt.Printf("Error fetching courses for category %s: %v\n", category.Name, err)
-			continue
-		}
-
-		for _, course := range courses {
-			allCourses = append(allCourses, struct {
-				Course   Course
-				Category Category
-			}{
-				Course:   course,
-				Category: category,
-			})
-		}
-	}
-
-	if len(allCourses) == 0 {
-		fmt.Println("No courses found in any category.")
-		return
-	}
-
-	// Prepare table for fuzzy search selection
-	var courseTable [][]string
-	courseTable = append(courseTable, []string{"Course Title", "Course Code", "Category"})
-	for _, courseData := range allCourses {
-		courseTable = append(courseTable, []string{
-			courseData.Course.Title,
-			courseData.Course.Code,
-			courseData.Category.Name,
-		})
-	}
-
-	// Use fuzzy search for course selection with the provided courseSearch query flag
-	selectedCourseIndex := helpers.TableSelectorFuzzy("Course", courseTable, courseSearch, helpers.FuzzySearchWithAcronym)
-	if selectedCourseIndex.ExitRequest || !selectedCourseIndex.Selected {
-		fmt.Println("Selection canceled")
-		return
-	}
-
-	selectedCourseData := allCourses[selectedCourseIndex.Index-1] // Adjust for header row
-
-	// Create the Syllabus directory inside CLI-TOP Downloads
-	outputDir, err := helpers.GetOrCreateDownloadDir("Syllabus")
-	if err != nil {
-		helpers.HandleError("creating syllabus download directory", err)
-		return
-	}
-
-	downloadedPath, err := DownloadSyllabus(
-		selectedCourseData.Course.Code,
-		selectedCourseData.Course.Title,
-		cookies,
-		regNo,
-		outputDir,
-	)
-	if err != nil {
-		helpers.HandleError("downloading syllabus", err)
-		return
-	}
-
-	pathDir := filepath.Dir(downloadedPath)
-
-	helpers.OpenFolder(pathDir)
-}
@@ -2,18 +2,18 @@ package features
 
 import (
 	"bytes"
-	"cli-top/debug"
-	"cli-top/helpers"
-	types "cli-top/types"
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/store"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -26,15 +26,9 @@ const (
 	saturdayIndex           = 6
 )
 
-type WorkingSaturday struct {
-	Date     time.Time
-	DayOrder string
-}
-
-func fetchWorkingSaturdays(regNo string, cookies types.Cookies, semSubID, classGroupID string) []WorkingSaturday {
-	var result []WorkingSaturday
+func fetchWorkingSaturdays(ctx context.Context, regNo string, cookies types.Cookies, semSubID, classGroupID string) []types.WorkingSaturday {
+	var result []types.WorkingSaturday
 
-	client := &http.Client{}
 	locIndia := time.FixedZone("IST", 5*3600+1800)
 	now := time.Now().In(locIndia)
 
@@ -46,7 +40,7 @@ func fetchWorkingSaturdays(regNo string, cookies types.Cookies, semSubID, classG
 	}
 	form0 := helpers.FormatBodyData(payload0)
 	_ = form0
-	body0, _, err0 := helpers.FetchReqClient(client, regNo, cookies, calendarPreviewURL, "https://vtop.vit.ac.in/vtop/content", []byte(form0), "POST", "application/x-www-form-urlencoded")
+	body0, err0 := helpers.VtopFor(ctx).Do(ctx, helpers.VtopRequest{URL: calendarPreviewURL, Body: form0, Referer: "https://vtop.vit.ac.in/vtop/content"})
 	_ = body0
 	_ = err0
 
@@ -58,7 +52,7 @@ func fetchWorkingSaturdays(regNo string, cookies types.Cookies, semSubID, classG
 	}
 	formVerify := helpers.FormatBodyData(payloadVerify)
 	_ = formVerify
-	bodyVerify, _, errVerify := helpers.FetchReqClient(client, regNo, cookies, calendarPreviewURL, "https://vtop.vit.ac.in/vtop/content", []byte(formVerify), "POST", "application/x-www-form-urlencoded")
+	bodyVerify, errVerify := helpers.VtopFor(ctx).Do(ctx, helpers.VtopRequest{URL: calendarPreviewURL, Body: formVerify, Referer: "https://vtop.vit.ac.in/vtop/content"})
 	_ = bodyVerify
 	_ = errVerify
 
@@ -71,7 +65,7 @@ func fetchWorkingSaturdays(regNo string, cookies types.Cookies, semSubID, classG
 	}
 	form1 := helpers.FormatBodyData(payload1)
 	_ = form1
-	body1, _, err1 := helpers.FetchReqClient(client, regNo, cookies, getDateForSemPreviewURL, "", []byte(form1), "POST", "application/x-www-form-urlencoded")
+	body1, err1 := helpers.VtopFor(ctx).Post(ctx, getDateForSemPreviewURL, form1)
 	_ = body1
 	_ = err1
 
@@ -86,7 +80,7 @@ func fetchWorkingSaturdays(regNo string, cookies types.Cookies, semSubID, classG
 		"x":            fmt.Sprintf("%d", time.Now().Unix()),
 	}
 	form2 := helpers.FormatBodyData(payload2)
-	body3, _, err3 := helpers.FetchReqClient(client, regNo, cookies, processViewCalendarURL, "", []byte(form2), "POST", "application/x-www-form-urlencoded")
+	body3, err3 := helpers.VtopFor(ctx).Post(ctx, processViewCalendarURL, form2)
 	if err3 != nil {
 		return result
 	}
@@ -142,7 +136,7 @@ func fetchWorkingSaturdays(regNo string, cookies types.Cookies, semSubID, classG
 			return
 		}
 		wsDate := time.Date(now.Year(), now.Month(), dayInt, 0, 0, 0, 0, locIndia)
-		result = append(result, WorkingSaturday{Date: wsDate, DayOrder: dayOrder})
+		result = append(result, types.WorkingSaturday{Date: wsDate, DayOrder: dayOrder})
 	})
 
 	return result
@@ -470,7 +464,7 @@ var schedule = map[string]map[string][]string{
 	},
 }
 
-// func updateTimetableWithWorkingSaturdays(timetable map[string][]types.Class, workingSaturdays []WorkingSaturday) {
+// func updateTimetableWithWorkingSaturdays(timetable map[string][]types.Class, workingSaturdays []types.WorkingSaturday) {
 // 	for _, ws := range workingSaturdays {
 // 		classes, ok := timetable[ws.DayOrder]
 // 		if !ok {
@@ -494,60 +488,41 @@ var schedule = map[string]map[string][]string{
 // 	}
 // }
 
-// FetchTimetableEntries retrieves the student's timetable as structured entries without printing output.
-func FetchTimetableEntries(regNo string, cookies types.Cookies) ([]types.TimetableEntry, error) {
-	if !helpers.ValidateLogin(cookies) {
-		return nil, errors.New("invalid login session")
-	}
-
-	semesters, err := helpers.GetSemDetails(cookies, regNo)
-	if err != nil {
-		return nil, err
-	}
-	if len(semesters) == 0 {
-		return nil, errors.New("no semesters available")
-	}
+const timetableURL = "https://vtop.vit.ac.in/vtop/processViewTimeTable"
 
-	url := "https://vtop.vit.ac.in/vtop/processViewTimeTable"
+// FetchTimetable returns a semester's weekly classes, by default those of the
+// latest semester that has any, with working Saturdays filled in.
+func FetchTimetable(ctx context.Context, s types.Session, opts types.FetchOptions) (types.Timetable, error) {
+	return cached(ctx, s, datasetKey("timetable", opts), func() (types.Timetable, error) {
+		return fetchTimetable(ctx, s, opts)
+	})
+}
 
-	for i := 0; i < len(semesters); i++ {
-		sem := semesters[i]
-		body, err := helpers.FetchReq(regNo, cookies, url, sem.SemID, "UTC", "POST", "")
+func fetchTimetable(ctx context.Context, s types.Session, opts types.FetchOptions) (types.Timetable, error) {
+	if err := checkSession(s); err != nil {
+		return types.Timetable{}, err
+	}
+	return latestWithData(ctx, s, opts, func(sem types.Semester) (types.Timetable, bool, error) {
+		tt := types.Timetable{Semester: sem.SemName}
+		body, err := helpers.VtopFor(ctx).Post(ctx, timetableURL, helpers.SemesterForm(s.RegNo, s.Cookies, sem.SemID))
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error fetching timetable for %s: %v\n", sem.SemName, err)
-			}
-			continue
+			return tt, false, err
 		}
-
 		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 		if err != nil {
-			if debug.Debug {
-				fmt.Printf("error parsing timetable html for %s: %v\n", sem.SemName, err)
-			}
-			continue
+			return tt, false, err
 		}
 
 		courseMap := getCourseName(doc)
 		if len(courseMap) == 0 {
-			continue
+			return tt, false, nil
 		}
-
 		timetable := makeTT(schedule, courseMap)
-		if _, exists := timetable["Saturday"]; !exists {
-			timetable["Saturday"] = []types.Class{}
-		}
-
-		workingSaturdays := fetchWorkingSaturdays(regNo, cookies, sem.SemID, classGroupID)
-		updateTimetableWithWorkingSaturdays(timetable, workingSaturdays)
-
-		entries := flattenTimetableEntries(timetable, courseMap)
-		if len(entries) > 0 {
-			return entries, nil
-		}
-	}
-
-	return []types.TimetableEntry{}, nil
+		tt.WorkingSaturdays = fetchWorkingSaturdays(ctx, s.RegNo, s.Cookies, sem.SemID, classGroupID)
+		updateTimetableWithWorkingSaturdays(timetable, tt.WorkingSaturdays)
+		tt.Classes = flattenTimetableEntries(timetable, courseMap)
+		return tt, true, nil
+	})
 }
 
 func flattenTimetableEntries(timetable map[string][]types.Class, courseMap map[string]types.SubjectTime) []types.TimetableEntry {
@@ -571,6 +546,7 @@ func flattenTimetableEntries(timetable map[string][]types.Class, courseMap map[s
 				Course:    class.Subject,
 				Slot:      class.Slot,
 				Venue:     class.Venue,
+				DayOrder:  class.DayOrder,
 			}
 
 			if meta, ok := courseMap[class.Subject]; ok {
@@ -606,71 +582,69 @@ func flattenTimetableEntries(timetable map[string][]types.Class, courseMap map[s
 	return entries
 }
 
-func GetTimeTable(regNo string, cookies types.Cookies, sem_choice int) {
-	if !helpers.ValidateLogin(cookies) {
-		return
+// classesByDay turns timetable entries back into the per-day classes that
+// printTT and makeISC work from.
+func classesByDay(entries []types.TimetableEntry) map[string][]types.Class {
+	timetable := map[string][]types.Class{"Saturday": {}}
+	for _, e := range entries {
+		timetable[e.Day] = append(timetable[e.Day], types.Class{
+			Subject:   e.Course,
+			Slot:      e.Slot,
+			Venue:     e.Venue,
+			StartTime: e.StartTime,
+			EndTime:   e.EndTime,
+			DayOrder:  e.DayOrder,
+		})
 	}
-	// Fetch all semesters to determine if user is a fresher (only one semester)
-	allSems, _ := helpers.GetSemDetails(cookies, regNo)
-	semester, err := helpers.SelectSemester(regNo, cookies, sem_choice)
-	if err != nil {
-		if debug.Debug {
-			fmt.Println(err)
-		}
+	return timetable
+}
+
+// ShowTimetable prints the week's classes and exports them to a calendar
+// file for the rest of semester, or prints tt in the --output format.
+func ShowTimetable(ctx context.Context, s types.Session, semester types.Semester, tt types.Timetable) {
+	if structuredOutput(tt) {
 		return
 	}
 
-	grp_list := getClassGroups(regNo, cookies, semester)
-	wsChan := make(chan []WorkingSaturday, 1)
-	go func() {
-		// Only fetch working Saturdays for the selected semester
-		wsChan <- fetchWorkingSaturdays(regNo, cookies, semester.SemID, classGroupID)
-	}()
-
-	url := "https://vtop.vit.ac.in/vtop/processViewTimeTable"
-	bodyText, err := helpers.FetchReq(regNo, cookies, url, semester.SemID, "UTC", "POST", "")
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	timetable := classesByDay(tt.Classes)
+	printTT(timetable, tt.WorkingSaturdays)
+	if store.Default.Offline {
+		// The calendar file needs the semester's dates from VTOP.
+		return
 	}
-	doc, _ := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
-
-	grp_list = getClassGroups(regNo, cookies, semester)
-	datelist := getDateList(regNo, cookies, semester, grp_list[1][1])
-	semSec, month, year := processDates(regNo, cookies, semester, grp_list[1][1], datelist, 0)
-	courseMap := getCourseName(doc)
-	timetable := makeTT(schedule, courseMap)
+	exportTimetableICS(ctx, s.RegNo, s.Cookies, semester, timetable, tt.WorkingSaturdays)
+}
 
-	if _, exists := timetable["Saturday"]; !exists {
-		timetable["Saturday"] = []types.Class{}
+// exportTimetableICS writes the semester's classes to an ICS file and prints
+// links to import it.
+func exportTimetableICS(ctx context.Context, regNo string, cookies types.Cookies, semester types.Semester, timetable map[string][]types.Class, workingSats []types.WorkingSaturday) {
+	groups, err := classGroups(ctx, regNo, cookies, semester)
+	if err != nil || len(groups) < 2 {
+		fmt.Fprintln(helpers.Messages, "Error fetching the academic calendar; no ICS file generated.")
+		return
 	}
-
-	workingSats := <-wsChan
-	// If user is a fresher (only one semester), only show working Saturdays for that semester
-	if len(allSems) == 1 {
-		// Already filtered by selected semester, nothing to do
-		updateTimetableWithWorkingSaturdays(timetable, workingSats)
-		printTT(timetable, workingSats)
-	} else {
-		// For non-freshers, also only show working Saturdays for the selected semester
-		updateTimetableWithWorkingSaturdays(timetable, workingSats)
-		printTT(timetable, workingSats)
+	datelist, err := calendarDates(ctx, regNo, cookies, semester, groups[1].ID)
+	if err != nil || len(datelist) == 0 {
+		fmt.Fprintln(helpers.Messages, "Error fetching the academic calendar; no ICS file generated.")
+		return
 	}
+	semSec, month, year := processDates(ctx, regNo, cookies, semester, groups[1].ID, datelist)
 
 	icsDir, err := helpers.GetOrCreateDownloadDir(filepath.Join("Other Downloads", "ICS File"))
 	if err != nil {
-		fmt.Println("Error creating ICS file directory:", err)
+		fmt.Fprintln(helpers.Messages, "Error creating ICS file directory:", err)
 		return
 	}
 	icsPath := filepath.Join(icsDir, "CLI-TOP_Timetable.ics")
 	icsContent := makeISC(timetable, semSec, month, year, workingSats)
 	if err := writetoFile(icsPath, icsContent); err != nil {
-		fmt.Println("Error generating ICS file:", err)
+		fmt.Fprintln(helpers.Messages, "Error generating ICS file:", err)
 	} else {
 		if link, err := helpers.UploadICSFile(icsPath, helpers.CalendarServerURL); err == nil {
-			fmt.Println("\nICS file generated and saved successfully.")
+			fmt.Fprintln(helpers.Messages, "\nICS file generated and saved successfully.")
 			helpers.GenerateCalendarImportLinks(link, "Timetable")
 		} else {
-			fmt.Println("Error uploading ICS file; please import manually.")
+			fmt.Fprintln(helpers.Messages, "Error uploading ICS file; please import manually.")
 		}
 	}
 }
@@ -695,7 +669,7 @@ func makeTT(schedule map[string]map[string][]string, courseMap map[string]types.
 	return timetable
 }
 
-func makeISC(timetable map[string][]types.Class, semSection [][]int, startMonth int, startYear int, workingSaturdays []WorkingSaturday) string {
+func makeISC(timetable map[string][]types.Class, semSection [][]int, startMonth int, startYear int, workingSaturdays []types.WorkingSaturday) string {
 	icsContent := "BEGIN:VCALENDAR\nVERSION:2.0\nCALSCALE:GREGORIAN\nX-WR-CALNAME:CLI-TOP Timetable\n"
 	startMonth++
 	startDate := time.Date(startYear, time.Month(startMonth), 1, 0, 0, 0, 0, time.UTC)
@@ -883,13 +857,13 @@ func getCourseName(doc *goquery.Document) map[string]types.SubjectTime {
 			}
 			courseMap[courseName] = sub
 		})
-	} else {
-		fmt.Println("Table with class 'table' not found")
+	} else if debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Table with class 'table' not found")
 	}
 	return courseMap
 }
 
-func updateTimetableWithWorkingSaturdays(timetable map[string][]types.Class, workingSaturdays []WorkingSaturday) {
+func updateTimetableWithWorkingSaturdays(timetable map[string][]types.Class, workingSaturdays []types.WorkingSaturday) {
 	for _, ws := range workingSaturdays {
 		classes, ok := timetable[ws.DayOrder]
 		if !ok || len(classes) == 0 {
@@ -928,7 +902,7 @@ func updateTimetableWithWorkingSaturdays(timetable map[string][]types.Class, wor
 	}
 }
 
-func printTT(timetable map[string][]types.Class, workingSaturdays []WorkingSaturday) {
+func printTT(timetable map[string][]types.Class, workingSaturdays []types.WorkingSaturday) {
 	daysOfWeek := []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
 
 	locIndia := time.FixedZone("IST", 5*3600+1800)
@@ -998,9 +972,9 @@ func printTT(timetable map[string][]types.Class, workingSaturdays []WorkingSatur
 				continue
 			}
 			if day == dayToHighlight {
-				fmt.Printf("%s\n\n", applyColor(day, Cyan+Bold))
+				fmt.Fprintf(helpers.Messages, "%s\n\n", applyColor(day, Cyan+Bold))
 			} else {
-				fmt.Printf("%s\n\n", day)
+				fmt.Fprintf(helpers.Messages, "%s\n\n", day)
 			}
 			sort.Slice(classes, func(i, j int) bool {
 				return classes[i].StartTime < classes[j].StartTime
@@ -1030,7 +1004,7 @@ func printTT(timetable map[string][]types.Class, workingSaturdays []WorkingSatur
 				tableData = append(tableData, row)
 			}
 			helpers.PrintTable(tableData, 0)
-			fmt.Println()
+			fmt.Fprintln(helpers.Messages)
 			continue
 		}
 
@@ -1062,13 +1036,13 @@ func printTT(timetable map[string][]types.Class, workingSaturdays []WorkingSatur
 		}
 
 		if day == dayToHighlight {
-			fmt.Printf("%s\n\n", applyColor(day, Cyan+Bold))
+			fmt.Fprintf(helpers.Messages, "%s\n\n", applyColor(day, Cyan+Bold))
 		} else {
-			fmt.Printf("%s\n\n", day)
+			fmt.Fprintf(helpers.Messages, "%s\n\n", day)
 		}
 
 		if matchingDayOrder != "" {
-			fmt.Printf("%s %s\n\n", applyColor("Working Saturday", helpers.Yellow),
+			fmt.Fprintf(helpers.Messages, "%s %s\n\n", applyColor("Working Saturday", helpers.Yellow),
 				applyColor(fmt.Sprintf("(Following %s schedule)", matchingDayOrder), helpers.Yellow))
 		}
 
@@ -1100,6 +1074,6 @@ func printTT(timetable map[string][]types.Class, workingSaturdays []WorkingSatur
 			tableData = append(tableData, row)
 		}
 		helpers.PrintTable(tableData, 0)
-		fmt.Println()
+		fmt.Fprintln(helpers.Messages)
 	}
 }
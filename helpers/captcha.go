@@ -1,16 +1,16 @@
 package helpers
 
 import (
-	"cli-top/debug"
-	types "cli-top/types"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
-	"image/jpeg"
 	"math"
-	"os"
 	"sort"
 	"strings"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 )
 
 func preImg(img [][]int) [][]int {
@@ -109,12 +109,15 @@ func matAdd(a []float32, b []float32) []float32 {
 
 func maxSoft(a []float32) []float32 {
 	n := append([]float32(nil), a...)
+	// Shifting by the largest logit keeps exp from overflowing float32 on
+	// freshly trained weights without changing the result.
+	shift := float64(a[argmax(a)])
 	s := float32(0)
 	for _, f := range n {
-		s += float32(math.Exp(float64(f)))
+		s += float32(math.Exp(float64(f) - shift))
 	}
 	for i := range a {
-		n[i] = float32(math.Exp(float64(a[i]))) / s
+		n[i] = float32(math.Exp(float64(a[i])-shift)) / s
 	}
 	return n
 }
@@ -142,81 +145,147 @@ func argmax(slice []float32) int {
 	return kvs[0].Key
 }
 
-func SolveCaptcha(imageURL string) string {
-	labelTxt := "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
-	killSwitch := CheckKillSwitch()
+const captchaLabels = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
 
-	if strings.HasPrefix(imageURL, "data:image/jpeg;base64,") {
-		base64Data := strings.TrimPrefix(imageURL, "data:image/jpeg;base64,")
-		data, err := base64.StdEncoding.DecodeString(base64Data)
-		if err != nil && debug.Debug {
-			fmt.Println("Error decoding base64:", err)
-			return ""
-		}
+// CaptchaResult is a solved captcha with, for solvers that have one, the
+// model's probability distribution over captchaLabels for each character.
+type CaptchaResult struct {
+	Text          string
+	Probabilities [][]float32
+}
 
-		img, _, err := image.Decode(strings.NewReader(string(data)))
-		if err != nil && debug.Debug {
-			fmt.Println(err)
-		}
-		// Save the image to a file (optional)
-		outFile, err := os.Create("captcha.jpg")
-		if err != nil && debug.Debug {
-			fmt.Println(err)
-		}
-		defer outFile.Close()
+// Confidence returns the probability the model gave each chosen character.
+func (r CaptchaResult) Confidence() []float32 {
+	confidence := make([]float32, len(r.Probabilities))
+	for i, probs := range r.Probabilities {
+		confidence[i] = probs[argmax(probs)]
+	}
+	return confidence
+}
 
-		err = jpeg.Encode(outFile, img, nil)
-		if err != nil && debug.Debug {
-			fmt.Println(err)
+// Weakest returns the position and probability of the least certain
+// character, or -1 when the solver reports no probabilities.
+func (r CaptchaResult) Weakest() (int, float32) {
+	weakest, lowest := -1, float32(1)
+	for i, p := range r.Confidence() {
+		if weakest == -1 || p < lowest {
+			weakest, lowest = i, p
 		}
+	}
+	return weakest, lowest
+}
 
-		var captcha string
-		if killSwitch == 1 {
-			fmt.Println("Captcha auto-solver has been disabled. \nPlease manually solve the captcha and answer here:")
-			fmt.Scanln(&captcha)
-		} else {
-			err = os.Remove("captcha.jpg")
-			if err != nil && debug.Debug {
-				fmt.Println(err)
-			}
+// String lists each character with its probability and the runner-up, e.g.
+// "B 0.48 (8 0.45)".
+func (r CaptchaResult) String() string {
+	parts := make([]string, len(r.Probabilities))
+	for i, probs := range r.Probabilities {
+		order := make([]int, len(probs))
+		for j := range order {
+			order[j] = j
 		}
+		sort.Slice(order, func(a, b int) bool { return probs[order[a]] > probs[order[b]] })
+		parts[i] = fmt.Sprintf("%c %.2f (%c %.2f)", captchaLabels[order[0]], probs[order[0]], captchaLabels[order[1]], probs[order[1]])
+	}
+	return strings.Join(parts, ", ")
+}
 
-		bounds := img.Bounds()
-		rgba := image.NewRGBA(bounds)
-		for y := 0; y < bounds.Dy(); y++ {
-			for x := 0; x < bounds.Dx(); x++ {
-				rgba.Set(x, y, img.At(x, y))
-			}
+// captchaFeatures splits a 200x40 captcha into its six character cells and
+// binarises each one into the input vector of the model.
+func captchaFeatures(img image.Image) [][]int {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			rgba.Set(x, y, img.At(x, y))
 		}
+	}
 
-		pd := rgba.Pix
-		bls := saturation(pd)
-
-		var out string
-		for i := 0; i < 6; i++ {
-			bls[i] = preImg(bls[i])
-			flatBls := flatten(bls[i])
-			result := matMul([][]int{flatBls}, weights)
-			result = matAdd(result, biases)
-			result = maxSoft(result)
-			maxIndex := argmax(result)
-			out += string(labelTxt[maxIndex])
-		}
+	pd := rgba.Pix
+	bls := saturation(pd)
 
-		if debug.Debug {
-			fmt.Println("(Helper - Captcha):", out)
-		}
+	features := make([][]int, len(bls))
+	for i := range bls {
+		features[i] = flatten(preImg(bls[i]))
+	}
+	return features
+}
 
-		if killSwitch == 1 {
-			return captcha
-		} else if killSwitch == 2 {
-			return "disabled"
-		} else {
-			return out
-		}
+// readCaptchaModel runs the built-in one-layer network over the six
+// character cells of a decoded captcha.
+func readCaptchaModel(img image.Image) CaptchaResult {
+	return BuiltinCaptchaModel().Predict(img)
+}
 
-	} else {
-		fmt.Println("Unsupported URL scheme")
+// DecodeCaptchaImage returns the JPEG bytes of a captcha given as a data URL
+// on the VTOP login page.
+func DecodeCaptchaImage(imageURL string) ([]byte, error) {
+	if !strings.HasPrefix(imageURL, "data:image/jpeg;base64,") {
+		return nil, fmt.Errorf("unsupported captcha URL scheme")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(imageURL, "data:image/jpeg;base64,"))
+	if err != nil {
+		return nil, fmt.Errorf("decoding captcha: %w", err)
+	}
+	return data, nil
+}
+
+// ErrLowConfidence is returned with a captcha whose least certain character
+// scored below MinCaptchaConfidence, meaning a fresh captcha is likely a
+// better bet than submitting this one.
+var ErrLowConfidence = errors.New("captcha solver is not confident")
+
+// MinCaptchaConfidence is the lowest per-character probability accepted from
+// solvers that report one. Zero accepts every guess.
+var MinCaptchaConfidence float32 = 0.35
+
+// ReadCaptcha solves a captcha with the configured solver. While the kill
+// switch disables the auto-solver the captcha is always asked for manually.
+// A low-confidence answer is still returned alongside ErrLowConfidence.
+func ReadCaptcha(imageURL string) (string, error) {
+	killSwitch := CheckKillSwitch()
+	if killSwitch == 2 {
+		return "disabled", nil
+	}
+
+	data, err := DecodeCaptchaImage(imageURL)
+	if err != nil {
+		return "", err
+	}
+
+	solver := ActiveCaptchaSolver
+	if killSwitch == 1 {
+		fmt.Fprintln(Messages, "Captcha auto-solver has been disabled.")
+		solver = ManualSolver{}
+	}
+
+	result, err := solveCaptcha(solver, data)
+	if err != nil {
+		return "", fmt.Errorf("%s captcha solver: %w", solver.Name(), err)
+	}
+	debug.Log(fmt.Sprintf("(Helper - Captcha): %s solved %q", solver.Name(), result.Text))
+	if len(result.Probabilities) > 0 {
+		debug.Log("(Helper - Captcha): confidence " + result.String())
+	}
+
+	if i, p := result.Weakest(); i >= 0 && p < MinCaptchaConfidence {
+		return result.Text, fmt.Errorf("%w: %q at position %d scored %.2f", ErrLowConfidence, result.Text[i], i+1, p)
+	}
+	return result.Text, nil
+}
+
+// SolveCaptcha is ReadCaptcha for callers that only want the text, returning
+// an empty string on failure.
+func SolveCaptcha(imageURL string) string {
+	captcha, err := ReadCaptcha(imageURL)
+	if errors.Is(err, ErrLowConfidence) {
+		return captcha
+	}
+	if err != nil {
+		if debug.Debug {
+			fmt.Fprintln(Messages, err)
+		}
 		return ""
 	}
+	return captcha
 }
@@ -0,0 +1,309 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"image"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaptchaModel is the single-layer softmax network used by ModelSolver:
+// one row of Weights per input pixel and one column per label.
+type CaptchaModel struct {
+	Weights [][]float32
+	Biases  []float32
+}
+
+// BuiltinCaptchaModel returns the model compiled in from weights.go.
+func BuiltinCaptchaModel() CaptchaModel {
+	return CaptchaModel{Weights: weights, Biases: biases}
+}
+
+// Clone returns a deep copy, so training never touches the built-in weights.
+func (m CaptchaModel) Clone() CaptchaModel {
+	clone := CaptchaModel{Weights: make([][]float32, len(m.Weights)), Biases: append([]float32(nil), m.Biases...)}
+	for i, row := range m.Weights {
+		clone.Weights[i] = append([]float32(nil), row...)
+	}
+	return clone
+}
+
+// Predict reads the six characters of a 200x40 captcha.
+func (m CaptchaModel) Predict(img image.Image) CaptchaResult {
+	var result CaptchaResult
+	for _, cell := range captchaFeatures(img) {
+		probs := m.probabilities(cell)
+		result.Text += string(captchaLabels[argmax(probs)])
+		result.Probabilities = append(result.Probabilities, probs)
+	}
+	return result
+}
+
+func (m CaptchaModel) probabilities(cell []int) []float32 {
+	return maxSoft(matAdd(matMul([][]int{cell}, m.Weights), m.Biases))
+}
+
+// CaptchaSample is a captcha image with its known answer.
+type CaptchaSample struct {
+	Path  string
+	Label string
+	Image image.Image
+}
+
+// captchaLabelFromName takes the answer from a sample file name such as
+// "AB12CD.jpg" or "AB12CD_1712345678.jpg".
+func captchaLabelFromName(name string) (string, bool) {
+	label := strings.TrimSuffix(name, filepath.Ext(name))
+	if i := strings.IndexByte(label, '_'); i >= 0 {
+		label = label[:i]
+	}
+	label = strings.ToUpper(label)
+	if len(label) != 6 {
+		return "", false
+	}
+	for i := 0; i < len(label); i++ {
+		if strings.IndexByte(captchaLabels, label[i]) < 0 {
+			return "", false
+		}
+	}
+	return label, true
+}
+
+// LoadCaptchaSamples reads every labelled JPEG in dir. Files whose names are
+// not a valid answer, or that are not 200x40 images, are reported in skipped.
+func LoadCaptchaSamples(dir string) (samples []CaptchaSample, skipped []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if ext != ".jpg" && ext != ".jpeg" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		label, ok := captchaLabelFromName(entry.Name())
+		if !ok {
+			skipped = append(skipped, path)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil || img.Bounds().Dx() != 200 || img.Bounds().Dy() != 40 {
+			skipped = append(skipped, path)
+			continue
+		}
+		samples = append(samples, CaptchaSample{Path: path, Label: label, Image: img})
+	}
+	return samples, skipped, nil
+}
+
+// SaveCaptchaSample stores a captcha VTOP accepted under its answer so it
+// can later be used to evaluate or retrain the model.
+func SaveCaptchaSample(dir string, jpegData []byte, answer string) (string, error) {
+	label, ok := captchaLabelFromName(answer)
+	if !ok {
+		return "", fmt.Errorf("%q is not a valid captcha answer", answer)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%d.jpg", label, time.Now().UnixNano()))
+	return path, os.WriteFile(path, jpegData, 0o600)
+}
+
+// CaptchaEvaluation summarises how a model does on labelled samples.
+type CaptchaEvaluation struct {
+	Samples    int
+	Solved     int // captchas with all six characters right
+	Chars      map[byte]*CharAccuracy
+	Confusions map[[2]byte]int // [expected, predicted] -> count
+}
+
+type CharAccuracy struct {
+	Total   int
+	Correct int
+}
+
+// CharConfusion is one mistake pair and how often it happened.
+type CharConfusion struct {
+	Expected  byte
+	Predicted byte
+	Count     int
+}
+
+// Accuracy returns the fraction of characters read correctly.
+func (e CaptchaEvaluation) Accuracy() float64 {
+	total, correct := 0, 0
+	for _, c := range e.Chars {
+		total += c.Total
+		correct += c.Correct
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(correct) / float64(total)
+}
+
+// TopConfusions returns the n most frequent mistakes.
+func (e CaptchaEvaluation) TopConfusions(n int) []CharConfusion {
+	var pairs []CharConfusion
+	for pair, count := range e.Confusions {
+		pairs = append(pairs, CharConfusion{Expected: pair[0], Predicted: pair[1], Count: count})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		return string([]byte{pairs[i].Expected, pairs[i].Predicted}) < string([]byte{pairs[j].Expected, pairs[j].Predicted})
+	})
+	if len(pairs) > n {
+		pairs = pairs[:n]
+	}
+	return pairs
+}
+
+// EvaluateCaptchaModel scores m against labelled samples.
+func EvaluateCaptchaModel(m CaptchaModel, samples []CaptchaSample) CaptchaEvaluation {
+	eval := CaptchaEvaluation{Chars: map[byte]*CharAccuracy{}, Confusions: map[[2]byte]int{}}
+	for _, sample := range samples {
+		predicted := m.Predict(sample.Image).Text
+		eval.Samples++
+		if predicted == sample.Label {
+			eval.Solved++
+		}
+		for i := 0; i < len(sample.Label); i++ {
+			expected := sample.Label[i]
+			if eval.Chars[expected] == nil {
+				eval.Chars[expected] = &CharAccuracy{}
+			}
+			eval.Chars[expected].Total++
+			if predicted[i] == expected {
+				eval.Chars[expected].Correct++
+			} else {
+				eval.Confusions[[2]byte{expected, predicted[i]}]++
+			}
+		}
+	}
+	return eval
+}
+
+// CaptchaTrainOptions controls TrainCaptchaModel.
+type CaptchaTrainOptions struct {
+	Epochs       int
+	LearningRate float64
+	Seed         int64
+	// Start is the model to continue training from; nil starts from zero.
+	Start *CaptchaModel
+	// Progress, if set, is called with the mean loss after every epoch.
+	Progress func(epoch int, loss float64)
+}
+
+// TrainCaptchaModel fits the one-layer model to samples with stochastic
+// gradient descent on the cross-entropy of each character cell.
+func TrainCaptchaModel(samples []CaptchaSample, opts CaptchaTrainOptions) CaptchaModel {
+	type example struct {
+		cell  []int
+		label int
+	}
+	var examples []example
+	for _, sample := range samples {
+		for i, cell := range captchaFeatures(sample.Image) {
+			examples = append(examples, example{cell: cell, label: strings.IndexByte(captchaLabels, sample.Label[i])})
+		}
+	}
+
+	var model CaptchaModel
+	if opts.Start != nil {
+		model = opts.Start.Clone()
+	} else {
+		inputs := len(BuiltinCaptchaModel().Weights)
+		model = CaptchaModel{Weights: make([][]float32, inputs), Biases: make([]float32, len(captchaLabels))}
+		for i := range model.Weights {
+			model.Weights[i] = make([]float32, len(captchaLabels))
+		}
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	rate := float32(opts.LearningRate)
+	for epoch := 1; epoch <= opts.Epochs; epoch++ {
+		rng.Shuffle(len(examples), func(i, j int) { examples[i], examples[j] = examples[j], examples[i] })
+
+		loss := 0.0
+		for _, ex := range examples {
+			probs := model.probabilities(ex.cell)
+			loss -= math.Log(math.Max(float64(probs[ex.label]), 1e-12))
+
+			// d(loss)/d(logit) of softmax cross-entropy is probs - onehot.
+			for k := range probs {
+				grad := probs[k]
+				if k == ex.label {
+					grad -= 1
+				}
+				if grad == 0 {
+					continue
+				}
+				step := rate * grad
+				model.Biases[k] -= step
+				for i, x := range ex.cell {
+					if x != 0 {
+						model.Weights[i][k] -= step
+					}
+				}
+			}
+		}
+		if opts.Progress != nil && len(examples) > 0 {
+			opts.Progress(epoch, loss/float64(len(examples)))
+		}
+	}
+	return model
+}
+
+// WriteCaptchaWeights emits m as a Go source file declaring the weights and
+// biases variables, in the layout of helpers/weights.go.
+func WriteCaptchaWeights(w io.Writer, m CaptchaModel, pkg string) error {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package %s\n\nvar weights = [][]float32{\n", pkg)
+	for _, row := range m.Weights {
+		src.WriteString("{\n")
+		writeFloatList(&src, row)
+		src.WriteString("},\n")
+	}
+	src.WriteString("}\n\nvar biases = []float32{\n")
+	writeFloatList(&src, m.Biases)
+	src.WriteString("}\n")
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+func writeFloatList(buf *bytes.Buffer, values []float32) {
+	for i, v := range values {
+		buf.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+		buf.WriteString(",")
+		if i%5 == 4 || i == len(values)-1 {
+			buf.WriteString("\n")
+		} else {
+			buf.WriteString(" ")
+		}
+	}
+}
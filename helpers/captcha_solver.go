@@ -0,0 +1,176 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	SolverModel   = "model"
+	SolverManual  = "manual"
+	SolverCommand = "command"
+)
+
+// CaptchaSolver reads the text of a VTOP login captcha from its JPEG bytes.
+type CaptchaSolver interface {
+	Name() string
+	Solve(jpegData []byte) (string, error)
+}
+
+// ConfidentSolver is a CaptchaSolver that can also report how sure it is of
+// each character.
+type ConfidentSolver interface {
+	CaptchaSolver
+	SolveWithConfidence(jpegData []byte) (CaptchaResult, error)
+}
+
+// solveCaptcha uses SolveWithConfidence when the solver offers it.
+func solveCaptcha(solver CaptchaSolver, jpegData []byte) (CaptchaResult, error) {
+	if confident, ok := solver.(ConfidentSolver); ok {
+		return confident.SolveWithConfidence(jpegData)
+	}
+	text, err := solver.Solve(jpegData)
+	return CaptchaResult{Text: text}, err
+}
+
+// ActiveCaptchaSolver is used by ReadCaptcha, chosen from CAPTCHA_SOLVER by cmd.
+var ActiveCaptchaSolver CaptchaSolver = ModelSolver{}
+
+// CaptchaSolvers lists the names accepted by NewCaptchaSolver.
+func CaptchaSolvers() []string {
+	return []string{SolverModel, SolverManual, SolverCommand}
+}
+
+// NewCaptchaSolver builds a solver by name. An empty name selects the
+// built-in model; the command solver runs command through the shell.
+func NewCaptchaSolver(name string, command string) (CaptchaSolver, error) {
+	switch name {
+	case "", SolverModel:
+		return ModelSolver{}, nil
+	case SolverManual:
+		return ManualSolver{}, nil
+	case SolverCommand:
+		if strings.TrimSpace(command) == "" {
+			return nil, errors.New("the command captcha solver needs CAPTCHA_COMMAND to be set")
+		}
+		return CommandSolver{Command: command, Timeout: 30 * time.Second}, nil
+	}
+	return nil, fmt.Errorf("unknown captcha solver %q, expected one of %s", name, strings.Join(CaptchaSolvers(), ", "))
+}
+
+// ModelSolver is the built-in network trained on VTOP captchas.
+type ModelSolver struct{}
+
+func (ModelSolver) Name() string { return SolverModel }
+
+func (s ModelSolver) Solve(jpegData []byte) (string, error) {
+	result, err := s.SolveWithConfidence(jpegData)
+	return result.Text, err
+}
+
+func (ModelSolver) SolveWithConfidence(jpegData []byte) (CaptchaResult, error) {
+	img, _, err := image.Decode(bytes.NewReader(jpegData))
+	if err != nil {
+		return CaptchaResult{}, fmt.Errorf("decoding captcha image: %w", err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 40 {
+		return CaptchaResult{}, fmt.Errorf("unexpected captcha size %dx%d", b.Dx(), b.Dy())
+	}
+	return readCaptchaModel(img), nil
+}
+
+// ManualSolver shows the captcha and asks for the answer. Terminals that
+// speak the kitty or sixel graphics protocols get the image inline; anywhere
+// else it is saved to captcha.jpg in the current directory.
+type ManualSolver struct{}
+
+func (ManualSolver) Name() string { return SolverManual }
+
+func (ManualSolver) Solve(jpegData []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(jpegData))
+	if err != nil {
+		return "", fmt.Errorf("decoding captcha image: %w", err)
+	}
+
+	shown := false
+	if out, ok := Messages.(*os.File); ok && term.IsTerminal(int(out.Fd())) {
+		if protocol := TerminalGraphics(); protocol != GraphicsNone {
+			if err := RenderImage(Messages, img, protocol); err == nil {
+				fmt.Fprintln(Messages)
+				shown = true
+			}
+		}
+	}
+	if !shown {
+		if err := os.WriteFile("captcha.jpg", jpegData, 0o644); err != nil {
+			return "", fmt.Errorf("saving captcha image: %w", err)
+		}
+		fmt.Fprintln(Messages, "Captcha saved to captcha.jpg, open it to read the characters.")
+	}
+
+	fmt.Fprint(Messages, "Enter captcha: ")
+	var captcha string
+	if _, err := fmt.Scanln(&captcha); err != nil {
+		return "", fmt.Errorf("reading captcha: %w", err)
+	}
+	return strings.ToUpper(strings.TrimSpace(captcha)), nil
+}
+
+// CommandSolver pipes the JPEG to an external program and reads the captcha
+// text from its standard output.
+type CommandSolver struct {
+	Command string
+	Timeout time.Duration
+}
+
+func (CommandSolver) Name() string { return SolverCommand }
+
+func (s CommandSolver) Solve(jpegData []byte) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", s.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", s.Command)
+	}
+	cmd.Stdin = bytes.NewReader(jpegData)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timeout = time.After(s.Timeout)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	case <-timeout:
+		cmd.Process.Kill()
+		<-done
+		return "", fmt.Errorf("timed out after %s", s.Timeout)
+	}
+
+	captcha := strings.ToUpper(strings.TrimSpace(stdout.String()))
+	if captcha == "" {
+		return "", errors.New("command printed no captcha")
+	}
+	return captcha, nil
+}
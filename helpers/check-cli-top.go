@@ -3,8 +3,6 @@ package helpers
 import (
 	"archive/zip"
 	"bufio"
-	"cli-top/debug"
-	types "cli-top/types"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,12 +14,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/fatih/color"
 	"github.com/spf13/viper"
 )
 
 var latestJSONURL = "https://cli-top.acmvit.in/latest.json"
 
+// killSwitchTimeout bounds the kill switch check, so a network that drops
+// the request does not leave cli-top hanging before it starts.
+const killSwitchTimeout = 10 * time.Second
+
 func SetLatestJSONURL(url string) {
 	latestJSONURL = url
 }
@@ -51,33 +55,33 @@ func displayUpdateLogo() {
 
 	pink.Print(logo)
 	cyan.Println("                    AUTO-UPDATER")
-	fmt.Println()
+	fmt.Fprintln(Messages)
 }
 
 // Version info structure to match the JSON response
 
 func CheckUpdate() {
-	client := &http.Client{}
+	client := NewHTTPClient(0)
 	req, err := http.NewRequest("GET", "https://cli-top.acmvit.in/latest.json", nil)
 
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 		return
 	}
 	resp, err := client.Do(req)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 		return
 	}
 	if resp == nil {
-		fmt.Println("Failed to connect to update server")
+		fmt.Fprintln(Messages, "Failed to connect to update server")
 		return
 	}
 	defer resp.Body.Close()
 
 	bodyText, err := io.ReadAll(resp.Body)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 		return
 	}
 
@@ -85,35 +89,35 @@ func CheckUpdate() {
 	var versionInfo types.VersionInfo
 	if err := json.Unmarshal(bodyText, &versionInfo); err != nil {
 		if debug.Debug {
-			fmt.Println("Error parsing version info:", err)
+			fmt.Fprintln(Messages, "Error parsing version info:", err)
 		}
 		// Fallback to the old string comparison method
 		if !strings.Contains(string(bodyText), debug.Version) {
-			fmt.Println("A new version of cli-top is available.\nCheck out: https://cli-top.acmvit.in/ for the latest release.")
+			fmt.Fprintln(Messages, "A new version of cli-top is available.\nCheck out: https://cli-top.acmvit.in/ for the latest release.")
 		} else {
-			fmt.Println("You are using the latest stable version of cli-top.")
+			fmt.Fprintln(Messages, "You are using the latest stable version of cli-top.")
 		}
 		return
 	}
 
 	// Compare versions
 	if versionInfo.Version != debug.Version {
-		fmt.Printf("A new version %s is available (you have %s).\n", versionInfo.Version, debug.Version)
-		fmt.Print("Would you like to update now? (y/n): ")
+		fmt.Fprintf(Messages, "A new version %s is available (you have %s).\n", versionInfo.Version, debug.Version)
+		fmt.Fprint(Messages, "Would you like to update now? (y/n): ")
 
 		reader := bufio.NewReader(os.Stdin)
 		input, _ := reader.ReadString('\n')
 		input = strings.TrimSpace(strings.ToLower(input))
 
 		if input == "y" || input == "yes" {
-			fmt.Println("Starting update process...")
+			fmt.Fprintln(Messages, "Starting update process...")
 			Update()
 		} else {
-			fmt.Println("Update skipped. You can update later with 'cli-top update'")
-			fmt.Println("or visit: https://cli-top.acmvit.in/ for the latest release.")
+			fmt.Fprintln(Messages, "Update skipped. You can update later with 'cli-top update'")
+			fmt.Fprintln(Messages, "or visit: https://cli-top.acmvit.in/ for the latest release.")
 		}
 	} else {
-		fmt.Println("You are using the latest stable version of cli-top.")
+		fmt.Fprintln(Messages, "You are using the latest stable version of cli-top.")
 	}
 }
 
@@ -143,33 +147,36 @@ func OpenURLInBrowser(url string) error {
 	return cmd.Start()
 }
 
+// CheckKillSwitch asks the cli-top server which features are switched off.
+// It goes through the proxy, DNS and CA settings in effect, so call it once
+// they are configured.
 func CheckKillSwitch() int {
-	client := &http.Client{}
+	client := NewHTTPClient(killSwitchTimeout)
 	req, err := http.NewRequest("GET", latestJSONURL, nil)
 
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 	}
 	resp, err := client.Do(req)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 	}
 	if resp == nil {
-		fmt.Println()
-		fmt.Println("Internet connection not available")
-		fmt.Println("Please reconnect and try again")
-		fmt.Println()
+		fmt.Fprintln(Messages)
+		fmt.Fprintln(Messages, "Internet connection not available")
+		fmt.Fprintln(Messages, "Please reconnect and try again")
+		fmt.Fprintln(Messages)
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
 	bodyText, err := io.ReadAll(resp.Body)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 	}
 
 	var versionInfo types.VersionInfo
 	if err := json.Unmarshal(bodyText, &versionInfo); err != nil && debug.Debug {
-		fmt.Println("Error parsing version info:", err)
+		fmt.Fprintln(Messages, "Error parsing version info:", err)
 		return 1
 	}
 
@@ -178,10 +185,10 @@ func CheckKillSwitch() int {
 
 // Update checks for a new version and auto-updates the current binary.
 func Update() {
-	fmt.Println("Checking for updates...")
-	resp, err := http.Get("https://cli-top.acmvit.in/latest.json")
+	fmt.Fprintln(Messages, "Checking for updates...")
+	resp, err := NewHTTPClient(0).Get("https://cli-top.acmvit.in/latest.json")
 	if err != nil {
-		fmt.Println("Error checking for update:", err)
+		fmt.Fprintln(Messages, "Error checking for update:", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -189,11 +196,11 @@ func Update() {
 	body, _ := io.ReadAll(resp.Body)
 	var vi types.VersionInfo
 	if json.Unmarshal(body, &vi) != nil || vi.Version == debug.Version {
-		fmt.Println("You are using the latest stable version of cli-top.")
+		fmt.Fprintln(Messages, "You are using the latest stable version of cli-top.")
 		return
 	}
 
-	fmt.Printf("A new version %s is available. Downloading update…\n", vi.Version)
+	fmt.Fprintf(Messages, "A new version %s is available. Downloading update…\n", vi.Version)
 
 	base := "https://github.com/technical-director-acmvit/cli-top-website/raw/main/buildFiles"
 	var dl string
@@ -207,13 +214,13 @@ func Update() {
 	case "darwin":
 		dl = fmt.Sprintf("%s/v%s/cli-top-macos_v%s.zip", base, vi.Version, vi.Version)
 	default:
-		fmt.Println("Auto-update not supported on", runtime.GOOS)
+		fmt.Fprintln(Messages, "Auto-update not supported on", runtime.GOOS)
 		return
 	}
 
-	resp, err = http.Get(dl)
+	resp, err = NewHTTPClient(0).Get(dl)
 	if err != nil {
-		fmt.Println("Error downloading update:", err)
+		fmt.Fprintln(Messages, "Error downloading update:", err)
 		return
 	}
 	defer resp.Body.Close()
@@ -230,15 +237,15 @@ func Update() {
 		yellow := color.New(color.FgHiYellow)
 		cyan := color.New(color.FgHiCyan)
 
-		fmt.Println("┌─────────────────────────────────────────────────────────┐")
-		fmt.Printf("│ %-55s │\n", fmt.Sprintf("Updating CLI-TOP to version %s", vi.Version))
-		fmt.Println("├─────────────────────────────────────────────────────────┤")
-		fmt.Printf("│ Current Version: %-38s │\n", debug.Version)
-		fmt.Printf("│ New Version:     %-38s │\n", vi.Version)
-		fmt.Println("└─────────────────────────────────────────────────────────┘")
-		fmt.Println()
+		fmt.Fprintln(Messages, "┌─────────────────────────────────────────────────────────┐")
+		fmt.Fprintf(Messages, "│ %-55s │\n", fmt.Sprintf("Updating CLI-TOP to version %s", vi.Version))
+		fmt.Fprintln(Messages, "├─────────────────────────────────────────────────────────┤")
+		fmt.Fprintf(Messages, "│ Current Version: %-38s │\n", debug.Version)
+		fmt.Fprintf(Messages, "│ New Version:     %-38s │\n", vi.Version)
+		fmt.Fprintln(Messages, "└─────────────────────────────────────────────────────────┘")
+		fmt.Fprintln(Messages)
 		yellow.Println("WARNING: Please do not close this window during the update!")
-		fmt.Println()
+		fmt.Fprintln(Messages)
 
 		cyan.Print("[*] Preparing installer... ")
 		installer := filepath.Join(os.TempDir(), fmt.Sprintf("cli-top_update_%s.exe", vi.Version))
@@ -277,17 +284,17 @@ timeout /t 3 /nobreak >nul`, vi.Version, installer, execPath, installer)
 		os.WriteFile(bat, []byte(script), 0644)
 		green.Println("[DONE]")
 
-		fmt.Println()
+		fmt.Fprintln(Messages)
 		green.Println("[*] Starting update process...")
 		yellow.Println("    The application will close and restart automatically.")
-		fmt.Println()
+		fmt.Fprintln(Messages)
 
 		time.Sleep(2 * time.Second)
 
 		exec.Command("cmd", "/C", "start", "", bat).Start()
 
 		cyan.Println("[+] Update is in progress. CLI-TOP will restart automatically.")
-		fmt.Println()
+		fmt.Fprintln(Messages)
 		os.Exit(0)
 	}
 
@@ -296,7 +303,7 @@ timeout /t 3 /nobreak >nul`, vi.Version, installer, execPath, installer)
 		if b, err := extractBinaryFromZipToBytes(data); err == nil {
 			data = b
 		} else {
-			fmt.Println("Error extracting binary:", err)
+			fmt.Fprintln(Messages, "Error extracting binary:", err)
 			return
 		}
 	}
@@ -305,10 +312,10 @@ timeout /t 3 /nobreak >nul`, vi.Version, installer, execPath, installer)
 	os.Rename(execPath, backup)
 	if os.WriteFile(execPath, data, 0755) != nil {
 		os.Rename(backup, execPath)
-		fmt.Println("Update failed; restored previous version.")
+		fmt.Fprintln(Messages, "Update failed; restored previous version.")
 		return
 	}
-	fmt.Printf("Successfully updated to %s. Restart the application to use the new version.\n", vi.Version)
+	fmt.Fprintf(Messages, "Successfully updated to %s. Restart the application to use the new version.\n", vi.Version)
 }
 
 // extractBinaryFromZip extracts the binary file from a zip archive.
@@ -411,7 +418,7 @@ func checkWritePermission(path string) error {
 }
 
 func CheckUpdateSilently() (bool, string, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := NewHTTPClient(5 * time.Second)
 	req, err := http.NewRequest("GET", "https://cli-top.acmvit.in/latest.json", nil)
 	if err != nil {
 		return false, "", err
@@ -458,19 +465,19 @@ func ShouldShowUpdateNotification() (bool, string) {
 
 	viper.Set("LAST_UPDATE_NOTIFIED_VERSION", currentVersion)
 	if err := viper.WriteConfig(); err != nil && debug.Debug {
-		fmt.Println("Error updating last notified version in config:", err)
+		fmt.Fprintln(Messages, "Error updating last notified version in config:", err)
 	}
 
 	return true, latestVersion
 }
 
 func ShowUpdateNotification(latestVersion string) {
-	fmt.Printf("\n")
-	fmt.Printf("┌─────────────────────────────────────────────────────────┐\n")
-	fmt.Printf("│ A new version of cli-top is available!                  │\n")
-	fmt.Printf("│ Current version: %-10s   Latest version: %-10s│\n", debug.Version, latestVersion)
-	fmt.Printf("│                                                         │\n")
-	fmt.Printf("│ Update with: cli-top -u                                 │\n")
-	fmt.Printf("└─────────────────────────────────────────────────────────┘\n")
-	fmt.Printf("\n")
+	fmt.Fprintf(Messages, "\n")
+	fmt.Fprintf(Messages, "┌─────────────────────────────────────────────────────────┐\n")
+	fmt.Fprintf(Messages, "│ A new version of cli-top is available!                  │\n")
+	fmt.Fprintf(Messages, "│ Current version: %-10s   Latest version: %-10s│\n", debug.Version, latestVersion)
+	fmt.Fprintf(Messages, "│                                                         │\n")
+	fmt.Fprintf(Messages, "│ Update with: cli-top -u                                 │\n")
+	fmt.Fprintf(Messages, "└─────────────────────────────────────────────────────────┘\n")
+	fmt.Fprintf(Messages, "\n")
 }
@@ -0,0 +1,254 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/types"
+	"golang.org/x/net/publicsuffix"
+)
+
+// VtopURL is the application root that VTOP scopes its session cookies to.
+var VtopURL = &url.URL{Scheme: "https", Host: "vtop.vit.ac.in", Path: "/vtop/"}
+
+// storedCookie is the on-disk form of a cookie. net/http/cookiejar does not
+// expose domain, path or expiry once a cookie is accepted, so they are kept
+// here as they arrive.
+type storedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// SessionJar is an http.CookieJar holding a VTOP session. Once the jar is
+// loaded from a file, every cookie set by a response is written through to
+// it so the session, and any cookie VTOP rotates mid-session, survives
+// between runs.
+type SessionJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	path    string
+	entries map[string]map[string]storedCookie // origin -> name -> cookie
+}
+
+var sessionJar = newSessionJar()
+
+func newSessionJar() *SessionJar {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		panic(fmt.Sprintf("helpers: failed to create cookie jar: %v", err))
+	}
+	return &SessionJar{jar: jar, entries: map[string]map[string]storedCookie{}}
+}
+
+// CookieJar returns the jar holding the CLI's VTOP session, the one Vtop
+// sends.
+func CookieJar() *SessionJar {
+	return sessionJar
+}
+
+func (j *SessionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+
+	origin := u.Scheme + "://" + u.Host
+	if j.entries[origin] == nil {
+		j.entries[origin] = map[string]storedCookie{}
+	}
+	for _, c := range cookies {
+		expires := c.Expires
+		if c.MaxAge > 0 {
+			expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		if c.MaxAge < 0 || (!expires.IsZero() && expires.Before(time.Now())) {
+			delete(j.entries[origin], c.Name)
+			continue
+		}
+		j.entries[origin][c.Name] = storedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     cookiePath(u, c),
+			Expires:  expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+	}
+
+	if err := j.save(); err != nil && debug.Debug {
+		fmt.Fprintln(Messages, "Error saving cookies:", err)
+	}
+}
+
+func (j *SessionJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// cookiePath is the path the jar scopes c to, following RFC 6265 when the
+// response did not set one.
+func cookiePath(u *url.URL, c *http.Cookie) string {
+	if c.Path != "" {
+		return c.Path
+	}
+	i := strings.LastIndex(u.Path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return u.Path[:i]
+}
+
+// Load points the jar at path and replaces its contents with the cookies
+// saved there. A missing file leaves the jar empty.
+func (j *SessionJar) Load(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fresh := newSessionJar()
+	j.jar = fresh.jar
+	j.entries = fresh.entries
+	j.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries map[string]map[string]storedCookie
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("corrupt cookie file %s: %w", path, err)
+	}
+
+	now := time.Now()
+	for origin, byName := range entries {
+		u, err := url.Parse(origin)
+		if err != nil {
+			continue
+		}
+		var cookies []*http.Cookie
+		for name, c := range byName {
+			if !c.Expires.IsZero() && c.Expires.Before(now) {
+				continue
+			}
+			cookies = append(cookies, &http.Cookie{
+				Name:     c.Name,
+				Value:    c.Value,
+				Domain:   c.Domain,
+				Path:     c.Path,
+				Expires:  c.Expires,
+				Secure:   c.Secure,
+				HttpOnly: c.HttpOnly,
+			})
+			if j.entries[origin] == nil {
+				j.entries[origin] = map[string]storedCookie{}
+			}
+			j.entries[origin][name] = c
+		}
+		j.jar.SetCookies(u, cookies)
+	}
+	return nil
+}
+
+// Clear drops every cookie, used before a fresh login and on logout.
+func (j *SessionJar) Clear() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	fresh := newSessionJar()
+	j.jar = fresh.jar
+	j.entries = fresh.entries
+	if j.path == "" {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// save writes the jar to its file; the caller holds j.mu.
+func (j *SessionJar) save() error {
+	if j.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(j.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// VtopCookies reports the session cookies the CLI currently holds for VTOP.
+// CSRF is not a cookie and is left empty.
+func VtopCookies() types.Cookies {
+	return sessionJar.VtopCookies()
+}
+
+// VtopCookies reports the session cookies j holds for VTOP.
+func (j *SessionJar) VtopCookies() types.Cookies {
+	var cookies types.Cookies
+	for _, c := range j.Cookies(VtopURL) {
+		switch c.Name {
+		case "JSESSIONID":
+			cookies.JSESSIONID = c.Value
+		case "SERVERID":
+			cookies.SERVERID = c.Value
+		}
+	}
+	return cookies
+}
+
+// SeedVtopCookies puts session cookies saved by older versions into the jar
+// when it does not already hold a session.
+func SeedVtopCookies(cookies types.Cookies) {
+	if VtopCookies().JSESSIONID != "" {
+		return
+	}
+	UseVtopCookies(cookies)
+}
+
+// UseVtopCookies makes cookies the session the CLI sends to VTOP, replacing
+// any it already holds.
+func UseVtopCookies(cookies types.Cookies) {
+	sessionJar.UseVtopCookies(cookies)
+}
+
+// UseVtopCookies makes cookies the session j sends to VTOP, replacing any it
+// already holds.
+func (j *SessionJar) UseVtopCookies(cookies types.Cookies) {
+	current := j.VtopCookies()
+	if cookies.JSESSIONID == "" || (cookies.JSESSIONID == current.JSESSIONID && cookies.SERVERID == current.SERVERID) {
+		return
+	}
+	seed := []*http.Cookie{{Name: "JSESSIONID", Value: cookies.JSESSIONID, Secure: true, HttpOnly: true}}
+	if cookies.SERVERID != "" {
+		seed = append(seed, &http.Cookie{Name: "SERVERID", Value: cookies.SERVERID, Path: "/"})
+	}
+	j.SetCookies(VtopURL, seed)
+}
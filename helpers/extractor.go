@@ -1,14 +1,13 @@
 package helpers
 
 import (
-	"cli-top/debug"
-	"cli-top/types"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -21,7 +20,7 @@ func extractImageSrc(html string) (string, error) {
 	src := doc.Find("#captchaBlock img").AttrOr("src", "")
 	if src == "" {
 		if debug.Debug {
-			fmt.Println("No captcha image found, retrying...")
+			fmt.Fprintln(Messages, "No captcha image found, retrying...")
 		}
 		return "nocaptcha", nil
 	}
@@ -32,7 +31,7 @@ func extractImageSrc(html string) (string, error) {
 func ExtractImage(html string) string {
 	src, err := extractImageSrc(html)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 		return ""
 	}
 	// fmt.Println(src)
@@ -40,35 +39,10 @@ func ExtractImage(html string) string {
 	return src
 }
 
-func ExtractCookies(resp *http.Response) types.Cookies {
-	if resp == nil {
-		fmt.Println("Response is nil")
-	}
-
-	secrets := make(map[string]string)
-	respCookies := resp.Cookies()
-	for _, cookie := range respCookies {
-		// fmt.Println(cookie.Name, cookie.Value)
-		secrets[cookie.Name] = cookie.Value
-	}
-
-	// if debug.Debug {
-	// 	fmt.Println("(Helper - ExtractCookies):", secrets)
-	// }
-
-	cookies := types.Cookies{
-		SERVERID:   secrets["SERVERID"],
-		CSRF:       "",
-		JSESSIONID: secrets["JSESSIONID"],
-	}
-
-	return cookies
-}
-
 func ExtractBodyText(resp *http.Response) string {
 	bodyText, err := io.ReadAll(resp.Body)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(Messages, err)
 		return ""
 	}
 
@@ -102,7 +76,7 @@ func ExtractCSRF(bodyString string) string {
 	}
 
 	if debug.Debug {
-		fmt.Println("(Helper - ExtractCSRF):", csrf)
+		fmt.Fprintln(Messages, "(Helper - ExtractCSRF):", csrf)
 	}
 
 	return csrf
@@ -123,7 +97,7 @@ func ExtractCSRF2(bodyString string) string {
 	}
 
 	if debug.Debug {
-		fmt.Println("(Helper - ExtractCSRF2):", csrf)
+		fmt.Fprintln(Messages, "(Helper - ExtractCSRF2):", csrf)
 	}
 
 	return csrf
@@ -0,0 +1,320 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+)
+
+// Redacted replaces secrets in recorded fixtures.
+const Redacted = "REDACTED"
+
+// FixtureExchange is one recorded request and the response VTOP gave.
+type FixtureExchange struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+type FixtureRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// FixtureResponse holds a text body in Body and anything else, such as a
+// downloaded PDF, in BodyBase64.
+type FixtureResponse struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"bodyBase64,omitempty"`
+}
+
+// fixtureMode is the active recorder or replayer, nil in normal use.
+type fixtureMode interface {
+	wrap(next http.RoundTripper) http.RoundTripper
+}
+
+var fixtures fixtureMode
+
+// ErrNotRecorded is returned under --replay for a request missing from the
+// recordings.
+var ErrNotRecorded = errors.New("no recording of this request")
+
+// Replaying reports whether requests are served from recordings.
+func Replaying() bool {
+	_, ok := fixtures.(*fixtureReplayer)
+	return ok
+}
+
+// RecordFixtures saves every VTOP request and response to dir, with
+// credentials, CSRF tokens and cookies redacted.
+func RecordFixtures(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	fixtures = &fixtureRecorder{dir: dir}
+	rebuildSharedTransport()
+	return nil
+}
+
+// ReplayFixtures answers VTOP requests from a directory written by
+// RecordFixtures instead of the network.
+func ReplayFixtures(dir string) error {
+	exchanges, err := LoadFixtures(dir)
+	if err != nil {
+		return err
+	}
+	if len(exchanges) == 0 {
+		return fmt.Errorf("no recordings found in %s", dir)
+	}
+	replayer := &fixtureReplayer{queues: map[string][]FixtureExchange{}}
+	for _, ex := range exchanges {
+		key := fixtureKey(ex.Request.Method, ex.Request.URL, ex.Request.Body)
+		replayer.queues[key] = append(replayer.queues[key], ex)
+	}
+	fixtures = replayer
+	rebuildSharedTransport()
+	return nil
+}
+
+// StopFixtures goes back to sending requests to VTOP unrecorded.
+func StopFixtures() {
+	fixtures = nil
+	rebuildSharedTransport()
+}
+
+// LoadFixtures reads the recordings in dir in the order they were made.
+func LoadFixtures(dir string) ([]FixtureExchange, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var exchanges []FixtureExchange
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var ex FixtureExchange
+		if err := json.Unmarshal(data, &ex); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, nil
+}
+
+type fixtureRecorder struct {
+	dir string
+	mu  sync.Mutex
+	n   int
+}
+
+func (r *fixtureRecorder) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var reqBody []byte
+		if req.Body != nil {
+			var err error
+			if reqBody, err = io.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+			req.Body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+		if err := r.save(req, reqBody, resp, respBody); err != nil {
+			debug.Log("Could not record " + req.URL.String() + ": " + err.Error())
+		}
+		return resp, nil
+	})
+}
+
+func (r *fixtureRecorder) save(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) error {
+	ex := FixtureExchange{
+		Request: FixtureRequest{
+			Method: req.Method,
+			URL:    redactQuery(req.URL),
+			Header: redactHeader(req.Header, "Cookie", "Authorization"),
+			Body:   redactForm(string(reqBody)),
+		},
+		Response: FixtureResponse{
+			Status: resp.StatusCode,
+			Header: redactHeader(resp.Header, "Set-Cookie"),
+		},
+	}
+	if utf8.Valid(respBody) {
+		ex.Response.Body = redactPage(string(respBody))
+	} else {
+		ex.Response.BodyBase64 = base64.StdEncoding.EncodeToString(respBody)
+	}
+	data, err := json.MarshalIndent(ex, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.n++
+	name := fmt.Sprintf("%04d-%s%s.json", r.n, strings.ToLower(req.Method), fixtureSlug.ReplaceAllString(req.URL.Path, "-"))
+	r.mu.Unlock()
+	return os.WriteFile(filepath.Join(r.dir, name), data, 0o600)
+}
+
+type fixtureReplayer struct {
+	mu     sync.Mutex
+	queues map[string][]FixtureExchange
+}
+
+// wrap never calls next: replay works without a network.
+func (r *fixtureReplayer) wrap(http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var reqBody []byte
+		if req.Body != nil {
+			reqBody, _ = io.ReadAll(req.Body)
+			req.Body.Close()
+		}
+		key := fixtureKey(req.Method, req.URL.String(), redactForm(string(reqBody)))
+
+		// Repeated requests get the recorded responses in order; the last one
+		// is served again once they run out.
+		r.mu.Lock()
+		queue := r.queues[key]
+		var ex FixtureExchange
+		if len(queue) > 0 {
+			ex = queue[0]
+			if len(queue) > 1 {
+				r.queues[key] = queue[1:]
+			}
+		}
+		r.mu.Unlock()
+		if len(queue) == 0 {
+			return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, req.URL.Path)
+		}
+
+		body := []byte(ex.Response.Body)
+		if ex.Response.BodyBase64 != "" {
+			body, _ = base64.StdEncoding.DecodeString(ex.Response.BodyBase64)
+		}
+		header := ex.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Del("Set-Cookie")
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", ex.Response.Status, http.StatusText(ex.Response.Status)),
+			StatusCode:    ex.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+var fixtureSlug = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// secretFields are redacted from recorded forms; volatileFields change on
+// every run and are left out when matching a request to its recording.
+var (
+	secretFields   = []string{"username", "password", "_csrf", "authorizedID"}
+	volatileFields = map[string]bool{"_csrf": true, "x": true, "nocache": true, "authorizedID": true, "username": true, "password": true, "captchaStr": true}
+)
+
+var (
+	urlencodedSecret = regexp.MustCompile(`(^|&)(` + strings.Join(secretFields, "|") + `)=[^&]*`)
+	multipartSecret  = regexp.MustCompile(`(name="(?:` + strings.Join(secretFields, "|") + `)"\r?\n\r?\n)[^\r\n]*`)
+	multipartField   = regexp.MustCompile(`name="([^"]+)"\r?\n\r?\n([^\r\n]*)`)
+	pageSecret       = regexp.MustCompile(`(name="_csrf"[^>]*?value=")[^"]*`)
+)
+
+func redactForm(body string) string {
+	body = urlencodedSecret.ReplaceAllString(body, "${1}${2}="+Redacted)
+	return multipartSecret.ReplaceAllString(body, "${1}"+Redacted)
+}
+
+// redactPage hides the CSRF token VTOP embeds in its pages.
+func redactPage(body string) string {
+	return pageSecret.ReplaceAllString(body, "${1}"+Redacted)
+}
+
+func redactQuery(u *url.URL) string {
+	redacted := *u
+	redacted.RawQuery = redactForm(u.RawQuery)
+	return redacted.String()
+}
+
+func redactHeader(h http.Header, names ...string) http.Header {
+	h = h.Clone()
+	for _, name := range names {
+		if values := h.Values(name); len(values) > 0 {
+			h[http.CanonicalHeaderKey(name)] = []string{Redacted}
+		}
+	}
+	return h
+}
+
+// fixtureKey identifies a request by method, path and the form fields that
+// do not change from run to run.
+func fixtureKey(method string, rawURL string, body string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return method + " " + rawURL + "\n" + body
+	}
+	return method + " " + u.Path + "?" + stableForm(u.RawQuery) + "\n" + stableForm(body)
+}
+
+func stableForm(form string) string {
+	var values url.Values
+	if strings.HasPrefix(form, "--") {
+		// Multipart boundaries are random, so only the fields are compared.
+		values = url.Values{}
+		for _, m := range multipartField.FindAllStringSubmatch(form, -1) {
+			values.Add(m[1], m[2])
+		}
+	} else {
+		var err error
+		if values, err = url.ParseQuery(form); err != nil {
+			return form
+		}
+	}
+	for field := range values {
+		if volatileFields[field] {
+			delete(values, field)
+		}
+	}
+	return values.Encode()
+}
@@ -3,10 +3,8 @@ package helpers
 import (
 	"archive/zip"
 	"bytes"
-	"cli-top/debug"
-	types "cli-top/types"
+	"context"
 	"fmt"
-	"io"
 	"mime"
 	"net/http"
 	"net/url"
@@ -19,6 +17,8 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/h2non/filetype"
 
 	"github.com/PuerkitoBio/goquery"
@@ -40,7 +40,7 @@ import (
 func StrToInt(str string) int {
 	num, err := strconv.Atoi(str)
 	if err != nil && debug.Debug {
-		fmt.Println("Error converting string to integer:", err)
+		fmt.Fprintln(Messages, "Error converting string to integer:", err)
 	}
 	return num
 }
@@ -60,16 +60,16 @@ func RemoveEmptyStrings(data []string) []string {
 }
 
 func GenerateCalendarImportLinks(icsURL string, calendarName string) {
-	fmt.Println("Import into your calendar using the links below:")
-	fmt.Println()
+	fmt.Fprintln(Messages, "Import into your calendar using the links below:")
+	fmt.Fprintln(Messages)
 	blueColor := "\033[34m"
 	resetColor := "\033[0m"
 	googleLink := GenerateGoogleCalendarLink(icsURL)
 	googleLinkText := blueColor + "Add to Google Calendar" + resetColor
-	fmt.Println(MakeANSILink(googleLinkText, googleLink))
+	fmt.Fprintln(Messages, MakeANSILink(googleLinkText, googleLink))
 	outlookLink := GenerateOutlookCalendarImportLink(icsURL, calendarName)
 	outlookLinkText := blueColor + "Add to Outlook Calendar" + resetColor
-	fmt.Println(MakeANSILink(outlookLinkText, outlookLink))
+	fmt.Fprintln(Messages, MakeANSILink(outlookLinkText, outlookLink))
 }
 
 func GenerateOutlookCalendarImportLink(icsURL string, calendarName string) string {
@@ -242,49 +242,6 @@ func FormatBodyDataClient(payloadMap map[string]string) []byte {
 	return []byte(sb.String())
 }
 
-func FetchReqClient(client *http.Client, regNo string, cookies types.Cookies, url string, referer string, formData []byte, method string, contentType string) ([]byte, http.Header, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(formData))
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
-	}
-
-	// Apply default headers for VTOP requests
-	SetVtopHeaders(req)
-
-	req.Header.Set("Content-Type", contentType)
-	if referer != "" {
-		req.Header.Set("Referer", referer)
-	}
-
-	if cookies.JSESSIONID != "" {
-		req.AddCookie(&http.Cookie{
-			Name:  "JSESSIONID",
-			Value: cookies.JSESSIONID,
-			Path:  "/",
-		})
-	}
-	if cookies.SERVERID != "" {
-		req.AddCookie(&http.Cookie{
-			Name:  "SERVERID",
-			Value: cookies.SERVERID,
-			Path:  "/",
-		})
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to perform HTTP request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	return body, resp.Header, nil
-}
-
 // func buildCookieHeader(cookies types.Cookies) string {
 // 	return fmt.Sprintf("JSESSIONID=%s; SERVERID=%s;", cookies.JSESSIONID, cookies.SERVERID)
 // }
@@ -310,7 +267,7 @@ func GetFileExtension(filename string, body []byte, headers http.Header) string
 
 	kind, err := filetypeMatch(body)
 	if err == nil && kind != "unknown" {
-		fmt.Printf("Filetype package detected: %s\n", kind)
+		fmt.Fprintf(Messages, "Filetype package detected: %s\n", kind)
 		switch kind {
 		case "doc":
 			return ".doc"
@@ -335,14 +292,14 @@ func GetFileExtension(filename string, body []byte, headers http.Header) string
 			}
 			return ".zip"
 		default:
-			fmt.Printf("Filetype package detected unknown type: %s\n", kind)
+			fmt.Fprintf(Messages, "Filetype package detected unknown type: %s\n", kind)
 		}
 	} else {
-		fmt.Println("Filetype package could not determine the file type.")
+		fmt.Fprintln(Messages, "Filetype package could not determine the file type.")
 	}
 
 	mimeType := http.DetectContentType(body)
-	fmt.Printf("MIME type detected: %s\n", mimeType)
+	fmt.Fprintf(Messages, "MIME type detected: %s\n", mimeType)
 	switch mimeType {
 	case "application/msword":
 		return ".doc"
@@ -367,51 +324,51 @@ func GetFileExtension(filename string, body []byte, headers http.Header) string
 		}
 		return ".zip"
 	default:
-		fmt.Printf("Unhandled MIME type: %s\n", mimeType)
+		fmt.Fprintf(Messages, "Unhandled MIME type: %s\n", mimeType)
 	}
 
 	if len(body) >= 8 && bytes.Equal(body[:8], []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}) {
-		fmt.Println("OLE Compound Document detected.")
+		fmt.Fprintln(Messages, "OLE Compound Document detected.")
 		if bytes.Contains(body, []byte("WordDocument")) {
-			fmt.Println("Identified as .doc")
+			fmt.Fprintln(Messages, "Identified as .doc")
 			return ".doc"
 		}
 		if bytes.Contains(body, []byte("Workbook")) || bytes.Contains(body, []byte("Book")) {
-			fmt.Println("Identified as .xls")
+			fmt.Fprintln(Messages, "Identified as .xls")
 			return ".xls"
 		}
 		if bytes.Contains(body, []byte("PowerPoint Document")) {
-			fmt.Println("Identified as .ppt")
+			fmt.Fprintln(Messages, "Identified as .ppt")
 			return ".ppt"
 		}
-		fmt.Println("OLE Compound Document but specific type not identified. Assigning .bin")
+		fmt.Fprintln(Messages, "OLE Compound Document but specific type not identified. Assigning .bin")
 		return ".bin"
 	}
 
 	if len(body) >= 4 && string(body[:4]) == "PK\x03\x04" {
-		fmt.Println("ZIP archive detected. Inspecting internal structure for OOXML formats.")
+		fmt.Fprintln(Messages, "ZIP archive detected. Inspecting internal structure for OOXML formats.")
 		readerAt := bytes.NewReader(body)
 		size := int64(len(body))
 		zipReader, err := zip.NewReader(readerAt, size)
 		if err == nil {
 			for _, f := range zipReader.File {
 				if strings.HasPrefix(f.Name, "ppt/") {
-					fmt.Println("Identified as .pptx")
+					fmt.Fprintln(Messages, "Identified as .pptx")
 					return ".pptx"
 				} else if strings.HasPrefix(f.Name, "word/") {
-					fmt.Println("Identified as .docx")
+					fmt.Fprintln(Messages, "Identified as .docx")
 					return ".docx"
 				} else if strings.HasPrefix(f.Name, "xl/") {
-					fmt.Println("Identified as .xlsx")
+					fmt.Fprintln(Messages, "Identified as .xlsx")
 					return ".xlsx"
 				}
 			}
 		} else {
-			fmt.Printf("Error reading ZIP structure: %v\n", err)
+			fmt.Fprintf(Messages, "Error reading ZIP structure: %v\n", err)
 		}
 	}
 
-	fmt.Println("Failed to determine file extension; assigning .bin")
+	fmt.Fprintln(Messages, "Failed to determine file extension; assigning .bin")
 	return ".bin"
 }
 
@@ -451,7 +408,7 @@ func getOOXMLExtension(body []byte) string {
 	size := int64(len(body))
 	zipReader, err := zip.NewReader(readerAt, size)
 	if err != nil {
-		fmt.Printf("Error reading ZIP structure: %v\n", err)
+		fmt.Fprintf(Messages, "Error reading ZIP structure: %v\n", err)
 		return ""
 	}
 	for _, f := range zipReader.File {
@@ -520,9 +477,9 @@ func ExtractRowData(rowSelection *goquery.Selection) []string {
 
 func HandleError(context string, err error) {
 	if debug.Debug {
-		fmt.Printf("Error %s: %v\n", context, err)
+		fmt.Fprintf(Messages, "Error %s: %v\n", context, err)
 	} else {
-		fmt.Println("An error occurred. Please try again.")
+		fmt.Fprintln(Messages, "An error occurred. Please try again.")
 	}
 }
 
@@ -569,14 +526,14 @@ func OpenFolder(path string) {
 			} else if _, err := exec.LookPath("gio"); err == nil {
 				cmd = exec.Command("gio", "open", path)
 			} else {
-				fmt.Println("Please open the folder manually:", path)
+				fmt.Fprintln(Messages, "Please open the folder manually:", path)
 				return
 			}
 		}
 	}
 
 	if err := cmd.Start(); err != nil {
-		fmt.Printf("Error opening folder: %v\n", err)
+		fmt.Fprintf(Messages, "Error opening folder: %v\n", err)
 	}
 }
 func ParseFloat(s string) float64 {
@@ -587,4 +544,6 @@ func ParseFloat(s string) float64 {
 	return f
 }
 
-var VtopLoginGlobal func() (types.Cookies, string)
+// VtopLoginGlobal logs in again when a request finds the session expired.
+// Call it only through Relogin.
+var VtopLoginGlobal func(ctx context.Context) (types.Cookies, string)
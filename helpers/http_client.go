@@ -2,22 +2,92 @@ package helpers
 
 import (
 	"crypto/tls"
+	"net"
 	"net/http"
+	"net/url"
 	"time"
 )
 
-var sharedHTTPClient *http.Client
+// Every transport is built by newTransport from these settings, so the
+// proxy, resolver and certificate checks chosen by ConfigureNetwork and
+// ConfigureTLS apply to all outgoing requests.
+var (
+	baseTLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	// vtopTLSConfig adds the pinned keys, which only VTOP is held to.
+	vtopTLSConfig = baseTLSConfig
+	proxyFunc     = http.ProxyFromEnvironment
+	dialer        = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+)
 
-func init() {
-	sharedHTTPClient = &http.Client{
-		Timeout: 60 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
+// vtopTransport is the connection pool behind sharedHTTPClient.
+var vtopTransport = NewVtopTransport()
+
+// sharedHTTPClient has no client-wide timeout: VtopClient and login bound
+// every request with a per-endpoint deadline on its context instead.
+var sharedHTTPClient = &http.Client{
+	Jar:       sessionJar,
+	Transport: wrapVtopTransport(vtopTransport),
 }
 
 // GetHTTPClient returns the shared HTTP client used throughout the application.
 func GetHTTPClient() *http.Client {
 	return sharedHTTPClient
 }
+
+// NewTransport returns a transport for any host, using the configured proxy
+// and resolver.
+func NewTransport() *http.Transport {
+	return newTransport(baseTLSConfig)
+}
+
+// NewVtopTransport is NewTransport with VTOP's certificate pins, for callers
+// that need their own connection pool, such as downloads.
+func NewVtopTransport() *http.Transport {
+	return newTransport(vtopTLSConfig)
+}
+
+// NewHTTPClient returns a client for non-VTOP hosts. A zero timeout means none.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: NewTransport()}
+}
+
+func newTransport(tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy:                 proxyFunc,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig.Clone(),
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// rebuildSharedTransport picks up changed settings in the shared client.
+func rebuildSharedTransport() {
+	old := vtopTransport
+	vtopTransport = NewVtopTransport()
+	sharedHTTPClient.Transport = wrapVtopTransport(vtopTransport)
+	old.CloseIdleConnections()
+}
+
+// wrapVtopTransport puts the rate limiter, and the recorder or replayer when
+// one is active, in front of a transport that talks to VTOP. Replayed
+// requests never reach the limiter.
+func wrapVtopTransport(t http.RoundTripper) http.RoundTripper {
+	t = rateLimited(t)
+	if fixtures == nil {
+		return t
+	}
+	return fixtures.wrap(t)
+}
+
+// ProxyURL returns the proxy a request to rawURL goes through, or nil.
+func ProxyURL(rawURL string) *url.URL {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil
+	}
+	u, _ := proxyFunc(req)
+	return u
+}
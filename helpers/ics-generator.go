@@ -14,7 +14,7 @@ import (
 	"strings"
 	"time"
 
-	"cli-top/types"
+	"github.com/ACM-VIT/CLI-TOP/types"
 )
 
 func GenerateICSFileDateOnly(events []types.ICSEvent, filePath string, calName string) error {
@@ -120,7 +120,7 @@ func UploadICSFile(filePath string, serverURL string) (string, error) {
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{}
+	client := NewHTTPClient(0)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload ICS file: %v", err)
@@ -1,15 +1,21 @@
 package helpers
 
 import (
-	types "cli-top/types"
+	"errors"
 	"fmt"
+
+	types "github.com/ACM-VIT/CLI-TOP/types"
 )
 
+// ErrNotLoggedIn is what code that must not print, such as the feature
+// fetchers, returns where ValidateLogin would print its message.
+var ErrNotLoggedIn = errors.New("please login first using the cli-top login command")
+
 // ValidateLogin checks if the user is logged in by validating the cookies
 // Returns true if the user is logged in, otherwise prints a message and returns false
 func ValidateLogin(cookies types.Cookies) bool {
 	if cookies.CSRF == "" || cookies.JSESSIONID == "" || cookies.SERVERID == "" {
-		fmt.Println("Please login first using the cli-top login command")
+		fmt.Fprintln(Messages, "Please login first using the cli-top login command")
 		return false
 	}
 	return true
@@ -0,0 +1,83 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// NetworkOptions chooses how requests leave the machine.
+type NetworkOptions struct {
+	// Proxy is an http://, https:// or socks5:// URL, optionally with
+	// user:password. Empty means HTTP_PROXY, HTTPS_PROXY and NO_PROXY from
+	// the environment.
+	Proxy string
+	// DNSServer is the host[:port] of a resolver used instead of the
+	// system's, for networks whose DNS cannot resolve VTOP.
+	DNSServer string
+}
+
+// ConfigureNetwork applies opts to every transport built afterwards and to
+// the shared VTOP client.
+func ConfigureNetwork(opts NetworkOptions) error {
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		u, err := parseProxyURL(opts.Proxy)
+		if err != nil {
+			return err
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.DNSServer != "" {
+		server, err := dnsServerAddress(opts.DNSServer)
+		if err != nil {
+			return err
+		}
+		d.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{Timeout: 5 * time.Second}).DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	proxyFunc, dialer = proxy, d
+	rebuildSharedTransport()
+	return nil
+}
+
+// parseProxyURL accepts socks5h:// as an alias of socks5://, since Go always
+// lets a SOCKS5 proxy resolve the target host.
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q, expected a URL such as socks5://127.0.0.1:1080", raw)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "socks5":
+	case "socks5h":
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http, https or socks5", u.Scheme)
+	}
+	return u, nil
+}
+
+func dnsServerAddress(server string) (string, error) {
+	if ip := net.ParseIP(server); ip != nil {
+		return net.JoinHostPort(server, "53"), nil
+	}
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server, nil
+	}
+	if strings.Contains(server, ":") {
+		return "", fmt.Errorf("invalid DNS server %q, expected host or host:port", server)
+	}
+	return net.JoinHostPort(server, "53"), nil
+}
@@ -0,0 +1,337 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat is how data commands print their results, chosen with
+// --output.
+type OutputFormat string
+
+const (
+	FormatTable OutputFormat = "table"
+	FormatJSON  OutputFormat = "json"
+	FormatYAML  OutputFormat = "yaml"
+	FormatCSV   OutputFormat = "csv"
+	FormatTSV   OutputFormat = "tsv"
+)
+
+// Output is the format in use; anything but FormatTable is machine-readable.
+var Output = FormatTable
+
+// dataOut is where Render writes.
+var dataOut io.Writer = os.Stdout
+
+// Messages is where prompts, progress and everything else that is not a
+// command's results are written. It is stdout until SetOutput picks a
+// machine-readable format, and stderr after.
+var Messages io.Writer = os.Stdout
+
+// ParseOutputFormat accepts the values of --output.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatTable, nil
+	case "yml":
+		return FormatYAML, nil
+	case FormatTable, FormatJSON, FormatYAML, FormatCSV, FormatTSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (use table, json, yaml, csv or tsv)", s)
+}
+
+// SetOutput selects the output format. For machine-readable formats, prompts,
+// progress bars and messages are sent to stderr, so stdout carries nothing
+// but the data and can be piped to jq or saved as a spreadsheet.
+func SetOutput(f OutputFormat) {
+	Output = f
+	if f == FormatTable {
+		return
+	}
+	Messages = os.Stderr
+	debug.Output = os.Stderr
+	color.Output = colorable.NewColorableStderr()
+}
+
+// Structured reports whether results are printed in a machine-readable
+// format rather than as a table.
+func Structured() bool {
+	return Output != FormatTable
+}
+
+// Render prints v, usually a slice of structs from package types, in the
+// chosen format. JSON and YAML keep v's shape and json field names; CSV and
+// TSV get one row per element, with a nested list expanded into one row per
+// entry.
+func Render(v any) error {
+	return Encode(dataOut, Output, v)
+}
+
+// Encode writes v to w in format f. FormatTable is not handled here.
+func Encode(w io.Writer, f OutputFormat, v any) error {
+	// An empty result is printed as [] rather than null.
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		v = reflect.MakeSlice(rv.Type(), 0, 0).Interface()
+	}
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(v)
+	case FormatYAML:
+		// Going through JSON keeps the json tags and field order.
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return err
+		}
+		blockStyle(&node)
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatCSV, FormatTSV:
+		header, rows := tabulate(v)
+		cw := csv.NewWriter(w)
+		if f == FormatTSV {
+			cw.Comma = '\t'
+		}
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	return fmt.Errorf("cannot encode as %s", f)
+}
+
+// blockStyle undoes the flow style YAML gives a document parsed from JSON.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Records is a printed table turned into data: one record per row, keyed by
+// the column headers. PrintTable renders it for features that have no typed
+// result yet.
+type Records struct {
+	Keys []string
+	Rows [][]string
+}
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// TableRecords converts a PrintTable table, header row first, into Records
+// with snake_case keys and colour codes removed.
+func TableRecords(table [][]string) Records {
+	var records Records
+	if len(table) == 0 {
+		return records
+	}
+	for _, h := range table[0] {
+		key := strings.Trim(nonWord.ReplaceAllString(strings.ToLower(StripAnsiCodes(h)), "_"), "_")
+		records.Keys = append(records.Keys, key)
+	}
+	for _, row := range table[1:] {
+		values := make([]string, len(records.Keys))
+		for i := range values {
+			if i < len(row) {
+				values[i] = strings.TrimSpace(StripAnsiCodes(row[i]))
+			}
+		}
+		records.Rows = append(records.Rows, values)
+	}
+	return records
+}
+
+// MarshalJSON writes the records as an array of objects, keeping the column
+// order.
+func (r Records) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, row := range r.Rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for j, key := range r.Keys {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			v, _ := json.Marshal(row[j])
+			buf.Write(k)
+			buf.WriteByte(':')
+			buf.Write(v)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// tabulate lays v out as CSV rows. A struct or slice of structs gets a
+// column per field; the first field holding a list of structs is expanded
+// into one row per entry, with its columns prefixed by the field name, and
+// any other nested value is written as JSON.
+func tabulate(v any) ([]string, [][]string) {
+	if records, ok := v.(Records); ok {
+		return records.Keys, records.Rows
+	}
+
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Pointer && !val.IsNil() {
+		val = val.Elem()
+	}
+	var items []reflect.Value
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			// Nil entries have nothing to show.
+			if item := reflect.Indirect(val.Index(i)); item.IsValid() {
+				items = append(items, item)
+			}
+		}
+	case reflect.Pointer:
+		// A nil pointer is an empty table, header only.
+	default:
+		items = []reflect.Value{val}
+	}
+
+	elem := val.Type()
+	if val.Kind() == reflect.Slice || val.Kind() == reflect.Array {
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct || elem == reflect.TypeOf(time.Time{}) {
+		rows := make([][]string, len(items))
+		for i, item := range items {
+			rows[i] = []string{cell(item)}
+		}
+		return []string{"value"}, rows
+	}
+
+	fields, nested := columns(elem)
+	header := fieldNames(fields, "")
+	var nestedFields []field
+	if nested != nil {
+		nestedFields, _ = columns(nested.typ.Elem())
+		header = append(header, fieldNames(nestedFields, nested.name+".")...)
+	}
+
+	var rows [][]string
+	for _, item := range items {
+		row := fieldValues(item, fields)
+		if nested == nil {
+			rows = append(rows, row)
+			continue
+		}
+		list := item.Field(nested.index)
+		if list.Len() == 0 {
+			rows = append(rows, append(row, make([]string, len(nestedFields))...))
+			continue
+		}
+		for i := 0; i < list.Len(); i++ {
+			rows = append(rows, append(append([]string{}, row...), fieldValues(list.Index(i), nestedFields)...))
+		}
+	}
+	return header, rows
+}
+
+type field struct {
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+// columns lists the exported fields of t by json name, and separately the
+// first one that is a list of structs.
+func columns(t reflect.Type) (fields []field, nested *field) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := sf.Name
+		if tag := sf.Tag.Get("json"); tag != "" {
+			if tag = strings.Split(tag, ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+		}
+		f := field{name: name, index: i, typ: sf.Type}
+		if nested == nil && sf.Type.Kind() == reflect.Slice && sf.Type.Elem().Kind() == reflect.Struct && sf.Type.Elem() != reflect.TypeOf(time.Time{}) {
+			nested = &f
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields, nested
+}
+
+func fieldNames(fields []field, prefix string) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = prefix + f.name
+	}
+	return names
+}
+
+func fieldValues(v reflect.Value, fields []field) []string {
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		values[i] = cell(v.Field(f.index))
+	}
+	return values
+}
+
+// cell formats one value for CSV; times use RFC 3339 and the zero time is
+// left empty.
+func cell(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return StripAnsiCodes(v.String())
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return cell(v.Elem())
+	}
+	data, _ := json.Marshal(v.Interface())
+	return string(data)
+}
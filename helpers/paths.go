@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const appDirName = "cli-top"
+
+// ConfigDir returns the directory holding cli-top configuration,
+// $XDG_CONFIG_HOME/cli-top or the platform equivalent.
+func ConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, appDirName)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, appDirName)
+	}
+	return "."
+}
+
+// StateDir returns the directory for session state such as cookies,
+// $XDG_STATE_HOME/cli-top or ~/.local/state/cli-top on Unix systems.
+func StateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, appDirName)
+	}
+	return xdgFallback(filepath.Join(".local", "state"), "state")
+}
+
+// DataDir returns the directory for persistent data such as the credential
+// vault, $XDG_DATA_HOME/cli-top or ~/.local/share/cli-top on Unix systems.
+func DataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, appDirName)
+	}
+	return xdgFallback(filepath.Join(".local", "share"), "data")
+}
+
+// xdgFallback resolves the XDG default under the home directory on Unix and
+// a subdirectory of the config directory on Windows and macOS.
+func xdgFallback(unixDir string, subDir string) string {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return filepath.Join(ConfigDir(), subDir)
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(ConfigDir(), subDir)
+	}
+	return filepath.Join(homeDir, unixDir, appDirName)
+}
@@ -1,45 +1,155 @@
 package helpers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
 )
 
-const MaxDownloads = 100
-const RateLimitWindow = 10 * time.Minute
+// RateLimiter is a token bucket shared by every request to VTOP. Rate tokens
+// are added per second up to Burst, and each request takes one, waiting for
+// it when the bucket is empty. With Path set the bucket is kept on disk, so
+// back-to-back invocations from a script draw from the same bucket.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+	Path  string
 
-var (
 	mu       sync.Mutex
-	tsBuffer [MaxDownloads]time.Time
-	start    int
-	count    int
-)
+	fullAt   time.Time
+	noticeAt time.Time
+}
 
-func IsRateLimitExceeded() bool {
-	mu.Lock()
-	defer mu.Unlock()
+// Limiter is applied to the VTOP transport; cmd sets it from --rate-limit,
+// --rate-burst and the state directory.
+var Limiter = &RateLimiter{Rate: 3, Burst: 20}
 
-	now := time.Now()
+// rateLimitState is the bucket as saved between invocations: the time at
+// which it will be full again.
+type rateLimitState struct {
+	FullAt time.Time `json:"fullAt"`
+}
 
-	// drop all timestamps outside the rate limit window
-	for count > 0 {
-		oldest := tsBuffer[start]
-		if now.Sub(oldest) <= RateLimitWindow {
-			break
-		}
-		start = (start + 1) % MaxDownloads
-		count--
+// Wait takes a token, blocking until one is available or ctx is done. A
+// Rate of zero or less disables the limit.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	wait := l.reserve(time.Now())
+	if wait <= 0 {
+		return nil
+	}
+	l.notify(wait)
+	return Sleep(ctx, wait)
+}
+
+// reserve takes the next token and returns how long until it is due.
+func (l *RateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Rate <= 0 {
+		return 0
+	}
+
+	if l.Path != "" {
+		defer lockFile(l.Path + ".lock")()
+	}
+
+	fullAt := l.load()
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+	interval := time.Duration(float64(time.Second) / l.Rate)
+	// The bucket holds Burst tokens, so a request may go ahead while it is
+	// refilling for no more than Burst-1 intervals.
+	wait := fullAt.Sub(now) - time.Duration(max(l.Burst, 1)-1)*interval
+	l.store(fullAt.Add(interval))
+	return wait
+}
+
+func (l *RateLimiter) load() time.Time {
+	if l.Path == "" {
+		return l.fullAt
 	}
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return l.fullAt
+	}
+	var state rateLimitState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return l.fullAt
+	}
+	return state.FullAt
+}
+
+func (l *RateLimiter) store(fullAt time.Time) {
+	l.fullAt = fullAt
+	if l.Path == "" {
+		return
+	}
+	data, _ := json.Marshal(rateLimitState{FullAt: fullAt})
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0o700); err != nil {
+		debug.Log("Could not save rate limit state: " + err.Error())
+		return
+	}
+	tmp := l.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		debug.Log("Could not save rate limit state: " + err.Error())
+		return
+	}
+	os.Rename(tmp, l.Path)
+}
 
-	if count >= MaxDownloads {
-		return true
+// notify prints the wait once rather than for every request queued behind it.
+func (l *RateLimiter) notify(wait time.Duration) {
+	if wait < time.Second {
+		return
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Before(l.noticeAt) {
+		return
+	}
+	l.noticeAt = now.Add(wait)
+	fmt.Fprintf(os.Stderr, "Waiting for rate limit (%s)...\n", wait.Round(time.Second))
+}
 
-	insertIdx := (start + count) % MaxDownloads
-	tsBuffer[insertIdx] = now
-	if count < MaxDownloads {
-		count++
+// lockFile keeps other cli-top processes out of the bucket while it is
+// updated. A lock left behind by a crashed process is taken over after a few
+// seconds, and if the lock cannot be had the update goes ahead anyway: an
+// occasional extra request is better than hanging.
+func lockFile(path string) (unlock func()) {
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > 5*time.Second {
+			os.Remove(path)
+			continue
+		}
+		if !os.IsExist(err) || time.Now().After(deadline) {
+			return func() {}
+		}
+		time.Sleep(10 * time.Millisecond)
 	}
+}
 
-	return false
+// rateLimited makes every request through t wait for the limiter first.
+func rateLimited(t http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if err := Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		return t.RoundTrip(req)
+	})
 }
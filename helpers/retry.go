@@ -0,0 +1,94 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+)
+
+// RetryPolicy decides how often a failed VTOP request is sent again.
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first.
+	Attempts int
+	// BaseDelay is the wait before the first retry; it doubles each time up
+	// to MaxDelay, and a random part of it is dropped so that parallel
+	// requests do not retry in step.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Retry is the policy applied by VtopClient, set from --retries and
+// --retry-delay by cmd.
+var Retry = RetryPolicy{Attempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 8 * time.Second}
+
+// Backoff returns the wait before retry number n, counting from 1.
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// transient reports whether resp is an outage that may clear up by itself.
+func transient(resp *VtopResponse) bool {
+	err := ClassifyResponse(resp)
+	return errors.Is(err, ErrMaintenance) || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrEmptyResponse)
+}
+
+// neverSent reports whether err happened before the request reached VTOP,
+// so even a request that changes something can safely be sent again.
+func neverSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// withRetry calls send until it succeeds, fails for good, or the policy runs
+// out. Requests that change state on VTOP are only repeated when they
+// certainly never arrived; reads are also repeated after connection resets,
+// timeouts, and responses ClassifyResponse sees as a passing outage.
+func withRetry(ctx context.Context, p RetryPolicy, url string, idempotent bool, send func() (*VtopResponse, error)) (*VtopResponse, error) {
+	attempts := max(p.Attempts, 1)
+	for attempt := 1; ; attempt++ {
+		resp, err := send()
+
+		var reason string
+		switch {
+		case err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, ErrNotRecorded) || IsCertificateError(err)):
+			return nil, err
+		case err != nil && (idempotent || neverSent(err)):
+			reason = err.Error()
+		case err != nil:
+			return nil, err
+		case idempotent && transient(resp):
+			err = ClassifyResponse(resp)
+			reason = err.Error()
+		default:
+			return resp, nil
+		}
+
+		if attempt >= attempts {
+			return nil, err
+		}
+		delay := p.Backoff(attempt)
+		debug.Log(fmt.Sprintf("%s: %s, retrying in %s (attempt %d of %d)", url, reason, delay.Round(time.Millisecond), attempt+1, attempts))
+		if err := Sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
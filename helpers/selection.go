@@ -1,14 +1,14 @@
 package helpers
 
 import (
-	"cli-top/debug"
-	"cli-top/types"
 	"fmt"
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/types"
+	// "github.com/olekukonko/tablewriter"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
-	// "github.com/olekukonko/tablewriter"
 )
 
 func RemoveCourseCode(courseName string) string {
@@ -288,12 +288,12 @@ func SortFacultiesAlphabetically(faculties []types.FacultyOld) {
 
 func SelectCourseMaterials(materials []types.CourseMaterial) ([]types.CourseMaterial, error) {
 	for {
-		fmt.Print("Enter the index numbers of the topics to download (e.g., 1,2,3), or 0 for bulk download: ")
+		fmt.Fprint(Messages, "Enter the index numbers of the topics to download (e.g., 1,2,3), or 0 for bulk download: ")
 		var input string
 		_, err := fmt.Scanln(&input)
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error reading input:", err)
+				fmt.Fprintln(Messages, "Error reading input:", err)
 			}
 			return nil, err
 		}
@@ -301,7 +301,7 @@ func SelectCourseMaterials(materials []types.CourseMaterial) ([]types.CourseMate
 		input = strings.TrimSpace(input)
 
 		if input == "" {
-			fmt.Println("No input provided.")
+			fmt.Fprintln(Messages, "No input provided.")
 			continue
 		}
 
@@ -327,11 +327,11 @@ func SelectCourseMaterials(materials []types.CourseMaterial) ([]types.CourseMate
 		}
 
 		if len(invalidIndices) > 0 {
-			fmt.Println("Invalid indices:", strings.Join(invalidIndices, ", "))
+			fmt.Fprintln(Messages, "Invalid indices:", strings.Join(invalidIndices, ", "))
 		}
 
 		if len(indexSet) == 0 {
-			fmt.Println("No valid indices selected.")
+			fmt.Fprintln(Messages, "No valid indices selected.")
 			continue
 		}
 
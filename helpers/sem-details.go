@@ -3,13 +3,14 @@ package helpers
 import (
 	"bufio"
 	"bytes"
-	"cli-top/debug"
-	"cli-top/types"
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/PuerkitoBio/goquery"
 )
 
@@ -48,9 +49,9 @@ func FindAndSaveSemIds(doc *goquery.Document) ([]types.Semester, error) {
 	if len(allsems) == 0 {
 		// If debug is enabled, output the HTML to help diagnose the issue
 		if debug.Debug {
-			fmt.Println("Document structure:")
+			fmt.Fprintln(Messages, "Document structure:")
 			html, _ := doc.Html()
-			fmt.Println(html)
+			fmt.Fprintln(Messages, html)
 		}
 		return nil, fmt.Errorf("no semesters found")
 	}
@@ -58,16 +59,16 @@ func FindAndSaveSemIds(doc *goquery.Document) ([]types.Semester, error) {
 }
 
 // GetSemDetails fetches semester details
-func GetSemDetails(cookies types.Cookies, regNo string) ([]types.Semester, error) {
+func GetSemDetails(ctx context.Context, cookies types.Cookies, regNo string) ([]types.Semester, error) {
 	if cookies.CSRF == "" || cookies.JSESSIONID == "" || cookies.SERVERID == "" {
 		return nil, fmt.Errorf("please login first using the cli-top login command")
 	}
 	url := "https://vtop.vit.ac.in/vtop/academics/common/StudentAttendance"
 	var allSems []types.Semester
-	bodyText, err := FetchReq(regNo, cookies, url, "", "", "POST", "")
+	bodyText, err := Vtop.Post(ctx, url, MenuForm(regNo, cookies))
 	if err != nil {
 		if debug.Debug {
-			fmt.Println("Error fetching semester details", err)
+			fmt.Fprintln(Messages, "Error fetching semester details", err)
 		}
 		return allSems, err
 	}
@@ -75,14 +76,14 @@ func GetSemDetails(cookies types.Cookies, regNo string) ([]types.Semester, error
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(bodyText))
 	if err != nil {
 		if debug.Debug {
-			fmt.Println("Error parsing the HTML document:", err)
+			fmt.Fprintln(Messages, "Error parsing the HTML document:", err)
 		}
 		return allSems, err
 	}
 	allSems, err = FindAndSaveSemIds(doc)
 	if err != nil {
 		if debug.Debug {
-			fmt.Println("Error fetching semester details", err)
+			fmt.Fprintln(Messages, "Error fetching semester details", err)
 		}
 		return allSems, err
 	}
@@ -90,10 +91,10 @@ func GetSemDetails(cookies types.Cookies, regNo string) ([]types.Semester, error
 	return allSems, nil
 }
 
-func GetSemDetailsBackup(cookies types.Cookies, regNo string) ([]types.Semester, error) {
+func GetSemDetailsBackup(ctx context.Context, cookies types.Cookies, regNo string) ([]types.Semester, error) {
 	url := "https://vtop.vit.ac.in/vtop/academics/common/StudentCoursePage"
 	var allSems []types.Semester
-	bodyText, err := FetchReq(regNo, cookies, url, "", "", "POST", "")
+	bodyText, err := Vtop.Post(ctx, url, MenuForm(regNo, cookies))
 	if err != nil {
 		return allSems, err
 	}
@@ -109,25 +110,29 @@ func GetSemDetailsBackup(cookies types.Cookies, regNo string) ([]types.Semester,
 	return allSems, nil
 }
 
-func SelectSemester(regNo string, cookies types.Cookies, sem_choice int) (types.Semester, error) {
-	semDetails, err := GetSemDetails(cookies, regNo)
+func SelectSemester(ctx context.Context, regNo string, cookies types.Cookies, sem_choice int) (types.Semester, error) {
+	semDetails, err := GetSemDetails(ctx, cookies, regNo)
 	var selectedSem types.Semester
 	if err != nil {
 		if debug.Debug {
-			fmt.Println("Error featching sem details:", err)
+			fmt.Fprintln(Messages, "Error featching sem details:", err)
 		}
-		semDetails, err = GetSemDetailsBackup(cookies, regNo)
+		semDetails, err = GetSemDetailsBackup(ctx, cookies, regNo)
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error fetching semester details in backup", err)
+				fmt.Fprintln(Messages, "Error fetching semester details in backup", err)
 			}
 			return selectedSem, err
 		}
 	}
+	return ChooseSemester(semDetails, sem_choice)
+}
+
+// ChooseSemester asks which of semDetails, listed oldest first, to use, or
+// takes number sem_choice when it is set.
+func ChooseSemester(semDetails []types.Semester, sem_choice int) (types.Semester, error) {
+	var selectedSem types.Semester
 	if len(semDetails) == 0 {
-		if debug.Debug {
-			fmt.Println("Error fetching semester details", err)
-		}
 		return selectedSem, fmt.Errorf("error fetching semester details or no semesters available. Try logging out and logging back in")
 	}
 
@@ -160,7 +165,7 @@ func clearInputBuffer() error {
 		b, err := reader.ReadByte()
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error clearing input buffer:", err)
+				fmt.Fprintln(Messages, "Error clearing input buffer:", err)
 			}
 			return err
 		}
@@ -39,7 +39,7 @@ func TableSelector(subject string, nestedList [][]string, initialQuery string) S
 	if isNumeric(initialQuery) {
 		choice, _ := strconv.Atoi(initialQuery)
 		if choice >= 1 && choice <= len(nestedList)-1 {
-			fmt.Printf("\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[choice][0])
+			fmt.Fprintf(Messages, "\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[choice][0])
 			return SelectionResult{Index: choice, Selected: true}
 		}
 	} else if initialQuery == "exit" {
@@ -49,12 +49,12 @@ func TableSelector(subject string, nestedList [][]string, initialQuery string) S
 	reader := bufio.NewReader(os.Stdin)
 
 	// Initial display of the table
-	fmt.Println("")
-	PrintTable(nestedList, 1)
-	fmt.Println("")
+	fmt.Fprintln(Messages, "")
+	DrawTable(nestedList, 1)
+	fmt.Fprintln(Messages, "")
 
 	for {
-		fmt.Printf("Choose a %s (enter a number): ", subject)
+		fmt.Fprintf(Messages, "Choose a %s (enter a number): ", subject)
 		input, _ := reader.ReadString('\n')
 		choice := strings.TrimSpace(input)
 
@@ -65,24 +65,24 @@ func TableSelector(subject string, nestedList [][]string, initialQuery string) S
 		// Check if input is a number
 		choiceNum, err := strconv.Atoi(choice)
 		if err != nil {
-			fmt.Printf("Invalid input. Please enter a number between 1 and %d.\n", len(nestedList)-1)
+			fmt.Fprintf(Messages, "Invalid input. Please enter a number between 1 and %d.\n", len(nestedList)-1)
 			// Reprint the table after invalid input
-			fmt.Println("")
-			PrintTable(nestedList, 1)
-			fmt.Println("")
+			fmt.Fprintln(Messages, "")
+			DrawTable(nestedList, 1)
+			fmt.Fprintln(Messages, "")
 			continue
 		}
 
 		// Validate the choice
 		if choiceNum >= 1 && choiceNum <= len(nestedList)-1 {
-			fmt.Printf("\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[choiceNum][0])
+			fmt.Fprintf(Messages, "\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[choiceNum][0])
 			return SelectionResult{Index: choiceNum, Selected: true}
 		} else {
-			fmt.Printf("Invalid choice. Please enter a number between 1 and %d.\n", len(nestedList)-1)
+			fmt.Fprintf(Messages, "Invalid choice. Please enter a number between 1 and %d.\n", len(nestedList)-1)
 			// Reprint the table after invalid input
-			fmt.Println("")
-			PrintTable(nestedList, 1)
-			fmt.Println("")
+			fmt.Fprintln(Messages, "")
+			DrawTable(nestedList, 1)
+			fmt.Fprintln(Messages, "")
 		}
 	}
 }
@@ -93,11 +93,11 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 	if isNumeric(initialQuery) {
 		choice, _ := strconv.Atoi(initialQuery)
 		if choice >= 1 && choice <= len(nestedList)-1 {
-			fmt.Printf("\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[choice][0])
+			fmt.Fprintf(Messages, "\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[choice][0])
 			return SelectionResult{Index: choice, Selected: true}
 		} else {
 			// Invalid numeric initial query, will display table and prompt for new input
-			fmt.Printf("Invalid number. Please enter a number between 1 and %d.\n", len(nestedList)-1)
+			fmt.Fprintf(Messages, "Invalid number. Please enter a number between 1 and %d.\n", len(nestedList)-1)
 			initialQuery = ""
 		}
 	} else if initialQuery == "exit" {
@@ -110,12 +110,12 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 	// If no initial query provided or if initial query was an invalid number, prompt for input
 	if searchQuery == "" {
 		if subject == "Course" {
-			fmt.Printf("Enter the course name to download the syllabus (or 'exit' to quit): ")
+			fmt.Fprintf(Messages, "Enter the course name to download the syllabus (or 'exit' to quit): ")
 		} else {
-			fmt.Println("")
-			PrintTable(nestedList, 1)
-			fmt.Println("")
-			fmt.Printf("Enter a search term or number for %s (or 'exit' to quit): ", subject)
+			fmt.Fprintln(Messages, "")
+			DrawTable(nestedList, 1)
+			fmt.Fprintln(Messages, "")
+			fmt.Fprintf(Messages, "Enter a search term or number for %s (or 'exit' to quit): ", subject)
 		}
 		input, _ := reader.ReadString('\n')
 		searchQuery = strings.TrimSpace(input)
@@ -129,15 +129,15 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 		// Check if query is a direct number selection
 		if num, err := strconv.Atoi(searchQuery); err == nil {
 			if num >= 1 && num <= len(nestedList)-1 {
-				fmt.Printf("\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[num][0])
+				fmt.Fprintf(Messages, "\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[num][0])
 				return SelectionResult{Index: num, Selected: true}
 			} else {
-				fmt.Printf("Invalid number. Please enter a number between 1 and %d.\n", len(nestedList)-1)
+				fmt.Fprintf(Messages, "Invalid number. Please enter a number between 1 and %d.\n", len(nestedList)-1)
 				// Reprint the table after invalid input
-				fmt.Println("")
-				PrintTable(nestedList, 1)
-				fmt.Println("")
-				fmt.Printf("Enter a search term or number for %s (or 'exit' to quit): ", subject)
+				fmt.Fprintln(Messages, "")
+				DrawTable(nestedList, 1)
+				fmt.Fprintln(Messages, "")
+				fmt.Fprintf(Messages, "Enter a search term or number for %s (or 'exit' to quit): ", subject)
 				input, _ := reader.ReadString('\n')
 				searchQuery = strings.TrimSpace(input)
 				if searchQuery == "exit" {
@@ -157,12 +157,12 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 		}
 
 		if len(selectedIndices) == 0 {
-			fmt.Println("No matching results found for your query.")
+			fmt.Fprintln(Messages, "No matching results found for your query.")
 			// Reprint the table after no results found
-			fmt.Println("")
-			PrintTable(nestedList, 1)
-			fmt.Println("")
-			fmt.Printf("Enter a new search term or number for %s (or 'exit' to quit): ", subject)
+			fmt.Fprintln(Messages, "")
+			DrawTable(nestedList, 1)
+			fmt.Fprintln(Messages, "")
+			fmt.Fprintf(Messages, "Enter a new search term or number for %s (or 'exit' to quit): ", subject)
 			input, _ := reader.ReadString('\n')
 			searchQuery = strings.TrimSpace(input)
 			if searchQuery == "exit" {
@@ -172,12 +172,12 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 		} else if len(selectedIndices) == 1 {
 			index := selectedIndices[0]
 			if index < 1 || index > len(nestedList)-1 {
-				fmt.Println("Selected index is out of range.")
+				fmt.Fprintln(Messages, "Selected index is out of range.")
 				// Reprint the table after invalid selection
-				fmt.Println("")
-				PrintTable(nestedList, 1)
-				fmt.Println("")
-				fmt.Printf("Enter a new search term or number for %s (or 'exit' to quit): ", subject)
+				fmt.Fprintln(Messages, "")
+				DrawTable(nestedList, 1)
+				fmt.Fprintln(Messages, "")
+				fmt.Fprintf(Messages, "Enter a new search term or number for %s (or 'exit' to quit): ", subject)
 				input, _ := reader.ReadString('\n')
 				searchQuery = strings.TrimSpace(input)
 				if searchQuery == "exit" {
@@ -185,7 +185,7 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 				}
 				continue
 			}
-			fmt.Printf("\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[index][0])
+			fmt.Fprintf(Messages, "\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[index][0])
 			return SelectionResult{Index: index, Selected: true}
 		} else {
 			// Multiple matches found, create a filtered list
@@ -199,12 +199,12 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 			}
 
 			if len(filteredList) <= 1 {
-				fmt.Println("No valid results found in the matched items.")
+				fmt.Fprintln(Messages, "No valid results found in the matched items.")
 				// Reprint the table after no valid results
-				fmt.Println("")
-				PrintTable(nestedList, 1)
-				fmt.Println("")
-				fmt.Printf("Enter a new search term or number for %s (or 'exit' to quit): ", subject)
+				fmt.Fprintln(Messages, "")
+				DrawTable(nestedList, 1)
+				fmt.Fprintln(Messages, "")
+				fmt.Fprintf(Messages, "Enter a new search term or number for %s (or 'exit' to quit): ", subject)
 				input, _ := reader.ReadString('\n')
 				searchQuery = strings.TrimSpace(input)
 				if searchQuery == "exit" {
@@ -213,14 +213,14 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 				continue
 			}
 
-			fmt.Println("\nMultiple matches found. Please select from the results below:")
-			fmt.Println("")
-			PrintTable(filteredList, 1)
-			fmt.Println("")
+			fmt.Fprintln(Messages, "\nMultiple matches found. Please select from the results below:")
+			fmt.Fprintln(Messages, "")
+			DrawTable(filteredList, 1)
+			fmt.Fprintln(Messages, "")
 
 			// Loop until valid selection from filtered results
 			for {
-				fmt.Printf("Choose a %s (number) or type 'search' for a new search: ", subject)
+				fmt.Fprintf(Messages, "Choose a %s (number) or type 'search' for a new search: ", subject)
 				input, _ := reader.ReadString('\n')
 				input = strings.TrimSpace(input)
 
@@ -229,10 +229,10 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 				}
 
 				if input == "search" {
-					fmt.Println()
-					PrintTable(nestedList, 1)
-					fmt.Println()
-					fmt.Printf("Enter a new search term or number for %s (or 'exit' to quit): ", subject)
+					fmt.Fprintln(Messages)
+					DrawTable(nestedList, 1)
+					fmt.Fprintln(Messages)
+					fmt.Fprintf(Messages, "Enter a new search term or number for %s (or 'exit' to quit): ", subject)
 					input, _ := reader.ReadString('\n')
 					searchQuery = strings.TrimSpace(input)
 					if searchQuery == "exit" {
@@ -243,35 +243,50 @@ func TableSelectorFuzzy(subject string, nestedList [][]string, initialQuery stri
 
 				choice, err := strconv.Atoi(input)
 				if err != nil {
-					fmt.Println("Invalid input. Please enter a valid number.")
+					fmt.Fprintln(Messages, "Invalid input. Please enter a valid number.")
 					// Reprint the filtered table after invalid input
-					fmt.Println("")
-					PrintTable(filteredList, 1)
-					fmt.Println("")
+					fmt.Fprintln(Messages, "")
+					DrawTable(filteredList, 1)
+					fmt.Fprintln(Messages, "")
 					continue
 				}
 
 				if choice < 1 || choice > len(filteredList)-1 {
-					fmt.Printf("Invalid choice. Please enter a number between 1 and %d.\n", len(filteredList)-1)
+					fmt.Fprintf(Messages, "Invalid choice. Please enter a number between 1 and %d.\n", len(filteredList)-1)
 					// Reprint the filtered table after invalid choice
-					fmt.Println("")
-					PrintTable(filteredList, 1)
-					fmt.Println("")
+					fmt.Fprintln(Messages, "")
+					DrawTable(filteredList, 1)
+					fmt.Fprintln(Messages, "")
 					continue
 				}
 
 				// Map the selection back to the original index directly from our mapping
 				originalIndex := filteredIndices[choice-1]
-				fmt.Printf("\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[originalIndex][0])
+				fmt.Fprintf(Messages, "\n    \033[1;44m Your selected %s: %s \033[0m\n\n", subject, nestedList[originalIndex][0])
 				return SelectionResult{Index: originalIndex, Selected: true}
 			}
 		}
 	}
 }
 
+// PrintTable prints a command's results. Under --output json, yaml, csv or
+// tsv the rows are rendered as records keyed by the headers instead.
 func PrintTable(nestedList [][]string, indexStatus int) int {
+	if Structured() {
+		if err := Render(TableRecords(nestedList)); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing output:", err)
+			return 1
+		}
+		return 0
+	}
+	return DrawTable(nestedList, indexStatus)
+}
+
+// DrawTable always draws nestedList as a table, for lists the user picks
+// from rather than results.
+func DrawTable(nestedList [][]string, indexStatus int) int {
 	if len(nestedList) == 0 {
-		fmt.Println("Ummm are you sure you are printing the right thing?")
+		fmt.Fprintln(Messages, "Ummm are you sure you are printing the right thing?")
 		return 1
 	}
 
@@ -332,25 +347,25 @@ func PrintTable(nestedList [][]string, indexStatus int) int {
 	}
 
 	// Print header
-	fmt.Print("   ") // Leading spaces
+	fmt.Fprint(Messages, "   ") // Leading spaces
 	for colIdx, headerCell := range normalizedList[0] {
 		aligned := leftAlign(headerCell, colWidths[colIdx])
-		fmt.Print(" ", aligned, " ")
+		fmt.Fprint(Messages, " ", aligned, " ")
 		if colIdx < len(normalizedList[0])-1 {
-			fmt.Print("│")
+			fmt.Fprint(Messages, "│")
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(Messages)
 
 	// Print separator
-	fmt.Print("    ") // Leading spaces
+	fmt.Fprint(Messages, "    ") // Leading spaces
 	for colIdx, width := range colWidths {
-		fmt.Print(strings.Repeat("─", width))
+		fmt.Fprint(Messages, strings.Repeat("─", width))
 		if colIdx < len(colWidths)-1 {
-			fmt.Print("─┼─")
+			fmt.Fprint(Messages, "─┼─")
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(Messages)
 
 	// Print data rows
 	for _, row := range normalizedList[1:] {
@@ -367,7 +382,7 @@ func PrintTable(nestedList [][]string, indexStatus int) int {
 
 		// Print each line of the row
 		for lineIdx := 0; lineIdx < maxLines; lineIdx++ {
-			fmt.Print("   ") // Leading spaces
+			fmt.Fprint(Messages, "   ") // Leading spaces
 			for colIdx, cellLines := range rowLines {
 				line := ""
 				if lineIdx < len(cellLines) {
@@ -381,12 +396,12 @@ func PrintTable(nestedList [][]string, indexStatus int) int {
 					aligned = leftAlign(line, colWidths[colIdx])
 				}
 
-				fmt.Print(" ", aligned, " ")
+				fmt.Fprint(Messages, " ", aligned, " ")
 				if colIdx < len(row)-1 {
-					fmt.Print("│")
+					fmt.Fprint(Messages, "│")
 				}
 			}
-			fmt.Println()
+			fmt.Fprintln(Messages)
 		}
 	}
 	return 0
@@ -0,0 +1,166 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	GraphicsNone  = "none"
+	GraphicsKitty = "kitty"
+	GraphicsSixel = "sixel"
+)
+
+// TerminalGraphics guesses which inline image protocol the terminal
+// understands. CLI_TOP_GRAPHICS (kitty, sixel or none) overrides the guess.
+func TerminalGraphics() string {
+	switch forced := strings.ToLower(os.Getenv("CLI_TOP_GRAPHICS")); forced {
+	case GraphicsKitty, GraphicsSixel, GraphicsNone:
+		return forced
+	}
+
+	termName := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", termName == "xterm-kitty",
+		termName == "xterm-ghostty", termProgram == "ghostty", termProgram == "WezTerm":
+		return GraphicsKitty
+	case strings.Contains(termName, "sixel"), termName == "foot", strings.HasPrefix(termName, "mlterm"),
+		termName == "yaft-256color", termProgram == "iTerm.app", termProgram == "mintty":
+		return GraphicsSixel
+	}
+	return GraphicsNone
+}
+
+// RenderImage writes img to w using the given inline graphics protocol.
+func RenderImage(w io.Writer, img image.Image, protocol string) error {
+	switch protocol {
+	case GraphicsKitty:
+		return renderKitty(w, img)
+	case GraphicsSixel:
+		return renderSixel(w, scaleImage(img, 2))
+	}
+	return fmt.Errorf("unsupported graphics protocol %q", protocol)
+}
+
+// renderKitty sends img as PNG through the kitty graphics protocol, which
+// limits each escape sequence to 4096 bytes of payload.
+func renderKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	payload := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	out := bufio.NewWriter(w)
+	for first := true; len(payload) > 0; first = false {
+		chunk := payload
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		payload = payload[len(chunk):]
+
+		more := 0
+		if len(payload) > 0 {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(out, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(out, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return out.Flush()
+}
+
+// renderSixel encodes img as sixels using a fixed 3-3-2 RGB palette.
+func renderSixel(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	indexed := make([]uint8, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			indexed[y*width+x] = uint8(r>>13)<<5 | uint8(g>>13)<<2 | uint8(b>>14)
+		}
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "\x1bPq\"1;1;%d;%d", width, height)
+	for c := 0; c < 256; c++ {
+		r := (c >> 5) * 100 / 7
+		g := ((c >> 2) & 7) * 100 / 7
+		b := (c & 3) * 100 / 3
+		fmt.Fprintf(out, "#%d;2;%d;%d;%d", c, r, g, b)
+	}
+
+	row := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		var used [256]bool
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[indexed[y*width+x]] = true
+			}
+		}
+
+		for c := 0; c < 256; c++ {
+			if !used[c] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				bits := byte(0)
+				for i := 0; i < 6 && top+i < height; i++ {
+					if indexed[(top+i)*width+x] == uint8(c) {
+						bits |= 1 << i
+					}
+				}
+				row[x] = '?' + bits
+			}
+			fmt.Fprintf(out, "#%d", c)
+			writeSixelRow(out, row)
+			out.WriteByte('$')
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\")
+	return out.Flush()
+}
+
+// writeSixelRow run-length encodes one colour's sixels for a band.
+func writeSixelRow(out *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(out, "!%d%c", n, row[i])
+		} else {
+			for k := 0; k < n; k++ {
+				out.WriteByte(row[i])
+			}
+		}
+		i = j
+	}
+}
+
+// scaleImage enlarges img by an integer factor with nearest-neighbour
+// sampling so small captchas stay legible.
+func scaleImage(img image.Image, factor int) image.Image {
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*factor, bounds.Dy()*factor))
+	for y := 0; y < bounds.Dy()*factor; y++ {
+		for x := 0; x < bounds.Dx()*factor; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x/factor, bounds.Min.Y+y/factor))
+		}
+	}
+	return scaled
+}
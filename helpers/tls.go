@@ -0,0 +1,113 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSOptions controls how connections to VTOP are verified.
+type TLSOptions struct {
+	// CAFiles are PEM bundles trusted in addition to the system roots, such
+	// as the root of a campus proxy that intercepts HTTPS.
+	CAFiles []string
+	// Pins, when set, are the only public keys VTOP may present, written as
+	// "sha256/<base64 of the SubjectPublicKeyInfo digest>".
+	Pins []string
+	// Insecure skips certificate verification entirely.
+	Insecure bool
+}
+
+// ConfigureTLS applies opts to every transport built afterwards; pins only
+// apply to VTOP. SSL_CERT_FILE is honoured on all platforms, not only where
+// the system pool reads it.
+func ConfigureTLS(opts TLSOptions) error {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.Insecure {
+		config.InsecureSkipVerify = true
+	} else {
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		files := opts.CAFiles
+		if env := os.Getenv("SSL_CERT_FILE"); env != "" {
+			files = append([]string{env}, files...)
+		}
+		for _, file := range files {
+			pem, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("reading CA bundle: %w", err)
+			}
+			if !roots.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("no PEM certificates found in %s", file)
+			}
+		}
+		config.RootCAs = roots
+	}
+
+	vtopConfig := config
+	if len(opts.Pins) > 0 {
+		pins := map[string]bool{}
+		for _, pin := range opts.Pins {
+			digest, ok := strings.CutPrefix(strings.TrimSpace(pin), "sha256/")
+			if raw, err := base64.StdEncoding.DecodeString(digest); !ok || err != nil || len(raw) != sha256.Size {
+				return fmt.Errorf("invalid pin %q, expected sha256/<base64 digest>", pin)
+			}
+			pins[digest] = true
+		}
+		vtopConfig = config.Clone()
+		vtopConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return checkPins(state, pins)
+		}
+	}
+
+	baseTLSConfig, vtopTLSConfig = config, vtopConfig
+	rebuildSharedTransport()
+	return nil
+}
+
+// ErrPinMismatch means VTOP presented a valid certificate whose key is not
+// one of the configured pins.
+var ErrPinMismatch = errors.New("certificate does not match any pinned key")
+
+// checkPins accepts the connection if any certificate in the verified chain,
+// or in the presented chain when verification is off, has a pinned key.
+func checkPins(state tls.ConnectionState, pins map[string]bool) error {
+	chains := state.VerifiedChains
+	if len(chains) == 0 {
+		chains = [][]*x509.Certificate{state.PeerCertificates}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if pins[CertificatePin(cert)] {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w for %s", ErrPinMismatch, state.ServerName)
+}
+
+// CertificatePin is the base64 SHA-256 digest of a certificate's public key,
+// the value that follows "sha256/" in a pin.
+func CertificatePin(cert *x509.Certificate) string {
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// IsCertificateError reports whether err came from rejecting VTOP's
+// certificate rather than from the network.
+func IsCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verifyErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) || errors.Is(err, ErrPinMismatch)
+}
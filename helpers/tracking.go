@@ -7,8 +7,8 @@ import (
 	"net/http"
 	"time"
 
-	"cli-top/debug"
-	types "cli-top/types"
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 	"github.com/spf13/viper"
 )
 
@@ -19,7 +19,7 @@ func RegisterUUID(uuid string) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		if debug.Debug {
-			fmt.Println("Error marshaling registration data:", err)
+			fmt.Fprintln(Messages, "Error marshaling registration data:", err)
 		}
 		return err
 	}
@@ -28,18 +28,18 @@ func RegisterUUID(uuid string) error {
 		req, err := http.NewRequest("POST", CalendarServerURL+"/register", bytes.NewBuffer(jsonData))
 		if err != nil {
 			if debug.Debug {
-				fmt.Println("Error creating registration request:", err)
+				fmt.Fprintln(Messages, "Error creating registration request:", err)
 			}
 			return err
 		}
 
 		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{Timeout: 5 * time.Second}
+		client := NewHTTPClient(5 * time.Second)
 		resp, err := client.Do(req)
 
 		if err != nil {
 			if debug.Debug {
-				fmt.Printf("Attempt %d: Error sending registration request: %v\n", i+1, err)
+				fmt.Fprintf(Messages, "Attempt %d: Error sending registration request: %v\n", i+1, err)
 			}
 			time.Sleep(2 * time.Second)
 			continue
@@ -48,35 +48,35 @@ func RegisterUUID(uuid string) error {
 
 		if resp.StatusCode == http.StatusCreated {
 			if debug.Debug {
-				fmt.Println("UUID registered successfully.")
+				fmt.Fprintln(Messages, "UUID registered successfully.")
 			}
 			viper.Set("UUID", uuid)
 			viper.Set("UNREGISTERED_UUID", "")
 			if err := viper.WriteConfig(); err != nil && debug.Debug {
-				fmt.Println("Error updating config after registration:", err)
+				fmt.Fprintln(Messages, "Error updating config after registration:", err)
 			}
 			return nil
 		}
 
 		if resp.StatusCode == http.StatusConflict {
 			if debug.Debug {
-				fmt.Println("UUID already registered.")
+				fmt.Fprintln(Messages, "UUID already registered.")
 			}
 			viper.Set("UUID", uuid)
 			viper.Set("UNREGISTERED_UUID", "")
 			if err := viper.WriteConfig(); err != nil && debug.Debug {
-				fmt.Println("Error updating config after conflict:", err)
+				fmt.Fprintln(Messages, "Error updating config after conflict:", err)
 			}
 			return nil
 		}
 
 		if debug.Debug {
-			fmt.Println("Unexpected response status during registration:", resp.Status)
+			fmt.Fprintln(Messages, "Unexpected response status during registration:", resp.Status)
 		}
 	}
 
 	if debug.Debug {
-		fmt.Println("Registration failed after retries. UUID remains unregistered.")
+		fmt.Fprintln(Messages, "Registration failed after retries. UUID remains unregistered.")
 	}
 	return fmt.Errorf("failed to register UUID after %d attempts", maxRetries)
 }
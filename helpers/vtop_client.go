@@ -0,0 +1,323 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+const defaultVtopDeadline = 60 * time.Second
+
+// vtopDeadlines bounds each request by the endpoint it hits. The longest
+// matching path prefix wins; anything else gets defaultVtopDeadline.
+var vtopDeadlines = map[string]time.Duration{
+	"/vtop/login":                            30 * time.Second,
+	"/vtop/prelogin":                         30 * time.Second,
+	"/vtop/init/page":                        30 * time.Second,
+	"/vtop/downloadPdf":                      5 * time.Minute,
+	"/vtop/downloadCourseMaterialFacultyPdf": 5 * time.Minute,
+	"/vtop/courseSyllabusDownload":           2 * time.Minute,
+	"/vtop/examinations/doDownloadQuestion":  2 * time.Minute,
+}
+
+// VtopRequest describes one call to VTOP. Method defaults to POST and
+// ContentType to a url-encoded form.
+type VtopRequest struct {
+	URL         string
+	Method      string
+	Body        string
+	ContentType string
+	Referer     string
+	// Timeout replaces the endpoint deadline when set.
+	Timeout time.Duration
+	// Mutating marks a request that changes something on VTOP, such as a
+	// registration, so it is not repeated once it may have arrived.
+	Mutating bool
+	// Attempts replaces the retry policy's attempt count when set, for
+	// callers that run their own retry loop.
+	Attempts int
+}
+
+// VtopResponse is a fully read VTOP response.
+type VtopResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// URL is where the request ended up after redirects.
+	URL string
+}
+
+// VtopClient sends requests to VTOP with the session in its cookie jar.
+// Every call takes a context, so cancelling it (Ctrl-C) aborts the request,
+// and each endpoint runs under its own deadline.
+type VtopClient struct {
+	http *http.Client
+	jar  *SessionJar
+}
+
+// Vtop is the client used by every feature unless the context names another
+// with WithVtopClient. It holds the CLI's saved session and logs in again
+// when it expires.
+var Vtop = &VtopClient{http: sharedHTTPClient, jar: sessionJar}
+
+// NewVtopClient returns a client with a cookie jar of its own, kept only in
+// memory, so sessions for several students can be used at once. It shares
+// the transport and rate limit with Vtop but never logs in again by itself;
+// an expired session is returned as ErrSessionExpired.
+func NewVtopClient() *VtopClient {
+	jar := newSessionJar()
+	return &VtopClient{http: &http.Client{Jar: jar, Transport: sharedHTTPClient.Transport}, jar: jar}
+}
+
+type vtopClientKey struct{}
+
+// WithVtopClient returns a copy of ctx under which features send their
+// requests through v instead of Vtop.
+func WithVtopClient(ctx context.Context, v *VtopClient) context.Context {
+	return context.WithValue(ctx, vtopClientKey{}, v)
+}
+
+// VtopFor returns the client for requests made under ctx: the one given to
+// WithVtopClient, or Vtop.
+func VtopFor(ctx context.Context) *VtopClient {
+	if v, ok := ctx.Value(vtopClientKey{}).(*VtopClient); ok {
+		return v
+	}
+	return Vtop
+}
+
+// Jar returns the jar holding v's session.
+func (v *VtopClient) Jar() *SessionJar {
+	return v.jar
+}
+
+// HTTP returns the http.Client behind v, for the login pages, which are not
+// sent through Do.
+func (v *VtopClient) HTTP() *http.Client {
+	return v.http
+}
+
+// WithHTTP returns a client sending through c instead of v's client, for
+// downloads that tune transports per attempt. The session jar is kept, and a
+// client without its own transport uses the shared one.
+func (v *VtopClient) WithHTTP(c *http.Client) *VtopClient {
+	withJar := *c
+	withJar.Jar = v.jar
+	if withJar.Transport == nil {
+		withJar.Transport = sharedHTTPClient.Transport
+	} else {
+		withJar.Transport = wrapVtopTransport(withJar.Transport)
+	}
+	return &VtopClient{http: &withJar, jar: v.jar}
+}
+
+// Deadline returns how long a request to rawURL may take.
+func Deadline(rawURL string) time.Duration {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return defaultVtopDeadline
+	}
+	deadline, longest := defaultVtopDeadline, 0
+	for prefix, d := range vtopDeadlines {
+		if strings.HasPrefix(u.Path, prefix) && len(prefix) > longest {
+			deadline, longest = d, len(prefix)
+		}
+	}
+	return deadline
+}
+
+// Do sends r and reads the whole response, retrying transient failures as
+// set by Retry. On Vtop, a timed out session triggers one login through the
+// registered hook and a retry. Responses that are not the requested page,
+// such as VTOP's maintenance notice, come back as a *VtopError.
+func (v *VtopClient) Do(ctx context.Context, r VtopRequest) (*VtopResponse, error) {
+	session := sessionGeneration()
+	resp, err := v.sendWithRetry(ctx, r)
+	if err != nil {
+		return nil, recordVtopError(err)
+	}
+	if sessionTimedOut(resp) {
+		if v.jar != sessionJar || VtopLoginGlobal == nil {
+			return nil, recordVtopError(&VtopError{Kind: ErrSessionExpired, Status: resp.StatusCode, URL: resp.URL})
+		}
+		relogin(ctx, session)
+		if resp, err = v.sendWithRetry(ctx, r); err != nil {
+			return nil, recordVtopError(err)
+		}
+	}
+
+	if err := ClassifyResponse(resp); err != nil && !(r.Mutating && errors.Is(err, ErrEmptyResponse)) {
+		return nil, recordVtopError(err)
+	}
+	return resp, nil
+}
+
+func sessionTimedOut(resp *VtopResponse) bool {
+	return resp.StatusCode == http.StatusNotFound || bytes.Contains(resp.Body, []byte("Session Timed Out")) ||
+		bytes.Contains(resp.Body, []byte("HTTP Status 404")) || (isHTML(resp) && isLoginPage(resp))
+}
+
+// Post sends a url-encoded form that only reads data and returns the
+// response body.
+func (v *VtopClient) Post(ctx context.Context, url string, form string) ([]byte, error) {
+	resp, err := v.Do(ctx, VtopRequest{URL: url, Body: form})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Submit is Post for forms that change something on VTOP.
+func (v *VtopClient) Submit(ctx context.Context, url string, form string) ([]byte, error) {
+	resp, err := v.Do(ctx, VtopRequest{URL: url, Body: form, Mutating: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (v *VtopClient) sendWithRetry(ctx context.Context, r VtopRequest) (*VtopResponse, error) {
+	policy := Retry
+	if r.Attempts > 0 {
+		policy.Attempts = r.Attempts
+	}
+	return withRetry(ctx, policy, r.URL, !r.Mutating, func() (*VtopResponse, error) {
+		return v.send(ctx, r)
+	})
+}
+
+func (v *VtopClient) send(ctx context.Context, r VtopRequest) (*VtopResponse, error) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = Deadline(r.URL)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	method := r.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var body io.Reader
+	if method != http.MethodGet {
+		body = strings.NewReader(r.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+
+	SetVtopHeaders(req)
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = "application/x-www-form-urlencoded"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if r.Referer != "" {
+		req.Header.Set("Referer", r.Referer)
+	}
+
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	return &VtopResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: data, URL: resp.Request.URL.String()}, nil
+}
+
+// requestError reports a cancelled or timed out request plainly instead of
+// as a transport error.
+func requestError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return context.Canceled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("VTOP did not respond in time: %w", context.DeadlineExceeded)
+	}
+	return fmt.Errorf("failed to perform HTTP request: %w", err)
+}
+
+// MenuForm is the body VTOP expects when opening a page from its menu.
+func MenuForm(regNo string, cookies types.Cookies) string {
+	return fmt.Sprintf("verifyMenu=true&authorizedID=%s&_csrf=%s&nocache=%d", regNo, cookies.CSRF, time.Now().UnixNano())
+}
+
+// SemesterForm is the body of pages that list data for one semester.
+func SemesterForm(regNo string, cookies types.Cookies, semID string) string {
+	return fmt.Sprintf("authorizedID=%s&_csrf=%s&semesterSubId=%s&x=%s", regNo, cookies.CSRF, semID, time.Now().UTC().Format(time.RFC1123))
+}
+
+// Sleep waits for d or until ctx is cancelled, whichever comes first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// sessionMu keeps a relogin, which starts a new session in Vtop's cookie
+// jar, from running while any other request to VTOP is in flight.
+// Requests hold it for reading and a relogin for writing.
+var (
+	sessionMu     sync.RWMutex
+	sessionLogins uint64 // relogins so far
+	lastRelogin   struct {
+		cookies types.Cookies
+		regNo   string
+	}
+)
+
+func sessionGeneration() uint64 {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	return sessionLogins
+}
+
+// Relogin logs in again through VtopLoginGlobal, which reads the password
+// from the configured credential store. It waits for requests in flight and
+// holds new ones back until the new session is in the jar.
+func Relogin(ctx context.Context) (types.Cookies, string) {
+	return relogin(ctx, sessionGeneration())
+}
+
+// relogin logs in again unless another relogin has finished since the
+// caller saw generation session, in which case its result is shared.
+func relogin(ctx context.Context, session uint64) (types.Cookies, string) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if sessionLogins != session || VtopLoginGlobal == nil {
+		return lastRelogin.cookies, lastRelogin.regNo
+	}
+	cookies, regNo := VtopLoginGlobal(ctx)
+	sessionLogins++
+	lastRelogin.cookies, lastRelogin.regNo = cookies, regNo
+	return cookies, regNo
+}
+
+// WithSession runs fn, which uses the session outside VtopClient, such as
+// checking it with the home page, without a relogin starting under it.
+func WithSession(fn func()) {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	fn()
+}
@@ -0,0 +1,225 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of VtopError, for use with errors.Is.
+var (
+	ErrMaintenance    = errors.New("VTOP is down for maintenance")
+	ErrUnavailable    = errors.New("VTOP is not responding properly")
+	ErrSessionExpired = errors.New("your VTOP session has expired")
+	ErrEmptyResponse  = errors.New("VTOP sent back an empty page")
+)
+
+// Suggested waits when VTOP does not say how long it will be down.
+var defaultRetryAfter = map[error]time.Duration{
+	ErrMaintenance:   30 * time.Minute,
+	ErrUnavailable:   5 * time.Minute,
+	ErrEmptyResponse: time.Minute,
+}
+
+// VtopError is a response that came back but does not hold the page that
+// was asked for.
+type VtopError struct {
+	Kind   error
+	Status int
+	URL    string
+	// RetryAt is when trying again is likely to work, or zero if unknown.
+	RetryAt time.Time
+}
+
+func (e *VtopError) Error() string {
+	msg := e.Kind.Error()
+	if e.Kind == ErrUnavailable && e.Status != 0 {
+		msg += fmt.Sprintf(" (HTTP %d %s)", e.Status, http.StatusText(e.Status))
+	}
+	switch {
+	case e.Kind == ErrSessionExpired:
+		msg += `. Run "cli-top login" to sign in again.`
+	case !e.RetryAt.IsZero():
+		msg += ". Try again " + retryHint(time.Until(e.RetryAt), e.RetryAt) + "."
+	default:
+		msg += "."
+	}
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
+
+func (e *VtopError) Unwrap() error { return e.Kind }
+
+func retryHint(wait time.Duration, at time.Time) string {
+	switch {
+	case wait <= time.Minute:
+		return "in a minute"
+	case wait < time.Hour:
+		return fmt.Sprintf("in about %d minutes", int(wait.Round(time.Minute).Minutes()))
+	}
+	return "after " + at.In(istLocation).Format("15:04 on Jan 2") + " IST"
+}
+
+var istLocation = time.FixedZone("IST", 5*3600+1800)
+
+// maintenanceMarkers appear on the page VTOP serves while it is down for
+// maintenance, which comes back with a 200 status.
+var maintenanceMarkers = []string{
+	"under maintenance",
+	"scheduled maintenance",
+	"temporarily unavailable",
+	"maintenance activity",
+}
+
+// maintenanceUntil finds the end time some maintenance banners announce,
+// such as "VTOP will be available after 6:00 PM".
+var maintenanceUntil = regexp.MustCompile(`(?i)\b(?:till|until|upto|up to|after|by)\s+(\d{1,2})(?:[:.](\d{2}))?\s*(am|pm|hrs)?\b`)
+
+// loginPageMarkers identify the login form VTOP redirects to once a session
+// is no longer valid.
+var loginPageMarkers = [][]byte{[]byte(`id="captchaBlock"`), []byte(`name="captchaStr"`), []byte(`id="vtopLoginForm"`)}
+
+// ClassifyResponse returns a *VtopError when resp is a maintenance page, a
+// gateway error, the login page or empty, and nil when it looks like the
+// page that was asked for.
+func ClassifyResponse(resp *VtopResponse) error {
+	now := time.Now()
+	verr := &VtopError{Status: resp.StatusCode, URL: resp.URL}
+
+	switch {
+	case isHTML(resp) && isMaintenancePage(resp.Body):
+		verr.Kind = ErrMaintenance
+		verr.RetryAt = announcedEnd(resp.Body, now)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		verr.Kind = ErrUnavailable
+	case isHTML(resp) && isLoginPage(resp):
+		verr.Kind = ErrSessionExpired
+		return verr
+	case resp.StatusCode == http.StatusOK && len(bytes.TrimSpace(resp.Body)) == 0:
+		verr.Kind = ErrEmptyResponse
+	default:
+		return nil
+	}
+
+	if verr.RetryAt.IsZero() {
+		if wait, ok := retryAfterHeader(resp.Header, now); ok {
+			verr.RetryAt = now.Add(wait)
+		} else {
+			verr.RetryAt = now.Add(defaultRetryAfter[verr.Kind])
+		}
+	}
+	return verr
+}
+
+// isHTML keeps downloaded files, which may well mention maintenance, out of
+// the page checks.
+func isHTML(resp *VtopResponse) bool {
+	return strings.Contains(resp.Header.Get("Content-Type"), "html") ||
+		strings.HasPrefix(http.DetectContentType(resp.Body), "text/html")
+}
+
+// isMaintenancePage looks for the markers in the title, or anywhere in a
+// page too short to be a real VTOP page, so that a class message about lab
+// maintenance is not mistaken for an outage.
+func isMaintenancePage(body []byte) bool {
+	text := bytes.ToLower(body)
+	if len(text) > 4096 {
+		start := bytes.Index(text, []byte("<title>"))
+		end := bytes.Index(text, []byte("</title>"))
+		if start < 0 || end < start {
+			return false
+		}
+		text = text[start:end]
+	}
+	for _, marker := range maintenanceMarkers {
+		if bytes.Contains(text, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoginPage(resp *VtopResponse) bool {
+	if u, err := url.Parse(resp.URL); err == nil && (u.Path == "/vtop/login" || u.Path == "/vtop/open/page") {
+		return true
+	}
+	for _, marker := range loginPageMarkers {
+		if bytes.Contains(resp.Body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// announcedEnd reads the time a maintenance banner gives, in IST, or zero.
+func announcedEnd(body []byte, now time.Time) time.Time {
+	m := maintenanceUntil.FindSubmatch(body)
+	if m == nil {
+		return time.Time{}
+	}
+	hour, _ := strconv.Atoi(string(m[1]))
+	minute, _ := strconv.Atoi(string(m[2]))
+	switch strings.ToLower(string(m[3])) {
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}
+	}
+	local := now.In(istLocation)
+	end := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, istLocation)
+	if end.Before(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// retryAfterHeader parses Retry-After as seconds or an HTTP date.
+func retryAfterHeader(h http.Header, now time.Time) (time.Duration, bool) {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now), true
+	}
+	return 0, false
+}
+
+var (
+	lastVtopErrorMu sync.Mutex
+	lastVtopError   *VtopError
+)
+
+// LastVtopError returns the most recent VtopError any request ran into, so
+// a command can explain an empty result once instead of per request.
+func LastVtopError() *VtopError {
+	lastVtopErrorMu.Lock()
+	defer lastVtopErrorMu.Unlock()
+	return lastVtopError
+}
+
+func recordVtopError(err error) error {
+	var verr *VtopError
+	if errors.As(err, &verr) {
+		lastVtopErrorMu.Lock()
+		lastVtopError = verr
+		lastVtopErrorMu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,27 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidCaptcha     = errors.New("invalid captcha")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrAccountLocked      = errors.New("maximum login attempts reached, account locked")
+	ErrSessionTimedOut    = errors.New("session timed out")
+	ErrNetwork            = errors.New("unable to reach VTOP")
+	ErrCaptchaSolver      = errors.New("captcha solver failed")
+
+	errNoCaptcha = errors.New("login page did not include a captcha")
+)
+
+// networkError wraps a failed request in ErrNetwork, unless it failed
+// because the caller gave up.
+func networkError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return context.Canceled
+	}
+	return fmt.Errorf("%w: %w", ErrNetwork, err)
+}
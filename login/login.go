@@ -1,27 +1,38 @@
 package login
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	types "cli-top/types"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 )
 
-func performLogin(userInfo types.LogIn, cookies types.Cookies, captcha string) types.Cookies {
+func performLogin(ctx context.Context, userInfo types.LogIn, cookies types.Cookies, captcha string) (types.Cookies, error) {
 
-	client := helpers.GetHTTPClient()
+	// A copy, so requests elsewhere keep following redirects.
+	client := *helpers.VtopFor(ctx).HTTP()
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
-	defer func() { client.CheckRedirect = nil }()
-	var data = strings.NewReader(fmt.Sprintf(`_csrf=%s&username=%s&password=%s&captchaStr=%s`, cookies.CSRF, userInfo.Username, userInfo.Password, captcha))
-	req, err := http.NewRequest("POST", "https://vtop.vit.ac.in/vtop/login", data)
+	form := url.Values{}
+	form.Set("_csrf", cookies.CSRF)
+	form.Set("username", userInfo.Username)
+	form.Set("password", userInfo.Password)
+	form.Set("captchaStr", captcha)
+	data := strings.NewReader(form.Encode())
+	ctx, cancel := context.WithTimeout(ctx, helpers.Deadline("https://vtop.vit.ac.in/vtop/login"))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://vtop.vit.ac.in/vtop/login", data)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(helpers.Messages, err)
 	}
 	helpers.SetVtopHeaders(req)
 	req.Header.Set("Cache-Control", "max-age=0")
@@ -29,94 +40,154 @@ func performLogin(userInfo types.LogIn, cookies types.Cookies, captcha string) t
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Sec-Fetch-Site", "same-origin")
 	req.Header.Set("Referer", "https://vtop.vit.ac.in/vtop/login")
-	req.Header.Set("Cookie", fmt.Sprintf("JSESSIONID=%s; SERVERID=%s", cookies.JSESSIONID, cookies.SERVERID))
 	resp, err := client.Do(req)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return types.Cookies{}, networkError(ctx, err)
 	}
 	defer resp.Body.Close()
 
-	if errorCheck(cookies) {
-		return types.Cookies{}
+	if err := errorCheck(ctx); err != nil {
+		return types.Cookies{}, err
 	}
 
-	tokens := helpers.ExtractCookies(resp)
+	tokens := helpers.VtopFor(ctx).Jar().VtopCookies()
+	tokens.CSRF = cookies.CSRF
 
-	return tokens
+	return tokens, nil
 }
 
-func errorCheck(cookies types.Cookies) bool {
-	client := helpers.GetHTTPClient()
-	req, err := http.NewRequest("GET", "https://vtop.vit.ac.in/vtop/login/error", nil)
+// errorCheck reads the login error page and maps its message to a typed error.
+func errorCheck(ctx context.Context) error {
+	client := helpers.VtopFor(ctx).HTTP()
+	ctx, cancel := context.WithTimeout(ctx, helpers.Deadline("https://vtop.vit.ac.in/vtop/login/error"))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://vtop.vit.ac.in/vtop/login/error", nil)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(helpers.Messages, err)
 	}
 	helpers.SetVtopHeaders(req)
 	req.Header.Set("Cache-Control", "max-age=0")
 	req.Header.Set("Referer", "https://vtop.vit.ac.in/vtop/login")
-	req.Header.Set("Cookie", fmt.Sprintf("JSESSIONID=%s; SERVERID=%s", cookies.JSESSIONID, cookies.SERVERID))
 	resp, err := client.Do(req)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return networkError(ctx, err)
 	}
 	defer resp.Body.Close()
 	bodyText, err := io.ReadAll(resp.Body)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return networkError(ctx, err)
 	}
 
-	if strings.Contains(string(bodyText), "Invalid Captcha") {
-		fmt.Println("\nInvalid Captcha. The captcha solver can sometimes confuse between B and 8, please retry...")
-		return true
-	}
-	if strings.Contains(string(bodyText), "Invalid LoginId/Password") {
-		fmt.Println("\nInvalid LoginId/Password. Please check your cli-top config and try again...")
-		return true
+	body := string(bodyText)
+	switch {
+	case strings.Contains(body, "Invalid Captcha"):
+		return ErrInvalidCaptcha
+	case strings.Contains(body, "Invalid LoginId/Password"), strings.Contains(body, "Invalid Username/Password"):
+		return ErrInvalidCredentials
+	case strings.Contains(body, "Maximum Fail Attempts"):
+		return ErrAccountLocked
 	}
 
-	if strings.Contains(string(bodyText), "Invalid Username/Password") {
-		fmt.Println("\nInvalid Username/Password. Please check your cli-top config and try again...")
-		return true
-	}
+	return nil
+}
 
-	if strings.Contains(string(bodyText), "Maximum Fail Attempts") {
-		fmt.Println("\nNumber Of Maximum Fail Attempts Reached. Use Forgot Password on VTOP to reset your password.")
-		return true
-	}
+// MaxCaptchaAttempts is the number of login pages fetched and solved before
+// Login gives up on captcha failures.
+var MaxCaptchaAttempts = 5
 
-	return false
-}
+// CaptchaBackoff is the delay before the second attempt, doubled on each retry.
+var CaptchaBackoff = 500 * time.Millisecond
 
-func Login(regNo string, password string) types.Cookies {
-	vtopTokens, captcha := getLoginPage()
-	// fmt.Println(captcha)
+const maxCaptchaBackoff = 8 * time.Second
 
+// OnCaptchaAccepted, when set, is called after a successful login with the
+// captcha image (a data URL) and the answer VTOP accepted.
+var OnCaptchaAccepted func(captchaImage string, answer string)
+
+// Login signs in to VTOP, starting a new session in the jar of the client
+// for ctx. A missing, doubtful or rejected captcha re-fetches prelogin/setup
+// and tries again within MaxCaptchaAttempts; any other failure is returned
+// straight away.
+func Login(ctx context.Context, regNo string, password string) (types.Cookies, error) {
 	userInfo := types.LogIn{
 		Username: regNo,
 		Password: password,
 	}
 
-	loginCreds := performLogin(userInfo, vtopTokens, captcha)
-	// fmt.Println(loginCreds)
-	vtopTokens.JSESSIONID = loginCreds.JSESSIONID
+	attempts := MaxCaptchaAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
 
-	return vtopTokens
+	var lastErr error
+	backoff := CaptchaBackoff
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			debug.Log(fmt.Sprintf("Login attempt %d of %d failed (%v), retrying in %s", attempt-1, attempts, lastErr, backoff))
+			if err := helpers.Sleep(ctx, backoff); err != nil {
+				return types.Cookies{}, err
+			}
+			backoff = min(backoff*2, maxCaptchaBackoff)
+		}
+
+		vtopTokens, captchaImage, captcha, err := getLoginPage(ctx)
+		if errors.Is(err, errNoCaptcha) {
+			lastErr = err
+			continue
+		}
+		if errors.Is(err, helpers.ErrLowConfidence) {
+			if attempt < attempts {
+				lastErr = err
+				continue
+			}
+			// Out of fresh captchas, a doubtful guess beats giving up.
+			debug.Log(fmt.Sprintf("Submitting low-confidence captcha on the last attempt: %v", err))
+			err = nil
+		}
+		if err != nil {
+			return types.Cookies{}, err
+		}
+
+		loginCreds, err := performLogin(ctx, userInfo, vtopTokens, captcha)
+		if errors.Is(err, ErrInvalidCaptcha) {
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			return types.Cookies{}, err
+		}
+
+		debug.Log(fmt.Sprintf("Login succeeded after %d attempt(s)", attempt))
+		if OnCaptchaAccepted != nil {
+			OnCaptchaAccepted(captchaImage, captcha)
+		}
+		return loginCreds, nil
+	}
+
+	if errors.Is(lastErr, errNoCaptcha) || errors.Is(lastErr, helpers.ErrLowConfidence) {
+		lastErr = ErrInvalidCaptcha
+	}
+	return types.Cookies{}, fmt.Errorf("%w (gave up after %d attempts)", lastErr, attempts)
 }
 
-func HomePage(vtopTokens types.Cookies) (types.Cookies, string) {
-	client := helpers.GetHTTPClient()
-	req, err := http.NewRequest("GET", "https://vtop.vit.ac.in/vtop/init/page", nil)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+// HomePage loads the post-login landing page with the session in the jar of
+// the client for ctx, returning the current cookies, the refreshed CSRF token and the
+// registration number. ErrSessionTimedOut means the session is no longer valid.
+func HomePage(ctx context.Context, vtopTokens types.Cookies) (types.Cookies, string, error) {
+	client := helpers.VtopFor(ctx).HTTP()
+	ctx, cancel := context.WithTimeout(ctx, helpers.Deadline("https://vtop.vit.ac.in/vtop/init/page"))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://vtop.vit.ac.in/vtop/init/page", nil)
+	if err != nil {
+		return vtopTokens, "", err
 	}
 	helpers.SetVtopHeaders(req)
 	req.Header.Set("Cache-Control", "max-age=0")
 	req.Header.Set("Sec-Fetch-Site", "same-origin")
 	req.Header.Set("Referer", "https://vtop.vit.ac.in/vtop/login")
-	req.Header.Set("Cookie", fmt.Sprintf("JSESSIONID=%s; SERVERID=%s", vtopTokens.JSESSIONID, vtopTokens.SERVERID))
 	resp, err := client.Do(req)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return vtopTokens, "", networkError(ctx, err)
 	}
 	defer resp.Body.Close()
 
@@ -124,48 +195,25 @@ func HomePage(vtopTokens types.Cookies) (types.Cookies, string) {
 
 	if strings.Contains(string(bodyText), "Session Timed Out") {
 		if debug.Debug {
-			fmt.Println("Session Timed Out, login failed. Retrying...")
+			fmt.Fprintln(helpers.Messages, "Session Timed Out, login failed. Retrying...")
 		}
-		return vtopTokens, ""
+		return vtopTokens, "", ErrSessionTimedOut
 	}
 
+	vtopTokens = helpers.VtopFor(ctx).Jar().VtopCookies()
 	vtopTokens.CSRF = helpers.ExtractCSRF2(bodyText)
 
 	RegNo, err := helpers.ExtractRegNo(bodyText)
-	if err != nil && debug.Debug {
-		// Handle the error
-		fmt.Println("Error:", err)
-		// You might want to return or log the error, or take other appropriate actions
-	}
-
-	if debug.Debug {
-		fmt.Println("(Helper - ExtractRegNo):", RegNo)
-	}
-
-	return vtopTokens, RegNo
-}
-
-func AutoRelogin(regNo string) (types.Cookies, bool) {
-	username := os.Getenv("VTOP_USERNAME")
-	password := os.Getenv("PASSWORD")
-	if username == "" || password == "" {
-		if debug.Debug {
-			fmt.Println("No stored credentials found for auto-relogin.")
-		}
-		return types.Cookies{}, false
-	}
-	if debug.Debug {
-		fmt.Println("Attempting auto-relogin with stored credentials...")
-	}
-	newCookies := Login(username, password)
-	if helpers.ValidateCookies(newCookies) {
+	if err != nil {
 		if debug.Debug {
-			fmt.Println("Auto-relogin successful.")
+			fmt.Fprintln(helpers.Messages, "Error:", err)
 		}
-		return newCookies, true
+		return vtopTokens, "", fmt.Errorf("%w: %v", ErrSessionTimedOut, err)
 	}
+
 	if debug.Debug {
-		fmt.Println("Auto-relogin failed.")
+		fmt.Fprintln(helpers.Messages, "(Helper - ExtractRegNo):", RegNo)
 	}
-	return types.Cookies{}, false
+
+	return vtopTokens, RegNo, nil
 }
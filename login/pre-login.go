@@ -1,44 +1,62 @@
 package login
 
 import (
-	"cli-top/debug"
-	"cli-top/helpers"
-	types "cli-top/types"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/ACM-VIT/CLI-TOP/debug"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	types "github.com/ACM-VIT/CLI-TOP/types"
 )
 
-func getSessionServer() types.Cookies {
-	client := helpers.GetHTTPClient()
-	req, err := http.NewRequest("GET", "https://vtop.vit.ac.in/", nil)
+// getSessionServer starts a new VTOP session, dropping any cookies left in
+// the jar by a previous one.
+func getSessionServer(ctx context.Context) (types.Cookies, error) {
+	if err := helpers.VtopFor(ctx).Jar().Clear(); err != nil && debug.Debug {
+		fmt.Fprintln(helpers.Messages, "Error clearing cookies:", err)
+	}
+
+	client := helpers.VtopFor(ctx).HTTP()
+	ctx, cancel := context.WithTimeout(ctx, helpers.Deadline("https://vtop.vit.ac.in/"))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://vtop.vit.ac.in/", nil)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(helpers.Messages, err)
 	}
 	helpers.SetVtopHeaders(req)
 	req.Header.Set("Sec-Fetch-Site", "none")
 	resp, err := client.Do(req)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return types.Cookies{}, networkError(ctx, err)
 	}
 	defer resp.Body.Close()
 
-	vtopCookies := helpers.ExtractCookies(resp)
+	vtopCookies := helpers.VtopFor(ctx).Jar().VtopCookies()
 	vtopCookies.CSRF = helpers.ExtractCSRF(helpers.ExtractBodyText(resp))
 
-	return vtopCookies
+	return vtopCookies, nil
 }
 
-func getLoginPage() (types.Cookies, string) {
+// getLoginPage opens a login page and solves its captcha, returning the
+// captcha image alongside the answer.
+func getLoginPage(ctx context.Context) (cookies types.Cookies, captchaImage string, captcha string, err error) {
 
-	cookies := getSessionServer()
+	cookies, err = getSessionServer(ctx)
+	if err != nil {
+		return types.Cookies{}, "", "", err
+	}
 
-	client := helpers.GetHTTPClient()
+	client := helpers.VtopFor(ctx).HTTP()
 	var data = strings.NewReader(fmt.Sprintf(`_csrf=%s&flag=VTOP`, cookies.CSRF))
-	req, err := http.NewRequest("POST", "https://vtop.vit.ac.in/vtop/prelogin/setup", data)
+	ctx, cancel := context.WithTimeout(ctx, helpers.Deadline("https://vtop.vit.ac.in/vtop/prelogin/setup"))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://vtop.vit.ac.in/vtop/prelogin/setup", data)
 	if err != nil && debug.Debug {
-		fmt.Println(err)
+		fmt.Fprintln(helpers.Messages, err)
 	}
 	helpers.SetVtopHeaders(req)
 	req.Header.Set("Cache-Control", "max-age=0")
@@ -46,32 +64,38 @@ func getLoginPage() (types.Cookies, string) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Sec-Fetch-Site", "same-origin")
 	req.Header.Set("Referer", "https://vtop.vit.ac.in/vtop/open/page")
-	req.Header.Set("Cookie", fmt.Sprintf("JSESSIONID=%s; SERVERID=%s", cookies.JSESSIONID, cookies.SERVERID))
 	resp, err := client.Do(req)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return types.Cookies{}, "", "", networkError(ctx, err)
 	}
 	defer resp.Body.Close()
 
 	bodyText, err := io.ReadAll(resp.Body)
-	if err != nil && debug.Debug {
-		fmt.Println(err)
+	if err != nil {
+		return types.Cookies{}, "", "", networkError(ctx, err)
 	}
 	// fmt.Printf("%s\n", bodyText)
 
 	stringBody := string(bodyText)
-	captchaImage := helpers.ExtractImage(stringBody)
+	captchaImage = helpers.ExtractImage(stringBody)
 	if captchaImage == "nocaptcha" {
-		// Vtop does not always send a captcha image, so try again
-		return getLoginPage()
+		// Vtop does not always send a captcha image, Login fetches a new page
+		return types.Cookies{}, "", "", errNoCaptcha
 	}
 	// fmt.Println("getLoginPage() - Captcha:", captchaImage)
 
-	captcha := helpers.SolveCaptcha(captchaImage)
+	captcha, err = helpers.ReadCaptcha(captchaImage)
+	if errors.Is(err, helpers.ErrLowConfidence) {
+		// Login decides whether to submit the guess or fetch a new captcha.
+		return cookies, captchaImage, captcha, err
+	}
+	if err != nil {
+		return types.Cookies{}, "", "", fmt.Errorf("%w: %v", ErrCaptchaSolver, err)
+	}
 	if strings.Contains(captcha, "disabled") {
-		fmt.Println("Captcha auto-solver has been disabled. \nPlease manually solve the captcha and answer here:")
+		fmt.Fprintln(helpers.Messages, "Captcha auto-solver has been disabled. \nPlease manually solve the captcha and answer here:")
 		fmt.Scanln(&captcha)
 	}
 
-	return cookies, captcha
+	return cookies, captchaImage, captcha, nil
 }
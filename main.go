@@ -1,7 +1,7 @@
 package main
 
 import (
-	"cli-top/cmd"
+	"github.com/ACM-VIT/CLI-TOP/cmd"
 )
 
 func main() {
@@ -0,0 +1,301 @@
+package vtop
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ACM-VIT/CLI-TOP/features"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/login"
+	"github.com/ACM-VIT/CLI-TOP/store"
+)
+
+// Client makes requests to VTOP as one logged-in student. Each client has
+// its own session, cookie jar and store, so clients for several students can
+// be used at once.
+type Client struct {
+	session Session
+	http    *helpers.VtopClient
+	store   *store.Store
+}
+
+// Options sets up where a Client keeps its state. The zero value gives the
+// client a cookie jar of its own and saves nothing.
+type Options struct {
+	// HTTP sends the client's requests. By default a new client from
+	// helpers.NewVtopClient is used.
+	HTTP *helpers.VtopClient
+	// Store keeps a copy of every dataset fetched, to use when VTOP cannot be
+	// reached or, with Store.MaxAge, while it is recent enough. Nil saves
+	// nothing.
+	Store *store.Store
+}
+
+// NewClient returns a client that is not logged in yet. Call Login, Resume
+// or SetSession before fetching anything.
+func NewClient(opts Options) *Client {
+	if opts.HTTP == nil {
+		opts.HTTP = helpers.NewVtopClient()
+	}
+	if opts.Store == nil {
+		opts.Store = &store.Store{}
+	}
+	return &Client{http: opts.HTTP, store: opts.Store}
+}
+
+// Login signs in with the student's VTOP username and password, solving the
+// captcha with cli-top's captcha solver.
+func Login(ctx context.Context, username, password string) (*Client, error) {
+	client := NewClient(Options{})
+	if err := client.Login(ctx, username, password); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Resume picks up a session saved from Client.Session after checking with
+// VTOP that it is still signed in. An expired session is reported as
+// ErrSessionExpired.
+func Resume(ctx context.Context, cookies Cookies) (*Client, error) {
+	client := NewClient(Options{})
+	if err := client.Resume(ctx, cookies); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// New returns a client for a session that is known to be valid, without
+// contacting VTOP. Methods fail with ErrNotLoggedIn if it has no cookies.
+func New(s Session) *Client {
+	client := NewClient(Options{})
+	client.SetSession(s)
+	return client
+}
+
+// Login signs c in with the student's VTOP username and password, replacing
+// any session it had.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	ctx = c.context(ctx)
+	cookies, err := login.Login(ctx, username, password)
+	if err != nil {
+		return err
+	}
+	return c.home(ctx, cookies)
+}
+
+// Resume gives c a session saved from Session, after checking with VTOP that
+// it is still signed in. An expired session is reported as
+// ErrSessionExpired.
+func (c *Client) Resume(ctx context.Context, cookies Cookies) error {
+	c.http.Jar().UseVtopCookies(cookies)
+	err := c.home(c.context(ctx), cookies)
+	if errors.Is(err, login.ErrSessionTimedOut) {
+		return ErrSessionExpired
+	}
+	return err
+}
+
+// SetSession makes s c's session without contacting VTOP.
+func (c *Client) SetSession(s Session) {
+	c.http.Jar().UseVtopCookies(s.Cookies)
+	c.session = s
+}
+
+// home loads the page VTOP shows after login, which gives the registration
+// number and a fresh CSRF token.
+func (c *Client) home(ctx context.Context, cookies Cookies) error {
+	cookies, regNo, err := login.HomePage(ctx, cookies)
+	if err != nil {
+		return err
+	}
+	c.session = Session{RegNo: regNo, Cookies: cookies}
+	return nil
+}
+
+// context returns ctx set up to send requests through c's jar and save
+// results to c's store.
+func (c *Client) context(ctx context.Context) context.Context {
+	return store.With(helpers.WithVtopClient(ctx, c.http), c.store)
+}
+
+// Session returns the session for saving and passing to Resume later.
+func (c *Client) Session() Session {
+	return c.session
+}
+
+// RegNo returns the registration number of the logged-in student.
+func (c *Client) RegNo() string {
+	return c.session.RegNo
+}
+
+// Semesters lists the student's semesters, latest first, for use in
+// FetchOptions.
+func (c *Client) Semesters(ctx context.Context) ([]Semester, error) {
+	return features.FetchSemesters(c.context(ctx), c.session)
+}
+
+// Profile returns the student's personal and academic details.
+func (c *Client) Profile(ctx context.Context) (StudentDetails, error) {
+	return features.FetchProfile(c.context(ctx), c.session, FetchOptions{})
+}
+
+// Marks returns the marks for each course in a semester, by default the
+// latest one that has marks.
+func (c *Client) Marks(ctx context.Context, opts FetchOptions) ([]CourseMarksSummary, error) {
+	return features.FetchMarks(c.context(ctx), c.session, opts)
+}
+
+// Attendance returns the attendance for each course in a semester, by
+// default the latest one that has attendance.
+func (c *Client) Attendance(ctx context.Context, opts FetchOptions) ([]AttendanceRecord, error) {
+	return features.FetchAttendance(c.context(ctx), c.session, opts)
+}
+
+// Timetable returns a semester's weekly classes, by default the latest
+// semester that has a timetable.
+func (c *Client) Timetable(ctx context.Context, opts FetchOptions) (Timetable, error) {
+	return features.FetchTimetable(c.context(ctx), c.session, opts)
+}
+
+// Exams returns every exam scheduled in a semester, past ones included, by
+// default the latest semester that has a schedule.
+func (c *Client) Exams(ctx context.Context, opts FetchOptions) ([]ExamEvent, error) {
+	return features.FetchExams(c.context(ctx), c.session, opts)
+}
+
+// DAs returns every digital assignment for each course in a semester, by
+// default the latest one that has courses.
+func (c *Client) DAs(ctx context.Context, opts FetchOptions) ([]SubjectDAs, error) {
+	return features.FetchDAs(c.context(ctx), c.session, opts)
+}
+
+// PendingAssignments returns the digital assignments that are still due and
+// have not been uploaded.
+func (c *Client) PendingAssignments(ctx context.Context, opts FetchOptions) ([]AssignmentSummary, error) {
+	return features.FetchPendingAssignments(c.context(ctx), c.session, opts)
+}
+
+// Grades returns a semester's grades and GPA, by default the latest
+// semester that has been graded.
+func (c *Client) Grades(ctx context.Context, opts FetchOptions) (SemesterGrades, error) {
+	return features.FetchGrades(c.context(ctx), c.session, opts)
+}
+
+// CGPA returns the student's CGPA, credits and grade distribution.
+func (c *Client) CGPA(ctx context.Context) (CGPASnapshot, error) {
+	return features.FetchCGPA(c.context(ctx), c.session, FetchOptions{})
+}
+
+// Receipts returns the student's fee payments.
+func (c *Client) Receipts(ctx context.Context) ([]Receipt, error) {
+	return features.FetchReceipts(c.context(ctx), c.session, FetchOptions{})
+}
+
+// LibraryDues returns the student's outstanding library payments.
+func (c *Client) LibraryDues(ctx context.Context) ([]LibraryDue, error) {
+	return features.FetchLibraryDues(c.context(ctx), c.session, FetchOptions{})
+}
+
+// Hostel returns the student's accommodation details.
+func (c *Client) Hostel(ctx context.Context) (HostelInfo, error) {
+	return features.FetchHostelInfo(c.context(ctx), c.session, FetchOptions{})
+}
+
+// NightSlips returns the student's late-hour permission requests.
+func (c *Client) NightSlips(ctx context.Context) ([]NightSlip, error) {
+	return features.FetchNightSlips(c.context(ctx), c.session, FetchOptions{})
+}
+
+// Leaves returns the student's hostel leave applications.
+func (c *Client) Leaves(ctx context.Context) ([]LeaveApplication, error) {
+	return features.FetchLeaves(c.context(ctx), c.session, FetchOptions{})
+}
+
+// Messages returns the messages faculty have posted to the student's
+// classes in a semester.
+func (c *Client) Messages(ctx context.Context, opts FetchOptions) ([]ClassMessage, error) {
+	return features.FetchClassMessages(c.context(ctx), c.session, opts)
+}
+
+// Courses lists the courses on the consolidated course page, in every
+// semester, for use with CourseMaterials.
+func (c *Client) Courses(ctx context.Context) ([]CoursePageCourse, error) {
+	return features.FetchCoursePageCourses(c.context(ctx), c.session)
+}
+
+// CourseMaterials returns the material each faculty has uploaded for a
+// course from Courses.
+func (c *Client) CourseMaterials(ctx context.Context, course Course) ([]FacultyMaterials, error) {
+	return features.FetchCourseMaterials(c.context(ctx), c.session, course)
+}
+
+// ArchiveCourses lists the courses on the course page VTOP kept before the
+// consolidated one, for the semester in opts.
+func (c *Client) ArchiveCourses(ctx context.Context, opts FetchOptions) ([]Course, error) {
+	return features.FetchArchiveCourses(c.context(ctx), c.session, opts)
+}
+
+// ArchiveFaculties lists everyone who taught a course from ArchiveCourses
+// in the semester in opts.
+func (c *Client) ArchiveFaculties(ctx context.Context, opts FetchOptions, course Course) ([]ArchiveFaculty, error) {
+	return features.FetchArchiveFaculties(c.context(ctx), c.session, opts, course)
+}
+
+// ArchiveMaterials returns the material a faculty from ArchiveFaculties
+// uploaded for their class.
+func (c *Client) ArchiveMaterials(ctx context.Context, faculty ArchiveFaculty) ([]CourseMaterial, error) {
+	return features.FetchArchiveMaterials(c.context(ctx), c.session, faculty)
+}
+
+// ClassGroups lists the class groups with an academic calendar in the
+// semester in opts.
+func (c *Client) ClassGroups(ctx context.Context, opts FetchOptions) ([]ClassGroup, error) {
+	return features.FetchClassGroups(c.context(ctx), c.session, opts)
+}
+
+// Calendar returns a class group's academic calendar for the semester in
+// opts, a month at a time.
+func (c *Client) Calendar(ctx context.Context, opts FetchOptions, group ClassGroup) ([]CalendarMonth, error) {
+	return features.FetchCalendar(c.context(ctx), c.session, opts, group)
+}
+
+// Facilities returns the physical education facilities open for
+// registration and the student's registrations.
+func (c *Client) Facilities(ctx context.Context) (Facilities, error) {
+	return features.FetchFacilities(c.context(ctx), c.session)
+}
+
+// RegisterFacility registers the student for a facility from Facilities and
+// returns VTOP's confirmation.
+func (c *Client) RegisterFacility(ctx context.Context, facility Facility) (string, error) {
+	return features.RegisterFacility(c.context(ctx), c.session, facility)
+}
+
+// SyllabusCourses lists the courses in the student's curriculum, for use
+// with Syllabus.
+func (c *Client) SyllabusCourses(ctx context.Context) ([]SyllabusCourse, error) {
+	return features.FetchSyllabusCourses(c.context(ctx), c.session)
+}
+
+// Syllabus returns the syllabus PDF of a course from SyllabusCourses.
+func (c *Client) Syllabus(ctx context.Context, course SyllabusCourse) ([]byte, error) {
+	return features.FetchSyllabus(c.context(ctx), c.session, course)
+}
+
+// AllocationCategories lists the curriculum categories on the course
+// allocation page.
+func (c *Client) AllocationCategories(ctx context.Context) ([]Category, error) {
+	return features.FetchAllocationCategories(c.context(ctx), c.session)
+}
+
+// AllocationCourses lists the courses offered in a category from
+// AllocationCategories.
+func (c *Client) AllocationCourses(ctx context.Context, category Category) ([]Course, error) {
+	return features.FetchAllocationCourses(c.context(ctx), c.session, category)
+}
+
+// CourseAllocation returns the classes of a course from AllocationCourses,
+// with their slot, venue and faculty.
+func (c *Client) CourseAllocation(ctx context.Context, course Course) ([]CourseAllocationDetail, error) {
+	return features.FetchCourseAllocation(c.context(ctx), c.session, course)
+}
@@ -0,0 +1,87 @@
+// Package vtop is a client for VTOP, VIT's student portal, for programs
+// that want cli-top's data without its commands. Log in once, or resume a
+// saved session, then call the typed methods on Client:
+//
+//	client, err := vtop.Login(ctx, username, password)
+//	if err != nil {
+//		return err
+//	}
+//	marks, err := client.Marks(ctx, vtop.FetchOptions{})
+//
+// Methods never print; they return the data or an error. Failures VTOP
+// itself causes are *VtopError values that match ErrMaintenance,
+// ErrUnavailable, ErrSessionExpired or ErrEmptyResponse with errors.Is.
+//
+// Each Client has its own session and cookie jar, so a program can hold
+// clients for several students at once; they share the retry policy and
+// rate limiter. A client saves nothing locally unless Options.Store is set,
+// and an expired session is returned as ErrSessionExpired rather than
+// logged in again.
+package vtop
+
+import (
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/login"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+// Types returned by Client, defined with the rest of cli-top's data.
+type (
+	Session                = types.Session
+	Cookies                = types.Cookies
+	FetchOptions           = types.FetchOptions
+	Semester               = types.Semester
+	StudentDetails         = types.StudentDetails
+	CourseMarksSummary     = types.CourseMarksSummary
+	AttendanceRecord       = types.AttendanceRecord
+	Timetable              = types.Timetable
+	TimetableEntry         = types.TimetableEntry
+	ExamEvent              = types.ExamEvent
+	SubjectDAs             = types.SubjectDAs
+	AssignmentSummary      = types.AssignmentSummary
+	SemesterGrades         = types.SemesterGrades
+	CGPASnapshot           = types.CGPASnapshot
+	Receipt                = types.Receipt
+	LibraryDue             = types.LibraryDue
+	HostelInfo             = types.HostelInfo
+	NightSlip              = types.NightSlip
+	LeaveApplication       = types.LeaveApplication
+	ClassMessage           = types.ClassMessage
+	CoursePageCourse       = types.CoursePageCourse
+	Course                 = types.Course
+	FacultyMaterials       = types.FacultyMaterials
+	CourseMaterial         = types.CourseMaterial
+	ArchiveFaculty         = types.FacultyOld
+	ClassGroup             = types.ClassGroup
+	CalendarMonth          = types.CalendarMonth
+	CalendarDay            = types.CalendarDay
+	Facilities             = types.Facilities
+	Facility               = types.Facility
+	Registration           = types.Registration
+	SyllabusCourse         = types.SyllabusCourse
+	Category               = types.Category
+	CourseAllocationDetail = types.CourseAllocationDetail
+	VtopError              = helpers.VtopError
+)
+
+// What the academic calendar marks each CalendarDay as.
+const (
+	CalendarBlank         = types.CalendarBlank
+	CalendarHoliday       = types.CalendarHoliday
+	CalendarExam          = types.CalendarExam
+	CalendarInstructional = types.CalendarInstructional
+)
+
+// Errors returned by Client, for use with errors.Is.
+var (
+	ErrNotLoggedIn        = helpers.ErrNotLoggedIn
+	ErrMaintenance        = helpers.ErrMaintenance
+	ErrUnavailable        = helpers.ErrUnavailable
+	ErrSessionExpired     = helpers.ErrSessionExpired
+	ErrEmptyResponse      = helpers.ErrEmptyResponse
+	ErrInvalidCaptcha     = login.ErrInvalidCaptcha
+	ErrInvalidCredentials = login.ErrInvalidCredentials
+	ErrAccountLocked      = login.ErrAccountLocked
+	ErrCaptchaSolver      = login.ErrCaptchaSolver
+	ErrNetwork            = login.ErrNetwork
+)
@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvStore is the legacy backend that keeps the encrypted password and its key
+// side by side in cli-top-config.env. It is only kept for existing configs.
+type EnvStore struct {
+	configPath string
+}
+
+func NewEnvStore(opts Options) *EnvStore {
+	return &EnvStore{configPath: opts.ConfigPath}
+}
+
+func (e *EnvStore) Name() string {
+	return BackendEnv
+}
+
+func (e *EnvStore) Get(account string) (string, error) {
+	ciphertext := unquote(viper.GetString("PASSWORD"))
+	key := unquote(viper.GetString("KEY"))
+	if ciphertext == "" || key == "" {
+		return "", ErrNotFound
+	}
+	return decryptPassword(ciphertext, key)
+}
+
+func (e *EnvStore) Set(account string, password string) error {
+	key, err := generateAESKey()
+	if err != nil {
+		return err
+	}
+	ciphertext, err := encryptPassword(password, key)
+	if err != nil {
+		return err
+	}
+	viper.Set("PASSWORD", "\""+ciphertext+"\"")
+	viper.Set("KEY", "\""+key+"\"")
+	return viper.WriteConfigAs(e.configPath)
+}
+
+// Upgrade re-encrypts a password saved in the original AES-CFB format, once
+// a login has shown it to be correct. Passwords already in the current
+// format are left alone.
+func (e *EnvStore) Upgrade(account string, password string) error {
+	if !isLegacyCiphertext(unquote(viper.GetString("PASSWORD"))) {
+		return nil
+	}
+	return e.Set(account, password)
+}
+
+func (e *EnvStore) Delete(account string) error {
+	viper.Set("PASSWORD", "")
+	viper.Set("KEY", "")
+	return viper.WriteConfigAs(e.configPath)
+}
+
+func unquote(value string) string {
+	return strings.Trim(value, "\"")
+}
@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// passwordFormatV2 prefixes AES-256-GCM ciphertexts. Anything without a
+// version prefix is the original AES-CFB format and is upgraded on the next
+// successful login.
+const passwordFormatV2 = "v2:"
+
+// ErrCredentialsCorrupted is returned when the env store cannot decrypt the
+// saved password.
+var ErrCredentialsCorrupted = errors.New("credentials corrupted, please re-login using the \"login\" command")
+
+func generateAESKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("error generating key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func encryptPassword(password string, key string) (string, error) {
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(rawKey) != 32 {
+		return "", fmt.Errorf("invalid encryption key")
+	}
+
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(password), nil)
+	return passwordFormatV2 + base64.URLEncoding.EncodeToString(sealed), nil
+}
+
+func decryptPassword(encryptedPassword string, key string) (string, error) {
+	if isLegacyCiphertext(encryptedPassword) {
+		return decryptLegacyPassword(encryptedPassword, key)
+	}
+
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(rawKey) != 32 {
+		return "", ErrCredentialsCorrupted
+	}
+
+	sealed, err := base64.URLEncoding.DecodeString(strings.TrimPrefix(encryptedPassword, passwordFormatV2))
+	if err != nil {
+		return "", ErrCredentialsCorrupted
+	}
+
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", ErrCredentialsCorrupted
+	}
+
+	password, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrCredentialsCorrupted
+	}
+
+	return string(password), nil
+}
+
+func isLegacyCiphertext(encryptedPassword string) bool {
+	return encryptedPassword != "" && !strings.HasPrefix(encryptedPassword, passwordFormatV2)
+}
+
+// decryptLegacyPassword reads the original AES-CFB format, where the key is
+// the first 32 characters of a base64 string used directly as key bytes.
+func decryptLegacyPassword(encryptedPassword string, key string) (string, error) {
+	decoded, err := base64.URLEncoding.DecodeString(encryptedPassword)
+	if err != nil {
+		return "", ErrCredentialsCorrupted
+	}
+
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", ErrCredentialsCorrupted
+	}
+
+	if len(decoded) < aes.BlockSize {
+		return "", ErrCredentialsCorrupted
+	}
+
+	iv := decoded[:aes.BlockSize]
+	password := decoded[aes.BlockSize:]
+
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(password, password)
+
+	return string(password), nil
+}
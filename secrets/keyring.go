@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeyringStore keeps passwords in the desktop keyring through the freedesktop
+// Secret Service D-Bus API, using libsecret's secret-tool as the client.
+type KeyringStore struct {
+	tool string
+}
+
+func NewKeyringStore() *KeyringStore {
+	return &KeyringStore{tool: "secret-tool"}
+}
+
+// KeyringAvailable reports whether secret-tool is installed and a session bus is reachable.
+func KeyringAvailable() bool {
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return false
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+func (k *KeyringStore) Name() string {
+	return BackendKeyring
+}
+
+func (k *KeyringStore) Get(account string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(k.tool, "lookup", "service", ServiceName, "account", account)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret-tool lookup failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	password := strings.TrimRight(stdout.String(), "\r\n")
+	if password == "" {
+		return "", ErrNotFound
+	}
+	return password, nil
+}
+
+func (k *KeyringStore) Set(account string, password string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(k.tool, "store", "--label", fmt.Sprintf("cli-top VTOP password (%s)", account), "service", ServiceName, "account", account)
+	cmd.Stdin = strings.NewReader(password)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (k *KeyringStore) Delete(account string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(k.tool, "clear", "service", ServiceName, "account", account)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("secret-tool clear failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	BackendKeyring = "keyring"
+	BackendVault   = "vault"
+	BackendEnv     = "env"
+)
+
+// ServiceName is the attribute used to group cli-top entries inside shared secret stores.
+const ServiceName = "cli-top"
+
+var (
+	ErrNotFound      = errors.New("no stored password found, please login using the \"login\" command")
+	ErrUnavailable   = errors.New("secret store backend is not available on this system")
+	ErrBadPassphrase = errors.New("incorrect vault passphrase")
+)
+
+// Store persists the VTOP password for an account outside of the plain config file.
+type Store interface {
+	Name() string
+	Get(account string) (string, error)
+	Set(account string, password string) error
+	Delete(account string) error
+}
+
+// Options carries everything the individual backends need to be constructed.
+type Options struct {
+	ConfigPath string
+	VaultPath  string
+	Passphrase func(confirm bool) (string, error)
+}
+
+// Backends lists the supported backend names in order of preference.
+func Backends() []string {
+	return []string{BackendKeyring, BackendVault, BackendEnv}
+}
+
+// Open returns the store for the named backend. An empty name picks the
+// system keyring when it is reachable and otherwise the encrypted vault; the
+// env file, which keeps the key beside the ciphertext, is only used when
+// asked for by name.
+func Open(backend string, opts Options) (Store, error) {
+	switch strings.ToLower(strings.TrimSpace(backend)) {
+	case "":
+		if KeyringAvailable() {
+			return NewKeyringStore(), nil
+		}
+		return NewVaultStore(opts), nil
+	case BackendKeyring:
+		if !KeyringAvailable() {
+			return nil, fmt.Errorf("%s: %w", BackendKeyring, ErrUnavailable)
+		}
+		return NewKeyringStore(), nil
+	case BackendVault:
+		return NewVaultStore(opts), nil
+	case BackendEnv:
+		return NewEnvStore(opts), nil
+	default:
+		return nil, fmt.Errorf("unknown secret store %q (expected one of %s)", backend, strings.Join(Backends(), ", "))
+	}
+}
+
+// Migrate moves the password for account from one store to another and
+// removes it from the source once the destination write has succeeded.
+func Migrate(from Store, to Store, account string) error {
+	if from.Name() == to.Name() {
+		return fmt.Errorf("credentials are already stored in %s", to.Name())
+	}
+
+	password, err := from.Get(account)
+	if err != nil {
+		return fmt.Errorf("reading from %s: %w", from.Name(), err)
+	}
+
+	if err := to.Set(account, password); err != nil {
+		return fmt.Errorf("writing to %s: %w", to.Name(), err)
+	}
+
+	if err := from.Delete(account); err != nil {
+		return fmt.Errorf("removing from %s: %w", from.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	vaultVersion = 1
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	vaultKeyLen  = 32
+)
+
+// vaultFile is the on-disk layout of the encrypted vault. Only the KDF
+// parameters and nonce are stored in the clear.
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// VaultStore keeps passwords in a local file encrypted with AES-GCM under a
+// key derived from a user passphrase with scrypt.
+type VaultStore struct {
+	path       string
+	passphrase func(confirm bool) (string, error)
+	cached     string
+}
+
+func NewVaultStore(opts Options) *VaultStore {
+	return &VaultStore{path: opts.VaultPath, passphrase: opts.Passphrase}
+}
+
+func (v *VaultStore) Name() string {
+	return BackendVault
+}
+
+func (v *VaultStore) Get(account string) (string, error) {
+	entries, err := v.load()
+	if err != nil {
+		return "", err
+	}
+	password, ok := entries[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return password, nil
+}
+
+func (v *VaultStore) Set(account string, password string) error {
+	entries, err := v.load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if entries == nil {
+		entries = map[string]string{}
+	}
+	entries[account] = password
+	return v.save(entries)
+}
+
+func (v *VaultStore) Delete(account string) error {
+	entries, err := v.load()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	delete(entries, account)
+	return v.save(entries)
+}
+
+func (v *VaultStore) getPassphrase(confirm bool) (string, error) {
+	if v.cached != "" {
+		return v.cached, nil
+	}
+	if passphrase := os.Getenv("CLI_TOP_VAULT_PASSPHRASE"); passphrase != "" {
+		v.cached = passphrase
+		return passphrase, nil
+	}
+	if v.passphrase == nil {
+		return "", errors.New("vault passphrase required, set CLI_TOP_VAULT_PASSPHRASE")
+	}
+	passphrase, err := v.passphrase(confirm)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("vault passphrase cannot be empty")
+	}
+	v.cached = passphrase
+	return passphrase, nil
+}
+
+func (v *VaultStore) load() (map[string]string, error) {
+	raw, err := os.ReadFile(v.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("vault file is corrupted: %w", err)
+	}
+	if file.Version != vaultVersion || file.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault format version %d", file.Version)
+	}
+
+	passphrase, err := v.getPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, vaultKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		v.cached = ""
+		return nil, ErrBadPassphrase
+	}
+
+	entries := map[string]string{}
+	if err := json.Unmarshal(plain, &entries); err != nil {
+		return nil, fmt.Errorf("vault contents are corrupted: %w", err)
+	}
+	return entries, nil
+}
+
+func (v *VaultStore) save(entries map[string]string) error {
+	_, statErr := os.Stat(v.path)
+	passphrase, err := v.getPassphrase(os.IsNotExist(statErr))
+	if err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{
+		Version: vaultVersion,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, 16),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), file.Salt, file.N, file.R, file.P, vaultKeyLen)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	raw, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(v.path, raw, 0o600)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,254 @@
+// Package store keeps a local copy of every dataset cli-top fetches from
+// VTOP, so commands can run offline or reuse data that is recent enough, and
+// the versions of those datasets whose changes are worth tracking.
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrNotSaved is returned for a dataset that has never been fetched.
+var ErrNotSaved = errors.New("no saved copy")
+
+// Store is a bbolt database of datasets, one bucket per registration
+// number, each saved as JSON along with when it was fetched.
+type Store struct {
+	// Path is the database file. With no Path nothing is saved and nothing
+	// is served.
+	Path string
+	// MaxAge lets a dataset fetched within that long be reused instead of
+	// asking VTOP again. Zero always asks VTOP.
+	MaxAge time.Duration
+	// Offline serves every dataset from the store, however old, and never
+	// asks VTOP.
+	Offline bool
+
+	mu     sync.Mutex
+	oldest time.Time
+}
+
+// Default is the store used by the feature fetchers unless the context
+// names another with With; cmd sets it from --offline, --max-age and the
+// profile's data directory.
+var Default = &Store{}
+
+type storeKey struct{}
+
+// With returns a copy of ctx under which the feature fetchers save to and
+// read from s instead of Default.
+func With(ctx context.Context, s *Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, s)
+}
+
+// For returns the store for fetches made under ctx: the one given to With,
+// or Default.
+func For(ctx context.Context) *Store {
+	if s, ok := ctx.Value(storeKey{}).(*Store); ok {
+		return s
+	}
+	return Default
+}
+
+// Entry is a saved dataset.
+type Entry struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Enabled reports whether the store has a database to use.
+func (s *Store) Enabled() bool {
+	return s.Path != ""
+}
+
+// Get returns the dataset saved under key for regNo, or ErrNotSaved.
+func (s *Store) Get(regNo string, key string) (Entry, error) {
+	var entry Entry
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(regNo))
+		if b == nil {
+			return ErrNotSaved
+		}
+		data := b.Get([]byte(key))
+		if data == nil {
+			return ErrNotSaved
+		}
+		return json.Unmarshal(data, &entry)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		err = ErrNotSaved
+	}
+	return entry, err
+}
+
+// Put saves v as the dataset under key for regNo, fetched now.
+func (s *Store) Put(regNo string, key string, v any) error {
+	return s.put(regNo, key, v, false)
+}
+
+// PutVersion saves v like Put, and also adds it to key's history as a new
+// version when it differs from the newest version there.
+func (s *Store) PutVersion(regNo string, key string, v any) error {
+	return s.put(regNo, key, v, true)
+}
+
+func (s *Store) put(regNo string, key string, v any, versioned bool) error {
+	if !s.Enabled() {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	entry, err := json.Marshal(Entry{FetchedAt: time.Now(), Data: data})
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(regNo))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(key), entry); err != nil || !versioned {
+			return err
+		}
+		return addVersion(b, key, data, entry)
+	})
+}
+
+// historyBucket holds, inside a registration number's bucket, one bucket of
+// versions per key, numbered from 1 in the order they were saved.
+const historyBucket = "history"
+
+func addVersion(b *bolt.Bucket, key string, data []byte, entry []byte) error {
+	history, err := b.CreateBucketIfNotExists([]byte(historyBucket))
+	if err != nil {
+		return err
+	}
+	versions, err := history.CreateBucketIfNotExists([]byte(key))
+	if err != nil {
+		return err
+	}
+	if _, last := versions.Cursor().Last(); last != nil {
+		var newest Entry
+		if err := json.Unmarshal(last, &newest); err == nil && bytes.Equal(newest.Data, data) {
+			return nil
+		}
+	}
+	n, err := versions.NextSequence()
+	if err != nil {
+		return err
+	}
+	return versions.Put(binary.BigEndian.AppendUint64(nil, n), entry)
+}
+
+// Version is one saved version of a dataset.
+type Version struct {
+	// Key is the key the dataset was saved under, such as "marks" or
+	// "marks/VL20242505".
+	Key    string `json:"key"`
+	Number uint64 `json:"version"`
+	Entry
+}
+
+// History returns every saved version of dataset for regNo, under the key
+// dataset itself and under each "dataset/..." key, oldest first.
+func (s *Store) History(regNo string, dataset string) ([]Version, error) {
+	var versions []Version
+	err := s.view(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(regNo))
+		if b == nil {
+			return nil
+		}
+		history := b.Bucket([]byte(historyBucket))
+		if history == nil {
+			return nil
+		}
+		return history.ForEachBucket(func(k []byte) error {
+			key := string(k)
+			if key != dataset && !strings.HasPrefix(key, dataset+"/") {
+				return nil
+			}
+			return history.Bucket(k).ForEach(func(n, data []byte) error {
+				v := Version{Key: key, Number: binary.BigEndian.Uint64(n)}
+				if err := json.Unmarshal(data, &v.Entry); err != nil {
+					return err
+				}
+				versions = append(versions, v)
+				return nil
+			})
+		})
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].FetchedAt.Before(versions[j].FetchedAt)
+	})
+	return versions, err
+}
+
+// Served notes that a dataset fetched at t was shown instead of asking
+// VTOP.
+func (s *Store) Served(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oldest.IsZero() || t.Before(s.oldest) {
+		s.oldest = t
+	}
+}
+
+// OldestServed returns when the oldest dataset served from the store in this
+// process was fetched, or zero if everything came from VTOP, so a command
+// can say how old its output is.
+func (s *Store) OldestServed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.oldest
+}
+
+// openTimeout bounds the wait for another cli-top process holding the
+// database.
+const openTimeout = 2 * time.Second
+
+// view runs fn in a read-only transaction. A missing database reports
+// os.ErrNotExist rather than being created.
+func (s *Store) view(fn func(*bolt.Tx) error) error {
+	if !s.Enabled() {
+		return ErrNotSaved
+	}
+	if _, err := os.Stat(s.Path); err != nil {
+		return err
+	}
+	db, err := bolt.Open(s.Path, 0o600, &bolt.Options{Timeout: openTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", s.Path, err)
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// update runs fn in a read-write transaction, creating the database.
+func (s *Store) update(fn func(*bolt.Tx) error) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o700); err != nil {
+		return err
+	}
+	db, err := bolt.Open(s.Path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", s.Path, err)
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
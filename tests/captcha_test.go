@@ -0,0 +1,339 @@
+package tests
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+// captchaCorpusDir holds real VTOP captchas saved by "cli-top dev captcha
+// collect", named after the answer VTOP accepted.
+const captchaCorpusDir = "testdata/captcha"
+
+// minCaptchaCorpus is the fewest captchas worth measuring the model on.
+const minCaptchaCorpus = 50
+
+// Minimum scores of the built-in model on the corpus.
+const (
+	minCaptchaCharAccuracy = 0.90
+	minCaptchaSolveRate    = 0.60
+	// Characters read with at least MinCaptchaConfidence must be right this
+	// often, or the refetch threshold is not doing its job.
+	minConfidentAccuracy = 0.95
+	// maxCaptchaOverconfidence bounds how far the model's confidence runs
+	// above how often it is right, averaged over confidence bins. Being less
+	// sure than it should only costs a refetch, so that side is not bounded.
+	maxCaptchaOverconfidence = 0.05
+)
+
+// loadCaptchaCorpus returns the labelled real captchas, failing the test if
+// fewer than minCaptchaCorpus have been checked in.
+func loadCaptchaCorpus(t testing.TB) []helpers.CaptchaSample {
+	t.Helper()
+	samples, skipped, err := helpers.LoadCaptchaSamples(captchaCorpusDir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(skipped) > 0 {
+		t.Fatalf("Corpus has unlabelled or malformed files: %v", skipped)
+	}
+	if len(samples) < minCaptchaCorpus {
+		t.Fatalf("%d of the %d real captchas needed are in %s; add more saved by \"cli-top dev captcha collect\"",
+			len(samples), minCaptchaCorpus, captchaCorpusDir)
+	}
+	return samples
+}
+
+func TestCaptchaModelAccuracy(t *testing.T) {
+	samples := loadCaptchaCorpus(t)
+
+	// Go through ModelSolver with the raw JPEG so decoding, saturation,
+	// preImg and matMul are all exercised together.
+	solved, correct, total := 0, 0, 0
+	for _, sample := range samples {
+		data, err := os.ReadFile(sample.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := helpers.ModelSolver{}.Solve(data)
+		if err != nil {
+			t.Fatalf("%s: %v", sample.Path, err)
+		}
+		if got == sample.Label {
+			solved++
+		}
+		for i := range sample.Label {
+			total++
+			if got[i] == sample.Label[i] {
+				correct++
+			}
+		}
+	}
+
+	charAccuracy := float64(correct) / float64(total)
+	solveRate := float64(solved) / float64(len(samples))
+	t.Logf("Read %.1f%% of characters and %d/%d captchas", 100*charAccuracy, solved, len(samples))
+
+	if charAccuracy < minCaptchaCharAccuracy || solveRate < minCaptchaSolveRate {
+		eval := helpers.EvaluateCaptchaModel(helpers.BuiltinCaptchaModel(), samples)
+		for _, c := range eval.TopConfusions(5) {
+			t.Logf("%c read as %c: %d", c.Expected, c.Predicted, c.Count)
+		}
+		t.Errorf("Expected at least %.0f%% of characters and %.0f%% of captchas, got %.1f%% and %.1f%%",
+			100*minCaptchaCharAccuracy, 100*minCaptchaSolveRate, 100*charAccuracy, 100*solveRate)
+	}
+}
+
+func TestCaptchaConfidenceCalibration(t *testing.T) {
+	samples := loadCaptchaCorpus(t)
+	model := helpers.BuiltinCaptchaModel()
+
+	// Ten equal-width confidence bins, each tracking how many characters
+	// fell in it, their total confidence and how many were right.
+	var bins [10]struct {
+		count, correct int
+		confidence     float64
+	}
+	confident, confidentCorrect := 0, 0
+	var rightConfidence, wrongConfidence float64
+	var right, wrong int
+	for _, sample := range samples {
+		result := model.Predict(sample.Image)
+		for i, p := range result.Confidence() {
+			ok := result.Text[i] == sample.Label[i]
+			bin := &bins[min(int(p*10), 9)]
+			bin.count++
+			bin.confidence += float64(p)
+			if ok {
+				bin.correct++
+				right++
+				rightConfidence += float64(p)
+			} else {
+				wrong++
+				wrongConfidence += float64(p)
+			}
+			if p >= helpers.MinCaptchaConfidence {
+				confident++
+				if ok {
+					confidentCorrect++
+				}
+			}
+		}
+	}
+
+	var overconfidence float64
+	total := right + wrong
+	for _, bin := range bins {
+		if bin.count > 0 {
+			gap := (bin.confidence - float64(bin.correct)) / float64(bin.count)
+			overconfidence += math.Max(gap, 0) * float64(bin.count) / float64(total)
+		}
+	}
+	t.Logf("Overconfidence %.3f over %d characters", overconfidence, total)
+	if overconfidence > maxCaptchaOverconfidence {
+		t.Errorf("Expected confidence to exceed accuracy by at most %.2f on average, got %.3f", maxCaptchaOverconfidence, overconfidence)
+	}
+
+	if confident > 0 {
+		if acc := float64(confidentCorrect) / float64(confident); acc < minConfidentAccuracy {
+			t.Errorf("Expected characters above the %.2f threshold to be right %.0f%% of the time, got %.1f%%",
+				helpers.MinCaptchaConfidence, 100*minConfidentAccuracy, 100*acc)
+		}
+	}
+	if right > 0 && wrong > 0 && wrongConfidence/float64(wrong) >= rightConfidence/float64(right) {
+		t.Errorf("Expected wrong characters to get lower confidence than right ones, got %.2f and %.2f",
+			wrongConfidence/float64(wrong), rightConfidence/float64(right))
+	}
+}
+
+// noiseImage is a 200x40 image of random pixels drawn from seed.
+func noiseImage(seed int64) image.Image {
+	rng := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, 200, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+		}
+	}
+	return img
+}
+
+// noiseCaptcha is a 200x40 JPEG of random pixels, enough to time the solver
+// and check the shape of its output without a corpus.
+func noiseCaptcha(t testing.TB) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, noiseImage(1), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCaptchaConfidenceMatchesText(t *testing.T) {
+	img, err := jpeg.Decode(bytes.NewReader(noiseCaptcha(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := helpers.BuiltinCaptchaModel().Predict(img)
+	if len(result.Probabilities) != 6 || len(result.Text) != 6 {
+		t.Fatalf("Expected 6 characters with probabilities, got %q and %d", result.Text, len(result.Probabilities))
+	}
+	for i, p := range result.Confidence() {
+		if p <= 0 || p > 1 {
+			t.Errorf("Character %d has confidence %v outside (0, 1]", i+1, p)
+		}
+	}
+	if i, p := result.Weakest(); i < 0 || p != result.Confidence()[i] {
+		t.Errorf("Weakest returned %d, %v for confidences %v", i, p, result.Confidence())
+	}
+}
+
+// constantModel reads every character as label, whatever the image.
+func constantModel(label byte) helpers.CaptchaModel {
+	const labels = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	model := helpers.CaptchaModel{Weights: make([][]float32, len(helpers.BuiltinCaptchaModel().Weights)), Biases: make([]float32, len(labels))}
+	for i := range model.Weights {
+		model.Weights[i] = make([]float32, len(labels))
+	}
+	model.Biases[strings.IndexByte(labels, label)] = 1
+	return model
+}
+
+func TestEvaluateCaptchaModel(t *testing.T) {
+	samples := []helpers.CaptchaSample{
+		{Label: "AAAAAA", Image: noiseImage(1)},
+		{Label: "ABBCAA", Image: noiseImage(2)},
+	}
+	eval := helpers.EvaluateCaptchaModel(constantModel('A'), samples)
+
+	if eval.Samples != 2 || eval.Solved != 1 {
+		t.Errorf("Expected 1 of 2 captchas solved, got %d of %d", eval.Solved, eval.Samples)
+	}
+	wantChars := map[byte]helpers.CharAccuracy{'A': {Total: 9, Correct: 9}, 'B': {Total: 2}, 'C': {Total: 1}}
+	if len(eval.Chars) != len(wantChars) {
+		t.Errorf("Expected accuracy for %d characters, got %d", len(wantChars), len(eval.Chars))
+	}
+	for char, want := range wantChars {
+		if got := eval.Chars[char]; got == nil || *got != want {
+			t.Errorf("Expected %c to be %+v, got %+v", char, want, got)
+		}
+	}
+	if got := eval.Accuracy(); got != 0.75 {
+		t.Errorf("Expected 9 of 12 characters right, got accuracy %v", got)
+	}
+	want := []helpers.CharConfusion{{Expected: 'B', Predicted: 'A', Count: 2}, {Expected: 'C', Predicted: 'A', Count: 1}}
+	if got := eval.TopConfusions(5); !slices.Equal(got, want) {
+		t.Errorf("Expected confusions %v, got %v", want, got)
+	}
+}
+
+func TestTrainCaptchaModel(t *testing.T) {
+	samples := []helpers.CaptchaSample{
+		{Label: "ABCDEF", Image: noiseImage(1)},
+		{Label: "234567", Image: noiseImage(2)},
+		{Label: "XYZ89H", Image: noiseImage(3)},
+	}
+	var losses []float64
+	model := helpers.TrainCaptchaModel(samples, helpers.CaptchaTrainOptions{
+		Epochs:       20,
+		LearningRate: 0.05,
+		Seed:         1,
+		Progress:     func(epoch int, loss float64) { losses = append(losses, loss) },
+	})
+
+	if len(losses) != 20 || losses[len(losses)-1] >= losses[0] {
+		t.Errorf("Expected the loss to fall over 20 epochs, got %v", losses)
+	}
+	eval := helpers.EvaluateCaptchaModel(model, samples)
+	if eval.Solved != len(samples) {
+		t.Errorf("Expected the model to learn all %d training captchas, solved %d", len(samples), eval.Solved)
+	}
+
+	start := helpers.BuiltinCaptchaModel()
+	before := start.Weights[0][0]
+	helpers.TrainCaptchaModel(samples, helpers.CaptchaTrainOptions{Epochs: 1, LearningRate: 0.05, Start: &start})
+	if start.Weights[0][0] != before {
+		t.Error("Expected training from a start model to leave it unchanged")
+	}
+}
+
+func TestWriteCaptchaWeights(t *testing.T) {
+	model := helpers.TrainCaptchaModel([]helpers.CaptchaSample{{Label: "ABCDEF", Image: noiseImage(1)}},
+		helpers.CaptchaTrainOptions{Epochs: 1, LearningRate: 0.05})
+	var buf bytes.Buffer
+	if err := helpers.WriteCaptchaWeights(&buf, model, "helpers"); err != nil {
+		t.Fatal(err)
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Expected valid Go source, got %v", err)
+	}
+	if !bytes.Equal(formatted, buf.Bytes()) {
+		t.Error("Expected the weights file to be gofmt-clean")
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "weights.go", buf.Bytes(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Name.Name != "helpers" {
+		t.Errorf("Expected package helpers, got %s", file.Name.Name)
+	}
+	vars := map[string]*ast.CompositeLit{}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.VAR {
+			for _, spec := range gen.Specs {
+				value := spec.(*ast.ValueSpec)
+				vars[value.Names[0].Name], _ = value.Values[0].(*ast.CompositeLit)
+			}
+		}
+	}
+	weights, biases := vars["weights"], vars["biases"]
+	if weights == nil || biases == nil {
+		t.Fatalf("Expected weights and biases declarations, got %v", vars)
+	}
+	if len(weights.Elts) != len(model.Weights) || len(biases.Elts) != len(model.Biases) {
+		t.Fatalf("Expected %d weight rows and %d biases, got %d and %d",
+			len(model.Weights), len(model.Biases), len(weights.Elts), len(biases.Elts))
+	}
+	for i, row := range weights.Elts {
+		if n := len(row.(*ast.CompositeLit).Elts); n != len(model.Biases) {
+			t.Fatalf("Expected %d columns in weight row %d, got %d", len(model.Biases), i, n)
+		}
+	}
+}
+
+func BenchmarkModelSolver(b *testing.B) {
+	data := noiseCaptcha(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := (helpers.ModelSolver{}).Solve(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCaptchaPredict(b *testing.B) {
+	img, err := jpeg.Decode(bytes.NewReader(noiseCaptcha(b)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	model := helpers.BuiltinCaptchaModel()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		model.Predict(img)
+	}
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+func TestCookieJarPersistsRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	jar := helpers.CookieJar()
+	if err := jar.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jar.Clear() })
+
+	jar.SetCookies(helpers.VtopURL, []*http.Cookie{
+		{Name: "JSESSIONID", Value: "first", Path: "/vtop"},
+		{Name: "SERVERID", Value: "s1", Path: "/"},
+	})
+	jar.SetCookies(helpers.VtopURL, []*http.Cookie{{Name: "JSESSIONID", Value: "rotated", Path: "/vtop"}})
+
+	if err := jar.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	cookies := helpers.VtopCookies()
+	if cookies.JSESSIONID != "rotated" || cookies.SERVERID != "s1" {
+		t.Errorf("Expected rotated session to be reloaded from disk, got %+v", cookies)
+	}
+
+	if err := jar.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if cookies := helpers.VtopCookies(); cookies.JSESSIONID != "" {
+		t.Errorf("Expected Clear to remove saved cookies, got %+v", cookies)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/features"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+var replaySession = types.Session{
+	RegNo:   "REPLAY",
+	Cookies: types.Cookies{SERVERID: "replay", CSRF: "replay", JSESSIONID: "replay"},
+}
+
+// replayPage serves page for a POST of form to path.
+func replayPage(t *testing.T, path string, form string, page string) {
+	t.Helper()
+	dir := t.TempDir()
+	ex := helpers.FixtureExchange{
+		Request:  helpers.FixtureRequest{Method: "POST", URL: "https://vtop.vit.ac.in" + path, Body: form},
+		Response: helpers.FixtureResponse{Status: 200, Body: page},
+	}
+	data, _ := json.Marshal(ex)
+	if err := os.WriteFile(filepath.Join(dir, "0001.json"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := helpers.ReplayFixtures(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(helpers.StopFixtures)
+}
+
+func TestFetchReceipts(t *testing.T) {
+	replayPage(t, "/vtop/finance/getStudentReceipts", "verifyMenu=true", `<table class="table-bordered"><tbody>
+			<tr><th>Invoice</th><th>Receipt</th><th>Date</th><th>Amount</th><th></th></tr>
+			<tr><td>INV1</td><td>R1</td><td>01-Jan-2024</td><td>1,20,000.00</td><td>VIEW</td></tr>
+		</tbody></table>`)
+
+	receipts, err := features.FetchReceipts(context.Background(), replaySession, types.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.Receipt{{InvoiceNumber: "INV1", ReceiptNumber: "R1", Date: "01-Jan-2024", Amount: 120000}}
+	if len(receipts) != 1 || receipts[0] != want[0] {
+		t.Errorf("Expected %+v, got %+v", want, receipts)
+	}
+}
+
+func TestFetchGrades(t *testing.T) {
+	replayPage(t, "/vtop/examinations/examGradeView/doStudentGradeView", "semesterSubId=VL20242505", `<table class="table table-hover table-bordered"><tbody>
+			<tr><td>1</td><td>CSE3001</td><td>Software Engineering</td><td>Embedded Theory</td><td></td><td></td><td></td><td>4</td><td>RG</td><td>78</td><td>A</td><td></td></tr>
+			<tr><td colspan="12">GPA : 8.61</td></tr>
+		</tbody></table>
+		<span style='font-size: 18px; font-weight: bold;'>GPA : 8.61</span>`)
+
+	opts := types.FetchOptions{Semester: types.Semester{SemID: "VL20242505", SemName: "Fall Semester 2024-25"}}
+	grades, err := features.FetchGrades(context.Background(), replaySession, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grades.Semester != opts.Semester.SemName || grades.GPA != 8.61 || len(grades.Courses) != 1 {
+		t.Fatalf("Unexpected grades %+v", grades)
+	}
+	want := types.CourseGrade{CourseCode: "CSE3001", CourseTitle: "Software Engineering", CourseType: "Embedded Theory", Credits: 4, Total: 78, Grading: "Relative", Grade: "A"}
+	if grades.Courses[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, grades.Courses[0])
+	}
+}
+
+func TestFetchRequiresLogin(t *testing.T) {
+	if _, err := features.FetchMarks(context.Background(), types.Session{RegNo: "REPLAY"}, types.FetchOptions{}); !errors.Is(err, helpers.ErrNotLoggedIn) {
+		t.Errorf("Expected ErrNotLoggedIn, got %v", err)
+	}
+}
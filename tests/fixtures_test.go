@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+func TestRecordAndReplayFixtures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "secret-session"})
+		w.Write([]byte(`<input type="hidden" name="_csrf" value="secret-token"><td>` + r.Form.Get("semesterSubId") + `</td>`))
+	}))
+	dir := t.TempDir()
+	t.Cleanup(helpers.StopFixtures)
+
+	if err := helpers.RecordFixtures(dir); err != nil {
+		t.Fatal(err)
+	}
+	form := "semesterSubId=VL2024&authorizedID=21BCE0001&_csrf=secret-token"
+	recorded, err := helpers.Vtop.Post(context.Background(), srv.URL+"/vtop/examinations/doSearchExamScheduleForStudent", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one recording, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, secret := range []string{"secret-session", "secret-token", "21BCE0001"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q to be redacted from the recording", secret)
+		}
+	}
+
+	if err := helpers.ReplayFixtures(dir); err != nil {
+		t.Fatal(err)
+	}
+	// A new session sends a different token; the recording must still match.
+	replayed, err := helpers.Vtop.Post(context.Background(), srv.URL+"/vtop/examinations/doSearchExamScheduleForStudent", "semesterSubId=VL2024&authorizedID=REPLAY&_csrf=replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(replayed), "<td>VL2024</td>") || len(replayed) != len(recorded)-len("secret-token")+len(helpers.Redacted) {
+		t.Errorf("Expected the recorded page, got %q", replayed)
+	}
+	if _, err := helpers.Vtop.Post(context.Background(), srv.URL+"/vtop/examinations/doSearchExamScheduleForStudent", "semesterSubId=VL2025"); !errors.Is(err, helpers.ErrNotRecorded) {
+		t.Errorf("Expected a request that was never recorded to fail, got %v", err)
+	}
+}
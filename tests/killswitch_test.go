@@ -2,8 +2,6 @@ package tests
 
 import (
 	"bytes"
-	"cli-top/helpers"
-	"cli-top/types"
 	"encoding/base64"
 	"encoding/json"
 	"image"
@@ -14,6 +12,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
 )
 
 type MockServer struct {
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/login"
+)
+
+// fixedSolver answers every captcha with the same text.
+type fixedSolver struct{}
+
+func (fixedSolver) Name() string                 { return "fixed" }
+func (fixedSolver) Solve([]byte) (string, error) { return "ABC123", nil }
+
+// loginServer stands in for VTOP's login flow. Each prelogin/setup request
+// takes the next page from captchas, true for a page with a captcha, and
+// each login POST the next message from results for the error page; both
+// repeat their last entry once used up.
+type loginServer struct {
+	mu       sync.Mutex
+	captchas []bool
+	results  []string
+	setups   int
+	posts    int
+}
+
+func (s *loginServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/latest.json":
+		w.Write([]byte(`{"version":"test","killSwitch":0}`))
+	case "/":
+		http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "mock"})
+		w.Write([]byte(`<script>var csrfValue = /*x*/'mock-csrf';</script>`))
+	case "/vtop/prelogin/setup":
+		page := `<div id="captchaBlock"></div>`
+		if s.captchas[min(s.setups, len(s.captchas)-1)] {
+			page = `<div id="captchaBlock"><img src="data:image/jpeg;base64,AAAA"></div>`
+		}
+		s.setups++
+		w.Write([]byte(page))
+	case "/vtop/login":
+		s.posts++
+		http.Redirect(w, r, "/vtop/login/error", http.StatusFound)
+	case "/vtop/login/error":
+		w.Write([]byte(s.results[min(s.posts-1, len(s.results)-1)]))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// useLoginServer sends VTOP requests made under the returned context to s,
+// solving every captcha as ABC123 without waiting between attempts.
+func useLoginServer(t *testing.T, s *loginServer) context.Context {
+	t.Helper()
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	savedSolver, savedBackoff, savedAttempts, savedLimiter := helpers.ActiveCaptchaSolver, login.CaptchaBackoff, login.MaxCaptchaAttempts, helpers.Limiter
+	savedURL := helpers.GetLatestJSONURL()
+	helpers.ActiveCaptchaSolver = fixedSolver{}
+	login.CaptchaBackoff = time.Millisecond
+	helpers.Limiter = &helpers.RateLimiter{Rate: 0, Burst: 1}
+	helpers.SetLatestJSONURL(srv.URL + "/latest.json")
+	t.Cleanup(func() {
+		helpers.ActiveCaptchaSolver, login.CaptchaBackoff, login.MaxCaptchaAttempts, helpers.Limiter = savedSolver, savedBackoff, savedAttempts, savedLimiter
+		helpers.SetLatestJSONURL(savedURL)
+	})
+
+	toServer := roundTripper(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host, r.Host = target.Scheme, target.Host, ""
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	client := helpers.NewVtopClient().WithHTTP(&http.Client{Transport: toServer})
+	return helpers.WithVtopClient(context.Background(), client)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestLoginStopsOnAccountErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		page string
+		want error
+	}{
+		{name: "invalid credentials", page: "Invalid LoginId/Password", want: login.ErrInvalidCredentials},
+		{name: "account locked", page: "Maximum Fail Attempts Reached", want: login.ErrAccountLocked},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &loginServer{captchas: []bool{true}, results: []string{tc.page}}
+			ctx := useLoginServer(t, s)
+			login.MaxCaptchaAttempts = 5
+
+			_, err := login.Login(ctx, "21BCE0001", "secret")
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+			if s.posts != 1 {
+				t.Errorf("Expected the password to be sent once, got %d login requests", s.posts)
+			}
+		})
+	}
+}
+
+func TestLoginRetriesInvalidCaptcha(t *testing.T) {
+	s := &loginServer{captchas: []bool{true}, results: []string{"Invalid Captcha", ""}}
+	ctx := useLoginServer(t, s)
+	login.MaxCaptchaAttempts = 5
+
+	if _, err := login.Login(ctx, "21BCE0001", "secret"); err != nil {
+		t.Fatalf("Expected the second captcha to log in, got %v", err)
+	}
+	if s.posts != 2 || s.setups != 2 {
+		t.Errorf("Expected a fresh captcha after the rejected one, got %d login requests and %d login pages", s.posts, s.setups)
+	}
+}
+
+func TestLoginGivesUpAfterCaptchaAttempts(t *testing.T) {
+	cases := []struct {
+		name      string
+		captchas  []bool
+		wantPosts int
+	}{
+		{name: "every captcha rejected", captchas: []bool{true}, wantPosts: 4},
+		{name: "no captcha on the page", captchas: []bool{false}, wantPosts: 0},
+		{name: "rejected and missing captchas", captchas: []bool{false, true, false, true}, wantPosts: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &loginServer{captchas: tc.captchas, results: []string{"Invalid Captcha"}}
+			ctx := useLoginServer(t, s)
+			login.MaxCaptchaAttempts = 4
+
+			_, err := login.Login(ctx, "21BCE0001", "secret")
+			if !errors.Is(err, login.ErrInvalidCaptcha) || !strings.Contains(err.Error(), "gave up after 4 attempts") {
+				t.Errorf("Expected ErrInvalidCaptcha after 4 attempts, got %v", err)
+			}
+			if s.setups != login.MaxCaptchaAttempts || s.posts != tc.wantPosts {
+				t.Errorf("Expected %d login pages and %d login requests, got %d and %d",
+					login.MaxCaptchaAttempts, tc.wantPosts, s.setups, s.posts)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+func TestVtopClientUsesConfiguredProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+	t.Cleanup(func() { helpers.ConfigureNetwork(helpers.NetworkOptions{}) })
+
+	if err := helpers.ConfigureNetwork(helpers.NetworkOptions{Proxy: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	body, err := helpers.Vtop.Post(context.Background(), "http://vtop.invalid/vtop/content", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "ok" || proxied != "http://vtop.invalid/vtop/content" {
+		t.Errorf("Expected the request to go through the proxy, proxy saw %q", proxied)
+	}
+
+	for _, bad := range []string{"ftp://127.0.0.1:21", "127.0.0.1:1080"} {
+		if err := helpers.ConfigureNetwork(helpers.NetworkOptions{Proxy: bad}); err == nil {
+			t.Errorf("Expected proxy %q to be rejected", bad)
+		}
+	}
+	if err := helpers.ConfigureNetwork(helpers.NetworkOptions{Proxy: "socks5h://127.0.0.1:1080", DNSServer: "1.1.1.1"}); err != nil {
+		t.Errorf("Expected socks5h proxy and bare DNS server to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+func TestEncodeOutputFormats(t *testing.T) {
+	marks := []types.CourseMarksSummary{{
+		CourseCode:  "CSE3001",
+		CourseTitle: "Software Engineering",
+		Components: []types.CourseMarksComponent{
+			{Title: "CAT1", MaxMarks: 50, ScoredMarks: 42},
+			{Title: "CAT2", MaxMarks: 50, ScoredMarks: 38.5},
+		},
+		TotalScored: 32.1,
+	}}
+
+	var out bytes.Buffer
+	if err := helpers.Encode(&out, helpers.FormatJSON, marks); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []types.CourseMarksSummary
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded[0].Components[1].ScoredMarks != 38.5 {
+		t.Errorf("Expected JSON to round-trip, got %s (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	if err := helpers.Encode(&out, helpers.FormatCSV, marks); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "course_code,course_title,") || !strings.Contains(lines[0], ",components.title,") {
+		t.Errorf("Expected a header and one row per component, got:\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[2], "CSE3001,Software Engineering,") || !strings.Contains(lines[2], ",CAT2,50,") {
+		t.Errorf("Expected course columns repeated on each component row, got %q", lines[2])
+	}
+
+	out.Reset()
+	if err := helpers.Encode(&out, helpers.FormatYAML, marks); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "- course_code: CSE3001\n  course_title: Software Engineering\n") {
+		t.Errorf("Expected block YAML with json field names in order, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	var none []types.AttendanceRecord
+	if err := helpers.Encode(&out, helpers.FormatJSON, none); err != nil || strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("Expected an empty result to be [], got %q", out.String())
+	}
+
+	for _, v := range []any{(*types.HostelInfo)(nil), []*types.HostelInfo{nil}} {
+		out.Reset()
+		if err := helpers.Encode(&out, helpers.FormatCSV, v); err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 1 || lines[0] == "" {
+			t.Errorf("Expected %T with nothing in it to give only a header, got %q", v, out.String())
+		}
+	}
+}
+
+func TestTableRecords(t *testing.T) {
+	table := [][]string{
+		{"INVOICE NUMBER", "Amount", "75% Alert"},
+		{"INV-1", "\033[32m1,200\033[0m", "Attend 2 more"},
+	}
+	var out bytes.Buffer
+	if err := helpers.Encode(&out, helpers.FormatJSON, helpers.TableRecords(table)); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"invoice_number":"INV-1","amount":"1,200","75_alert":"Attend 2 more"}]`
+	var compact bytes.Buffer
+	json.Compact(&compact, out.Bytes())
+	if compact.String() != want {
+		t.Errorf("Expected %s, got %s", want, compact.String())
+	}
+
+	out.Reset()
+	helpers.Encode(&out, helpers.FormatTSV, helpers.TableRecords(table))
+	if out.String() != "invoice_number\tamount\t75_alert\nINV-1\t1,200\tAttend 2 more\n" {
+		t.Errorf("Unexpected TSV %q", out.String())
+	}
+
+	if _, err := helpers.ParseOutputFormat("xml"); err == nil {
+		t.Error("Expected an unknown format to be rejected")
+	}
+}
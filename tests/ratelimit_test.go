@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+func TestRateLimiterSharesBucketAcrossInvocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit.json")
+	first := &helpers.RateLimiter{Rate: 10, Burst: 2, Path: path}
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := first.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("Expected the burst to go through at once, took %s", elapsed)
+	}
+
+	// A later invocation starts from the saved bucket, not a full one.
+	second := &helpers.RateLimiter{Rate: 10, Burst: 2, Path: path}
+	if err := second.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected to wait for a token, went ahead after %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := second.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to stop with the context, got %v", err)
+	}
+
+	unlimited := &helpers.RateLimiter{Rate: 0, Burst: 1}
+	for i := 0; i < 100; i++ {
+		unlimited.Wait(context.Background())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a zero rate to disable the limit, took %s", elapsed)
+	}
+}
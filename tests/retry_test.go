@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+func TestVtopClientRetriesTransientFailures(t *testing.T) {
+	saved := helpers.Retry
+	helpers.Retry = helpers.RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}
+	t.Cleanup(func() { helpers.Retry = saved })
+
+	var calls int
+	var failures []func(w http.ResponseWriter)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= len(failures) {
+			failures[calls-1](w)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	unavailable := func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) }
+	maintenance := func(w http.ResponseWriter) { w.Write([]byte("<h1>VTOP is under maintenance</h1>")) }
+	reset := func(w http.ResponseWriter) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}
+
+	cases := []struct {
+		name      string
+		failures  []func(w http.ResponseWriter)
+		mutating  bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "5xx then ok", failures: []func(http.ResponseWriter){unavailable, unavailable}, wantCalls: 3},
+		{name: "maintenance then ok", failures: []func(http.ResponseWriter){maintenance}, wantCalls: 2},
+		{name: "connection reset then ok", failures: []func(http.ResponseWriter){reset}, wantCalls: 2},
+		{name: "gives up", failures: []func(http.ResponseWriter){unavailable, unavailable, unavailable}, wantCalls: 3, wantErr: true},
+		{name: "mutation not repeated", failures: []func(http.ResponseWriter){unavailable}, mutating: true, wantCalls: 1, wantErr: true},
+		{name: "mutation not repeated after reset", failures: []func(http.ResponseWriter){reset}, mutating: true, wantCalls: 1, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls, failures = 0, tc.failures
+			_, err := helpers.Vtop.Do(context.Background(), helpers.VtopRequest{URL: srv.URL, Mutating: tc.mutating})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Expected error %v, got %v", tc.wantErr, err)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("Expected %d requests, got %d", tc.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffIsBounded(t *testing.T) {
+	policy := helpers.RetryPolicy{Attempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for n := 1; n <= 8; n++ {
+		full := 100 * time.Millisecond << (n - 1)
+		if full > time.Second {
+			full = time.Second
+		}
+		for i := 0; i < 20; i++ {
+			if d := policy.Backoff(n); d < full/2 || d > full {
+				t.Fatalf("Backoff(%d) = %s, expected between %s and %s", n, d, full/2, full)
+			}
+		}
+	}
+}
+
+func TestExpiredSessionLogsInOnce(t *testing.T) {
+	var loggedIn atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !loggedIn.Load() {
+			w.Write([]byte("Session Timed Out"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var logins atomic.Int32
+	saved := helpers.VtopLoginGlobal
+	helpers.VtopLoginGlobal = func(ctx context.Context) (types.Cookies, string) {
+		logins.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		loggedIn.Store(true)
+		return types.Cookies{}, "21BCE0001"
+	}
+	t.Cleanup(func() { helpers.VtopLoginGlobal = saved })
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := helpers.Vtop.Do(context.Background(), helpers.VtopRequest{URL: srv.URL})
+			if err != nil || string(resp.Body) != "ok" {
+				t.Errorf("Expected the request to succeed after logging in, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := logins.Load(); n != 1 {
+		t.Errorf("Expected requests that found the session expired together to share one login, got %d", n)
+	}
+}
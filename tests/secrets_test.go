@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/secrets"
+	"github.com/spf13/viper"
+)
+
+func TestVaultStoreRoundTrip(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.json")
+	t.Setenv("CLI_TOP_VAULT_PASSPHRASE", "correct horse")
+
+	store := secrets.NewVaultStore(secrets.Options{VaultPath: vaultPath})
+	if err := store.Set("21BCE0001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := secrets.NewVaultStore(secrets.Options{VaultPath: vaultPath})
+	password, err := reopened.Get("21BCE0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "hunter2" {
+		t.Errorf("Expected stored password to round-trip, got %q", password)
+	}
+
+	if _, err := reopened.Get("21BCE0002"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for unknown account, got %v", err)
+	}
+
+	t.Setenv("CLI_TOP_VAULT_PASSPHRASE", "wrong")
+	wrong := secrets.NewVaultStore(secrets.Options{VaultPath: vaultPath})
+	if _, err := wrong.Get("21BCE0001"); !errors.Is(err, secrets.ErrBadPassphrase) {
+		t.Errorf("Expected ErrBadPassphrase, got %v", err)
+	}
+}
+
+// useEnvStore gives the env store a config file of its own.
+func useEnvStore(t *testing.T) *secrets.EnvStore {
+	t.Helper()
+	t.Cleanup(func() {
+		viper.Set("PASSWORD", "")
+		viper.Set("KEY", "")
+	})
+	return secrets.NewEnvStore(secrets.Options{ConfigPath: filepath.Join(t.TempDir(), "config.env")})
+}
+
+// failingStore refuses every write, like a keyring that is locked.
+type failingStore struct{ secrets.Store }
+
+func (failingStore) Name() string                       { return "failing" }
+func (failingStore) Set(account, password string) error { return errors.New("store is locked") }
+
+func TestMigrateMovesPassword(t *testing.T) {
+	env := useEnvStore(t)
+	if err := env.Set("21BCE0001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := secrets.Migrate(env, failingStore{}, "21BCE0001"); err == nil {
+		t.Fatal("Expected a failed write to fail the migration")
+	}
+	if password, err := env.Get("21BCE0001"); err != nil || password != "hunter2" {
+		t.Fatalf("Expected the source to keep the password after a failed write, got %q, %v", password, err)
+	}
+
+	t.Setenv("CLI_TOP_VAULT_PASSPHRASE", "correct horse")
+	vault := secrets.NewVaultStore(secrets.Options{VaultPath: filepath.Join(t.TempDir(), "vault.json")})
+	if err := secrets.Migrate(env, vault, "21BCE0001"); err != nil {
+		t.Fatal(err)
+	}
+	if password, err := vault.Get("21BCE0001"); err != nil || password != "hunter2" {
+		t.Errorf("Expected the vault to hold the password, got %q, %v", password, err)
+	}
+	if _, err := env.Get("21BCE0001"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Expected the env store to be emptied, got %v", err)
+	}
+	if err := secrets.Migrate(vault, vault, "21BCE0001"); err == nil {
+		t.Error("Expected migrating a store onto itself to fail")
+	}
+}
+
+func TestEnvStoreUpgradesLegacyPassword(t *testing.T) {
+	env := useEnvStore(t)
+
+	// The original format: AES-CFB keyed by the first 32 characters of the
+	// KEY value, with the IV in front of the ciphertext.
+	key := "0123456789abcdef0123456789abcdef"
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := make([]byte, aes.BlockSize+len("hunter2"))
+	if _, err := rand.Read(sealed[:aes.BlockSize]); err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCFBEncrypter(block, sealed[:aes.BlockSize]).XORKeyStream(sealed[aes.BlockSize:], []byte("hunter2"))
+	viper.Set("PASSWORD", `"`+base64.URLEncoding.EncodeToString(sealed)+`"`)
+	viper.Set("KEY", `"`+key+`"`)
+
+	if password, err := env.Get("21BCE0001"); err != nil || password != "hunter2" {
+		t.Fatalf("Expected the legacy password to decrypt, got %q, %v", password, err)
+	}
+	if err := env.Upgrade("21BCE0001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	upgraded := strings.Trim(viper.GetString("PASSWORD"), `"`)
+	if !strings.HasPrefix(upgraded, "v2:") {
+		t.Fatalf("Expected the password to be re-encrypted with AES-GCM, got %q", upgraded)
+	}
+	if password, err := env.Get("21BCE0001"); err != nil || password != "hunter2" {
+		t.Errorf("Expected the upgraded password to decrypt, got %q, %v", password, err)
+	}
+
+	if err := env.Upgrade("21BCE0001", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if again := strings.Trim(viper.GetString("PASSWORD"), `"`); again != upgraded {
+		t.Error("Expected a current password not to be re-encrypted")
+	}
+}
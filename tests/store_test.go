@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/features"
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/store"
+	"github.com/ACM-VIT/CLI-TOP/types"
+)
+
+// useStore points the fetchers at a fresh database for the test.
+func useStore(t *testing.T) *store.Store {
+	t.Helper()
+	saved := store.Default
+	store.Default = &store.Store{Path: filepath.Join(t.TempDir(), "vtop.db")}
+	t.Cleanup(func() { store.Default = saved })
+	return store.Default
+}
+
+func TestOfflineServesSavedData(t *testing.T) {
+	db := useStore(t)
+	ctx := context.Background()
+
+	db.Offline = true
+	if _, err := features.FetchReceipts(ctx, replaySession, types.FetchOptions{}); !errors.Is(err, store.ErrNotSaved) {
+		t.Fatalf("Expected ErrNotSaved before anything was fetched, got %v", err)
+	}
+
+	db.Offline = false
+	replayPage(t, "/vtop/finance/getStudentReceipts", "verifyMenu=true", `<table class="table-bordered"><tbody>
+			<tr><th>Invoice</th><th>Receipt</th><th>Date</th><th>Amount</th><th></th></tr>
+			<tr><td>INV1</td><td>R1</td><td>01-Jan-2024</td><td>500.00</td><td>VIEW</td></tr>
+		</tbody></table>`)
+	if _, err := features.FetchReceipts(ctx, replaySession, types.FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !db.OldestServed().IsZero() {
+		t.Error("Expected a live fetch not to count as served from the store")
+	}
+	helpers.StopFixtures()
+
+	db.Offline = true
+	receipts, err := features.FetchReceipts(ctx, replaySession, types.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 1 || receipts[0].Amount != 500 {
+		t.Errorf("Unexpected saved receipts %+v", receipts)
+	}
+	if asOf := db.OldestServed(); asOf.IsZero() || time.Since(asOf) > time.Minute {
+		t.Errorf("Expected the fetch time of the saved copy, got %v", asOf)
+	}
+}
+
+func TestMaxAgeReusesFreshData(t *testing.T) {
+	db := useStore(t)
+	if err := db.Put(replaySession.RegNo, "receipts", []types.Receipt{{InvoiceNumber: "SAVED"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// With no recording to replay, any request to VTOP fails.
+	replayPage(t, "/vtop/other", "", "")
+	db.MaxAge = time.Hour
+	receipts, err := features.FetchReceipts(context.Background(), replaySession, types.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 1 || receipts[0].InvoiceNumber != "SAVED" {
+		t.Errorf("Expected the saved receipts, got %+v", receipts)
+	}
+
+	db.MaxAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, err := features.FetchReceipts(context.Background(), replaySession, types.FetchOptions{}); !errors.Is(err, helpers.ErrNotRecorded) {
+		t.Errorf("Expected a stale copy to be fetched again, got %v", err)
+	}
+}
+
+func TestChangesBetweenVersions(t *testing.T) {
+	db := useStore(t)
+	marks := []types.CourseMarksSummary{{CourseCode: "CSE3001", CourseType: "ETH", Components: []types.CourseMarksComponent{
+		{Title: "CAT1", MaxMarks: 50, ScoredMarks: 38},
+	}}}
+	for range 2 {
+		if err := db.PutVersion(replaySession.RegNo, "marks/VL1", marks); err != nil {
+			t.Fatal(err)
+		}
+	}
+	marks[0].Components = append(marks[0].Components, types.CourseMarksComponent{Title: "CAT2", MaxMarks: 50, ScoredMarks: 42})
+	if err := db.PutVersion(replaySession.RegNo, "marks/VL1", marks); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := features.FetchHistory(replaySession, "marks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].Version != 2 || history[1].Changes != 1 {
+		t.Fatalf("Expected a version only when the marks changed, got %+v", history)
+	}
+
+	changes, err := features.FetchChanges(replaySession, "marks", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Description != "CAT2 marks posted for CSE3001: 42/50" {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+	if changes, _ := features.FetchChanges(replaySession, "marks", 7*24*time.Hour); len(changes) != 1 {
+		t.Errorf("Expected the same change within a week, got %+v", changes)
+	}
+}
+
+func TestChangesKeepRepeatsAcrossSemesters(t *testing.T) {
+	db := useStore(t)
+	// A retaken course is graded in two semesters, the second of which is
+	// also saved as the latest copy.
+	graded := types.SemesterGrades{Courses: []types.CourseGrade{{CourseCode: "CSE3001", CourseType: "ETH", Grade: "A"}}}
+	for _, key := range []string{"grades/VL1", "grades/VL2", "grades"} {
+		for _, grades := range []types.SemesterGrades{{}, graded} {
+			if err := db.PutVersion(replaySession.RegNo, key, grades); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	changes, err := features.FetchChanges(replaySession, "grades", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Semester == changes[1].Semester {
+		t.Errorf("Expected the grade posted once in each semester, got %+v", changes)
+	}
+}
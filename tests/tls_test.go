@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+func TestVtopClientVerifiesCertificates(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	t.Setenv("SSL_CERT_FILE", "")
+	t.Cleanup(func() { helpers.ConfigureTLS(helpers.TLSOptions{}) })
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	cert := srv.Certificate()
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pin := "sha256/" + helpers.CertificatePin(cert)
+	otherPin := "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	cases := []struct {
+		name     string
+		opts     helpers.TLSOptions
+		wantErr  bool
+		certFail bool
+	}{
+		{name: "untrusted", opts: helpers.TLSOptions{}, wantErr: true, certFail: true},
+		{name: "ca file", opts: helpers.TLSOptions{CAFiles: []string{caFile}}},
+		{name: "pinned", opts: helpers.TLSOptions{CAFiles: []string{caFile}, Pins: []string{pin}}},
+		{name: "wrong pin", opts: helpers.TLSOptions{CAFiles: []string{caFile}, Pins: []string{otherPin}}, wantErr: true, certFail: true},
+		{name: "insecure", opts: helpers.TLSOptions{Insecure: true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := helpers.ConfigureTLS(tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			_, err := helpers.Vtop.Post(context.Background(), srv.URL, "")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Expected error %v, got %v", tc.wantErr, err)
+			}
+			if tc.certFail && !helpers.IsCertificateError(err) {
+				t.Errorf("Expected a certificate error, got %v", err)
+			}
+		})
+	}
+
+	if err := helpers.ConfigureTLS(helpers.TLSOptions{Pins: []string{"not-a-pin"}}); err == nil {
+		t.Error("Expected a malformed pin to be rejected")
+	}
+}
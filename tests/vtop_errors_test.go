@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+)
+
+func TestClassifyResponse(t *testing.T) {
+	html := http.Header{"Content-Type": {"text/html;charset=UTF-8"}}
+	longPage := "<html><title>VTOP</title><body>" + strings.Repeat("<tr><td>row</td></tr>", 300) +
+		"<p>Lab 3 will be under maintenance on Friday.</p></body></html>"
+
+	cases := []struct {
+		name string
+		resp helpers.VtopResponse
+		want error
+	}{
+		{"maintenance banner", helpers.VtopResponse{StatusCode: 200, Header: html, Body: []byte("<h2>VTOP is under maintenance. It will be available after 6:00 PM.</h2>")}, helpers.ErrMaintenance},
+		{"gateway error", helpers.VtopResponse{StatusCode: 502, Header: http.Header{"Retry-After": {"120"}}, Body: []byte("<html>Bad Gateway</html>")}, helpers.ErrUnavailable},
+		{"login redirect", helpers.VtopResponse{StatusCode: 200, Header: html, URL: "https://vtop.vit.ac.in/vtop/login", Body: []byte("<html></html>")}, helpers.ErrSessionExpired},
+		{"login form", helpers.VtopResponse{StatusCode: 200, Header: html, Body: []byte(`<div id="captchaBlock"><img></div>`)}, helpers.ErrSessionExpired},
+		{"empty body", helpers.VtopResponse{StatusCode: 200, Body: []byte(" \n")}, helpers.ErrEmptyResponse},
+		{"class message about maintenance", helpers.VtopResponse{StatusCode: 200, Header: html, Body: []byte(longPage)}, nil},
+		{"pdf mentioning maintenance", helpers.VtopResponse{StatusCode: 200, Header: http.Header{"Content-Type": {"application/pdf"}}, Body: []byte("%PDF-1.4 under maintenance")}, nil},
+		{"normal page", helpers.VtopResponse{StatusCode: 200, Header: html, Body: []byte("<table><tr><td>9.1</td></tr></table>")}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := helpers.ClassifyResponse(&tc.resp)
+			if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+
+	var verr *helpers.VtopError
+	err := helpers.ClassifyResponse(&cases[0].resp)
+	if !errors.As(err, &verr) || verr.RetryAt.In(time.FixedZone("IST", 5*3600+1800)).Format("15:04") != "18:00" {
+		t.Errorf("Expected the announced end time to be used, got %v", err)
+	}
+	err = helpers.ClassifyResponse(&cases[1].resp)
+	if !errors.As(err, &verr) || time.Until(verr.RetryAt).Round(time.Minute) != 2*time.Minute {
+		t.Errorf("Expected Retry-After to set the retry time, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Try again in about 2 minutes") {
+		t.Errorf("Expected the message to suggest when to retry, got %q", err.Error())
+	}
+}
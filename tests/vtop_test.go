@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/ACM-VIT/CLI-TOP/helpers"
+	"github.com/ACM-VIT/CLI-TOP/pkg/vtop"
+)
+
+func TestClientCourseMaterials(t *testing.T) {
+	replayPage(t, "/vtop/academics/CoursePageConsolidated/getCourseDetail", "CourseId=AM_CSE3001_00001&CoursType=ETH", `<table id="materialTable"><tbody>
+			<tr><td>1</td><td></td><td><div class="mt-1"><span style="color:#2E86C1">Introduction</span><span style="color:#28B463">Module 1</span></div></td>
+				<td><div class="mt-1"><span>10001 - Priya K - SCOPE</span><span>01-Aug-2025</span></div></td>
+				<td><button name="downloadmat" data-fileid="F1"></button></td></tr>
+			<tr><td>2</td><td></td><td><div class="mt-1"><span style="color:#2E86C1">Requirements</span></div></td>
+				<td><div class="mt-1"><span>10002 - Arun M - SCOPE</span><span>03-Aug-2025</span></div></td>
+				<td><button name="downloadmat" data-fileid="F2"></button></td></tr>
+			<tr><td>3</td><td></td><td><div class="mt-1"><span style="color:#2E86C1">Design</span></div></td>
+				<td><div class="mt-1"><span>10001 - Priya K - SCOPE</span><span>08-Aug-2025</span></div></td>
+				<td><button name="downloadmat" data-fileid="F3"></button></td></tr>
+		</tbody></table>`)
+
+	client := vtop.New(replaySession)
+	course := vtop.Course{ID: "AM_CSE3001_00001", Name: "Fall Semester 2025-26 - CSE3001 - Software Engineering - ETH - Embedded Theory - A1+TA1"}
+	uploads, err := client.CourseMaterials(context.Background(), course)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 2 {
+		t.Fatalf("Expected materials from 2 faculty, got %+v", uploads)
+	}
+	if uploads[0].Faculty.Name != "Arun M" || len(uploads[0].Materials) != 1 {
+		t.Errorf("Unexpected first faculty %+v", uploads[0])
+	}
+	priya := uploads[1]
+	if priya.Faculty.Name != "Priya K" || priya.Faculty.ErpID != "10001" || len(priya.Materials) != 2 {
+		t.Fatalf("Unexpected second faculty %+v", priya)
+	}
+	first := priya.Materials[0]
+	if first.Topic != "Introduction" || first.MNo != "Module 1" || first.ReferenceMaterials[0].MaterialID != "F1" {
+		t.Errorf("Unexpected material %+v", first)
+	}
+}
+
+func TestClientRequiresLogin(t *testing.T) {
+	client := vtop.New(vtop.Session{})
+	if _, err := client.Receipts(context.Background()); !errors.Is(err, vtop.ErrNotLoggedIn) {
+		t.Errorf("Expected ErrNotLoggedIn, got %v", err)
+	}
+}
+
+func TestClientsKeepSeparateSessions(t *testing.T) {
+	before := helpers.VtopCookies()
+	vtop.New(vtop.Session{RegNo: "21BCE0001", Cookies: vtop.Cookies{JSESSIONID: "first"}})
+	if after := helpers.VtopCookies(); after != before {
+		t.Errorf("Expected a client to leave the CLI's session alone, got %+v", after)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("JSESSIONID"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	clients := map[string]*helpers.VtopClient{}
+	for _, session := range []string{"first", "second"} {
+		clients[session] = helpers.NewVtopClient()
+		clients[session].Jar().SetCookies(u, []*http.Cookie{{Name: "JSESSIONID", Value: session}})
+	}
+	for session, client := range clients {
+		ctx := helpers.WithVtopClient(context.Background(), client)
+		resp, err := helpers.VtopFor(ctx).Do(ctx, helpers.VtopRequest{URL: srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(resp.Body) != session {
+			t.Errorf("Expected the %s client to send its own session, got %q", session, resp.Body)
+		}
+	}
+}
@@ -47,6 +47,8 @@ type TimetableEntry struct {
 	Slot       string `json:"slot"`
 	Venue      string `json:"venue"`
 	Faculty    string `json:"faculty"`
+	// DayOrder is set on working Saturday classes to the weekday they follow.
+	DayOrder string `json:"day_order,omitempty"`
 }
 
 // AssignmentSummary stores details about pending or upcoming assignments.
@@ -0,0 +1,173 @@
+package types
+
+import "time"
+
+// Session is a logged-in VTOP session: what every feature fetcher needs to
+// make requests on the student's behalf.
+type Session struct {
+	RegNo   string
+	Cookies Cookies
+}
+
+// FetchOptions narrows what a fetcher retrieves. The zero value asks for the
+// latest semester that has data.
+type FetchOptions struct {
+	Semester Semester
+}
+
+// Receipt is one fee payment from the finance receipts page.
+type Receipt struct {
+	InvoiceNumber string  `json:"invoice_number"`
+	ReceiptNumber string  `json:"receipt_number"`
+	Date          string  `json:"date"`
+	Amount        float64 `json:"amount"`
+}
+
+// LibraryDue is an outstanding library payment.
+type LibraryDue struct {
+	Type   string  `json:"type"`
+	Amount float64 `json:"amount"`
+}
+
+// HostelDetail is one labelled line of the accommodation section of the
+// student profile, such as the block or room number.
+type HostelDetail struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// HostelInfo is the student's accommodation as VTOP lists it.
+type HostelInfo struct {
+	Details []HostelDetail `json:"details"`
+}
+
+// NightSlip is a late-hour permission request and its status.
+type NightSlip struct {
+	Venue      string `json:"venue"`
+	EventType  string `json:"event_type"`
+	Details    string `json:"details"`
+	AppliedTo  string `json:"applied_to"`
+	FromDate   string `json:"from_date"`
+	ToDate     string `json:"to_date"`
+	FromToTime string `json:"from_to_time"`
+	Status     string `json:"status"`
+}
+
+// ClassMessage is a message posted by faculty to a class.
+type ClassMessage struct {
+	Course  string `json:"course"`
+	Message string `json:"message"`
+}
+
+// CourseGrade is the final grade for one course.
+type CourseGrade struct {
+	CourseCode  string  `json:"course_code"`
+	CourseTitle string  `json:"course_title"`
+	CourseType  string  `json:"course_type"`
+	Credits     float64 `json:"credits"`
+	Total       float64 `json:"total"`
+	Grading     string  `json:"grading"`
+	Grade       string  `json:"grade"`
+}
+
+// SemesterGrades holds a semester's grades and the GPA VTOP shows with them.
+type SemesterGrades struct {
+	Semester string        `json:"semester"`
+	Courses  []CourseGrade `json:"courses"`
+	GPA      float64       `json:"gpa"`
+}
+
+// WorkingSaturday is a Saturday that follows another weekday's timetable.
+type WorkingSaturday struct {
+	Date     time.Time `json:"date"`
+	DayOrder string    `json:"day_order"`
+}
+
+// Timetable is a semester's weekly classes, including those repeated on
+// working Saturdays.
+type Timetable struct {
+	Semester         string            `json:"semester"`
+	Classes          []TimetableEntry  `json:"classes"`
+	WorkingSaturdays []WorkingSaturday `json:"working_saturdays"`
+}
+
+// CoursePageCourse is a course listed on the consolidated course page.
+type CoursePageCourse struct {
+	Semester string `json:"semester"`
+	Course   Course `json:"course"`
+}
+
+// FacultyMaterials is the material one faculty has uploaded for a course.
+type FacultyMaterials struct {
+	Faculty   Faculty          `json:"faculty"`
+	Materials []CourseMaterial `json:"materials"`
+}
+
+// Facilities are the physical education facilities open for registration
+// and the student's registrations.
+type Facilities struct {
+	Facilities    []Facility     `json:"facilities"`
+	Registrations []Registration `json:"registrations"`
+}
+
+// CourseAllocationDetail is one class of a course offered for registration.
+type CourseAllocationDetail struct {
+	Code    string `json:"code"`
+	Title   string `json:"title"`
+	Type    string `json:"type"`
+	Venue   string `json:"venue"`
+	Slot    string `json:"slot"`
+	Faculty string `json:"faculty"`
+}
+
+// SyllabusCourse is a course in the student's curriculum with a syllabus to
+// download.
+type SyllabusCourse struct {
+	Code     string `json:"code"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+}
+
+// ClassGroup is a group of classes that follows its own academic calendar.
+type ClassGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// CalendarDay is what the academic calendar marks a day as.
+type CalendarDay int
+
+const (
+	// CalendarBlank pads the month's first week before the 1st.
+	CalendarBlank CalendarDay = iota
+	CalendarHoliday
+	CalendarExam
+	CalendarInstructional
+)
+
+// CalendarMonth is one month of a class group's academic calendar.
+type CalendarMonth struct {
+	Month string `json:"month"`
+	Year  string `json:"year"`
+	// Days has a cell for each day from the Sunday of the first week.
+	Days []CalendarDay `json:"days"`
+}
+
+// DatasetVersion is one saved version of a dataset, as cli-top history lists
+// it.
+type DatasetVersion struct {
+	Version   uint64    `json:"version"`
+	Semester  string    `json:"semester"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Changes counts the rows added or changed since the previous version
+	// of the same semester.
+	Changes int `json:"changes"`
+}
+
+// DatasetChange is a row added or changed between two versions of a
+// dataset, at the fetch that first saw it.
+type DatasetChange struct {
+	At          time.Time `json:"at"`
+	Semester    string    `json:"semester"`
+	Description string    `json:"description"`
+}
@@ -19,8 +19,8 @@ type Category struct {
 	Name string
 }
 type Course struct {
-	ID   string
-	Name string
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type Request struct {
@@ -35,17 +35,17 @@ type KeyStruct struct {
 }
 
 type StudentDetails struct {
-	RegisterNumber string
-	ProgramBranch  string
-	VITEmail       string
-	SchoolName     string
+	RegisterNumber string `json:"register_number"`
+	ProgramBranch  string `json:"program_branch"`
+	VITEmail       string `json:"vit_email"`
+	SchoolName     string `json:"school_name"`
 }
 
 type Faculty struct {
-	Name         string
-	ErpID        string
-	SemesterName string
-	CourseName   string
+	Name         string `json:"name"`
+	ErpID        string `json:"erp_id"`
+	SemesterName string `json:"semester_name"`
+	CourseName   string `json:"course_name"`
 }
 
 type Slot struct {
@@ -54,79 +54,59 @@ type Slot struct {
 }
 
 type CourseMaterial struct {
-	Index              int
-	Date               string
-	DayOrderSlot       string
-	Topic              string
-	ReferenceMaterials []ReferenceMaterial
-	WebLink            string
-	MNo                string
-	TNo                string
+	Index              int                 `json:"index"`
+	Date               string              `json:"date"`
+	DayOrderSlot       string              `json:"day_order_slot,omitempty"`
+	Topic              string              `json:"topic"`
+	ReferenceMaterials []ReferenceMaterial `json:"reference_materials"`
+	WebLink            string              `json:"web_link,omitempty"`
+	MNo                string              `json:"module,omitempty"`
+	TNo                string              `json:"topic_no,omitempty"`
 }
 
 type ReferenceMaterial struct {
-	Name         string
-	MaterialID   string
-	MaterialDate string
-}
-
-type LeaveRequest struct {
-	VisitPlace string
-	Reason     string
-	LeaveType  string
-	From       string
-	To         string
-	Status     string
+	Name         string `json:"name"`
+	MaterialID   string `json:"material_id"`
+	MaterialDate string `json:"material_date,omitempty"`
 }
 
 type Semester struct {
-	SemName string
-	SemID   string
+	SemName string `json:"name"`
+	SemID   string `json:"id"`
 }
 
 type ExamEvent struct {
-	CourseCode  string
-	CourseTitle string
-	Slot        string
-	ExamDate    time.Time
-	ExamTime    string
-	Venue       string
-	Seat        string
-	SeatNo      string
-	DaysLeft    int
-	Category    string
+	CourseCode  string    `json:"course_code"`
+	CourseTitle string    `json:"course_title"`
+	Slot        string    `json:"slot"`
+	ExamDate    time.Time `json:"exam_date"`
+	ExamTime    string    `json:"exam_time"`
+	Venue       string    `json:"venue"`
+	Seat        string    `json:"seat"`
+	SeatNo      string    `json:"seat_no"`
+	DaysLeft    int       `json:"days_left"`
+	Category    string    `json:"category"`
 }
 
 type DAsubject struct {
-	Name string
-	Code string
-	ID   string
+	Name string `json:"name"`
+	Code string `json:"code"`
+	ID   string `json:"id"`
 }
 
 type DAEvent struct {
-	Title        string
-	Description  string
-	DueDate      time.Time
-	DaysLeft     int
-	QP           string
-	Last_upload  string
-	DownloadLink string
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	DueDate      time.Time `json:"due_date"`
+	DaysLeft     int       `json:"days_left"`
+	QP           string    `json:"qp"`
+	Last_upload  string    `json:"last_upload"`
+	DownloadLink string    `json:"download_link"`
 }
 
 type SubjectDAs struct {
-	Subject DAsubject
-	DAs     []DAEvent
-}
-
-type NightSlipRequest struct {
-	Venue      string
-	EventType  string
-	Details    string
-	AppliedTo  string
-	FromDate   string
-	ToDate     string
-	FromToTime string
-	Status     string
+	Subject DAsubject `json:"subject"`
+	DAs     []DAEvent `json:"das"`
 }
 
 type LatestDA struct {